@@ -0,0 +1,123 @@
+// Package buildqueue 定义 pipeline_service 与 executor_service 共用的构建队列消息格式与队列拓扑
+package buildqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Version 当前消息格式版本
+// 同一版本内只允许新增可选字段（消费端忽略未知字段）；删除或改变字段语义时递增版本
+const Version = 1
+
+// ContentType 构建消息的内容类型
+const ContentType = "application/json"
+
+// legacyContentType 旧版以 | 分隔的字符串消息（build_id|pipeline_id|project_id|commit|branch|变量 JSON）
+const legacyContentType = "text/plain"
+
+// 消息头
+const (
+	HeaderAttempt        = "x-xc-attempt"          // 已失败的投递次数
+	HeaderError          = "x-xc-error"            // 最近一次失败原因
+	HeaderDeadLetteredAt = "x-xc-dead-lettered-at" // 进入死信队列的时间（RFC3339）
+)
+
+// ErrUnsupportedVersion 消息版本高于当前实现（由更新的发布方产生）
+var ErrUnsupportedVersion = errors.New("unsupported build message version")
+
+// Message 构建消息（JSON，字段名为蛇形）
+// 执行器以数据库中的 Build/BuildSnapshot 为准，消息中的提交、分支与变量用于排查与死信展示
+type Message struct {
+	Version    int               `json:"version"`
+	BuildID    uint64            `json:"build_id"`
+	PipelineID uint64            `json:"pipeline_id"`
+	ProjectID  uint64            `json:"project_id"`
+	CommitSHA  string            `json:"commit_sha,omitempty"`
+	Branch     string            `json:"branch,omitempty"`
+	Variables  map[string]string `json:"variables,omitempty"`
+	EnqueuedAt time.Time         `json:"enqueued_at"`
+}
+
+// Encode 编码消息；未设置版本与入队时间时补全
+func Encode(m Message) ([]byte, error) {
+	if m.Version == 0 {
+		m.Version = Version
+	}
+	if m.EnqueuedAt.IsZero() {
+		m.EnqueuedAt = time.Now().UTC()
+	}
+	return json.Marshal(m)
+}
+
+// Publishing 构造持久化的 AMQP 消息
+func Publishing(m Message) (amqp.Publishing, error) {
+	body, err := Encode(m)
+	if err != nil {
+		return amqp.Publishing{}, fmt.Errorf("encode build message: %w", err)
+	}
+	return amqp.Publishing{
+		ContentType:  ContentType,
+		Body:         body,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		Type:         "build." + strconv.Itoa(Version),
+	}, nil
+}
+
+// Decode 解码构建消息；兼容旧版 | 分隔格式（版本视为 0）
+// 版本缺失、版本过高或缺少 build_id 时返回错误
+func Decode(contentType string, body []byte) (*Message, error) {
+	trimmed := bytes.TrimSpace(body)
+	if contentType == legacyContentType || (contentType == "" && !bytes.HasPrefix(trimmed, []byte("{"))) {
+		return decodeLegacy(string(trimmed))
+	}
+	var m Message
+	if err := json.Unmarshal(trimmed, &m); err != nil {
+		return nil, fmt.Errorf("decode build message: %w", err)
+	}
+	switch {
+	case m.Version <= 0:
+		return nil, fmt.Errorf("decode build message: missing version")
+	case m.Version > Version:
+		return nil, fmt.Errorf("%w: %d (max %d)", ErrUnsupportedVersion, m.Version, Version)
+	case m.BuildID == 0:
+		return nil, fmt.Errorf("decode build message: missing build_id")
+	}
+	return &m, nil
+}
+
+func decodeLegacy(s string) (*Message, error) {
+	// 变量 JSON 可能包含分隔符，按前 5 段切分
+	parts := strings.SplitN(s, "|", 6)
+	buildID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || buildID == 0 {
+		return nil, fmt.Errorf("decode legacy build message: invalid build id %q", parts[0])
+	}
+	m := &Message{BuildID: buildID}
+	if len(parts) > 1 {
+		m.PipelineID, _ = strconv.ParseUint(parts[1], 10, 64)
+	}
+	if len(parts) > 2 {
+		m.ProjectID, _ = strconv.ParseUint(parts[2], 10, 64)
+	}
+	if len(parts) > 3 {
+		m.CommitSHA = parts[3]
+	}
+	if len(parts) > 4 {
+		m.Branch = parts[4]
+	}
+	if len(parts) > 5 && parts[5] != "" && parts[5] != "{}" {
+		if err := json.Unmarshal([]byte(parts[5]), &m.Variables); err != nil {
+			return nil, fmt.Errorf("decode legacy build message: variables: %w", err)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,64 @@
+package buildqueue
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	body, err := Encode(Message{BuildID: 42, PipelineID: 7, ProjectID: 3, CommitSHA: "abc", Branch: "main", Variables: map[string]string{"A": "x|y"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Decode(ContentType, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != Version || m.BuildID != 42 || m.PipelineID != 7 || m.ProjectID != 3 || m.Branch != "main" || m.Variables["A"] != "x|y" || m.EnqueuedAt.IsZero() {
+		t.Fatalf("unexpected message: %+v", m)
+	}
+}
+
+func TestDecodeLegacy(t *testing.T) {
+	cases := []struct {
+		body string
+		want Message
+	}{
+		{"42|7|3|abc|main", Message{BuildID: 42, PipelineID: 7, ProjectID: 3, CommitSHA: "abc", Branch: "main"}},
+		{`42|7|3|abc|main|{"A":"x|y"}`, Message{BuildID: 42, PipelineID: 7, ProjectID: 3, CommitSHA: "abc", Branch: "main", Variables: map[string]string{"A": "x|y"}}},
+		{"42", Message{BuildID: 42}},
+	}
+	for _, c := range cases {
+		for _, ct := range []string{"text/plain", ""} {
+			m, err := Decode(ct, []byte(c.body))
+			if err != nil {
+				t.Fatalf("Decode(%q, %q): %v", ct, c.body, err)
+			}
+			if m.BuildID != c.want.BuildID || m.PipelineID != c.want.PipelineID || m.ProjectID != c.want.ProjectID ||
+				m.CommitSHA != c.want.CommitSHA || m.Branch != c.want.Branch || m.Variables["A"] != c.want.Variables["A"] {
+				t.Errorf("Decode(%q, %q) = %+v, want %+v", ct, c.body, m, c.want)
+			}
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, c := range []struct {
+		ct, body string
+	}{
+		{"application/json", `{"build_id":1}`},
+		{"application/json", `{"version":1}`},
+		{"application/json", `not json`},
+		{"text/plain", "abc|1"},
+		{"text/plain", "0|1"},
+		{"", ""},
+	} {
+		if _, err := Decode(c.ct, []byte(c.body)); err == nil {
+			t.Errorf("Decode(%q, %q): expected error", c.ct, c.body)
+		}
+	}
+	_, err := Decode(ContentType, []byte(`{"version":99,"build_id":1}`))
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
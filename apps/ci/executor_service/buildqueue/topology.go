@@ -0,0 +1,68 @@
+package buildqueue
+
+import (
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// 队列拓扑（均为默认交换机下的持久化队列）：
+// - <queue>：主队列，pipeline_service 发布、executor_service 消费
+// - <queue>.retry.<n>：第 n 次失败后的延迟队列，消息按 expiration 过期后经死信路由回主队列
+// - <queue>.hold：并发组被占用的构建的等待队列，消息按 expiration 过期后经死信路由回主队列
+// - <queue>.dlq：重试耗尽或无法解析的消息，由管理接口查看与重新入队
+
+// RetryQueue 第 attempt 次失败后的延迟队列名
+func RetryQueue(queue string, attempt int) string { return fmt.Sprintf("%s.retry.%d", queue, attempt) }
+
+// HoldQueue 并发组等待队列名
+func HoldQueue(queue string) string { return queue + ".hold" }
+
+// DeadLetterQueue 死信队列名
+func DeadLetterQueue(queue string) string { return queue + ".dlq" }
+
+// DeclareQueue 声明主队列
+// 主队列不带参数，与早期部署声明的队列兼容（参数不一致会导致 PRECONDITION_FAILED）
+func DeclareQueue(ch *amqp.Channel, queue string) error {
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare queue %s: %w", queue, err)
+	}
+	return nil
+}
+
+// DeclareTopology 声明主队列、maxAttempts-1 个延迟队列、并发组等待队列与死信队列
+// 延迟时长由每条消息的 expiration 指定，同一延迟队列内的消息延迟相同，不会因队首阻塞而乱序
+func DeclareTopology(ch *amqp.Channel, queue string, maxAttempts int) error {
+	if err := DeclareQueue(ch, queue); err != nil {
+		return err
+	}
+	args := amqp.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": queue,
+	}
+	for n := 1; n < maxAttempts; n++ {
+		if _, err := ch.QueueDeclare(RetryQueue(queue, n), true, false, false, false, args); err != nil {
+			return fmt.Errorf("declare retry queue %d: %w", n, err)
+		}
+	}
+	if _, err := ch.QueueDeclare(HoldQueue(queue), true, false, false, false, args); err != nil {
+		return fmt.Errorf("declare hold queue: %w", err)
+	}
+	if _, err := ch.QueueDeclare(DeadLetterQueue(queue), true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare dead-letter queue: %w", err)
+	}
+	return nil
+}
+
+// Attempt 读取消息已失败的投递次数
+func Attempt(h amqp.Table) int {
+	switch v := h[HeaderAttempt].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
@@ -2,17 +2,25 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"time"
+	"xcoding/apps/ci/executor_service/internal/artifact"
+	"xcoding/apps/ci/executor_service/internal/cache"
 	"xcoding/apps/ci/executor_service/internal/config"
 	"xcoding/apps/ci/executor_service/internal/consumer"
+	"xcoding/apps/ci/executor_service/internal/executor"
 	"xcoding/apps/ci/executor_service/internal/gateway"
 	"xcoding/apps/ci/executor_service/internal/service"
 	"xcoding/apps/ci/executor_service/internal/ws"
 	"xcoding/apps/ci/executor_service/models"
 	civ1 "xcoding/gen/go/ci/v1"
+	coderepositoryv1 "xcoding/gen/go/code_repository/v1"
+	projectv1 "xcoding/gen/go/project/v1"
+	"xcoding/pkg/auth"
 	cddb "xcoding/pkg/db"
 	"xcoding/pkg/server"
 
@@ -38,16 +46,21 @@ func main() {
 	}
 
 	if err := gormDB.AutoMigrate(
-		&models.Build{}, &models.BuildSnapshot{}, &models.BuildJob{}, &models.BuildJobEdge{}, &models.BuildStep{}, &models.BuildStepLogChunk{},
+		&models.Build{}, &models.BuildSnapshot{}, &models.BuildJob{}, &models.BuildJobEdge{}, &models.BuildStep{}, &models.BuildStepLogChunk{}, &models.BuildServiceLogChunk{}, &models.ConcurrencyLock{}, &models.RunnerClass{}, &models.BuildArtifact{}, &models.CacheEntry{},
 	); err != nil {
 		log.Fatalf("Executor migrate failed: %v", err)
 	}
+	classes, err := executor.LoadRunnerClasses(gormDB.GetDB(), cfg.Runner.ClassesFile)
+	if err != nil {
+		log.Fatalf("Executor runner classes: %v", err)
+	}
 
 	grpcAddr := cfg.GRPCAddr()
 	httpAddr := cfg.HTTPAddr()
 
 	grpcServer := server.StartGRPCServer(grpcAddr, nil, func(s *grpc.Server) {
 		execSvc = service.New(gormDB.GetDB())
+		execSvc.SetRunnerClasses(classes)
 		civ1.RegisterExecutorServiceServer(s, execSvc)
 	})
 
@@ -65,6 +78,60 @@ func main() {
 	rootMux.Handle("/ci_service/api/v1/executor/ws/builds/", ws.NewHandler(gormDB.GetDB()))
 	rootMux.Handle("/", mux)
 
+	// 拨号 CodeRepository 服务（xcoding/checkout 获取仓库克隆凭据，缓存回退查找仓库默认分支）
+	var codeRepoClient coderepositoryv1.CodeRepositoryServiceClient
+	if addr := strings.TrimSpace(cfg.CodeRepository.Address); addr != "" {
+		codeRepoConn, err := grpc.DialContext(context.Background(), fmt.Sprintf("%s:%d", addr, cfg.CodeRepository.Port), grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(auth.ServiceTokenClientInterceptor(cfg.CodeRepository.ServiceToken)))
+		if err != nil {
+			log.Fatalf("executor: dial code_repository: %v", err)
+		}
+		codeRepoClient = coderepositoryv1.NewCodeRepositoryServiceClient(codeRepoConn)
+	} else {
+		log.Printf("executor: code_repository address not set; xcoding/checkout and the cache default branch fallback disabled")
+	}
+	// 拨号 Project 服务（缓存管理接口校验项目 owner/admin）
+	if addr := strings.TrimSpace(cfg.Project.Address); addr != "" {
+		projectConn, err := grpc.DialContext(context.Background(), fmt.Sprintf("%s:%d", addr, cfg.Project.Port), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			log.Fatalf("executor: dial project: %v", err)
+		}
+		execSvc.SetProjectClient(projectv1.NewProjectServiceClient(projectConn))
+	} else {
+		log.Printf("executor: project address not set; only super admins can manage caches")
+	}
+
+	// 制品存储：runner 经内部路径上传/下载制品与恢复/保存缓存（构建令牌鉴权），用户经网关路径下载制品
+	var artifacts *executor.ArtifactEndpoint
+	store, err := artifact.NewStore(cfg.Artifacts)
+	if err != nil {
+		log.Fatalf("executor: artifact store: %v", err)
+	}
+	if store != nil {
+		h, err := artifact.NewHandler(gormDB.GetDB(), store, cfg.Artifacts.TokenSecret, int64(cfg.Artifacts.MaxSizeMB)<<20)
+		if err != nil {
+			log.Fatalf("executor: artifact handler: %v", err)
+		}
+		rootMux.Handle(artifact.RunnerPathPrefix, h)
+		if err := mux.HandlePath(http.MethodGet, "/ci_service/api/v1/executor/builds/{build_id}/artifacts/{name}/download", h.Download); err != nil {
+			log.Fatalf("executor: register artifact download: %v", err)
+		}
+		caches := cache.NewManager(gormDB.GetDB(), store, int64(cfg.Cache.QuotaMB)<<20)
+		var branches cache.DefaultBranchSource
+		if codeRepoClient != nil {
+			branches = cache.CodeRepositoryDefaultBranch{Client: codeRepoClient}
+		}
+		rootMux.Handle(cache.RunnerPathPrefix, cache.NewHandler(gormDB.GetDB(), caches, h.Token, branches))
+		execSvc.SetCaches(caches)
+		artifactURL := strings.TrimSpace(cfg.Artifacts.URL)
+		if artifactURL == "" {
+			artifactURL = "http://" + server.ComputeLocalDialAddr(cfg.HTTP.Address, cfg.HTTP.Port)
+		}
+		artifacts = &executor.ArtifactEndpoint{URL: artifactURL, Token: h.Token}
+	} else {
+		log.Printf("executor: artifact backend not set; xcoding/upload-artifact, xcoding/download-artifact and xcoding/cache disabled")
+	}
+
 	httpServer := server.StartHTTPServerDefault(httpAddr, rootMux)
 
 	execClient := civ1.NewExecutorServiceClient(conn)
@@ -78,9 +145,34 @@ func main() {
 	if qname == "" {
 		qname = "ci_builds"
 	}
-	qc := consumer.NewQueueConsumer(url, qname, execClient, gormDB.GetDB(), os.Getenv("POD_NAMESPACE"))
+	retry := consumer.RetryPolicy{
+		MaxAttempts: cfg.Queue.MaxAttempts,
+		Backoff:     time.Duration(cfg.Queue.RetryBackoffSeconds) * time.Second,
+		MaxBackoff:  time.Duration(cfg.Queue.MaxRetryBackoffSeconds) * time.Second,
+	}
+	// 副本标识默认取主机名（K8s 下即 Pod 名）
+	owner := strings.TrimSpace(cfg.Lease.OwnerID)
+	if owner == "" {
+		owner, _ = os.Hostname()
+	}
+	lease := consumer.LeaseOptions{
+		Owner:               owner,
+		TTL:                 time.Duration(cfg.Lease.TTLSeconds) * time.Second,
+		MaxConcurrentBuilds: cfg.Queue.MaxConcurrentBuilds,
+	}
+	opts := executor.EngineOptions{MaxParallelJobs: cfg.Engine.MaxParallelJobs, Artifacts: artifacts}
+	if codeRepoClient != nil {
+		opts.Checkout = executor.CodeRepositoryCheckout{Client: codeRepoClient}
+	}
+	qc := consumer.NewQueueConsumer(url, qname, execClient, gormDB.GetDB(), os.Getenv("POD_NAMESPACE"), cfg.Runner, classes, opts, retry, lease)
+	if store != nil {
+		qc.SetArtifactStore(store)
+	}
 	if err := qc.Start(context.Background()); err != nil {
 		log.Printf("executor: queue start error: %v", err)
+	} else {
+		execSvc.SetDeadLetterQueue(qc)
+		execSvc.SetBuildCanceler(qc)
 	}
 	server.WaitForShutdown(grpcServer, httpServer, cfg.ShutdownTimeout(), func(ctx context.Context) error { qc.Close(); return nil })
 }
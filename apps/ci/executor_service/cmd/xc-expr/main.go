@@ -0,0 +1,112 @@
+// xc-expr 在 Job 内求值引用 steps 上下文或 hashFiles() 的步骤表达式，由执行器生成的脚本调用（见 internal/executor/runtime_expr.go）
+//
+// 用法：
+//
+//	xc-expr if <条件> [此前步骤是否失败]   条件成立时退出码为 0，不成立为 1，出错为 2
+//	xc-expr render <文本>                  替换文本中的 ${{ }} 表达式后写到标准输出
+//
+// 表达式上下文取自 $XC_EXPR_STATE/context.json，steps 上下文取自 $XC_EXPR_STATE/steps/<id>/ 下的 outcome、conclusion 与 outputs
+// hashFiles() 的模式相对 $XC_EXPR_WORKSPACE（未设置时为当前目录）
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/parser"
+)
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, "usage: xc-expr if <condition> [failed] | xc-expr render <text>")
+		os.Exit(2)
+	}
+	ctx, err := loadContext(os.Getenv("XC_EXPR_STATE"))
+	if err != nil {
+		fail(err)
+	}
+	switch os.Args[1] {
+	case "if":
+		failed := len(os.Args) > 3 && os.Args[3] != "" && os.Args[3] != "0"
+		ok, err := expr.EvaluateCondition(os.Args[2], ctx.With(failed, false))
+		if err != nil {
+			fail(err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	case "render":
+		s, err := expr.Interpolate(os.Args[2], ctx)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Print(s)
+	default:
+		fail(fmt.Errorf("unknown command %q", os.Args[1]))
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "xc-expr: %v\n", err)
+	os.Exit(2)
+}
+
+// loadContext 读取执行器写入的表达式上下文，并按已记录的步骤结果构造 steps 上下文
+// 输出文件格式有误时保留已解析的部分（与执行器经 __step_output__ 回传的处理一致）
+func loadContext(dir string) (*expr.Context, error) {
+	if dir == "" {
+		return nil, errors.New("XC_EXPR_STATE is not set")
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "context.json"))
+	if err != nil {
+		return nil, fmt.Errorf("read context: %w", err)
+	}
+	var values map[string]any
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, fmt.Errorf("decode context: %w", err)
+	}
+	ctx := expr.NewContext()
+	for k, v := range values {
+		ctx.Set(k, v)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "steps"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("read steps: %w", err)
+	}
+	steps := map[string]any{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		d := filepath.Join(dir, "steps", e.Name())
+		outputs := map[string]any{}
+		if raw, err := os.ReadFile(filepath.Join(d, "outputs")); err == nil {
+			parsed, _ := parser.ParseOutputFile(string(raw))
+			for k, v := range parsed {
+				outputs[k] = v
+			}
+		}
+		steps[e.Name()] = map[string]any{
+			"outputs":    outputs,
+			"outcome":    readState(filepath.Join(d, "outcome")),
+			"conclusion": readState(filepath.Join(d, "conclusion")),
+		}
+	}
+	ctx.Set("steps", steps)
+	workspace := os.Getenv("XC_EXPR_WORKSPACE")
+	if workspace == "" {
+		workspace = "."
+	}
+	ctx.HashFiles = func(patterns []string) (string, error) { return expr.HashFiles(workspace, patterns) }
+	return ctx, nil
+}
+
+func readState(path string) string {
+	raw, _ := os.ReadFile(path)
+	return strings.TrimSpace(string(raw))
+}
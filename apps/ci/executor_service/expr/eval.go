@@ -0,0 +1,366 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// funcArity 内置函数参数个数约束：[min, max]，max<0 表示不限
+var funcArity = map[string][2]int{
+	"success":    {0, 0},
+	"failure":    {0, 0},
+	"always":     {0, 0},
+	"cancelled":  {0, 0},
+	"contains":   {2, 2},
+	"startswith": {2, 2},
+	"endswith":   {2, 2},
+	"format":     {1, -1},
+	"join":       {1, 2},
+	"tojson":     {1, 1},
+	"fromjson":   {1, 1},
+	"hashfiles":  {1, -1},
+}
+
+func checkArity(c *callNode) error {
+	ar, ok := funcArity[c.name]
+	if !ok {
+		return fmt.Errorf("unknown function %s()", c.name)
+	}
+	if len(c.args) < ar[0] || (ar[1] >= 0 && len(c.args) > ar[1]) {
+		return fmt.Errorf("function %s() got %d arguments", c.name, len(c.args))
+	}
+	return nil
+}
+
+func eval(n node, ctx *Context) (any, error) {
+	switch v := n.(type) {
+	case *literalNode:
+		return v.value, nil
+	case *contextNode:
+		if ctx == nil {
+			return nil, nil
+		}
+		return lookup(ctx.Values, v.name), nil
+	case *propertyNode:
+		target, err := eval(v.target, ctx)
+		if err != nil {
+			return nil, err
+		}
+		key, err := eval(v.key, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return index(target, key), nil
+	case *filterNode:
+		target, err := eval(v.target, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return filter(target), nil
+	case *notNode:
+		val, err := eval(v.operand, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return !IsTruthy(val), nil
+	case *binaryNode:
+		left, err := eval(v.left, ctx)
+		if err != nil {
+			return nil, err
+		}
+		// && 与 || 短路并返回操作数本身（与 GitHub 行为一致）
+		if v.op == tkAnd {
+			if !IsTruthy(left) {
+				return left, nil
+			}
+			return eval(v.right, ctx)
+		}
+		if v.op == tkOr {
+			if IsTruthy(left) {
+				return left, nil
+			}
+			return eval(v.right, ctx)
+		}
+		right, err := eval(v.right, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return compare(v.op, left, right), nil
+	case *callNode:
+		return call(v, ctx)
+	}
+	return nil, fmt.Errorf("unsupported expression node %T", n)
+}
+
+func call(c *callNode, ctx *Context) (any, error) {
+	if ctx == nil {
+		ctx = &Context{}
+	}
+	switch c.name {
+	case "success":
+		return !ctx.Failed && !ctx.Cancelled && !ctx.Skipped, nil
+	case "failure":
+		return ctx.Failed && !ctx.Cancelled, nil
+	case "always":
+		return true, nil
+	case "cancelled":
+		return ctx.Cancelled, nil
+	}
+	args := make([]any, len(c.args))
+	for i, a := range c.args {
+		v, err := eval(a, ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	switch c.name {
+	case "contains":
+		if arr, ok := args[0].([]any); ok {
+			for _, it := range arr {
+				if looseEqual(it, args[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return strings.Contains(strings.ToLower(ToString(args[0])), strings.ToLower(ToString(args[1]))), nil
+	case "startswith":
+		return strings.HasPrefix(strings.ToLower(ToString(args[0])), strings.ToLower(ToString(args[1]))), nil
+	case "endswith":
+		return strings.HasSuffix(strings.ToLower(ToString(args[0])), strings.ToLower(ToString(args[1]))), nil
+	case "format":
+		return format(ToString(args[0]), args[1:])
+	case "join":
+		sep := ","
+		if len(args) > 1 {
+			sep = ToString(args[1])
+		}
+		if arr, ok := args[0].([]any); ok {
+			parts := make([]string, len(arr))
+			for i, it := range arr {
+				parts[i] = ToString(it)
+			}
+			return strings.Join(parts, sep), nil
+		}
+		return ToString(args[0]), nil
+	case "tojson":
+		bs, err := json.MarshalIndent(args[0], "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("toJSON: %w", err)
+		}
+		return string(bs), nil
+	case "fromjson":
+		var out any
+		if err := json.Unmarshal([]byte(ToString(args[0])), &out); err != nil {
+			return nil, fmt.Errorf("fromJSON: %w", err)
+		}
+		return Normalize(out), nil
+	case "hashfiles":
+		if ctx.HashFiles == nil {
+			return nil, fmt.Errorf("hashFiles() is only available while the job runs")
+		}
+		patterns := make([]string, len(args))
+		for i, a := range args {
+			patterns[i] = ToString(a)
+		}
+		return ctx.HashFiles(patterns)
+	}
+	return nil, fmt.Errorf("unknown function %s()", c.name)
+}
+
+// format 实现 format('{0} {1}', a, b)；'{{' 与 '}}' 转义为字面花括号
+func format(f string, args []any) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(f); i++ {
+		c := f[i]
+		if c == '{' {
+			if i+1 < len(f) && f[i+1] == '{' {
+				b.WriteByte('{')
+				i++
+				continue
+			}
+			end := strings.IndexByte(f[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("format: unclosed '{' in %q", f)
+			}
+			n, err := strconv.Atoi(f[i+1 : i+end])
+			if err != nil || n < 0 || n >= len(args) {
+				return "", fmt.Errorf("format: invalid placeholder %q in %q", f[i:i+end+1], f)
+			}
+			b.WriteString(ToString(args[n]))
+			i += end
+			continue
+		}
+		if c == '}' {
+			if i+1 < len(f) && f[i+1] == '}' {
+				i++
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), nil
+}
+
+// lookup 大小写不敏感地读取对象属性
+func lookup(m map[string]any, key string) any {
+	if m == nil {
+		return nil
+	}
+	if v, ok := m[key]; ok {
+		return v
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+func index(target, key any) any {
+	switch t := target.(type) {
+	case map[string]any:
+		return lookup(t, ToString(key))
+	case []any:
+		// 对过滤结果继续取属性：a.*.b
+		if s, ok := key.(string); ok {
+			out := make([]any, 0, len(t))
+			for _, it := range t {
+				if m, ok := it.(map[string]any); ok {
+					if v := lookup(m, s); v != nil {
+						out = append(out, v)
+					}
+				}
+			}
+			return out
+		}
+		f := toNumber(key)
+		if math.IsNaN(f) || f < 0 || int(f) >= len(t) {
+			return nil
+		}
+		return t[int(f)]
+	}
+	return nil
+}
+
+func filter(target any) any {
+	switch t := target.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]any, 0, len(t))
+		for _, k := range keys {
+			out = append(out, t[k])
+		}
+		return out
+	case []any:
+		return t
+	}
+	return []any{}
+}
+
+func compare(op tokenKind, left, right any) bool {
+	switch op {
+	case tkEq:
+		return looseEqual(left, right)
+	case tkNe:
+		return !looseEqual(left, right)
+	}
+	ls, lok := left.(string)
+	rs, rok := right.(string)
+	if lok && rok {
+		c := strings.Compare(strings.ToLower(ls), strings.ToLower(rs))
+		switch op {
+		case tkLt:
+			return c < 0
+		case tkLe:
+			return c <= 0
+		case tkGt:
+			return c > 0
+		case tkGe:
+			return c >= 0
+		}
+		return false
+	}
+	l, r := toNumber(left), toNumber(right)
+	if math.IsNaN(l) || math.IsNaN(r) {
+		return false
+	}
+	switch op {
+	case tkLt:
+		return l < r
+	case tkLe:
+		return l <= r
+	case tkGt:
+		return l > r
+	case tkGe:
+		return l >= r
+	}
+	return false
+}
+
+// looseEqual 宽松相等：同类型直接比较（字符串忽略大小写），不同类型转为数字比较
+func looseEqual(a, b any) bool {
+	switch av := a.(type) {
+	case nil:
+		if b == nil {
+			return true
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.EqualFold(av, bv)
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			return av == bv
+		}
+	case float64:
+		if bv, ok := b.(float64); ok {
+			return av == bv
+		}
+	case map[string]any, []any:
+		// 对象与数组仅在引用相同时相等；此处无法比较引用，统一视为不等
+		return false
+	}
+	switch b.(type) {
+	case map[string]any, []any:
+		return false
+	}
+	l, r := toNumber(a), toNumber(b)
+	if math.IsNaN(l) || math.IsNaN(r) {
+		return false
+	}
+	return l == r
+}
+
+func toNumber(v any) float64 {
+	switch t := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if t {
+			return 1
+		}
+		return 0
+	case float64:
+		return t
+	case string:
+		s := strings.TrimSpace(t)
+		if s == "" {
+			return 0
+		}
+		if f, err := parseNumber(s); err == nil {
+			return f
+		}
+		return math.NaN()
+	}
+	return math.NaN()
+}
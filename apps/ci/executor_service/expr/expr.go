@@ -0,0 +1,320 @@
+// Package expr 实现与 GitHub Actions 兼容的 ${{ }} 表达式求值
+// 支持：
+//   - 上下文：env、needs、inputs、github、matrix、steps、jobs 等（由调用方注入）；
+//     steps 仅在 Job 运行时可用，引用它的步骤表达式由 Job 内的 xc-expr 求值（见 cmd/xc-expr）
+//   - 运算符：! == != < <= > >= && || 以及属性访问 a.b / a['b'] / a[0] / a.*
+//   - 函数：success() failure() always() cancelled() contains() startsWith() endsWith()
+//     format() join() toJSON() fromJSON()
+//     hashFiles()：需要工作区，仅由 Job 内的 xc-expr 求值（Context.HashFiles）
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Context 表达式求值上下文
+type Context struct {
+	Values    map[string]any // 命名上下文，键为小写（env/needs/inputs/github/...）
+	Failed    bool           // 前置 Job/Step 是否失败，驱动 success()/failure()
+	Cancelled bool           // 构建是否已取消，驱动 cancelled()
+	Skipped   bool           // 直接依赖的 Job 是否被跳过：此时 success() 为 false，failure() 不受影响
+
+	// HashFiles 计算工作区文件摘要，驱动 hashFiles()；仅 Job 内求值时提供，为 nil 时调用报错
+	HashFiles func(patterns []string) (string, error)
+}
+
+// NewContext 创建空的求值上下文
+func NewContext() *Context {
+	return &Context{Values: map[string]any{}}
+}
+
+// Set 设置命名上下文；值会被归一化为 map[string]any/[]any/float64 等基础类型
+func (c *Context) Set(name string, v any) {
+	if c.Values == nil {
+		c.Values = map[string]any{}
+	}
+	c.Values[strings.ToLower(name)] = Normalize(v)
+}
+
+// Get 读取命名上下文
+func (c *Context) Get(name string) any {
+	if c == nil {
+		return nil
+	}
+	return lookup(c.Values, strings.ToLower(name))
+}
+
+// With 复制上下文并覆盖状态标记（命名上下文浅拷贝共享）
+func (c *Context) With(failed, cancelled bool) *Context {
+	out := &Context{Values: map[string]any{}, Failed: failed, Cancelled: cancelled}
+	if c != nil {
+		out.HashFiles = c.HashFiles
+		for k, v := range c.Values {
+			out.Values[k] = v
+		}
+	}
+	return out
+}
+
+// Evaluate 对单个表达式（不含 ${{ }} 包裹）求值
+func Evaluate(src string, ctx *Context) (any, error) {
+	n, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return eval(n, ctx)
+}
+
+// EvaluateCondition 对 if 条件求值
+// 规则（与 GitHub 一致）：
+// - 可带或不带 ${{ }} 包裹；空条件等价于 success()
+// - 条件中未调用状态函数时隐式追加 success() &&
+func EvaluateCondition(cond string, ctx *Context) (bool, error) {
+	src := strings.TrimSpace(cond)
+	if strings.HasPrefix(src, "${{") && strings.HasSuffix(src, "}}") && strings.Count(src, "${{") == 1 {
+		src = strings.TrimSpace(src[3 : len(src)-2])
+	} else if strings.Contains(src, "${{") {
+		// 条件中混合了文本与表达式：先插值，再按字符串真值判断
+		s, err := Interpolate(src, ctx)
+		if err != nil {
+			return false, err
+		}
+		src = "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	if src == "" {
+		src = "success()"
+	}
+	n, err := parse(src)
+	if err != nil {
+		return false, fmt.Errorf("invalid if condition %q: %w", cond, err)
+	}
+	if !usesStatusFunction(n) {
+		n = &binaryNode{op: tkAnd, left: &callNode{name: "success"}, right: n}
+	}
+	v, err := eval(n, ctx)
+	if err != nil {
+		return false, fmt.Errorf("evaluate if condition %q: %w", cond, err)
+	}
+	return IsTruthy(v), nil
+}
+
+// Interpolate 替换字符串中的所有 ${{ expr }} 片段为其字符串值
+func Interpolate(s string, ctx *Context) (string, error) {
+	if !strings.Contains(s, "${{") {
+		return s, nil
+	}
+	var b strings.Builder
+	rest := s
+	for {
+		start := strings.Index(rest, "${{")
+		if start < 0 {
+			b.WriteString(rest)
+			break
+		}
+		end := findClose(rest[start+3:])
+		if end < 0 {
+			return "", fmt.Errorf("unterminated expression in %q", s)
+		}
+		b.WriteString(rest[:start])
+		v, err := Evaluate(rest[start+3:start+3+end], ctx)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(ToString(v))
+		rest = rest[start+3+end+2:]
+	}
+	return b.String(), nil
+}
+
+// findClose 查找表达式结束的 }}，跳过字符串字面量中的花括号
+func findClose(s string) int {
+	inStr := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			inStr = !inStr
+			continue
+		}
+		if !inStr && c == '}' && i+1 < len(s) && s[i+1] == '}' {
+			return i
+		}
+	}
+	return -1
+}
+
+// Validate 仅做语法检查（不求值），用于提前发现表达式错误；空串合法（if 为空等价于 success()）
+func Validate(s string) error {
+	_, err := expressions(s)
+	return err
+}
+
+// Contexts 返回 s（规则同 Validate）中表达式引用的命名上下文（小写，按首次出现排序）
+func Contexts(s string) ([]string, error) {
+	nodes, err := expressions(s)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	seen := map[string]bool{}
+	for _, n := range nodes {
+		walk(n, func(n node) {
+			if c, ok := n.(*contextNode); ok && !seen[c.name] {
+				seen[c.name] = true
+				names = append(names, c.name)
+			}
+		})
+	}
+	return names, nil
+}
+
+// NeedsRuntime s 中的表达式是否引用了 Job 运行时才有的值（steps 上下文或 hashFiles()），此类表达式由 Job 内的脚本求值
+// 语法错误返回 false，由调用方的求值报告
+func NeedsRuntime(s string) bool {
+	return RuntimeReference(s) != ""
+}
+
+// RuntimeReference 返回 s 中需在 Job 运行时求值的引用："hashFiles"（优先，Job 结束后工作区不再可用）、"steps"（steps 上下文），均未引用或语法错误时为空串
+func RuntimeReference(s string) string {
+	lower := strings.ToLower(s)
+	if !strings.Contains(lower, "steps") && !strings.Contains(lower, "hashfiles") {
+		return ""
+	}
+	nodes, err := expressions(s)
+	if err != nil {
+		return ""
+	}
+	ref := ""
+	for _, n := range nodes {
+		walk(n, func(n node) {
+			switch v := n.(type) {
+			case *contextNode:
+				if v.name == "steps" && ref == "" {
+					ref = "steps"
+				}
+			case *callNode:
+				if v.name == "hashfiles" {
+					ref = "hashFiles"
+				}
+			}
+		})
+	}
+	return ref
+}
+
+// expressions 解析 s 中的全部表达式：不含 ${{ 时整体视为一个表达式（if 条件），否则逐个解析 ${{ }} 片段
+func expressions(s string) ([]node, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	if !strings.Contains(s, "${{") {
+		n, err := parse(s)
+		if err != nil {
+			return nil, err
+		}
+		return []node{n}, nil
+	}
+	var nodes []node
+	rest := s
+	for {
+		start := strings.Index(rest, "${{")
+		if start < 0 {
+			return nodes, nil
+		}
+		end := findClose(rest[start+3:])
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated expression in %q", s)
+		}
+		n, err := parse(rest[start+3 : start+3+end])
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		rest = rest[start+3+end+2:]
+	}
+}
+
+// IsTruthy 真值判断：false、0、-0、""、null、NaN 为假，其余为真
+func IsTruthy(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case float64:
+		return t != 0 && !math.IsNaN(t)
+	case string:
+		return t != ""
+	}
+	return true
+}
+
+// ToString 将值转换为字符串（用于插值与字符串函数）
+func ToString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case bool:
+		if t {
+			return "true"
+		}
+		return "false"
+	case float64:
+		if t == math.Trunc(t) && math.Abs(t) < 1e15 {
+			return strconv.FormatInt(int64(t), 10)
+		}
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case []any:
+		return "Array"
+	case map[string]any:
+		return "Object"
+	}
+	return fmt.Sprint(v)
+}
+
+// Normalize 将常见 Go 类型转换为表达式内部使用的基础类型
+func Normalize(v any) any {
+	switch t := v.(type) {
+	case nil, string, bool, float64:
+		return t
+	case int:
+		return float64(t)
+	case int32:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case uint64:
+		return float64(t)
+	case float32:
+		return float64(t)
+	case map[string]string:
+		out := make(map[string]any, len(t))
+		for k, v := range t {
+			out[k] = v
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, v := range t {
+			out[k] = Normalize(v)
+		}
+		return out
+	case []string:
+		out := make([]any, len(t))
+		for i, v := range t {
+			out[i] = v
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, v := range t {
+			out[i] = Normalize(v)
+		}
+		return out
+	}
+	return fmt.Sprint(v)
+}
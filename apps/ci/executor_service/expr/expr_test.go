@@ -0,0 +1,249 @@
+package expr
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testContext() *Context {
+	ctx := NewContext()
+	ctx.Set("env", map[string]string{"NAME": "Main", "EMPTY": ""})
+	ctx.Set("matrix", map[string]any{"os": "linux", "versions": []string{"1.21", "1.22"}})
+	return ctx
+}
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		src  string
+		want any
+	}{
+		// 优先级：! > 比较 > 相等 > && > ||；&& 与 || 返回操作数本身
+		{"!false && 0 == 0 || false", true},
+		{"1 < 2 == true", true},
+		{"!1 == false", true},
+		{"1 == 1 && 2", float64(2)},
+		{"0 && 'x'", float64(0)},
+		{"0 || 'x'", "x"},
+		{"'' || null", nil},
+		{"env.empty || env.name", "Main"},
+		{"(1 || 0) && 'y'", "y"},
+		// 宽松相等与类型转换
+		{"'' == 0", true},
+		{"null == 0", true},
+		{"null == ''", true},
+		{"null == false", true},
+		{"true == 1", true},
+		{"'1' == 1", true},
+		{"' 2 ' == 2", true},
+		{"'0x10' == 16", true},
+		{"'abc' == 'ABC'", true},
+		{"'abc' != 'ABD'", true},
+		{"'abc' == 0", false},
+		{"'abc' != 0", true},
+		{"'abc' < 1", false},
+		{"'abc' >= 1", false},
+		{"'B' > 'a'", true},
+		{"fromJSON('[]') == fromJSON('[]')", false},
+		// 上下文访问（大小写不敏感）
+		{"env.name", "Main"},
+		{"ENV['NAME']", "Main"},
+		{"matrix.versions[1]", "1.22"},
+		{"matrix.missing.deep", nil},
+		{"fromJSON('[{\"a\":1},{\"a\":2}]').*.a", []any{float64(1), float64(2)}},
+		// 函数
+		{"contains('Hello World', 'WORLD')", true},
+		{"contains(matrix.versions, '1.22')", true},
+		{"contains(fromJSON('[1,2]'), '2')", true},
+		{"contains(matrix.versions, '1.2')", false},
+		{"startsWith('refs/heads/main', 'REFS/heads/')", true},
+		{"startsWith('main', 'refs/')", false},
+		{"endsWith('app.tar.gz', '.GZ')", true},
+		{"format('{0}-{1}', 'a', 3)", "a-3"},
+		{"format('{{0}} is {0}', 'x')", "{0} is x"},
+		{"format('{0}}}', 'a')", "a}"},
+		{"join(matrix.versions, '+')", "1.21+1.22"},
+		{"join(matrix.versions)", "1.21,1.22"},
+		{"join('x', '-')", "x"},
+		{"toJSON(fromJSON('{\"a\":[1,true]}'))", "{\n  \"a\": [\n    1,\n    true\n  ]\n}"},
+		{"toJSON('s')", "\"s\""},
+		{"fromJSON('{\"a\":[1,2]}').a[1]", float64(2)},
+		{"fromJSON('true')", true},
+	}
+	for _, c := range cases {
+		got, err := Evaluate(c.src, testContext())
+		if err != nil {
+			t.Errorf("Evaluate(%q): %v", c.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Evaluate(%q) = %#v, want %#v", c.src, got, c.want)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	cases := []struct {
+		src, want string
+	}{
+		{"1 ==", "unexpected end of expression"},
+		{"a = b", "unexpected '='"},
+		{"'open", "unterminated string"},
+		{"foo()", "unknown function foo()"},
+		{"contains('a')", "function contains() got 1 arguments"},
+		{"format('{1}', 'a')", "invalid placeholder"},
+		{"format('{0', 'a')", "unclosed '{'"},
+		{"fromJSON('{')", "fromJSON"},
+		{"hashFiles('go.sum')", "hashFiles() is only available while the job runs"},
+		{"hashFiles()", "function hashfiles() got 0 arguments"},
+	}
+	for _, c := range cases {
+		_, err := Evaluate(c.src, testContext())
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("Evaluate(%q) error = %v, want %q", c.src, err, c.want)
+		}
+	}
+}
+
+func TestEvaluateCondition(t *testing.T) {
+	cases := []struct {
+		cond                       string
+		failed, cancelled, skipped bool
+		want                       bool
+	}{
+		// 未调用状态函数时隐式追加 success() &&
+		{"", false, false, false, true},
+		{"", true, false, false, false},
+		{"env.name == 'main'", false, false, false, true},
+		{"env.name == 'main'", true, false, false, false},
+		{"${{ env.name == 'main' }}", false, false, false, true},
+		{"env.name == 'dev'", false, false, false, false},
+		{"true", false, true, false, false},
+		// 显式调用状态函数时不追加
+		{"always()", true, true, false, true},
+		{"failure()", false, false, false, false},
+		{"failure()", true, false, false, true},
+		{"failure()", true, true, false, false},
+		{"success()", false, true, false, false},
+		{"success()", false, false, true, false},
+		{"failure()", false, false, true, false},
+		{"cancelled()", false, true, false, true},
+		{"!cancelled()", true, false, false, true},
+		{"${{ failure() || env.name == 'main' }}", false, false, false, true},
+		{"always() && env.name == 'dev'", true, false, false, false},
+		// 文本与表达式混合时按插值结果的字符串真值判断
+		{"${{ env.empty }}", false, false, false, false},
+		{"x${{ env.empty }}", false, false, false, true},
+	}
+	for _, c := range cases {
+		ctx := testContext().With(c.failed, c.cancelled)
+		ctx.Skipped = c.skipped
+		got, err := EvaluateCondition(c.cond, ctx)
+		if err != nil {
+			t.Errorf("EvaluateCondition(%q): %v", c.cond, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("EvaluateCondition(%q, failed=%v, cancelled=%v, skipped=%v) = %v, want %v", c.cond, c.failed, c.cancelled, c.skipped, got, c.want)
+		}
+	}
+	if _, err := EvaluateCondition("success( &&", testContext()); err == nil || !strings.Contains(err.Error(), "invalid if condition") {
+		t.Errorf("malformed condition error = %v", err)
+	}
+}
+
+func TestInterpolateAndValidate(t *testing.T) {
+	got, err := Interpolate("os=${{ matrix.os }} v=${{ format('{0}}}', 'a') }} n=${{ 1.50 }}", testContext())
+	if err != nil || got != "os=linux v=a} n=1.5" {
+		t.Errorf("Interpolate = %q, %v", got, err)
+	}
+	if _, err := Interpolate("${{ matrix.os", testContext()); err == nil {
+		t.Error("unterminated interpolation accepted")
+	}
+	for _, ok := range []string{"", "github.ref == 'refs/heads/main'", "${{ always() }}", "run on ${{ matrix.os }} and ${{ env.name }}"} {
+		if err := Validate(ok); err != nil {
+			t.Errorf("Validate(%q): %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"a ==", "${{ contains('a') }}", "ok ${{ 'x' }} ${{ (1 }}", "${{ 1"} {
+		if err := Validate(bad); err == nil {
+			t.Errorf("Validate(%q) accepted", bad)
+		}
+	}
+}
+
+func TestRuntimeReference(t *testing.T) {
+	cases := map[string]string{
+		"":                                             "",
+		"github.ref == 'refs/heads/main'":              "",
+		"steps.build.outcome == 'success'":             "steps",
+		"echo ${{ steps.meta.outputs.version }}":       "steps",
+		"key-${{ hashFiles('**/go.sum') }}":            "hashFiles",
+		"${{ steps.x.outcome }}-${{ hashFiles('a') }}": "hashFiles",
+		"'steps' and hashFiles as plain text":          "",
+		"${{ steps.x":                                  "",
+	}
+	for src, want := range cases {
+		if got := RuntimeReference(src); got != want {
+			t.Errorf("RuntimeReference(%q) = %q, want %q", src, got, want)
+		}
+	}
+}
+
+func TestHashFiles(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"go.sum":            "a",
+		"sub/go.sum":        "b",
+		"sub/deep/go.sum":   "c",
+		"vendor/x/go.sum":   "d",
+		"package-lock.json": "e",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func(patterns ...string) string {
+		t.Helper()
+		h, err := HashFiles(root, patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	all := hash("**/go.sum")
+	if len(all) != 64 {
+		t.Fatalf("hash = %q, want 64 hex chars", all)
+	}
+	if hash("go.sum", "sub/**/go.sum", "vendor/*/go.sum") != all {
+		t.Error("same file set hashed differently")
+	}
+	if hash("./go.sum") != hash(filepath.Join(root, "go.sum")) {
+		t.Error("relative and absolute patterns differ")
+	}
+	if got := hash("**/go.sum", "!vendor/**"); got == all || got != hash("go.sum", "sub/**/go.sum") {
+		t.Errorf("exclusion not applied: %q", got)
+	}
+	if hash("go.sum") == hash("sub/go.sum") {
+		t.Error("different contents share a hash")
+	}
+	if got := hash("**/*.lock"); got != "" {
+		t.Errorf("no match hash = %q, want empty", got)
+	}
+	if _, err := HashFiles(root, []string{"/etc/passwd"}); err == nil {
+		t.Error("pattern outside workspace accepted")
+	}
+
+	ctx := NewContext()
+	ctx.HashFiles = func(patterns []string) (string, error) { return HashFiles(root, patterns) }
+	got, err := Interpolate("deps-${{ hashFiles('**/go.sum', '!vendor/**') }}", ctx.With(false, false))
+	if err != nil || got != "deps-"+hash("go.sum", "sub/**/go.sum") {
+		t.Errorf("Interpolate hashFiles = %q, %v", got, err)
+	}
+}
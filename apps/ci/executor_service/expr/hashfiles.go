@@ -0,0 +1,121 @@
+package expr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// HashFiles 计算 root 下匹配 patterns 的文件摘要，语义与 GitHub Actions 的 hashFiles() 一致：
+//   - 模式相对 root，支持 * ? [...] 与匹配任意层目录的 **；以 ! 开头的模式排除此前已匹配的文件
+//   - 匹配的文件按路径排序，逐个计算 SHA-256 后再对摘要序列计算 SHA-256，以十六进制返回
+//   - 没有匹配的文件时返回空串
+func HashFiles(root string, patterns []string) (string, error) {
+	type rule struct {
+		exclude bool
+		parts   []string
+	}
+	var rules []rule
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		exclude := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		if p == "" {
+			continue
+		}
+		if filepath.IsAbs(p) {
+			rel, err := filepath.Rel(root, p)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return "", fmt.Errorf("hashFiles: pattern %q is outside the workspace %s", p, root)
+			}
+			p = rel
+		}
+		p = path.Clean(filepath.ToSlash(p))
+		if _, err := path.Match(p, ""); err != nil {
+			return "", fmt.Errorf("hashFiles: invalid pattern %q", p)
+		}
+		rules = append(rules, rule{exclude: exclude, parts: strings.Split(p, "/")})
+	}
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		matched := false
+		for _, r := range rules {
+			if r.exclude == matched && matchParts(r.parts, parts) {
+				matched = !r.exclude
+			}
+		}
+		if matched {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("hashFiles: %w", err)
+	}
+	if len(files) == 0 {
+		return "", nil
+	}
+	sort.Strings(files)
+	total := sha256.New()
+	for _, f := range files {
+		sum, err := fileSHA256(f)
+		if err != nil {
+			return "", fmt.Errorf("hashFiles: %w", err)
+		}
+		total.Write(sum)
+	}
+	return hex.EncodeToString(total.Sum(nil)), nil
+}
+
+// matchParts 按路径段匹配：** 匹配零或多个目录段，其余段按 path.Match
+func matchParts(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func fileSHA256(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,204 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tkEOF tokenKind = iota
+	tkIdent
+	tkNumber
+	tkString
+	tkTrue
+	tkFalse
+	tkNull
+	tkLParen
+	tkRParen
+	tkLBracket
+	tkRBracket
+	tkDot
+	tkComma
+	tkStar
+	tkNot
+	tkAnd
+	tkOr
+	tkEq
+	tkNe
+	tkLt
+	tkLe
+	tkGt
+	tkGe
+)
+
+type token struct {
+	kind tokenKind
+	text string  // 原始文本（标识符/字符串内容）
+	num  float64 // 数字字面量
+	pos  int     // 在表达式中的起始偏移，用于错误提示
+}
+
+// lex 将表达式切分为 token 序列
+// 说明：语法与 GitHub Actions 表达式保持一致：字符串仅支持单引号（连续两个单引号表示一个单引号），
+// 标识符允许包含 '-'（如 steps.my-step.outputs）
+func lex(src string) ([]token, error) {
+	var out []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			out = append(out, token{kind: tkLParen, pos: i})
+			i++
+		case c == ')':
+			out = append(out, token{kind: tkRParen, pos: i})
+			i++
+		case c == '[':
+			out = append(out, token{kind: tkLBracket, pos: i})
+			i++
+		case c == ']':
+			out = append(out, token{kind: tkRBracket, pos: i})
+			i++
+		case c == ',':
+			out = append(out, token{kind: tkComma, pos: i})
+			i++
+		case c == '*':
+			out = append(out, token{kind: tkStar, pos: i})
+			i++
+		case c == '.' && !(i+1 < len(src) && isDigit(src[i+1]) && (len(out) == 0 || !isOperand(out[len(out)-1].kind))):
+			out = append(out, token{kind: tkDot, pos: i})
+			i++
+		case c == '!':
+			if i+1 < len(src) && src[i+1] == '=' {
+				out = append(out, token{kind: tkNe, pos: i})
+				i += 2
+			} else {
+				out = append(out, token{kind: tkNot, pos: i})
+				i++
+			}
+		case c == '=':
+			if i+1 < len(src) && src[i+1] == '=' {
+				out = append(out, token{kind: tkEq, pos: i})
+				i += 2
+			} else {
+				return nil, fmt.Errorf("unexpected '=' at position %d", i)
+			}
+		case c == '<':
+			if i+1 < len(src) && src[i+1] == '=' {
+				out = append(out, token{kind: tkLe, pos: i})
+				i += 2
+			} else {
+				out = append(out, token{kind: tkLt, pos: i})
+				i++
+			}
+		case c == '>':
+			if i+1 < len(src) && src[i+1] == '=' {
+				out = append(out, token{kind: tkGe, pos: i})
+				i += 2
+			} else {
+				out = append(out, token{kind: tkGt, pos: i})
+				i++
+			}
+		case c == '&':
+			if i+1 < len(src) && src[i+1] == '&' {
+				out = append(out, token{kind: tkAnd, pos: i})
+				i += 2
+			} else {
+				return nil, fmt.Errorf("unexpected '&' at position %d", i)
+			}
+		case c == '|':
+			if i+1 < len(src) && src[i+1] == '|' {
+				out = append(out, token{kind: tkOr, pos: i})
+				i += 2
+			} else {
+				return nil, fmt.Errorf("unexpected '|' at position %d", i)
+			}
+		case c == '\'':
+			start := i
+			i++
+			var b strings.Builder
+			closed := false
+			for i < len(src) {
+				if src[i] == '\'' {
+					if i+1 < len(src) && src[i+1] == '\'' {
+						b.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					closed = true
+					break
+				}
+				b.WriteByte(src[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			out = append(out, token{kind: tkString, text: b.String(), pos: start})
+		case isDigit(c) || c == '-' || c == '.':
+			start := i
+			i++
+			for i < len(src) && (isIdentChar(src[i]) || src[i] == '.' || ((src[i] == '+' || src[i] == '-') && (src[i-1] == 'e' || src[i-1] == 'E'))) {
+				i++
+			}
+			text := src[start:i]
+			n, err := parseNumber(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", text, start)
+			}
+			out = append(out, token{kind: tkNumber, num: n, text: text, pos: start})
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && isIdentChar(src[i]) {
+				i++
+			}
+			text := src[start:i]
+			switch text {
+			case "true":
+				out = append(out, token{kind: tkTrue, text: text, pos: start})
+			case "false":
+				out = append(out, token{kind: tkFalse, text: text, pos: start})
+			case "null":
+				out = append(out, token{kind: tkNull, text: text, pos: start})
+			default:
+				out = append(out, token{kind: tkIdent, text: text, pos: start})
+			}
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+	out = append(out, token{kind: tkEOF, pos: len(src)})
+	return out, nil
+}
+
+func isDigit(c byte) bool      { return c >= '0' && c <= '9' }
+func isIdentStart(c byte) bool { return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isIdentChar(c byte) bool  { return isIdentStart(c) || isDigit(c) || c == '-' }
+
+// isOperand 判断 token 是否可作为属性访问的左操作数（用于区分 .5 与 a.b）
+func isOperand(k tokenKind) bool {
+	return k == tkIdent || k == tkRParen || k == tkRBracket || k == tkStar
+}
+
+// parseNumber 解析十进制/十六进制/科学计数法数字
+func parseNumber(s string) (float64, error) {
+	neg := strings.HasPrefix(s, "-")
+	body := strings.TrimPrefix(s, "-")
+	if strings.HasPrefix(body, "0x") || strings.HasPrefix(body, "0X") {
+		n, err := strconv.ParseInt(body[2:], 16, 64)
+		if err != nil {
+			return 0, err
+		}
+		if neg {
+			n = -n
+		}
+		return float64(n), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,293 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// node 表达式语法树节点
+type node interface{}
+
+type literalNode struct{ value any }
+
+// contextNode 顶层命名上下文访问（如 env、needs、github）
+type contextNode struct{ name string }
+
+// propertyNode 属性访问：a.b / a['b'] / a[0]
+type propertyNode struct {
+	target node
+	key    node
+}
+
+// filterNode 对象过滤：a.*
+type filterNode struct{ target node }
+
+type notNode struct{ operand node }
+
+type binaryNode struct {
+	op          tokenKind
+	left, right node
+}
+
+type callNode struct {
+	name string // 小写函数名
+	args []node
+}
+
+type exprParser struct {
+	toks []token
+	pos  int
+	src  string
+}
+
+// parse 将表达式源码解析为语法树
+func parse(src string) (node, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks, src: src}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tkEOF {
+		return nil, fmt.Errorf("unexpected token at position %d in %q", p.peek().pos, src)
+	}
+	return n, nil
+}
+
+func (p *exprParser) peek() token { return p.toks[p.pos] }
+
+func (p *exprParser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tkEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) expect(k tokenKind, what string) (token, error) {
+	t := p.next()
+	if t.kind != k {
+		return t, fmt.Errorf("expected %s at position %d in %q", what, t.pos, p.src)
+	}
+	return t, nil
+}
+
+func (p *exprParser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tkOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: tkOr, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (node, error) {
+	left, err := p.parseEquality()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tkAnd {
+		p.next()
+		right, err := p.parseEquality()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: tkAnd, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseEquality() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for k := p.peek().kind; k == tkEq || k == tkNe; k = p.peek().kind {
+		p.next()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: k, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseComparison() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for k := p.peek().kind; k == tkLt || k == tkLe || k == tkGt || k == tkGe; k = p.peek().kind {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: k, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (node, error) {
+	if p.peek().kind == tkNot {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tkDot:
+			p.next()
+			t := p.next()
+			switch t.kind {
+			case tkStar:
+				n = &filterNode{target: n}
+			case tkIdent, tkTrue, tkFalse, tkNull:
+				n = &propertyNode{target: n, key: &literalNode{value: t.text}}
+			default:
+				return nil, fmt.Errorf("expected property name at position %d in %q", t.pos, p.src)
+			}
+		case tkLBracket:
+			p.next()
+			if p.peek().kind == tkStar {
+				p.next()
+				n = &filterNode{target: n}
+			} else {
+				key, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				n = &propertyNode{target: n, key: key}
+			}
+			if _, err := p.expect(tkRBracket, "']'"); err != nil {
+				return nil, err
+			}
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tkNumber:
+		return &literalNode{value: t.num}, nil
+	case tkString:
+		return &literalNode{value: t.text}, nil
+	case tkTrue:
+		return &literalNode{value: true}, nil
+	case tkFalse:
+		return &literalNode{value: false}, nil
+	case tkNull:
+		return &literalNode{value: nil}, nil
+	case tkLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tkRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case tkIdent:
+		if p.peek().kind == tkLParen {
+			p.next()
+			call := &callNode{name: strings.ToLower(t.text)}
+			if p.peek().kind != tkRParen {
+				for {
+					arg, err := p.parseOr()
+					if err != nil {
+						return nil, err
+					}
+					call.args = append(call.args, arg)
+					if p.peek().kind != tkComma {
+						break
+					}
+					p.next()
+				}
+			}
+			if _, err := p.expect(tkRParen, "')'"); err != nil {
+				return nil, err
+			}
+			if err := checkArity(call); err != nil {
+				return nil, err
+			}
+			return call, nil
+		}
+		return &contextNode{name: strings.ToLower(t.text)}, nil
+	case tkEOF:
+		return nil, fmt.Errorf("unexpected end of expression %q", p.src)
+	default:
+		return nil, fmt.Errorf("unexpected token at position %d in %q", t.pos, p.src)
+	}
+}
+
+// usesStatusFunction 判断语法树中是否调用了状态函数（success/failure/always/cancelled）
+// 说明：与 GitHub 一致，未显式调用状态函数的 if 条件会隐式追加 success() &&
+func usesStatusFunction(n node) bool {
+	switch v := n.(type) {
+	case *callNode:
+		switch v.name {
+		case "success", "failure", "always", "cancelled":
+			return true
+		}
+		for _, a := range v.args {
+			if usesStatusFunction(a) {
+				return true
+			}
+		}
+	case *binaryNode:
+		return usesStatusFunction(v.left) || usesStatusFunction(v.right)
+	case *notNode:
+		return usesStatusFunction(v.operand)
+	case *propertyNode:
+		return usesStatusFunction(v.target) || usesStatusFunction(v.key)
+	case *filterNode:
+		return usesStatusFunction(v.target)
+	}
+	return false
+}
+
+// walk 先序遍历语法树
+func walk(n node, fn func(node)) {
+	fn(n)
+	switch v := n.(type) {
+	case *callNode:
+		for _, a := range v.args {
+			walk(a, fn)
+		}
+	case *binaryNode:
+		walk(v.left, fn)
+		walk(v.right, fn)
+	case *notNode:
+		walk(v.operand, fn)
+	case *propertyNode:
+		walk(v.target, fn)
+		walk(v.key, fn)
+	case *filterNode:
+		walk(v.target, fn)
+	}
+}
@@ -0,0 +1,206 @@
+package artifact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
+	civ1 "xcoding/gen/go/ci/v1"
+
+	"gorm.io/gorm"
+)
+
+// RunnerPathPrefix runner 上传/下载制品的路径前缀（不经 API 网关暴露，以构建令牌鉴权）
+// 完整路径：/internal/artifacts/builds/{build_id}/artifacts/{name}[?job=<Job 名>]
+const RunnerPathPrefix = "/internal/artifacts/"
+
+// Handler 制品服务
+// - runner 接口（ServeHTTP）：PUT/POST 上传 tar.gz 归档、GET 下载，Authorization: Bearer <构建令牌>
+// - 用户下载（Download）：经 gRPC 网关注册，由网关完成登录鉴权
+type Handler struct {
+	db      *gorm.DB
+	store   Store
+	key     []byte
+	maxSize int64
+	mux     *http.ServeMux
+}
+
+// NewHandler 创建制品服务；secret 为签发构建令牌的密钥（必填，多副本间一致），maxSize 为单个制品的字节上限
+func NewHandler(db *gorm.DB, store Store, secret string, maxSize int64) (*Handler, error) {
+	if strings.TrimSpace(secret) == "" {
+		return nil, errors.New("artifact token secret is required (EXECUTOR_ARTIFACT_TOKEN_SECRET)")
+	}
+	key := []byte(secret)
+	h := &Handler{db: db, store: store, key: key, maxSize: maxSize, mux: http.NewServeMux()}
+	h.mux.HandleFunc("PUT "+RunnerPathPrefix+"builds/{build_id}/artifacts/{name}", h.upload)
+	h.mux.HandleFunc("POST "+RunnerPathPrefix+"builds/{build_id}/artifacts/{name}", h.upload)
+	h.mux.HandleFunc("GET "+RunnerPathPrefix+"builds/{build_id}/artifacts/{name}", h.runnerDownload)
+	return h, nil
+}
+
+// Token 构建令牌：HMAC-SHA256(key, "artifact:<build_id>")，仅可访问该构建的制品
+func (h *Handler) Token(buildID uint64) string {
+	m := hmac.New(sha256.New, h.key)
+	fmt.Fprintf(m, "artifact:%d", buildID)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) { h.mux.ServeHTTP(w, r) }
+
+// runnerRequest 解析并鉴权 runner 请求，返回构建 ID 与制品名；失败时已写入错误响应
+func (h *Handler) runnerRequest(w http.ResponseWriter, r *http.Request) (uint64, string, bool) {
+	buildID, err := strconv.ParseUint(r.PathValue("build_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid build id", http.StatusBadRequest)
+		return 0, "", false
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !hmac.Equal([]byte(token), []byte(h.Token(buildID))) {
+		http.Error(w, "invalid artifact token", http.StatusUnauthorized)
+		return 0, "", false
+	}
+	name := r.PathValue("name")
+	if !parser.ArtifactNamePattern.MatchString(name) {
+		http.Error(w, "invalid artifact name", http.StatusBadRequest)
+		return 0, "", false
+	}
+	return buildID, name, true
+}
+
+// upload 保存制品：请求体先落临时文件（计算大小与 SHA256）再写入存储
+// 构建须处于运行中；同一构建内制品名已存在时返回 409
+func (h *Handler) upload(w http.ResponseWriter, r *http.Request) {
+	buildID, name, ok := h.runnerRequest(w, r)
+	if !ok {
+		return
+	}
+	var b models.Build
+	if err := h.db.Select("id", "status").First(&b, buildID).Error; err != nil {
+		http.Error(w, "build not found", http.StatusNotFound)
+		return
+	}
+	if civ1.BuildStatus(b.Status) != civ1.BuildStatus_BUILD_STATUS_RUNNING {
+		http.Error(w, "build is not running", http.StatusConflict)
+		return
+	}
+	if h.exists(buildID, name) {
+		http.Error(w, fmt.Sprintf("artifact %q already exists in build %d", name, buildID), http.StatusConflict)
+		return
+	}
+
+	f, err := os.CreateTemp("", "xc-artifact-*")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	sum := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, sum), http.MaxBytesReader(w, r.Body, h.maxSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("artifact exceeds %d bytes", h.maxSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 对象键带随机后缀：并发上传同名制品时落库失败的一方只删除自己的对象
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	a := models.BuildArtifact{
+		BuildID:    buildID,
+		Name:       name,
+		JobName:    r.URL.Query().Get("job"),
+		Size:       size,
+		SHA256:     hex.EncodeToString(sum.Sum(nil)),
+		StorageKey: fmt.Sprintf("builds/%d/%s-%s.tar.gz", buildID, name, hex.EncodeToString(suffix)),
+		CreatedAt:  time.Now(),
+	}
+	if err := h.store.Put(r.Context(), a.StorageKey, f, size); err != nil {
+		http.Error(w, "store artifact: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := h.db.Create(&a).Error; err != nil {
+		_ = h.store.Delete(r.Context(), a.StorageKey)
+		if h.exists(buildID, name) {
+			http.Error(w, fmt.Sprintf("artifact %q already exists in build %d", name, buildID), http.StatusConflict)
+			return
+		}
+		http.Error(w, "save artifact: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]any{"name": a.Name, "size": a.Size, "sha256": a.SHA256})
+}
+
+func (h *Handler) exists(buildID uint64, name string) bool {
+	var n int64
+	h.db.Model(&models.BuildArtifact{}).Where("build_id = ? AND name = ?", buildID, name).Count(&n)
+	return n > 0
+}
+
+func (h *Handler) runnerDownload(w http.ResponseWriter, r *http.Request) {
+	buildID, name, ok := h.runnerRequest(w, r)
+	if !ok {
+		return
+	}
+	h.serve(w, r, buildID, name, false)
+}
+
+// Download 用户下载制品（grpc-gateway runtime.HandlerFunc），路径参数 build_id、name
+func (h *Handler) Download(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	buildID, err := strconv.ParseUint(params["build_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid build id", http.StatusBadRequest)
+		return
+	}
+	h.serve(w, r, buildID, params["name"], true)
+}
+
+// serve 输出制品归档；attachment 为 true 时以 <name>.tar.gz 作为下载文件名
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, buildID uint64, name string, attachment bool) {
+	var a models.BuildArtifact
+	if err := h.db.Where("build_id = ? AND name = ?", buildID, name).First(&a).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, fmt.Sprintf("artifact %q not found in build %d", name, buildID), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rc, err := h.store.Get(r.Context(), a.StorageKey)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, ErrNotFound) {
+			status = http.StatusGone
+		}
+		http.Error(w, "read artifact: "+err.Error(), status)
+		return
+	}
+	defer rc.Close()
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	w.Header().Set("X-Checksum-Sha256", a.SHA256)
+	if attachment {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", a.Name+".tar.gz"))
+	}
+	_, _ = io.Copy(w, rc)
+}
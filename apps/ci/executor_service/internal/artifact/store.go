@@ -0,0 +1,114 @@
+package artifact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"xcoding/apps/ci/executor_service/internal/config"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("artifact object not found")
+
+// Store 制品对象存储
+type Store interface {
+	// Put 写入对象，size 为 r 的字节数；同名对象被覆盖
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get 读取对象，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，不存在时不报错
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStore 按配置创建制品存储；backend 为空时返回 nil（不启用制品）
+func NewStore(cfg config.ArtifactConfig) (Store, error) {
+	switch strings.TrimSpace(cfg.Backend) {
+	case "":
+		return nil, nil
+	case "fs":
+		return NewFSStore(cfg.Dir)
+	case "s3":
+		c := cfg.S3
+		if c.Endpoint == "" || c.Bucket == "" {
+			return nil, errors.New("artifact s3 endpoint and bucket are required")
+		}
+		return &S3Store{Endpoint: c.Endpoint, Region: c.Region, Bucket: c.Bucket, AccessKey: c.AccessKey, SecretKey: c.SecretKey, Prefix: c.Prefix, PathStyle: c.PathStyle}, nil
+	}
+	return nil, fmt.Errorf("unknown artifact backend %q (supported: fs, s3)", cfg.Backend)
+}
+
+// FSStore 本地目录存储（开发、测试与单副本部署）
+type FSStore struct {
+	Root string
+}
+
+// NewFSStore 创建本地目录存储，目录不存在时创建
+func NewFSStore(root string) (*FSStore, error) {
+	if strings.TrimSpace(root) == "" {
+		return nil, errors.New("artifact dir is required")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &FSStore{Root: root}, nil
+}
+
+// path 对象键对应的文件路径；键为 / 分隔的相对路径，不允许越出根目录
+func (s *FSStore) path(key string) (string, error) {
+	p := filepath.Join(s.Root, filepath.FromSlash(key))
+	if rel, err := filepath.Rel(s.Root, p); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return p, nil
+}
+
+// Put 先写入同目录临时文件再重命名，读取方不会看到写了一半的对象
+func (s *FSStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+func (s *FSStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *FSStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
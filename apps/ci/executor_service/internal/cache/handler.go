@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"xcoding/apps/ci/executor_service/internal/artifact"
+	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
+	civ1 "xcoding/gen/go/ci/v1"
+	coderepositoryv1 "xcoding/gen/go/code_repository/v1"
+
+	"gorm.io/gorm"
+)
+
+// RunnerPathPrefix runner 恢复/保存缓存的路径前缀（不经 API 网关暴露，以构建令牌鉴权）
+// 完整路径：/internal/caches/builds/{build_id}
+// - GET：请求头 X-Cache-Key 与 X-Cache-Restore-Key（可多个），命中时返回归档并以 X-Cache-Key 标明命中的 key，未命中返回 204
+// - PUT/POST：请求头 X-Cache-Key，请求体为归档；key 已存在返回 409，超过项目配额返回 413
+const RunnerPathPrefix = "/internal/caches/"
+
+// DefaultBranchSource 项目仓库默认分支的来源
+type DefaultBranchSource interface {
+	// DefaultBranch 返回项目仓库的默认分支；仓库未标记默认分支时返回空串
+	DefaultBranch(ctx context.Context, projectID uint64) (string, error)
+}
+
+// CodeRepositoryDefaultBranch 经 code_repository 服务 gRPC 接口获取项目唯一仓库的默认分支
+type CodeRepositoryDefaultBranch struct {
+	Client coderepositoryv1.CodeRepositoryServiceClient
+}
+
+func (c CodeRepositoryDefaultBranch) DefaultBranch(ctx context.Context, projectID uint64) (string, error) {
+	resp, err := c.Client.GetRepositoryDefaultBranch(ctx, &coderepositoryv1.GetRepositoryDefaultBranchRequest{ProjectId: projectID})
+	if err != nil {
+		return "", err
+	}
+	return resp.GetBranch(), nil
+}
+
+// Handler 缓存的 runner 接口；作用域取自构建（项目、流水线、分支），回退分支取自项目仓库的默认分支
+type Handler struct {
+	db       *gorm.DB
+	m        *Manager
+	token    func(buildID uint64) string
+	branches DefaultBranchSource
+	mux      *http.ServeMux
+}
+
+// NewHandler 创建缓存接口；token 为构建令牌（与制品共用），branches 为空时恢复不回退到默认分支
+func NewHandler(db *gorm.DB, m *Manager, token func(buildID uint64) string, branches DefaultBranchSource) *Handler {
+	h := &Handler{db: db, m: m, token: token, branches: branches, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET "+RunnerPathPrefix+"builds/{build_id}", h.restore)
+	h.mux.HandleFunc("PUT "+RunnerPathPrefix+"builds/{build_id}", h.save)
+	h.mux.HandleFunc("POST "+RunnerPathPrefix+"builds/{build_id}", h.save)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) { h.mux.ServeHTTP(w, r) }
+
+// build 解析并鉴权 runner 请求，返回所属构建与请求的缓存 key；失败时已写入错误响应
+func (h *Handler) build(w http.ResponseWriter, r *http.Request) (*models.Build, string, bool) {
+	buildID, err := strconv.ParseUint(r.PathValue("build_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid build id", http.StatusBadRequest)
+		return nil, "", false
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !hmac.Equal([]byte(token), []byte(h.token(buildID))) {
+		http.Error(w, "invalid cache token", http.StatusUnauthorized)
+		return nil, "", false
+	}
+	key := strings.TrimSpace(r.Header.Get("X-Cache-Key"))
+	if err := validKey(key); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, "", false
+	}
+	var b models.Build
+	if err := h.db.Select("id", "project_id", "pipeline_id", "branch", "status").First(&b, buildID).Error; err != nil {
+		http.Error(w, "build not found", http.StatusNotFound)
+		return nil, "", false
+	}
+	return &b, key, true
+}
+
+func validKey(key string) error {
+	if key == "" {
+		return errors.New("X-Cache-Key is required")
+	}
+	return parser.ValidCacheKey(key)
+}
+
+func scopeOf(b *models.Build) Scope {
+	return Scope{ProjectID: b.ProjectID, PipelineID: b.PipelineID, Branch: b.Branch}
+}
+
+// defaultBranch 构建所属项目仓库的默认分支；获取失败（如项目有多个仓库）时仅记录日志，恢复不回退
+func (h *Handler) defaultBranch(ctx context.Context, b *models.Build) string {
+	if h.branches == nil || b.ProjectID == 0 {
+		return ""
+	}
+	br, err := h.branches.DefaultBranch(ctx, b.ProjectID)
+	if err != nil {
+		log.Printf("executor: cache default branch of project %d: %v", b.ProjectID, err)
+		return ""
+	}
+	return br
+}
+
+func (h *Handler) restore(w http.ResponseWriter, r *http.Request) {
+	b, key, ok := h.build(w, r)
+	if !ok {
+		return
+	}
+	var restoreKeys []string
+	for _, v := range r.Header.Values("X-Cache-Restore-Key") {
+		if v = strings.TrimSpace(v); v != "" {
+			if err := parser.ValidCacheKey(v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			restoreKeys = append(restoreKeys, v)
+		}
+	}
+	sc := scopeOf(b)
+	sc.DefaultBranch = h.defaultBranch(r.Context(), b)
+	e, err := h.m.Lookup(r.Context(), sc, key, restoreKeys)
+	if err != nil {
+		http.Error(w, "lookup cache: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if e == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	rc, err := h.m.Open(r.Context(), e)
+	if err != nil {
+		if errors.Is(err, artifact.ErrNotFound) {
+			// 归档已丢失：清理失效条目，按未命中处理
+			_, _, _ = h.m.remove(r.Context(), []models.CacheEntry{*e})
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Error(w, "read cache: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer rc.Close()
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Length", strconv.FormatInt(e.Size, 10))
+	w.Header().Set("X-Cache-Key", e.Key)
+	w.Header().Set("X-Checksum-Sha256", e.SHA256)
+	_, _ = io.Copy(w, rc)
+}
+
+// save 保存缓存：请求体先落临时文件（计算大小与 SHA256）再写入存储；构建须处于运行中
+func (h *Handler) save(w http.ResponseWriter, r *http.Request) {
+	b, key, ok := h.build(w, r)
+	if !ok {
+		return
+	}
+	if civ1.BuildStatus(b.Status) != civ1.BuildStatus_BUILD_STATUS_RUNNING {
+		http.Error(w, "build is not running", http.StatusConflict)
+		return
+	}
+	f, err := os.CreateTemp("", "xc-cache-*")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	sum := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, sum), http.MaxBytesReader(w, r.Body, h.m.Quota()))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("cache exceeds the project quota of %d bytes", h.m.Quota()), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	e, err := h.m.Save(r.Context(), scopeOf(b), b.ID, key, f, size, hex.EncodeToString(sum.Sum(nil)))
+	switch {
+	case errors.Is(err, ErrExists):
+		http.Error(w, fmt.Sprintf("cache %q already exists on branch %q", key, b.Branch), http.StatusConflict)
+		return
+	case errors.Is(err, ErrTooLarge):
+		http.Error(w, fmt.Sprintf("cache exceeds the project quota of %d bytes", h.m.Quota()), http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		http.Error(w, "save cache: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]any{"key": e.Key, "size": e.Size, "sha256": e.SHA256})
+}
@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"xcoding/apps/ci/executor_service/internal/artifact"
+	"xcoding/apps/ci/executor_service/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrExists 作用域内已存在相同 key 的缓存（缓存不可覆盖）
+	ErrExists = errors.New("cache entry already exists")
+	// ErrTooLarge 归档超过项目配额
+	ErrTooLarge = errors.New("cache entry exceeds the project quota")
+)
+
+// Scope 缓存作用域：项目/流水线/分支；DefaultBranch 为项目仓库的默认分支，恢复时回退查找（为空时不回退）
+type Scope struct {
+	ProjectID     uint64
+	PipelineID    uint64
+	Branch        string
+	DefaultBranch string
+}
+
+// Manager 依赖缓存管理：查找、保存、按配额淘汰与清理；归档存放在制品存储中
+type Manager struct {
+	db    *gorm.DB
+	store artifact.Store
+	quota int64
+	mu    sync.Mutex // 串行化本副本内的保存与淘汰
+}
+
+// NewManager 创建缓存管理器；quota 为每个项目的缓存总字节上限
+func NewManager(db *gorm.DB, store artifact.Store, quota int64) *Manager {
+	return &Manager{db: db, store: store, quota: quota}
+}
+
+// Quota 每个项目的缓存总字节上限
+func (m *Manager) Quota() int64 { return m.quota }
+
+// Lookup 查找可恢复的缓存，未命中时返回 nil
+// 顺序：key 精确匹配，其次依次按 restore-keys 前缀匹配（同一前缀取最新保存的条目）；每一项先查当前分支，再查默认分支
+func (m *Manager) Lookup(ctx context.Context, sc Scope, key string, restoreKeys []string) (*models.CacheEntry, error) {
+	branches := []string{sc.Branch}
+	if sc.DefaultBranch != "" && sc.DefaultBranch != sc.Branch {
+		branches = append(branches, sc.DefaultBranch)
+	}
+	type candidate struct {
+		cond string
+		arg  string
+	}
+	cands := []candidate{{"key = ?", key}}
+	for _, rk := range restoreKeys {
+		cands = append(cands, candidate{`key LIKE ? ESCAPE '\'`, escapeLike(rk) + "%"})
+	}
+	for _, c := range cands {
+		for _, br := range branches {
+			var e models.CacheEntry
+			err := m.db.WithContext(ctx).
+				Where("project_id = ? AND pipeline_id = ? AND branch = ?", sc.ProjectID, sc.PipelineID, br).
+				Where(c.cond, c.arg).Order("created_at DESC").First(&e).Error
+			if err == nil {
+				return &e, nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Open 读取缓存归档并更新最近使用时间
+func (m *Manager) Open(ctx context.Context, e *models.CacheEntry) (io.ReadCloser, error) {
+	rc, err := m.store.Get(ctx, e.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+	_ = m.db.Model(&models.CacheEntry{}).Where("id = ?", e.ID).Updates(map[string]any{"last_used_at": time.Now()}).Error
+	return rc, nil
+}
+
+// Save 保存缓存归档：作用域内 key 已存在返回 ErrExists，超过配额返回 ErrTooLarge
+// 写入前按最近使用时间淘汰该项目最久未用的条目，使项目缓存总量不超过配额
+func (m *Manager) Save(ctx context.Context, sc Scope, buildID uint64, key string, r io.Reader, size int64, sha256 string) (*models.CacheEntry, error) {
+	if size > m.quota {
+		return nil, ErrTooLarge
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.exists(sc, key) {
+		return nil, ErrExists
+	}
+	if err := m.evict(ctx, sc.ProjectID, size); err != nil {
+		return nil, fmt.Errorf("evict: %w", err)
+	}
+	now := time.Now()
+	e := models.CacheEntry{
+		ProjectID:  sc.ProjectID,
+		PipelineID: sc.PipelineID,
+		Branch:     sc.Branch,
+		Key:        key,
+		BuildID:    buildID,
+		Size:       size,
+		SHA256:     sha256,
+		StorageKey: fmt.Sprintf("caches/%d/%d/%d-%d.tar", sc.ProjectID, sc.PipelineID, buildID, now.UnixNano()),
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	if err := m.store.Put(ctx, e.StorageKey, r, size); err != nil {
+		return nil, err
+	}
+	if err := m.db.WithContext(ctx).Create(&e).Error; err != nil {
+		_ = m.store.Delete(ctx, e.StorageKey)
+		if m.exists(sc, key) {
+			return nil, ErrExists
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (m *Manager) exists(sc Scope, key string) bool {
+	var n int64
+	m.db.Model(&models.CacheEntry{}).Where("project_id = ? AND pipeline_id = ? AND branch = ? AND key = ?", sc.ProjectID, sc.PipelineID, sc.Branch, key).Count(&n)
+	return n > 0
+}
+
+// evict 淘汰项目内最久未使用的条目，直到现有总量加上 incoming 不超过配额
+func (m *Manager) evict(ctx context.Context, projectID uint64, incoming int64) error {
+	var total int64
+	if err := m.db.WithContext(ctx).Model(&models.CacheEntry{}).Where("project_id = ?", projectID).Select("COALESCE(SUM(size), 0)").Scan(&total).Error; err != nil {
+		return err
+	}
+	if total+incoming <= m.quota {
+		return nil
+	}
+	var entries []models.CacheEntry
+	if err := m.db.WithContext(ctx).Where("project_id = ?", projectID).Order("last_used_at ASC, id ASC").Find(&entries).Error; err != nil {
+		return err
+	}
+	var victims []models.CacheEntry
+	for _, e := range entries {
+		if total+incoming <= m.quota {
+			break
+		}
+		victims = append(victims, e)
+		total -= e.Size
+	}
+	_, _, err := m.remove(ctx, victims)
+	return err
+}
+
+// remove 删除条目及其归档，返回删除的条目数与字节数
+func (m *Manager) remove(ctx context.Context, entries []models.CacheEntry) (int, int64, error) {
+	var freed int64
+	for i, e := range entries {
+		if err := m.store.Delete(ctx, e.StorageKey); err != nil {
+			return i, freed, err
+		}
+		if err := m.db.WithContext(ctx).Delete(&models.CacheEntry{}, e.ID).Error; err != nil {
+			return i, freed, err
+		}
+		freed += e.Size
+	}
+	return len(entries), freed, nil
+}
+
+// Filter 列表与清理条件；零值字段不参与过滤
+type Filter struct {
+	ProjectID  uint64
+	PipelineID uint64
+	Branch     string
+	KeyPrefix  string
+	IDs        []uint64
+}
+
+func (m *Manager) query(ctx context.Context, f Filter) *gorm.DB {
+	q := m.db.WithContext(ctx).Model(&models.CacheEntry{}).Where("project_id = ?", f.ProjectID)
+	if f.PipelineID != 0 {
+		q = q.Where("pipeline_id = ?", f.PipelineID)
+	}
+	if f.Branch != "" {
+		q = q.Where("branch = ?", f.Branch)
+	}
+	if f.KeyPrefix != "" {
+		q = q.Where(`key LIKE ? ESCAPE '\'`, escapeLike(f.KeyPrefix)+"%")
+	}
+	if len(f.IDs) > 0 {
+		q = q.Where("id IN ?", f.IDs)
+	}
+	return q
+}
+
+// List 按条件列出缓存条目（最近使用的在前），并返回项目缓存总字节数
+func (m *Manager) List(ctx context.Context, f Filter) ([]models.CacheEntry, int64, error) {
+	var entries []models.CacheEntry
+	if err := m.query(ctx, f).Order("last_used_at DESC, id DESC").Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if err := m.db.WithContext(ctx).Model(&models.CacheEntry{}).Where("project_id = ?", f.ProjectID).Select("COALESCE(SUM(size), 0)").Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// Purge 删除符合条件的缓存条目及其归档，返回删除的条目数与字节数
+func (m *Manager) Purge(ctx context.Context, f Filter) (int, int64, error) {
+	var entries []models.CacheEntry
+	if err := m.query(ctx, f).Find(&entries).Error; err != nil {
+		return 0, 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.remove(ctx, entries)
+}
+
+// escapeLike 转义 LIKE 通配符（以 \ 为转义字符）
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"xcoding/apps/ci/executor_service/internal/artifact"
+	"xcoding/apps/ci/executor_service/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestManager(t *testing.T, quota int64) (*Manager, *artifact.FSStore) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.CacheEntry{}); err != nil {
+		t.Fatal(err)
+	}
+	store, err := artifact.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewManager(db, store, quota), store
+}
+
+// save 保存内容为 body 的缓存，并将创建与最近使用时间固定为 at（便于断言“最新”与 LRU 顺序）
+func save(t *testing.T, m *Manager, sc Scope, key, body string, at time.Time) *models.CacheEntry {
+	t.Helper()
+	e, err := m.Save(context.Background(), sc, 1, key, strings.NewReader(body), int64(len(body)), "")
+	if err != nil {
+		t.Fatalf("save %s/%s: %v", sc.Branch, key, err)
+	}
+	if err := m.db.Model(e).Updates(map[string]any{"created_at": at, "last_used_at": at}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestManagerLookup(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestManager(t, 1<<20)
+	base := time.Now().Add(-time.Hour)
+	feature := Scope{ProjectID: 1, PipelineID: 2, Branch: "feature", DefaultBranch: "develop"}
+	develop := Scope{ProjectID: 1, PipelineID: 2, Branch: "develop"}
+	save(t, m, develop, "go-old", "a", base)
+	save(t, m, develop, "go-new", "b", base.Add(time.Minute))
+	save(t, m, develop, "node_1", "c", base)
+	save(t, m, feature, "go-feature", "d", base)
+	save(t, m, Scope{ProjectID: 1, PipelineID: 3, Branch: "develop"}, "exact", "e", base)
+	save(t, m, Scope{ProjectID: 1, PipelineID: 2, Branch: "main"}, "main-only", "f", base)
+
+	cases := []struct {
+		name        string
+		sc          Scope
+		key         string
+		restoreKeys []string
+		want        string // 命中的 key，空表示未命中
+	}{
+		{"exact on default branch", feature, "go-new", nil, "go-new"},
+		{"current branch before default branch", feature, "miss", []string{"go-"}, "go-feature"},
+		{"newest prefix match on default branch", feature, "miss", []string{"nope-", "go-n", "go-"}, "go-new"},
+		{"restore keys in order", develop, "miss", []string{"go-o", "go-"}, "go-old"},
+		{"like wildcards escaped", feature, "miss", []string{"node%", "node_"}, "node_1"},
+		{"underscore is literal", feature, "miss", []string{"nodeX"}, ""},
+		{"scoped to pipeline", feature, "exact", nil, ""},
+		{"no fallback without default branch", Scope{ProjectID: 1, PipelineID: 2, Branch: "feature"}, "go-new", []string{"node"}, ""},
+		{"repository default branch, not main", feature, "main-only", nil, ""},
+		{"other project", Scope{ProjectID: 9, PipelineID: 2, Branch: "develop"}, "go-new", nil, ""},
+	}
+	for _, c := range cases {
+		e, err := m.Lookup(ctx, c.sc, c.key, c.restoreKeys)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		got := ""
+		if e != nil {
+			got = e.Key
+		}
+		if got != c.want {
+			t.Errorf("%s: hit %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestManagerSave(t *testing.T) {
+	ctx := context.Background()
+	m, store := newTestManager(t, 10)
+	sc := Scope{ProjectID: 1, PipelineID: 2, Branch: "main"}
+	base := time.Now().Add(-time.Hour)
+	a := save(t, m, sc, "a", "aaaa", base)
+	b := save(t, m, sc, "b", "bbbb", base.Add(time.Minute))
+	other := save(t, m, Scope{ProjectID: 2, PipelineID: 2, Branch: "main"}, "a", "xxxxxxxx", base)
+
+	// 恢复 a 后 b 成为最久未用的条目
+	rc, err := m.Open(ctx, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(rc); string(body) != "aaaa" {
+		t.Errorf("restored %q", body)
+	}
+	rc.Close()
+
+	if _, err := m.Save(ctx, sc, 2, "a", strings.NewReader("zz"), 2, ""); !errors.Is(err, ErrExists) {
+		t.Errorf("save existing key error = %v, want ErrExists", err)
+	}
+	if _, err := m.Save(ctx, sc, 2, "big", strings.NewReader(strings.Repeat("z", 11)), 11, ""); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("save over quota error = %v, want ErrTooLarge", err)
+	}
+	if _, err := m.Save(ctx, sc, 2, "c", strings.NewReader("cccc"), 4, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, total, err := m.List(ctx, Filter{ProjectID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, e := range entries {
+		keys = append(keys, e.Key)
+	}
+	if got := strings.Join(keys, ","); got != "c,a" || total != 8 {
+		t.Errorf("project caches = %s (%d bytes), want c,a (8 bytes) after evicting b", got, total)
+	}
+	if _, err := store.Get(ctx, b.StorageKey); !errors.Is(err, artifact.ErrNotFound) {
+		t.Errorf("evicted archive still stored: %v", err)
+	}
+	// 配额按项目计算，其它项目的条目不被淘汰
+	if _, total, _ := m.List(ctx, Filter{ProjectID: 2}); total != other.Size {
+		t.Errorf("other project total = %d, want %d", total, other.Size)
+	}
+
+	n, freed, err := m.Purge(ctx, Filter{ProjectID: 1, KeyPrefix: "c"})
+	if err != nil || n != 1 || freed != 4 {
+		t.Errorf("purge = %d, %d, %v; want 1 entry of 4 bytes", n, freed, err)
+	}
+}
@@ -14,6 +14,15 @@ type Config struct {
 	HTTP     HTTPConfig     `mapstructure:"http"`
 	Log      LogConfig      `mapstructure:"log"`
 	Queue    QueueConfig    `mapstructure:"queue"`
+	Lease    LeaseConfig    `mapstructure:"lease"`
+	Engine   EngineConfig   `mapstructure:"engine"`
+	Runner   RunnerConfig   `mapstructure:"runner"`
+	// CodeRepository 代码仓库服务地址（xcoding/checkout 获取克隆凭据），未配置地址时不支持检出动作
+	CodeRepository CodeRepositoryClientConfig `mapstructure:"code_repository"`
+	// Project 项目服务地址（缓存管理接口校验项目 owner/admin），未配置地址时仅超级管理员可管理缓存
+	Project   ProjectClientConfig `mapstructure:"project"`
+	Artifacts ArtifactConfig      `mapstructure:"artifacts"`
+	Cache     CacheConfig         `mapstructure:"cache"`
 }
 
 type DatabaseConfig struct {
@@ -37,9 +46,107 @@ type LogConfig struct {
 	Level  string `mapstructure:"level"`
 	Format string `mapstructure:"format"`
 }
+
+// QueueConfig 构建队列
+// - max_attempts：单条消息的最大投递次数（含首次），耗尽后进入死信队列 <queue>.dlq
+// - retry_backoff_seconds / max_retry_backoff_seconds：重试退避，第 n 次失败后延迟 base*2^(n-1) 秒，不超过上限
 type QueueConfig struct {
-	URL   string `mapstructure:"url"`
-	Queue string `mapstructure:"queue"`
+	URL                    string `mapstructure:"url"`
+	Queue                  string `mapstructure:"queue"`
+	MaxAttempts            int    `mapstructure:"max_attempts"`
+	RetryBackoffSeconds    int    `mapstructure:"retry_backoff_seconds"`
+	MaxRetryBackoffSeconds int    `mapstructure:"max_retry_backoff_seconds"`
+	MaxConcurrentBuilds    int    `mapstructure:"max_concurrent_builds"` // 单副本同时运行的构建上限（即 prefetch）
+}
+
+// LeaseConfig 构建租约（多副本部署）
+// - owner_id：副本标识，空值使用主机名（Pod 名）
+// - ttl_seconds：租约有效期，副本每 ttl/3 续约；超过有效期未续约的运行中构建由其它副本接管
+type LeaseConfig struct {
+	OwnerID    string `mapstructure:"owner_id"`
+	TTLSeconds int    `mapstructure:"ttl_seconds"`
+}
+
+type EngineConfig struct {
+	MaxParallelJobs int `mapstructure:"max_parallel_jobs"` // 单个构建同时运行的 Job 上限，0 表示不限
+}
+
+// RunnerConfig Job 执行后端
+// - backend：k8s（默认，集群内运行 Pod）或 local（本机 /bin/bash，便于开发调试）
+// - workspace：local 后端的临时工作目录根路径，空值使用系统临时目录
+// - image_pull_timeout_seconds：k8s 后端等待 runner 容器启动（调度与镜像拉取）的上限，0 表示不限
+// - classes_file：执行器类别（runs-on 标签 → Pod 模板）配置文件，其中的类别只读；另可经管理接口定义
+// - docker_actions：docker Action 的步骤容器（仅 k8s 后端）
+// - expr_image：提供 /app/xc-expr 的镜像（通常为执行器镜像），步骤表达式引用 steps 上下文的 Job 由 init 容器复制到 Pod（仅 k8s 后端）
+type RunnerConfig struct {
+	Backend                 string             `mapstructure:"backend"`
+	Workspace               string             `mapstructure:"workspace"`
+	ImagePullTimeoutSeconds int                `mapstructure:"image_pull_timeout_seconds"`
+	ClassesFile             string             `mapstructure:"classes_file"`
+	DockerActions           DockerActionConfig `mapstructure:"docker_actions"`
+	ExprImage               string             `mapstructure:"expr_image"`
+}
+
+// DockerActionConfig docker Action（runs.using: docker 与 uses: docker://）
+// - tools_image：提供静态 busybox 的镜像，复制到共享卷供步骤容器的包装脚本使用（Action 镜像可不含 Shell）
+// - builder_image：以 Dockerfile 声明镜像的 Action 在 Job Pod 的 init 容器中构建，使用 kaniko 镜像
+// - builder_args：传给 kaniko 的额外参数（空格分隔，如 --cache=true --insecure）
+// - registry：构建出的镜像推送到的仓库前缀（如 registry.example.com/ci-actions），镜像位于 <registry>/project-<项目 ID>/ 下；为空时不支持 Dockerfile 类 Action
+// - registry_secret：kubernetes.io/dockerconfigjson 类型的 Secret 名，用于推送与拉取构建出的镜像；须含 {project_id}（如 ci-actions-{project_id}），
+//   按项目选用仅能推送该项目路径的凭据（kaniko 执行 Dockerfile 时凭据可见），不含占位符时拒绝构建
+type DockerActionConfig struct {
+	ToolsImage     string `mapstructure:"tools_image"`
+	BuilderImage   string `mapstructure:"builder_image"`
+	BuilderArgs    string `mapstructure:"builder_args"`
+	Registry       string `mapstructure:"registry"`
+	RegistrySecret string `mapstructure:"registry_secret"`
+}
+
+// CodeRepositoryClientConfig 代码仓库服务 gRPC 地址
+// - service_token：服务间共享令牌（INTERNAL_SERVICE_TOKEN），code_repository 的内部接口（检出凭据、默认分支）须携带
+type CodeRepositoryClientConfig struct {
+	Address      string `mapstructure:"address"`
+	Port         int    `mapstructure:"port"`
+	ServiceToken string `mapstructure:"service_token"`
+}
+
+// ProjectClientConfig 项目服务 gRPC 地址
+type ProjectClientConfig struct {
+	Address string `mapstructure:"address"`
+	Port    int    `mapstructure:"port"`
+}
+
+// ArtifactConfig 构建制品存储（xcoding/upload-artifact、xcoding/download-artifact）
+// - backend：fs（本地目录）或 s3（S3 兼容对象存储）；为空时不启用制品，使用制品动作的 Job 失败
+// - dir：fs 后端的存储目录
+// - url：runner 访问执行器 HTTP 服务的地址（如 http://ci-executor:10056），空值使用本机 HTTP 地址（适用于 local 后端）
+// - token_secret：签发制品令牌的密钥，多副本部署需一致；启用制品存储时必填，为空时拒绝启动
+// - max_size_mb：单个制品的大小上限
+type ArtifactConfig struct {
+	Backend     string   `mapstructure:"backend"`
+	Dir         string   `mapstructure:"dir"`
+	URL         string   `mapstructure:"url"`
+	TokenSecret string   `mapstructure:"token_secret"`
+	MaxSizeMB   int      `mapstructure:"max_size_mb"`
+	S3          S3Config `mapstructure:"s3"`
+}
+
+// S3Config S3 兼容对象存储；path_style 为 true 时以 <endpoint>/<bucket>/<key> 访问（MinIO 等）
+type S3Config struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	Prefix    string `mapstructure:"prefix"`
+	PathStyle bool   `mapstructure:"path_style"`
+}
+
+// CacheConfig 依赖缓存（xcoding/cache），归档存放在制品存储中
+// - quota_mb：每个项目的缓存总量上限，超出时淘汰最久未使用的条目
+// 当前分支未命中时回退查找项目仓库的默认分支（经 code_repository 服务获取）
+type CacheConfig struct {
+	QuotaMB int `mapstructure:"quota_mb"`
 }
 
 func (c *Config) GRPCAddr() string               { return fmt.Sprintf("%s:%d", c.GRPC.Address, c.GRPC.Port) }
@@ -68,6 +175,54 @@ func Load() (*Config, error) {
 
 	viper.BindEnv("queue.url", "RABBITMQ_URL")
 	viper.BindEnv("queue.queue", "RABBITMQ_QUEUE")
+	viper.SetDefault("queue.max_attempts", 3)
+	viper.SetDefault("queue.retry_backoff_seconds", 10)
+	viper.SetDefault("queue.max_retry_backoff_seconds", 300)
+	viper.BindEnv("queue.max_attempts", "EXECUTOR_QUEUE_MAX_ATTEMPTS")
+	viper.BindEnv("queue.retry_backoff_seconds", "EXECUTOR_QUEUE_RETRY_BACKOFF_SECONDS")
+	viper.BindEnv("queue.max_retry_backoff_seconds", "EXECUTOR_QUEUE_MAX_RETRY_BACKOFF_SECONDS")
+	viper.SetDefault("queue.max_concurrent_builds", 1)
+	viper.BindEnv("queue.max_concurrent_builds", "EXECUTOR_MAX_CONCURRENT_BUILDS")
+	viper.SetDefault("lease.ttl_seconds", 60)
+	viper.BindEnv("lease.owner_id", "EXECUTOR_ID")
+	viper.BindEnv("lease.ttl_seconds", "EXECUTOR_LEASE_TTL_SECONDS")
+
+	viper.BindEnv("engine.max_parallel_jobs", "EXECUTOR_MAX_PARALLEL_JOBS")
+	viper.BindEnv("runner.backend", "EXECUTOR_RUNNER_BACKEND")
+	viper.BindEnv("runner.workspace", "EXECUTOR_RUNNER_WORKSPACE")
+	viper.SetDefault("runner.image_pull_timeout_seconds", 300)
+	viper.BindEnv("runner.image_pull_timeout_seconds", "EXECUTOR_RUNNER_IMAGE_PULL_TIMEOUT_SECONDS")
+	viper.BindEnv("runner.classes_file", "EXECUTOR_RUNNER_CLASSES_FILE")
+	viper.BindEnv("runner.expr_image", "EXECUTOR_RUNNER_EXPR_IMAGE")
+	viper.SetDefault("runner.docker_actions.tools_image", "busybox:1.36-musl")
+	viper.SetDefault("runner.docker_actions.builder_image", "gcr.io/kaniko-project/executor:v1.23.2")
+	viper.BindEnv("runner.docker_actions.tools_image", "EXECUTOR_DOCKER_ACTION_TOOLS_IMAGE")
+	viper.BindEnv("runner.docker_actions.builder_image", "EXECUTOR_DOCKER_ACTION_BUILDER_IMAGE")
+	viper.BindEnv("runner.docker_actions.builder_args", "EXECUTOR_DOCKER_ACTION_BUILDER_ARGS")
+	viper.BindEnv("runner.docker_actions.registry", "EXECUTOR_DOCKER_ACTION_REGISTRY")
+	viper.BindEnv("runner.docker_actions.registry_secret", "EXECUTOR_DOCKER_ACTION_REGISTRY_SECRET")
+	viper.BindEnv("code_repository.address", "CODE_REPOSITORY_GRPC_ADDRESS")
+	viper.BindEnv("code_repository.port", "CODE_REPOSITORY_GRPC_PORT")
+	viper.BindEnv("code_repository.service_token", "INTERNAL_SERVICE_TOKEN")
+	viper.BindEnv("project.address", "PROJECT_GRPC_ADDRESS")
+	viper.BindEnv("project.port", "PROJECT_GRPC_PORT")
+
+	viper.BindEnv("artifacts.backend", "EXECUTOR_ARTIFACT_BACKEND")
+	viper.BindEnv("artifacts.dir", "EXECUTOR_ARTIFACT_DIR")
+	viper.BindEnv("artifacts.url", "EXECUTOR_ARTIFACT_URL")
+	viper.BindEnv("artifacts.token_secret", "EXECUTOR_ARTIFACT_TOKEN_SECRET")
+	viper.SetDefault("artifacts.max_size_mb", 1024)
+	viper.BindEnv("artifacts.max_size_mb", "EXECUTOR_ARTIFACT_MAX_SIZE_MB")
+	viper.SetDefault("artifacts.s3.region", "us-east-1")
+	viper.BindEnv("artifacts.s3.endpoint", "EXECUTOR_ARTIFACT_S3_ENDPOINT")
+	viper.BindEnv("artifacts.s3.region", "EXECUTOR_ARTIFACT_S3_REGION")
+	viper.BindEnv("artifacts.s3.bucket", "EXECUTOR_ARTIFACT_S3_BUCKET")
+	viper.BindEnv("artifacts.s3.access_key", "EXECUTOR_ARTIFACT_S3_ACCESS_KEY")
+	viper.BindEnv("artifacts.s3.secret_key", "EXECUTOR_ARTIFACT_S3_SECRET_KEY")
+	viper.BindEnv("artifacts.s3.prefix", "EXECUTOR_ARTIFACT_S3_PREFIX")
+	viper.BindEnv("artifacts.s3.path_style", "EXECUTOR_ARTIFACT_S3_PATH_STYLE")
+	viper.SetDefault("cache.quota_mb", 10240)
+	viper.BindEnv("cache.quota_mb", "EXECUTOR_CACHE_QUOTA_MB")
 
 	var cfg Config
 	if err := viper.Unmarshal(&cfg); err != nil {
@@ -0,0 +1,29 @@
+package consumer
+
+import (
+	"context"
+	"time"
+	"xcoding/apps/ci/executor_service/internal/executor"
+	"xcoding/apps/ci/executor_service/models"
+	civ1 "xcoding/gen/go/ci/v1"
+)
+
+// CancelBuild 取消未结束的构建，返回构建是否由本次调用取消（已结束时为 false）
+// - 构建以条件更新记为 CANCELLED：仍在队列中的构建出队时直接确认跳过
+// - 本副本运行中的构建以 executor.ErrCancelled 中止引擎：终止运行中的 Job，未结束的 Job 与步骤记为 cancelled
+// - 其它副本运行中的构建由其续约循环发现后同样中止
+func (c *QueueConsumer) CancelBuild(ctx context.Context, buildID uint64) (bool, error) {
+	now := time.Now()
+	res := c.db.WithContext(ctx).Model(&models.Build{}).Where("id = ? AND status NOT IN ?", buildID, terminalStatuses).
+		Updates(map[string]any{"status": int32(civ1.BuildStatus_BUILD_STATUS_CANCELLED), "finished_at": &now})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	c.mu.Lock()
+	cancel := c.active[buildID]
+	c.mu.Unlock()
+	if cancel != nil {
+		cancel(executor.ErrCancelled)
+	}
+	return res.RowsAffected == 1, nil
+}
@@ -0,0 +1,90 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	"xcoding/apps/ci/executor_service/buildqueue"
+	"xcoding/apps/ci/executor_service/internal/executor"
+	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
+	civ1 "xcoding/gen/go/ci/v1"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// errConcurrencyHeld 构建的并发组被其它构建或 Job 占用
+var errConcurrencyHeld = errors.New("concurrency group is held by another build")
+
+// holdDelay 构建因并发组被占用而等待时，重新出队的间隔
+const holdDelay = 15 * time.Second
+
+// acquireConcurrency 出队时求值工作流级 concurrency.group 并占用并发组（同一项目内同组构建互斥）
+// - 组名写入 Build.ConcurrencyGroup；表达式求值失败时构建判定失败
+// - cancel-in-progress：先取消同组较早的未结束构建（含排队等待的构建），再占用并发组
+// - 并发组被占用时返回 errConcurrencyHeld，构建保持 QUEUED 等待
+func (c *QueueConsumer) acquireConcurrency(ctx context.Context, buildID uint64, wf *parser.Workflow) error {
+	if wf.Concurrency == nil {
+		return nil
+	}
+	var b models.Build
+	if err := c.db.First(&b, buildID).Error; err != nil {
+		return fmt.Errorf("load build: %w", err)
+	}
+	group, err := executor.WorkflowConcurrencyGroup(c.db, &b, wf)
+	if err != nil {
+		now := time.Now()
+		_ = c.db.Model(&models.Build{}).Where("id = ? AND status NOT IN ?", buildID, terminalStatuses).
+			Updates(map[string]any{"status": int32(civ1.BuildStatus_BUILD_STATUS_FAILED), "finished_at": &now}).Error
+		return fmt.Errorf("evaluate concurrency.group: %w", err)
+	}
+	if group == "" {
+		return nil
+	}
+	_ = c.db.Model(&models.Build{}).Where("id = ?", buildID).Update("concurrency_group", group).Error
+	if wf.Concurrency.CancelInProgress {
+		c.cancelInProgress(ctx, &b, group)
+	}
+	ok, err := executor.AcquireConcurrency(c.db, executor.ConcurrencyLockName(b.ProjectID, group), buildID, "")
+	if err != nil {
+		return fmt.Errorf("acquire concurrency group %q: %w", group, err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %q", errConcurrencyHeld, group)
+	}
+	return nil
+}
+
+// cancelInProgress concurrency.cancel-in-progress：取消同一项目同一并发组中较早的未结束构建
+func (c *QueueConsumer) cancelInProgress(ctx context.Context, b *models.Build, group string) {
+	var ids []uint64
+	if err := c.db.Model(&models.Build{}).
+		Where("project_id = ? AND concurrency_group = ? AND id < ? AND status NOT IN ?", b.ProjectID, group, b.ID, terminalStatuses).
+		Pluck("id", &ids).Error; err != nil {
+		log.Printf("executor: build %d: list concurrency group %q: %v", b.ID, group, err)
+		return
+	}
+	for _, id := range ids {
+		if ok, err := c.CancelBuild(ctx, id); err != nil {
+			log.Printf("executor: build %d: cancel build %d in concurrency group %q: %v", b.ID, id, group, err)
+		} else if ok {
+			log.Printf("executor: build %d: cancelled build %d in concurrency group %q", b.ID, id, group)
+		}
+	}
+}
+
+// hold 将等待并发组的构建投递到等待队列，holdDelay 后重新出队；不计入失败次数
+func (c *QueueConsumer) hold(ctx context.Context, m amqp.Delivery, buildID uint64, cause error) {
+	p := republish(m, nil)
+	p.Expiration = strconv.FormatInt(holdDelay.Milliseconds(), 10)
+	if err := c.publish(ctx, buildqueue.HoldQueue(c.queue), p); err != nil {
+		log.Printf("executor: build %d: hold: %v", buildID, err)
+		_ = m.Nack(false, true)
+		return
+	}
+	_ = m.Ack(false)
+	log.Printf("executor: build %d: %v, waiting %s", buildID, cause, holdDelay)
+}
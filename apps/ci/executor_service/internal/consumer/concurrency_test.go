@@ -0,0 +1,85 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"xcoding/apps/ci/executor_service/buildqueue"
+	"xcoding/apps/ci/executor_service/internal/executor"
+	"xcoding/apps/ci/executor_service/models"
+	civ1 "xcoding/gen/go/ci/v1"
+)
+
+const groupedWorkflow = `concurrency:
+  group: deploy-${{ github.ref_name }}
+jobs:
+  build:
+    steps:
+      - run: echo hello
+`
+
+func TestConcurrencyHold(t *testing.T) {
+	ctx := context.Background()
+	c, pub := newTestConsumer(t)
+	running, queued := int32(civ1.BuildStatus_BUILD_STATUS_RUNNING), int32(civ1.BuildStatus_BUILD_STATUS_QUEUED)
+	createBuild(t, c.db, models.Build{ID: 1, ProjectID: 7, Branch: "main", Status: running, ConcurrencyGroup: "deploy-main"}, groupedWorkflow)
+	if ok, err := executor.AcquireConcurrency(c.db, executor.ConcurrencyLockName(7, "deploy-main"), 1, ""); err != nil || !ok {
+		t.Fatalf("acquire for build 1: %v, %v", ok, err)
+	}
+	// 其它项目的同名并发组互不影响
+	createBuild(t, c.db, models.Build{ID: 2, ProjectID: 8, Branch: "main", Status: queued}, groupedWorkflow)
+	createBuild(t, c.db, models.Build{ID: 3, ProjectID: 7, Branch: "main", Status: queued}, groupedWorkflow)
+
+	d, ack := delivery(t, 3, 1)
+	c.process(ctx, d)
+	sent := pub.take()
+	if ack.acks != 1 || len(sent) != 1 || sent[0].queue != "ci_builds.hold" || sent[0].p.Expiration != "15000" {
+		t.Fatalf("held build: acks %d, published %+v; want ci_builds.hold", ack.acks, sent)
+	}
+	if n := buildqueue.Attempt(sent[0].p.Headers); n != 1 {
+		t.Errorf("attempt header = %d, want unchanged 1", n)
+	}
+	if b := loadBuild(t, c.db, 3); b.Status != queued || b.LeaseOwner != "" || b.ConcurrencyGroup != "deploy-main" {
+		t.Errorf("held build = status %d, lease %q, group %q", b.Status, b.LeaseOwner, b.ConcurrencyGroup)
+	}
+
+	d, ack = delivery(t, 2, 0)
+	c.process(ctx, d)
+	if sent := pub.take(); ack.acks != 1 || len(sent) != 0 {
+		t.Errorf("build in another project: acks %d, published %+v", ack.acks, sent)
+	}
+	if b := loadBuild(t, c.db, 2); b.Status != int32(civ1.BuildStatus_BUILD_STATUS_SUCCEEDED) {
+		t.Errorf("build 2 status = %d, want SUCCEEDED", b.Status)
+	}
+}
+
+func TestConcurrencyCancelInProgress(t *testing.T) {
+	ctx := context.Background()
+	c, pub := newTestConsumer(t)
+	wf := "concurrency:\n  group: deploy\n  cancel-in-progress: true\n" + testWorkflow
+	running, queued := int32(civ1.BuildStatus_BUILD_STATUS_RUNNING), int32(civ1.BuildStatus_BUILD_STATUS_QUEUED)
+	createBuild(t, c.db, models.Build{ID: 1, ProjectID: 7, Status: running, ConcurrencyGroup: "deploy"}, wf)
+	createBuild(t, c.db, models.Build{ID: 2, ProjectID: 7, Status: queued, ConcurrencyGroup: "deploy"}, wf)
+	createBuild(t, c.db, models.Build{ID: 3, ProjectID: 8, Status: queued, ConcurrencyGroup: "deploy"}, wf)
+	createBuild(t, c.db, models.Build{ID: 4, ProjectID: 7, Status: queued}, wf)
+	if ok, err := executor.AcquireConcurrency(c.db, executor.ConcurrencyLockName(7, "deploy"), 1, ""); err != nil || !ok {
+		t.Fatalf("acquire for build 1: %v, %v", ok, err)
+	}
+	var cause error
+	c.active[1] = func(err error) { cause = err }
+
+	d, ack := delivery(t, 4, 0)
+	c.process(ctx, d)
+	if sent := pub.take(); ack.acks != 1 || len(sent) != 0 {
+		t.Errorf("build 4: acks %d, published %+v", ack.acks, sent)
+	}
+	cancelled := int32(civ1.BuildStatus_BUILD_STATUS_CANCELLED)
+	for id, want := range map[uint64]int32{1: cancelled, 2: cancelled, 3: queued, 4: int32(civ1.BuildStatus_BUILD_STATUS_SUCCEEDED)} {
+		if b := loadBuild(t, c.db, id); b.Status != want {
+			t.Errorf("build %d status = %d, want %d", id, b.Status, want)
+		}
+	}
+	if !errors.Is(cause, executor.ErrCancelled) {
+		t.Errorf("running build stopped with %v, want ErrCancelled", cause)
+	}
+}
@@ -0,0 +1,175 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"xcoding/apps/ci/executor_service/buildqueue"
+	"xcoding/apps/ci/executor_service/models"
+	civ1 "xcoding/gen/go/ci/v1"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// DeadLetters 查看死信队列前 limit 条消息（不移除），同时返回队列中的消息总数
+// 使用独立通道 basic.get 且不确认，关闭通道后消息按原顺序回到队列
+func (c *QueueConsumer) DeadLetters(ctx context.Context, limit int) ([]*civ1.DeadLetterBuild, int, error) {
+	ch, err := c.adminChannel()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer ch.Close()
+	dlq := buildqueue.DeadLetterQueue(c.queue)
+	q, err := ch.QueueDeclarePassive(dlq, true, false, false, false, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("inspect %s: %w", dlq, err)
+	}
+	var out []*civ1.DeadLetterBuild
+	for len(out) < limit {
+		d, ok, err := ch.Get(dlq, false)
+		if err != nil {
+			return nil, 0, fmt.Errorf("get %s: %w", dlq, err)
+		}
+		if !ok {
+			break
+		}
+		out = append(out, deadLetterProto(d))
+	}
+	return out, q.Messages, nil
+}
+
+// RequeueDeadLetters 将死信队列中指定构建（all 为 true 时为全部可解析的消息）重新投递到主队列
+// 重新入队前将构建重置为 PENDING 并清除上次执行的记录（见 resetBuild）；重新投递的消息升级为当前版本，失败计数清零
+// 未选中或无法解析的消息保留在死信队列中
+func (c *QueueConsumer) RequeueDeadLetters(ctx context.Context, buildIDs []uint64, all bool) ([]uint64, error) {
+	ch, err := c.adminChannel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+	dlq := buildqueue.DeadLetterQueue(c.queue)
+	q, err := ch.QueueDeclarePassive(dlq, true, false, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("inspect %s: %w", dlq, err)
+	}
+	want := make(map[uint64]bool, len(buildIDs))
+	for _, id := range buildIDs {
+		want[id] = true
+	}
+	done := map[uint64]bool{}
+	var requeued []uint64
+	for i := 0; i < q.Messages; i++ {
+		d, ok, err := ch.Get(dlq, false)
+		if err != nil {
+			return requeued, fmt.Errorf("get %s: %w", dlq, err)
+		}
+		if !ok {
+			break
+		}
+		msg, err := buildqueue.Decode(d.ContentType, d.Body)
+		if err != nil || !(all || want[msg.BuildID]) {
+			continue
+		}
+		// 同一构建的重复死信只重新投递一次
+		if !done[msg.BuildID] {
+			if err := c.resetBuild(ctx, msg.BuildID); err != nil {
+				return requeued, fmt.Errorf("reset build %d: %w", msg.BuildID, err)
+			}
+			msg.Version, msg.EnqueuedAt = 0, time.Time{}
+			p, err := buildqueue.Publishing(*msg)
+			if err != nil {
+				return requeued, err
+			}
+			if err := c.publish(ctx, c.queue, p); err != nil {
+				return requeued, err
+			}
+			done[msg.BuildID] = true
+			requeued = append(requeued, msg.BuildID)
+		}
+		if err := d.Ack(false); err != nil {
+			return requeued, fmt.Errorf("ack dead letter: %w", err)
+		}
+	}
+	return requeued, nil
+}
+
+func (c *QueueConsumer) adminChannel() (*amqp.Channel, error) {
+	if c.conn == nil || c.conn.IsClosed() {
+		return nil, fmt.Errorf("rabbitmq connection not available")
+	}
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("open channel: %w", err)
+	}
+	return ch, nil
+}
+
+// resetBuild 将构建重置为 PENDING，清除上次执行留下的 Job、依赖边、Step、步骤与服务日志、制品、并发组与租约
+// 制品对象在记录删除提交后尽力删除，失败时仅记录日志
+func (c *QueueConsumer) resetBuild(ctx context.Context, buildID uint64) error {
+	var keys []string
+	err := c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		steps := tx.Model(&models.BuildStep{}).Select("id").Where("build_id = ?", buildID)
+		if err := tx.Where("build_step_id IN (?)", steps).Delete(&models.BuildStepLogChunk{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.BuildArtifact{}).Where("build_id = ?", buildID).Pluck("storage_key", &keys).Error; err != nil {
+			return err
+		}
+		for _, m := range []any{&models.BuildStep{}, &models.BuildJobEdge{}, &models.BuildJob{}, &models.BuildServiceLogChunk{}, &models.BuildArtifact{}, &models.ConcurrencyLock{}} {
+			if err := tx.Where("build_id = ?", buildID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Build{}).Where("id = ?", buildID).Updates(map[string]any{
+			"status":             int32(civ1.BuildStatus_BUILD_STATUS_PENDING),
+			"started_at":         nil,
+			"finished_at":        nil,
+			"concurrency_group":  "",
+			"lease_owner":        "",
+			"lease_heartbeat_at": nil,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if c.store != nil {
+		for _, k := range keys {
+			if err := c.store.Delete(ctx, k); err != nil {
+				log.Printf("executor: build %d: delete artifact %s: %v", buildID, k, err)
+			}
+		}
+	}
+	return nil
+}
+
+// deadLetterProto 转换死信消息；无法解析的消息仅保留原始内容与失败信息
+func deadLetterProto(d amqp.Delivery) *civ1.DeadLetterBuild {
+	pb := &civ1.DeadLetterBuild{
+		Attempts: int32(buildqueue.Attempt(d.Headers)),
+		Body:     string(d.Body),
+	}
+	if s, ok := d.Headers[buildqueue.HeaderError].(string); ok {
+		pb.Error = s
+	}
+	if s, ok := d.Headers[buildqueue.HeaderDeadLetteredAt].(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			pb.DeadLetteredAt = timestamppb.New(t)
+		}
+	}
+	if msg, err := buildqueue.Decode(d.ContentType, d.Body); err == nil {
+		pb.BuildId = msg.BuildID
+		pb.PipelineId = msg.PipelineID
+		pb.ProjectId = msg.ProjectID
+		pb.CommitSha = msg.CommitSHA
+		pb.Branch = msg.Branch
+		pb.Version = int32(msg.Version)
+		if !msg.EnqueuedAt.IsZero() {
+			pb.EnqueuedAt = timestamppb.New(msg.EnqueuedAt)
+		}
+	}
+	return pb
+}
@@ -0,0 +1,90 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"xcoding/apps/ci/executor_service/internal/artifact"
+	"xcoding/apps/ci/executor_service/models"
+	civ1 "xcoding/gen/go/ci/v1"
+)
+
+func TestResetBuild(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestConsumer(t)
+	store, err := artifact.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetArtifactStore(store)
+	now := time.Now()
+	for _, id := range []uint64{1, 2} {
+		createBuild(t, c.db, models.Build{ID: id, Status: int32(civ1.BuildStatus_BUILD_STATUS_FAILED), StartedAt: &now, FinishedAt: &now,
+			ConcurrencyGroup: "deploy", LeaseOwner: "me", LeaseHeartbeatAt: &now}, testWorkflow)
+		step := models.BuildStep{BuildID: id, JobName: "build", Index: 1, Name: "s", Status: "failed"}
+		key := fmt.Sprintf("builds/%d/dist.tar.gz", id)
+		for _, row := range []any{
+			&models.BuildJob{BuildID: id, Name: "build", Status: "failed", Index: 1},
+			&models.BuildJobEdge{BuildID: id, FromJob: "a", ToJob: "build"},
+			&step,
+			&models.BuildServiceLogChunk{BuildID: id, JobName: "build", Service: "db", Content: "ready"},
+			&models.BuildArtifact{BuildID: id, Name: "dist", StorageKey: key},
+			&models.ConcurrencyLock{Name: fmt.Sprintf("7/deploy-%d", id), BuildID: id},
+		} {
+			if err := c.db.Create(row).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := c.db.Create(&models.BuildStepLogChunk{BuildStepID: step.ID, Content: "boom"}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.resetBuild(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	b := loadBuild(t, c.db, 1)
+	if b.Status != int32(civ1.BuildStatus_BUILD_STATUS_PENDING) || b.StartedAt != nil || b.FinishedAt != nil || b.ConcurrencyGroup != "" || b.LeaseOwner != "" || b.LeaseHeartbeatAt != nil {
+		t.Errorf("build 1 after reset = %+v", b)
+	}
+	counts := func(id uint64) map[string]int64 {
+		out := map[string]int64{}
+		steps := c.db.Model(&models.BuildStep{}).Select("id").Where("build_id = ?", id)
+		var n int64
+		c.db.Model(&models.BuildStepLogChunk{}).Where("build_step_id IN (?)", steps).Count(&n)
+		out["step logs"] = n
+		for name, m := range map[string]any{"jobs": &models.BuildJob{}, "edges": &models.BuildJobEdge{}, "steps": &models.BuildStep{},
+			"service logs": &models.BuildServiceLogChunk{}, "artifacts": &models.BuildArtifact{}, "locks": &models.ConcurrencyLock{}} {
+			c.db.Model(m).Where("build_id = ?", id).Count(&n)
+			out[name] = n
+		}
+		return out
+	}
+	for name, n := range counts(1) {
+		if n != 0 {
+			t.Errorf("build 1 %s = %d after reset, want 0", name, n)
+		}
+	}
+	for name, n := range counts(2) {
+		if n != 1 {
+			t.Errorf("build 2 %s = %d, want untouched", name, n)
+		}
+	}
+	if _, err := store.Get(ctx, "builds/1/dist.tar.gz"); !errors.Is(err, artifact.ErrNotFound) {
+		t.Errorf("artifact object of build 1: %v, want deleted", err)
+	}
+	if r, err := store.Get(ctx, "builds/2/dist.tar.gz"); err != nil {
+		t.Errorf("artifact object of build 2: %v", err)
+	} else {
+		_ = r.Close()
+	}
+	if snap := (models.BuildSnapshot{}); c.db.Where("build_id = ?", 1).First(&snap).Error != nil {
+		t.Error("snapshot deleted by reset")
+	}
+}
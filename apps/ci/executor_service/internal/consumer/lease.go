@@ -0,0 +1,166 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+	"xcoding/apps/ci/executor_service/internal/executor"
+	"xcoding/apps/ci/executor_service/models"
+	civ1 "xcoding/gen/go/ci/v1"
+)
+
+// LeaseOptions 构建租约与副本并发
+// - Owner：本副本标识，写入 Build.LeaseOwner
+// - TTL：租约有效期；本副本每 TTL/3 为运行中的构建续约，并接管租约过期（持有者失联）的运行中构建
+// - MaxConcurrentBuilds：本副本同时运行的构建上限（含接管的构建），同时作为 RabbitMQ prefetch
+type LeaseOptions struct {
+	Owner               string
+	TTL                 time.Duration
+	MaxConcurrentBuilds int
+}
+
+// claim 以条件更新抢占构建租约：构建未结束，且无持有者、持有者为本副本或租约已过期
+func (c *QueueConsumer) claim(buildID uint64) (bool, error) {
+	now := time.Now()
+	res := c.db.Model(&models.Build{}).
+		Where("id = ? AND status NOT IN ?", buildID, terminalStatuses).
+		Where("lease_owner = '' OR lease_owner IS NULL OR lease_owner = ? OR lease_heartbeat_at IS NULL OR lease_heartbeat_at < ?", c.lease.Owner, now.Add(-c.lease.TTL)).
+		Updates(map[string]any{"lease_owner": c.lease.Owner, "lease_heartbeat_at": &now})
+	return res.RowsAffected == 1, res.Error
+}
+
+// release 释放未结束构建的租约与并发组并退回排队状态（消息转入重试或等待队列时调用，避免被当作失联构建接管）
+func (c *QueueConsumer) release(buildID uint64) {
+	_ = c.db.Model(&models.Build{}).
+		Where("id = ? AND lease_owner = ? AND status NOT IN ?", buildID, c.lease.Owner, terminalStatuses).
+		Updates(map[string]any{"lease_owner": "", "status": int32(civ1.BuildStatus_BUILD_STATUS_QUEUED)}).Error
+	_ = executor.ReleaseConcurrency(c.db, buildID, "")
+}
+
+// reserve 登记本副本即将运行的构建；已登记时返回 false
+func (c *QueueConsumer) reserve(buildID uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.active[buildID]; ok {
+		return false
+	}
+	c.active[buildID] = nil
+	return true
+}
+
+func (c *QueueConsumer) unreserve(buildID uint64) {
+	c.mu.Lock()
+	delete(c.active, buildID)
+	c.mu.Unlock()
+}
+
+// runBuild 在本副本的租约下运行构建；resume 为 true 时按落库状态恢复（执行器中断或接管失联副本的构建）
+// 租约被其它副本接管时以 executor.ErrDetached 中止，返回该错误
+func (c *QueueConsumer) runBuild(ctx context.Context, buildID uint64, resume bool) error {
+	bctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	c.mu.Lock()
+	c.active[buildID] = cancel
+	c.mu.Unlock()
+	defer c.unreserve(buildID)
+	if resume {
+		return c.resumeBuild(bctx, buildID)
+	}
+	return c.handleBuild(bctx, buildID)
+}
+
+// leaseLoop 周期性续约与接管，直至 ctx 结束
+func (c *QueueConsumer) leaseLoop(ctx context.Context) {
+	t := time.NewTicker(c.lease.TTL / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			c.heartbeat()
+			c.reclaim(ctx)
+		}
+	}
+}
+
+// heartbeat 为本副本运行中的构建续约；已被取消的构建中止，租约已被其它副本接管的构建从本副本脱离
+func (c *QueueConsumer) heartbeat() {
+	c.mu.Lock()
+	ids := make([]uint64, 0, len(c.active))
+	for id := range c.active {
+		ids = append(ids, id)
+	}
+	c.mu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+	now := time.Now()
+	if err := c.db.Model(&models.Build{}).Where("id IN ? AND lease_owner = ?", ids, c.lease.Owner).
+		Updates(map[string]any{"lease_heartbeat_at": &now}).Error; err != nil {
+		log.Printf("executor: lease heartbeat: %v", err)
+		return
+	}
+	var owned []uint64
+	if err := c.db.Model(&models.Build{}).Where("id IN ? AND lease_owner = ?", ids, c.lease.Owner).Pluck("id", &owned).Error; err != nil {
+		return
+	}
+	// 其它副本经取消接口或 cancel-in-progress 取消的构建
+	var cancelled []uint64
+	_ = c.db.Model(&models.Build{}).Where("id IN ? AND status = ?", ids, int32(civ1.BuildStatus_BUILD_STATUS_CANCELLED)).Pluck("id", &cancelled).Error
+	held := make(map[uint64]bool, len(owned))
+	for _, id := range owned {
+		held[id] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range cancelled {
+		if cancel := c.active[id]; cancel != nil {
+			log.Printf("executor: build %d: cancelled, stopping", id)
+			cancel(executor.ErrCancelled)
+		}
+	}
+	for _, id := range ids {
+		if cancel := c.active[id]; cancel != nil && !held[id] {
+			log.Printf("executor: build %d: lease taken over by another executor, detaching", id)
+			cancel(executor.ErrDetached)
+		}
+	}
+}
+
+// reclaim 接管无持有者、持有者为本副本（重启前的自身）或租约过期的运行中构建：重新附着仍在运行的 Job 并继续 DAG
+// 本副本并发已满时留待下次
+func (c *QueueConsumer) reclaim(ctx context.Context) {
+	var ids []uint64
+	if err := c.db.Model(&models.Build{}).
+		Where("status = ?", int32(civ1.BuildStatus_BUILD_STATUS_RUNNING)).
+		Where("lease_owner = '' OR lease_owner IS NULL OR lease_owner = ? OR lease_heartbeat_at IS NULL OR lease_heartbeat_at < ?", c.lease.Owner, time.Now().Add(-c.lease.TTL)).
+		Order("id").Pluck("id", &ids).Error; err != nil {
+		log.Printf("executor: reclaim builds: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if !c.reserve(id) {
+			continue
+		}
+		select {
+		case c.slots <- struct{}{}:
+		default:
+			c.unreserve(id)
+			return
+		}
+		if ok, err := c.claim(id); err != nil || !ok {
+			<-c.slots
+			c.unreserve(id)
+			continue
+		}
+		go func(buildID uint64) {
+			defer func() { <-c.slots }()
+			log.Printf("executor: build %d: resuming interrupted build", buildID)
+			if err := c.runBuild(ctx, buildID, true); err != nil && !errors.Is(err, executor.ErrDetached) {
+				log.Printf("executor: build %d: resume: %v", buildID, err)
+			}
+		}(id)
+	}
+}
@@ -0,0 +1,97 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"xcoding/apps/ci/executor_service/internal/executor"
+	"xcoding/apps/ci/executor_service/models"
+	civ1 "xcoding/gen/go/ci/v1"
+)
+
+func TestClaim(t *testing.T) {
+	c, _ := newTestConsumer(t)
+	queued := int32(civ1.BuildStatus_BUILD_STATUS_QUEUED)
+	fresh, stale := time.Now(), time.Now().Add(-2*time.Minute)
+	createBuild(t, c.db, models.Build{ID: 1, Status: queued}, "")
+	createBuild(t, c.db, models.Build{ID: 2, Status: queued, LeaseOwner: "other", LeaseHeartbeatAt: &fresh}, "")
+	createBuild(t, c.db, models.Build{ID: 3, Status: queued, LeaseOwner: "other", LeaseHeartbeatAt: &stale}, "")
+	createBuild(t, c.db, models.Build{ID: 4, Status: queued, LeaseOwner: "me", LeaseHeartbeatAt: &fresh}, "")
+	createBuild(t, c.db, models.Build{ID: 5, Status: int32(civ1.BuildStatus_BUILD_STATUS_FAILED)}, "")
+	for id, want := range map[uint64]bool{1: true, 2: false, 3: true, 4: true, 5: false} {
+		ok, err := c.claim(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Errorf("claim(%d) = %v, want %v", id, ok, want)
+		}
+		if b := loadBuild(t, c.db, id); ok && (b.LeaseOwner != "me" || b.LeaseHeartbeatAt == nil || b.LeaseHeartbeatAt.Before(fresh)) {
+			t.Errorf("build %d lease = %q at %v after claim", id, b.LeaseOwner, b.LeaseHeartbeatAt)
+		}
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	c, _ := newTestConsumer(t)
+	running := int32(civ1.BuildStatus_BUILD_STATUS_RUNNING)
+	old := time.Now().Add(-30 * time.Second)
+	createBuild(t, c.db, models.Build{ID: 1, Status: running, LeaseOwner: "me", LeaseHeartbeatAt: &old}, "")
+	createBuild(t, c.db, models.Build{ID: 2, Status: running, LeaseOwner: "other", LeaseHeartbeatAt: &old}, "")
+	createBuild(t, c.db, models.Build{ID: 3, Status: int32(civ1.BuildStatus_BUILD_STATUS_CANCELLED), LeaseOwner: "me", LeaseHeartbeatAt: &old}, "")
+	causes := map[uint64]error{}
+	for _, id := range []uint64{1, 2, 3} {
+		id := id
+		c.active[id] = func(cause error) { causes[id] = cause }
+	}
+	c.heartbeat()
+	if b := loadBuild(t, c.db, 1); b.LeaseHeartbeatAt == nil || !b.LeaseHeartbeatAt.After(old) {
+		t.Errorf("build 1 heartbeat not renewed: %v", b.LeaseHeartbeatAt)
+	}
+	if b := loadBuild(t, c.db, 2); !b.LeaseHeartbeatAt.Equal(old) {
+		t.Errorf("build 2 heartbeat renewed for another owner: %v", b.LeaseHeartbeatAt)
+	}
+	if causes[1] != nil {
+		t.Errorf("build 1 stopped: %v", causes[1])
+	}
+	if !errors.Is(causes[2], executor.ErrDetached) {
+		t.Errorf("build 2 cause = %v, want ErrDetached", causes[2])
+	}
+	if !errors.Is(causes[3], executor.ErrCancelled) {
+		t.Errorf("build 3 cause = %v, want ErrCancelled", causes[3])
+	}
+}
+
+func TestReclaim(t *testing.T) {
+	c, _ := newTestConsumer(t)
+	running := int32(civ1.BuildStatus_BUILD_STATUS_RUNNING)
+	fresh, stale := time.Now(), time.Now().Add(-2*time.Minute)
+	// 失联副本的构建（心跳过期）被接管并恢复运行；存活副本的构建不受影响
+	createBuild(t, c.db, models.Build{ID: 1, Status: running, LeaseOwner: "other", LeaseHeartbeatAt: &stale}, testWorkflow)
+	createBuild(t, c.db, models.Build{ID: 2, Status: running, LeaseOwner: "other", LeaseHeartbeatAt: &fresh}, testWorkflow)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	c.reclaim(ctx)
+	for {
+		b := loadBuild(t, c.db, 1)
+		if isTerminal(b.Status) {
+			if b.Status != int32(civ1.BuildStatus_BUILD_STATUS_SUCCEEDED) || b.LeaseOwner != "me" {
+				t.Errorf("build 1 status = %d, lease %q; want SUCCEEDED leased by me", b.Status, b.LeaseOwner)
+			}
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("reclaimed build did not finish, status %d", b.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if b := loadBuild(t, c.db, 2); b.Status != running || b.LeaseOwner != "other" {
+		t.Errorf("build 2 status = %d, lease %q; want untouched", b.Status, b.LeaseOwner)
+	}
+	var jobs []models.BuildJob
+	_ = c.db.Where("build_id = ?", 1).Find(&jobs).Error
+	if len(jobs) != 1 || jobs[0].Status != "succeeded" {
+		t.Errorf("build 1 jobs = %+v, want build succeeded", jobs)
+	}
+}
@@ -2,12 +2,19 @@ package consumer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"xcoding/apps/ci/executor_service/buildqueue"
+	"xcoding/apps/ci/executor_service/internal/artifact"
+	"xcoding/apps/ci/executor_service/internal/config"
 	"xcoding/apps/ci/executor_service/internal/executor"
-	"xcoding/apps/ci/executor_service/internal/parser"
 	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
 	civ1 "xcoding/gen/go/ci/v1"
 
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -15,24 +22,67 @@ import (
 	"gorm.io/gorm"
 )
 
-type BuildMessage struct{ BuildID uint64 }
-
 type ExecutorClient interface {
 	CancelBuild(ctx context.Context, in *civ1.CancelExecutorBuildRequest, opts ...grpc.CallOption) (*civ1.CancelExecutorBuildResponse, error)
 }
 
+// RetryPolicy 构建消息的重试策略
+// 构建未进入终态（快照读取失败等）时，第 n 次失败后延迟 Backoff*2^(n-1)（不超过 MaxBackoff）重新投递；
+// 失败次数达到 MaxAttempts 后进入死信队列
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Delay 第 failures 次失败后的重试延迟
+func (p RetryPolicy) Delay(failures int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < failures && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
 type QueueConsumer struct {
-	url    string
-	queue  string
-	conn   *amqp.Connection
-	ch     *amqp.Channel
-	client ExecutorClient
-	db     *gorm.DB
-	k8s    *executor.K8sEnv
+	url     string
+	queue   string
+	conn    *amqp.Connection
+	ch      *amqp.Channel
+	pub     publisher // 确认模式，用于重试、死信与重新入队
+	client  ExecutorClient
+	db      *gorm.DB
+	runner  executor.JobRunner
+	rcfg    config.RunnerConfig
+	classes *executor.RunnerClassRegistry
+	opts    executor.EngineOptions
+	store   artifact.Store // 制品存储，重新入队时删除上次执行的制品；nil 时仅删除记录
+	retry   RetryPolicy
+	lease   LeaseOptions
+	slots   chan struct{} // 并发构建槽位，容量为 MaxConcurrentBuilds
+
+	mu     sync.Mutex
+	active map[uint64]context.CancelCauseFunc // 本副本运行中的构建（已登记尚未开始时为 nil）
 }
 
-func NewQueueConsumer(url, queue string, client ExecutorClient, db *gorm.DB, namespace string) *QueueConsumer {
-	return &QueueConsumer{url: url, queue: queue, client: client, db: db}
+func NewQueueConsumer(url, queue string, client ExecutorClient, db *gorm.DB, namespace string, rcfg config.RunnerConfig, classes *executor.RunnerClassRegistry, opts executor.EngineOptions, retry RetryPolicy, lease LeaseOptions) *QueueConsumer {
+	if retry.MaxAttempts <= 0 {
+		retry.MaxAttempts = 1
+	}
+	if lease.MaxConcurrentBuilds <= 0 {
+		lease.MaxConcurrentBuilds = 1
+	}
+	if lease.TTL <= 0 {
+		lease.TTL = time.Minute
+	}
+	return &QueueConsumer{
+		url: url, queue: queue, client: client, db: db, rcfg: rcfg, classes: classes, opts: opts, retry: retry, lease: lease,
+		slots:  make(chan struct{}, lease.MaxConcurrentBuilds),
+		active: map[uint64]context.CancelCauseFunc{},
+	}
 }
 
 func (c *QueueConsumer) Start(ctx context.Context) error {
@@ -45,58 +95,279 @@ func (c *QueueConsumer) Start(ctx context.Context) error {
 		_ = conn.Close()
 		return fmt.Errorf("open channel: %w", err)
 	}
-	if _, err := ch.QueueDeclare(c.queue, true, false, false, false, nil); err != nil {
+	if err := buildqueue.DeclareTopology(ch, c.queue, c.retry.MaxAttempts); err != nil {
 		_ = ch.Close()
 		_ = conn.Close()
-		return fmt.Errorf("declare queue: %w", err)
+		return err
 	}
-	c.conn, c.ch = conn, ch
+	pub, err := conn.Channel()
+	if err == nil {
+		err = pub.Confirm(false)
+	}
+	if err != nil {
+		_ = ch.Close()
+		_ = conn.Close()
+		return fmt.Errorf("open publish channel: %w", err)
+	}
+	c.conn, c.ch, c.pub = conn, ch, confirmChannel{pub}
 
-	kenv, err := executor.NewK8sEnv()
+	runner, err := executor.NewJobRunner(ctx, c.rcfg, c.classes)
 	if err != nil {
-		return fmt.Errorf("k8s env: %w", err)
+		return fmt.Errorf("job runner: %w", err)
+	}
+	c.runner = runner
+	// 先接管重启前的自身或失联副本未结束的构建，再开始消费；之后周期性续约与接管
+	c.reclaim(ctx)
+	go c.leaseLoop(ctx)
+	// prefetch 即单副本并发构建上限；消息在构建进入终态后才确认
+	if err := ch.Qos(c.lease.MaxConcurrentBuilds, 0, false); err != nil {
+		return fmt.Errorf("qos: %w", err)
 	}
-	c.k8s = kenv
-	msgs, err := ch.Consume(c.queue, "", true, false, false, false, nil)
+	msgs, err := ch.Consume(c.queue, "", false, false, false, false, nil)
 	if err != nil {
 		return fmt.Errorf("consume: %w", err)
 	}
 	go func() {
 		for m := range msgs {
-			parts := strings.Split(string(m.Body), "|")
-			if len(parts) < 1 {
-				continue
-			}
-			var buildID uint64
-			fmt.Sscanf(parts[0], "%d", &buildID)
-			_ = c.handleBuild(ctx, buildID)
+			// 与接管的构建共享并发槽位
+			c.slots <- struct{}{}
+			go func(m amqp.Delivery) {
+				defer func() { <-c.slots }()
+				c.process(ctx, m)
+			}(m)
 		}
 	}()
 	return nil
 }
 
+// process 处理一条构建消息
+// - 无法解析或构建不存在：直接进入死信队列（重试无意义）
+// - 构建已处于终态（重复投递）、本副本正在运行或租约由其它存活副本持有：确认并跳过
+// - 构建已处于 RUNNING（执行器中断后重新投递）：抢占租约后按落库状态恢复
+// - 并发组被占用：释放租约，构建保持 QUEUED，经等待队列延迟重新投递（不计入失败次数）
+// - 执行后构建进入终态（成功、失败或取消）或租约被其它副本接管：确认；否则释放租约，计为一次失败，按退避重试或进入死信队列
+func (c *QueueConsumer) process(ctx context.Context, m amqp.Delivery) {
+	failures := buildqueue.Attempt(m.Headers)
+	msg, err := buildqueue.Decode(m.ContentType, m.Body)
+	if err != nil {
+		c.deadLetter(ctx, m, 0, failures, err)
+		return
+	}
+	var b models.Build
+	if err := c.db.Select("id", "status").First(&b, msg.BuildID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.deadLetter(ctx, m, 0, failures, fmt.Errorf("build %d not found", msg.BuildID))
+		} else {
+			c.retryOrDeadLetter(ctx, m, msg.BuildID, failures+1, fmt.Errorf("load build: %w", err))
+		}
+		return
+	}
+	if isTerminal(b.Status) || !c.reserve(msg.BuildID) {
+		_ = m.Ack(false)
+		return
+	}
+	ok, err := c.claim(msg.BuildID)
+	if err != nil || !ok {
+		c.unreserve(msg.BuildID)
+		if err != nil {
+			c.retryOrDeadLetter(ctx, m, msg.BuildID, failures+1, fmt.Errorf("claim lease: %w", err))
+			return
+		}
+		log.Printf("executor: build %d: leased by another executor, skipping", msg.BuildID)
+		_ = m.Ack(false)
+		return
+	}
+
+	herr := c.runBuild(ctx, msg.BuildID, b.Status == int32(civ1.BuildStatus_BUILD_STATUS_RUNNING))
+	if errors.Is(herr, executor.ErrDetached) {
+		_ = m.Ack(false)
+		return
+	}
+	if errors.Is(herr, errConcurrencyHeld) {
+		c.release(msg.BuildID)
+		c.hold(ctx, m, msg.BuildID, herr)
+		return
+	}
+	if err := c.db.Select("id", "status").First(&b, msg.BuildID).Error; err == nil && isTerminal(b.Status) {
+		if herr != nil {
+			log.Printf("executor: build %d: %v", msg.BuildID, herr)
+		}
+		_ = m.Ack(false)
+		return
+	}
+	if herr == nil {
+		herr = errors.New("build did not reach a terminal state")
+	}
+	c.release(msg.BuildID)
+	c.retryOrDeadLetter(ctx, m, msg.BuildID, failures+1, herr)
+}
+
+// retryOrDeadLetter 失败次数未达上限时投递到对应的延迟队列，否则进入死信队列
+func (c *QueueConsumer) retryOrDeadLetter(ctx context.Context, m amqp.Delivery, buildID uint64, failures int, cause error) {
+	if failures >= c.retry.MaxAttempts {
+		c.deadLetter(ctx, m, buildID, failures, cause)
+		return
+	}
+	delay := c.retry.Delay(failures)
+	p := republish(m, amqp.Table{
+		buildqueue.HeaderAttempt: int32(failures),
+		buildqueue.HeaderError:   truncate(cause.Error(), 1024),
+	})
+	p.Expiration = strconv.FormatInt(delay.Milliseconds(), 10)
+	if err := c.publish(ctx, buildqueue.RetryQueue(c.queue, failures), p); err != nil {
+		log.Printf("executor: build %d: schedule retry: %v", buildID, err)
+		_ = m.Nack(false, true)
+		return
+	}
+	_ = m.Ack(false)
+	log.Printf("executor: build %d: attempt %d/%d failed, retrying in %s: %v", buildID, failures, c.retry.MaxAttempts, delay, cause)
+}
+
+// deadLetter 将消息移入死信队列；buildID 非 0 时将未结束的构建标记为 FAILED
+func (c *QueueConsumer) deadLetter(ctx context.Context, m amqp.Delivery, buildID uint64, failures int, cause error) {
+	p := republish(m, amqp.Table{
+		buildqueue.HeaderAttempt:        int32(failures),
+		buildqueue.HeaderError:          truncate(cause.Error(), 1024),
+		buildqueue.HeaderDeadLetteredAt: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err := c.publish(ctx, buildqueue.DeadLetterQueue(c.queue), p); err != nil {
+		log.Printf("executor: dead-letter message: %v", err)
+		_ = m.Nack(false, true)
+		return
+	}
+	_ = m.Ack(false)
+	log.Printf("executor: build %d: moved to %s after %d failed attempt(s): %v", buildID, buildqueue.DeadLetterQueue(c.queue), failures, cause)
+	if buildID == 0 {
+		return
+	}
+	now := time.Now()
+	_ = c.db.Model(&models.Build{}).Where("id = ? AND status NOT IN ?", buildID, terminalStatuses).
+		Updates(map[string]any{"status": int32(civ1.BuildStatus_BUILD_STATUS_FAILED), "finished_at": &now}).Error
+}
+
+// SetArtifactStore 设置制品存储（未启用制品时不设置）
+func (c *QueueConsumer) SetArtifactStore(s artifact.Store) { c.store = s }
+
+// publisher 发布消息到指定队列并等待 broker 确认
+type publisher interface {
+	Publish(ctx context.Context, queue string, p amqp.Publishing) error
+	Close() error
+}
+
+// confirmChannel 确认模式通道
+type confirmChannel struct{ ch *amqp.Channel }
+
+func (c confirmChannel) Close() error { return c.ch.Close() }
+
+// publish 发布到 queue 并等待 broker 确认
+func (c *QueueConsumer) publish(ctx context.Context, queue string, p amqp.Publishing) error {
+	return c.pub.Publish(ctx, queue, p)
+}
+
+// Publish 经确认模式通道发布到默认交换机，等待 broker 确认
+func (c confirmChannel) Publish(ctx context.Context, queue string, p amqp.Publishing) error {
+	dc, err := c.ch.PublishWithDeferredConfirmWithContext(ctx, "", queue, false, false, p)
+	if err != nil {
+		return fmt.Errorf("publish to %s: %w", queue, err)
+	}
+	ok, err := dc.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("publish to %s: %w", queue, err)
+	}
+	if !ok {
+		return fmt.Errorf("publish to %s: nacked by broker", queue)
+	}
+	return nil
+}
+
+// republish 复制消息内容与消息头，并覆盖指定消息头
+func republish(m amqp.Delivery, headers amqp.Table) amqp.Publishing {
+	h := amqp.Table{}
+	for k, v := range m.Headers {
+		h[k] = v
+	}
+	for k, v := range headers {
+		h[k] = v
+	}
+	return amqp.Publishing{
+		Headers:      h,
+		ContentType:  m.ContentType,
+		Body:         m.Body,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    m.Timestamp,
+		Type:         m.Type,
+		MessageId:    m.MessageId,
+	}
+}
+
+// terminalStatuses 构建终态
+var terminalStatuses = []int32{
+	int32(civ1.BuildStatus_BUILD_STATUS_SUCCEEDED),
+	int32(civ1.BuildStatus_BUILD_STATUS_FAILED),
+	int32(civ1.BuildStatus_BUILD_STATUS_CANCELLED),
+}
+
+func isTerminal(status int32) bool {
+	for _, s := range terminalStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+// handleBuild 占用并发组后开始运行构建；构建已结束（如排队期间被取消）时直接返回
 func (c *QueueConsumer) handleBuild(ctx context.Context, buildID uint64) error {
-	if c.db != nil {
-		now := time.Now()
-		_ = c.db.Model(&models.Build{}).Where("id = ?", buildID).Updates(map[string]any{"status": int32(civ1.BuildStatus_BUILD_STATUS_RUNNING), "started_at": &now}).Error
+	wf, err := c.prepareBuild(buildID)
+	if err != nil {
+		return err
+	}
+	if err := c.acquireConcurrency(ctx, buildID, wf); err != nil {
+		return err
+	}
+	now := time.Now()
+	res := c.db.Model(&models.Build{}).Where("id = ? AND status NOT IN ?", buildID, terminalStatuses).
+		Updates(map[string]any{"status": int32(civ1.BuildStatus_BUILD_STATUS_RUNNING), "started_at": &now})
+	if res.Error == nil && res.RowsAffected == 0 {
+		return nil
 	}
+	eng := executor.NewEngine(c.runner, c.db, nil)
+	eng.Options = c.opts
+	err = eng.RunWorkflow(ctx, buildID, wf)
+	return err
+}
+
+// prepareBuild 读取快照并校验工作流，按需初始化 BuildJob/BuildStep 与 DAG 边
+func (c *QueueConsumer) prepareBuild(buildID uint64) (*parser.Workflow, error) {
 	var snap models.BuildSnapshot
 	if err := c.db.Where("build_id = ?", buildID).First(&snap).Error; err != nil {
-		return fmt.Errorf("snapshot: %w", err)
+		return nil, fmt.Errorf("snapshot: %w", err)
 	}
-	wf, err := parser.ParseWorkflowYAML(snap.WorkflowYAML)
+	wf, err := parser.ValidateWorkflowYAMLWith(snap.WorkflowYAML, c.validateOptions())
 	if err != nil {
-		return fmt.Errorf("parse workflow: %w", err)
+		// 工作流非法（needs 指向不存在的 Job、依赖成环、runs-on 类别未定义等）时直接判定构建失败，避免 Job 永久 pending
+		now := time.Now()
+		_ = c.db.Model(&models.Build{}).Where("id = ? AND status NOT IN ?", buildID, terminalStatuses).Updates(map[string]any{"status": int32(civ1.BuildStatus_BUILD_STATUS_FAILED), "finished_at": &now}).Error
+		return nil, fmt.Errorf("invalid workflow: %w", err)
 	}
 
-	// 延迟初始化：检查是否已有 BuildJob，若无则创建
+	// 延迟初始化：检查是否已有 BuildJob，若无则创建（矩阵任务按展开后的子任务逐个建档）
 	var count int64
 	if err := c.db.Model(&models.BuildJob{}).Where("build_id = ?", buildID).Count(&count).Error; err == nil && count == 0 {
+		dag := executor.BuildDAG(wf)
 		idx := int32(0)
-		for name, j := range wf.Jobs {
+		for _, name := range dag.Names() {
+			j := dag.Jobs[name]
 			idx++
 			_ = c.db.Create(&models.BuildJob{BuildID: buildID, Name: name, Status: "pending", Index: idx}).Error
-			for _, n := range j.Needs {
+			for _, n := range dag.Needs[name] {
 				_ = c.db.Create(&models.BuildJobEdge{BuildID: buildID, FromJob: n, ToJob: name}).Error
 			}
 			stepIdx := int32(0)
@@ -106,13 +377,41 @@ func (c *QueueConsumer) handleBuild(ctx context.Context, buildID uint64) error {
 			}
 		}
 	}
+	return wf, nil
+}
 
-	eng := executor.NewEngine(c.k8s, c.db, nil)
-	err = eng.RunWorkflow(ctx, buildID, wf)
-	return err
+// validateOptions 出队时的校验项：k8s 后端校验 runs-on 类别（local 后端忽略 runs-on）
+func (c *QueueConsumer) validateOptions() parser.ValidateOptions {
+	if _, ok := c.runner.(*executor.K8sRunner); !ok {
+		return parser.ValidateOptions{}
+	}
+	labels, err := c.classes.Labels()
+	if err != nil {
+		// 类别读取失败时不阻塞构建，未定义的类别在创建 Job 时判定失败
+		log.Printf("executor: load runner classes: %v", err)
+	}
+	return parser.ValidateOptions{RunnerLabels: labels}
+}
+
+// resumeBuild 按落库状态恢复构建：重新附着仍在运行的 Job、从最后落库的日志位置继续，并按 BuildJob 状态继续 DAG
+// 无法恢复（快照缺失等）的构建标记为 FAILED
+func (c *QueueConsumer) resumeBuild(ctx context.Context, buildID uint64) error {
+	wf, err := c.prepareBuild(buildID)
+	if err != nil {
+		now := time.Now()
+		_ = c.db.Model(&models.Build{}).Where("id = ? AND status NOT IN ?", buildID, terminalStatuses).
+			Updates(map[string]any{"status": int32(civ1.BuildStatus_BUILD_STATUS_FAILED), "finished_at": &now}).Error
+		return err
+	}
+	eng := executor.NewEngine(c.runner, c.db, nil)
+	eng.Options = c.opts
+	return eng.ResumeWorkflow(ctx, buildID, wf)
 }
 
 func (c *QueueConsumer) Close() {
+	if c.pub != nil {
+		_ = c.pub.Close()
+	}
 	if c.ch != nil {
 		_ = c.ch.Close()
 	}
@@ -0,0 +1,208 @@
+package consumer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"xcoding/apps/ci/executor_service/buildqueue"
+	"xcoding/apps/ci/executor_service/internal/config"
+	"xcoding/apps/ci/executor_service/internal/executor"
+	"xcoding/apps/ci/executor_service/models"
+	civ1 "xcoding/gen/go/ci/v1"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const testWorkflow = `jobs:
+  build:
+    steps:
+      - run: echo hello
+`
+
+// fakePublisher 记录发布的消息
+type fakePublisher struct {
+	mu   sync.Mutex
+	sent []published
+}
+
+type published struct {
+	queue string
+	p     amqp.Publishing
+}
+
+func (f *fakePublisher) Publish(ctx context.Context, queue string, p amqp.Publishing) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, published{queue, p})
+	return nil
+}
+
+func (f *fakePublisher) Close() error { return nil }
+
+// take 返回并清空已发布的消息
+func (f *fakePublisher) take() []published {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := f.sent
+	f.sent = nil
+	return out
+}
+
+// fakeAck 记录消息的确认结果
+type fakeAck struct{ acks, nacks int }
+
+func (a *fakeAck) Ack(tag uint64, multiple bool) error { a.acks++; return nil }
+
+func (a *fakeAck) Nack(tag uint64, multiple, requeue bool) error { a.nacks++; return nil }
+
+func (a *fakeAck) Reject(tag uint64, requeue bool) error { a.nacks++; return nil }
+
+// newTestConsumer 基于内存 SQLite 与 local 后端的消费者，发布的消息记录在返回的 fakePublisher 中
+func newTestConsumer(t *testing.T) (*QueueConsumer, *fakePublisher) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内存库按连接隔离：限制为单连接，引擎的并发查询共享同一个库
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.Build{}, &models.BuildSnapshot{}, &models.BuildJob{}, &models.BuildJobEdge{}, &models.BuildStep{},
+		&models.BuildStepLogChunk{}, &models.BuildServiceLogChunk{}, &models.BuildArtifact{}, &models.ConcurrencyLock{}); err != nil {
+		t.Fatal(err)
+	}
+	c := NewQueueConsumer("", "ci_builds", nil, db, "", config.RunnerConfig{}, nil, executor.EngineOptions{},
+		RetryPolicy{MaxAttempts: 2, Backoff: time.Second}, LeaseOptions{Owner: "me", TTL: time.Minute})
+	c.runner = executor.NewLocalRunner(t.TempDir())
+	pub := &fakePublisher{}
+	c.pub = pub
+	return c, pub
+}
+
+// createBuild 创建构建；workflow 非空时同时创建快照
+func createBuild(t *testing.T, db *gorm.DB, b models.Build, workflow string) {
+	t.Helper()
+	if b.Name == "" {
+		b.Name = "b"
+	}
+	if err := db.Create(&b).Error; err != nil {
+		t.Fatal(err)
+	}
+	if workflow != "" {
+		if err := db.Create(&models.BuildSnapshot{BuildID: b.ID, WorkflowYAML: workflow}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func loadBuild(t *testing.T, db *gorm.DB, id uint64) models.Build {
+	t.Helper()
+	var b models.Build
+	if err := db.First(&b, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// delivery 构造构建消息；failures 为已失败的投递次数
+func delivery(t *testing.T, buildID uint64, failures int) (amqp.Delivery, *fakeAck) {
+	t.Helper()
+	p, err := buildqueue.Publishing(buildqueue.Message{BuildID: buildID, ProjectID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack := &fakeAck{}
+	h := amqp.Table{}
+	if failures > 0 {
+		h[buildqueue.HeaderAttempt] = int32(failures)
+	}
+	return amqp.Delivery{Acknowledger: ack, ContentType: p.ContentType, Body: p.Body, Headers: h}, ack
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	for failures, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := p.Delay(failures); got != want {
+			t.Errorf("Delay(%d) = %s, want %s", failures, got, want)
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	ctx := context.Background()
+	c, pub := newTestConsumer(t)
+	queued := int32(civ1.BuildStatus_BUILD_STATUS_QUEUED)
+	fresh := time.Now()
+
+	// 无法解析与构建不存在：直接进入死信队列
+	d, ack := delivery(t, 1, 0)
+	d.Body = []byte("{")
+	c.process(ctx, d)
+	if sent := pub.take(); ack.acks != 1 || len(sent) != 1 || sent[0].queue != "ci_builds.dlq" {
+		t.Errorf("malformed message: acks %d, published %+v", ack.acks, sent)
+	}
+	d, ack = delivery(t, 404, 0)
+	c.process(ctx, d)
+	if sent := pub.take(); ack.acks != 1 || len(sent) != 1 || sent[0].queue != "ci_builds.dlq" {
+		t.Errorf("unknown build: acks %d, published %+v", ack.acks, sent)
+	}
+
+	// 已结束（重复投递）与其它存活副本持有租约：确认并跳过
+	createBuild(t, c.db, models.Build{ID: 2, Status: int32(civ1.BuildStatus_BUILD_STATUS_SUCCEEDED)}, testWorkflow)
+	createBuild(t, c.db, models.Build{ID: 3, Status: queued, LeaseOwner: "other", LeaseHeartbeatAt: &fresh}, testWorkflow)
+	for _, id := range []uint64{2, 3} {
+		d, ack = delivery(t, id, 0)
+		c.process(ctx, d)
+		if sent := pub.take(); ack.acks != 1 || len(sent) != 0 {
+			t.Errorf("build %d: acks %d, published %+v; want acked only", id, ack.acks, sent)
+		}
+	}
+	if b := loadBuild(t, c.db, 3); b.LeaseOwner != "other" || b.Status != queued {
+		t.Errorf("build 3 lease = %q, status %d; want untouched", b.LeaseOwner, b.Status)
+	}
+
+	// 正常运行至终态后确认
+	createBuild(t, c.db, models.Build{ID: 4, Status: queued}, testWorkflow)
+	d, ack = delivery(t, 4, 0)
+	c.process(ctx, d)
+	if sent := pub.take(); ack.acks != 1 || len(sent) != 0 {
+		t.Errorf("build 4: acks %d, published %+v", ack.acks, sent)
+	}
+	if b := loadBuild(t, c.db, 4); b.Status != int32(civ1.BuildStatus_BUILD_STATUS_SUCCEEDED) || b.LeaseOwner != "me" {
+		t.Errorf("build 4 status = %d, lease %q; want SUCCEEDED leased by me", b.Status, b.LeaseOwner)
+	}
+
+	// 未进入终态（快照缺失）：释放租约并按失败次数投递到延迟队列，达到上限后进入死信队列并判定失败
+	createBuild(t, c.db, models.Build{ID: 5, Status: queued}, "")
+	d, ack = delivery(t, 5, 0)
+	c.process(ctx, d)
+	sent := pub.take()
+	if ack.acks != 1 || len(sent) != 1 || sent[0].queue != "ci_builds.retry.1" || sent[0].p.Expiration != "1000" {
+		t.Fatalf("first failure: acks %d, published %+v; want ci_builds.retry.1 after 1s", ack.acks, sent)
+	}
+	if n := buildqueue.Attempt(sent[0].p.Headers); n != 1 {
+		t.Errorf("attempt header = %d, want 1", n)
+	}
+	if b := loadBuild(t, c.db, 5); b.Status != queued || b.LeaseOwner != "" {
+		t.Errorf("build 5 status = %d, lease %q; want QUEUED without lease", b.Status, b.LeaseOwner)
+	}
+	retried := amqp.Delivery{Acknowledger: ack, ContentType: sent[0].p.ContentType, Body: sent[0].p.Body, Headers: sent[0].p.Headers}
+	c.process(ctx, retried)
+	sent = pub.take()
+	if ack.acks != 2 || len(sent) != 1 || sent[0].queue != "ci_builds.dlq" || buildqueue.Attempt(sent[0].p.Headers) != 2 {
+		t.Fatalf("second failure: acks %d, published %+v; want dead letter after 2 attempts", ack.acks, sent)
+	}
+	if _, ok := sent[0].p.Headers[buildqueue.HeaderDeadLetteredAt]; !ok {
+		t.Error("dead letter missing dead-lettered-at header")
+	}
+	if b := loadBuild(t, c.db, 5); b.Status != int32(civ1.BuildStatus_BUILD_STATUS_FAILED) {
+		t.Errorf("build 5 status = %d, want FAILED", b.Status)
+	}
+}
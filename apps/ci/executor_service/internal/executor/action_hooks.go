@@ -0,0 +1,105 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"xcoding/apps/ci/executor_service/expr"
+	act "xcoding/apps/ci/executor_service/internal/executor/actions"
+	"xcoding/apps/ci/executor_service/parser"
+)
+
+// actionStateDir Action 前置/后置阶段的状态目录（Job 含此类 Action 时在脚本开头创建）
+// step-<序号>/action 为 node Action 的落地目录，step-<序号>/ran 表示主阶段已执行（后置阶段据此执行）
+const actionStateDir = "__XC_ACTIONS"
+
+// actionDir node Action 的落地目录（Shell 双引号字面量）；Action 无前置/后置阶段时返回空串（下载到临时目录）
+func actionDir(idx int, meta *act.ResolvedAction) string {
+	if meta == nil || (!meta.HasPre() && !meta.HasPost()) {
+		return ""
+	}
+	return fmt.Sprintf("\"$%s/step-%d/action\"", actionStateDir, idx+1)
+}
+
+// actionRanScript 主阶段开始时记录已执行，与 GitHub 一致：步骤被跳过时不执行其后置阶段
+func actionRanScript(idx int) string {
+	return fmt.Sprintf("mkdir -p \"$%[1]s/step-%[2]d\" && touch \"$%[1]s/step-%[2]d/ran\"\n", actionStateDir, idx+1)
+}
+
+// hasActionHooks Job 是否包含带前置/后置阶段的 Action
+func hasActionHooks(job parser.Job) bool {
+	for _, st := range job.Steps {
+		if meta, _ := isDockerAction(st, job); meta != nil && (meta.HasPre() || meta.HasPost()) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildPreSteps 按步骤顺序生成 Action 的前置阶段：在所有步骤之前执行，条件为 pre-if（默认 always()）
+func buildPreSteps(job parser.Job, ectx *expr.Context) string {
+	var b strings.Builder
+	for i, st := range job.Steps {
+		meta, _ := isDockerAction(st, job)
+		if meta == nil || !meta.HasPre() {
+			continue
+		}
+		b.WriteString(buildHookStep(i, st, job, ectx, meta, act.HookPre))
+	}
+	return b.String()
+}
+
+// buildPostSteps 按步骤逆序生成后置阶段：缓存保存段与 Action 的后置阶段（条件为 post-if，默认 always()）
+// 后置阶段仅在对应步骤的主阶段执行过时执行
+func buildPostSteps(job parser.Job, ectx *expr.Context) string {
+	var b strings.Builder
+	for i := len(job.Steps) - 1; i >= 0; i-- {
+		st := job.Steps[i]
+		if parser.IsCache(st.Uses) {
+			b.WriteString(buildCachePostStep(i, st))
+			continue
+		}
+		meta, _ := isDockerAction(st, job)
+		if meta == nil || !meta.HasPost() {
+			continue
+		}
+		fmt.Fprintf(&b, "if [ -f \"$%s/step-%d/ran\" ]; then\n%sfi\n", actionStateDir, i+1, buildHookStep(i, st, job, ectx, meta, act.HookPost))
+	}
+	return b.String()
+}
+
+// buildHookStep 生成 Action 前置/后置阶段（hook 为 act.HookPre/HookPost）的执行段
+// 以 __step_pre__/__step_post__ 标记将日志归入对应步骤，不改变步骤状态；失败时 Job 判定为失败
+func buildHookStep(idx int, st parser.Step, job parser.Job, ectx *expr.Context, meta *act.ResolvedAction, hook string) string {
+	marker, label := MarkerStepPre, "Pre"
+	if hook == act.HookPost {
+		marker, label = MarkerStepPost, "Post"
+	}
+	var frag string
+	var err error
+	if meta.Using == "docker" {
+		frag = buildDockerPhaseScript(idx, hook)
+	} else {
+		frag, err = act.BuildHookScript(st, job, ectx, actionDir(idx, meta), hook)
+	}
+	onSuccess, onFailure, cerr := stepConditions(parser.Step{If: meta.HookIf(hook)}, ectx)
+	if cerr != nil {
+		err, onSuccess, onFailure = cerr, true, true
+	}
+	if err != nil {
+		frag = fmt.Sprintf("echo %s >&2\nexit 1", shellQuote(err.Error()))
+	}
+	if strings.TrimSpace(frag) == "" {
+		return ""
+	}
+	run := fmt.Sprintf("echo %s %s\nset +e\n(\nset -e\n%s\n)\ncode=$?\nset -e\n", marker, shellQuote(st.Name), strings.TrimRight(frag, "\n"))
+	run += fmt.Sprintf("if [ $code -ne 0 ]; then echo \"%s step failed with exit code $code\" >&2; __xc_failed=1; __xc_exit=$code; fi\n", label)
+	switch {
+	case onSuccess && onFailure:
+		return run
+	case onSuccess:
+		return fmt.Sprintf("if [ \"$__xc_failed\" = \"0\" ]; then\n%sfi\n", run)
+	case onFailure:
+		return fmt.Sprintf("if [ \"$__xc_failed\" != \"0\" ]; then\n%sfi\n", run)
+	}
+	return ""
+}
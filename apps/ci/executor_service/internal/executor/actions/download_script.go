@@ -3,7 +3,7 @@ package actions
 import (
 	"fmt"
 	"strings"
-	"xcoding/apps/ci/executor_service/internal/parser"
+	"xcoding/apps/ci/executor_service/parser"
 )
 
 func DownloadUsesScript(step parser.Step) (string, error) {
@@ -8,9 +8,42 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
+var commitSHARe = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// ResolveCommitSHA 经 GitHub API 将分支、标签或提交解析为完整的提交 SHA
+// 说明：与 FetchTarball 一致，读取环境变量 XC_GITHUB_TOKEN 注入 Authorization
+func ResolveCommitSHA(owner, name, ref string) (string, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("https://api.github.com/repos/%s/%s/commits/%s", owner, name, ref), nil)
+	if err != nil {
+		return "", err
+	}
+	if tok := os.Getenv("XC_GITHUB_TOKEN"); tok != "" {
+		req.Header.Set("Authorization", "token "+tok)
+	}
+	req.Header.Set("Accept", "application/vnd.github.sha")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolve %s/%s@%s: %s", owner, name, ref, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", err
+	}
+	sha := strings.ToLower(strings.TrimSpace(string(body)))
+	if !commitSHARe.MatchString(sha) {
+		return "", fmt.Errorf("resolve %s/%s@%s: unexpected response %q", owner, name, ref, sha)
+	}
+	return sha, nil
+}
+
 // FetchTarball 下载 GitHub 仓库 tarball 并解压到目标目录
 // 说明：支持匿名下载；如需私仓，读取环境变量 XC_GITHUB_TOKEN 注入 Authorization
 func FetchTarball(owner, name, ref, dest string) error {
@@ -0,0 +1,338 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// docker Action 的步骤容器需以包装脚本等待 runner 的执行请求，镜像自身的 ENTRYPOINT/CMD 被覆盖，
+// 因此须在生成 Pod 规范时确定入口：action.yml 未指定 entrypoint 时，Dockerfile 构建的镜像取自 Dockerfile（未声明时取基础镜像），
+// 其它镜像经镜像仓库（Registry HTTP API V2，匿名令牌）读取镜像配置
+
+// ImageEntrypoint 镜像的 ENTRYPOINT 与 CMD
+type ImageEntrypoint struct {
+	Entrypoint []string
+	Cmd        []string
+}
+
+// DockerfileImage Dockerfile 构建出的镜像的入口
+type DockerfileImage struct {
+	ImageEntrypoint
+	// Base 非空时 ENTRYPOINT 继承自该基础镜像，CmdSet 为 false 时 CMD 亦继承
+	Base   string
+	CmdSet bool
+}
+
+// ParseDockerfile 解析 Dockerfile 最终阶段的 ENTRYPOINT/CMD
+// 说明：FROM 引用此前阶段时沿阶段链查找；与 docker 一致，声明 ENTRYPOINT 的阶段不继承基础镜像的 CMD；FROM 中引用的 ARG 以其默认值替换
+func ParseDockerfile(content string) (DockerfileImage, error) {
+	type stage struct {
+		name, base    string
+		ep, cmd       []string
+		hasEp, hasCmd bool
+	}
+	var (
+		stages []*stage
+		args   = map[string]string{}
+	)
+	for _, line := range dockerfileLines(content) {
+		inst, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		switch strings.ToUpper(inst) {
+		case "ARG":
+			if len(stages) == 0 {
+				k, v, _ := strings.Cut(rest, "=")
+				args[strings.TrimSpace(k)] = strings.Trim(strings.TrimSpace(v), `"`)
+			}
+		case "FROM":
+			fields := strings.Fields(rest)
+			for len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+				fields = fields[1:]
+			}
+			if len(fields) == 0 {
+				return DockerfileImage{}, fmt.Errorf("invalid FROM: %s", line)
+			}
+			st := &stage{base: expandArgs(fields[0], args)}
+			if len(fields) >= 3 && strings.EqualFold(fields[1], "as") {
+				st.name = strings.ToLower(fields[2])
+			}
+			stages = append(stages, st)
+		case "ENTRYPOINT":
+			if len(stages) == 0 {
+				return DockerfileImage{}, fmt.Errorf("ENTRYPOINT before FROM")
+			}
+			st := stages[len(stages)-1]
+			st.ep, st.hasEp = dockerfileCommand(rest), true
+		case "CMD":
+			if len(stages) == 0 {
+				return DockerfileImage{}, fmt.Errorf("CMD before FROM")
+			}
+			st := stages[len(stages)-1]
+			st.cmd, st.hasCmd = dockerfileCommand(rest), true
+		}
+	}
+	if len(stages) == 0 {
+		return DockerfileImage{}, fmt.Errorf("no FROM instruction")
+	}
+	byName := map[string]*stage{}
+	for _, st := range stages {
+		if st.name != "" {
+			byName[st.name] = st
+		}
+	}
+	// 自最终阶段向上：CMD 取最近的声明，遇到声明 ENTRYPOINT 的阶段即止
+	var out DockerfileImage
+	st := stages[len(stages)-1]
+	for n := 0; ; n++ {
+		if n > len(stages) {
+			return DockerfileImage{}, fmt.Errorf("cyclic FROM stages")
+		}
+		if st.hasCmd && !out.CmdSet {
+			out.Cmd, out.CmdSet = st.cmd, true
+		}
+		if st.hasEp {
+			out.Entrypoint, out.CmdSet = st.ep, true
+			return out, nil
+		}
+		parent, ok := byName[strings.ToLower(st.base)]
+		if !ok {
+			out.Base = st.base
+			return out, nil
+		}
+		st = parent
+	}
+}
+
+// dockerfileLines 合并续行（行尾 \）并去除注释与空行
+func dockerfileLines(content string) []string {
+	var (
+		out []string
+		cur strings.Builder
+	)
+	for _, raw := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		line := strings.TrimSpace(raw)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasSuffix(line, `\`) {
+			cur.WriteString(strings.TrimSuffix(line, `\`) + " ")
+			continue
+		}
+		cur.WriteString(line)
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			out = append(out, s)
+		}
+		cur.Reset()
+	}
+	if s := strings.TrimSpace(cur.String()); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
+
+// dockerfileCommand ENTRYPOINT/CMD 的参数：exec 形式（JSON 数组）原样取用，shell 形式以 /bin/sh -c 执行
+func dockerfileCommand(s string) []string {
+	var v []string
+	if strings.HasPrefix(s, "[") && json.Unmarshal([]byte(s), &v) == nil {
+		return v
+	}
+	return []string{"/bin/sh", "-c", s}
+}
+
+var argRefRe = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)\}?`)
+
+func expandArgs(s string, args map[string]string) string {
+	return argRefRe.ReplaceAllStringFunc(s, func(m string) string {
+		return args[argRefRe.FindStringSubmatch(m)[1]]
+	})
+}
+
+// registryClient 访问镜像仓库的 HTTP 客户端（测试中可替换）
+var registryClient = &http.Client{Timeout: 30 * time.Second}
+
+var (
+	imageMu    sync.Mutex
+	imageCache = map[string]imageEntry{}
+)
+
+type imageEntry struct {
+	ep ImageEntrypoint
+	at time.Time
+}
+
+// ResolveImageEntrypoint 读取镜像配置中的 ENTRYPOINT 与 CMD（结果缓存 resolveTTL）；scratch 返回空值
+// 说明：多架构镜像取 linux/amd64（不存在时取首个 linux 平台）；仅支持公开镜像（匿名令牌）
+func ResolveImageEntrypoint(ctx context.Context, image string) (ImageEntrypoint, error) {
+	if image == "scratch" {
+		return ImageEntrypoint{}, nil
+	}
+	imageMu.Lock()
+	if e, ok := imageCache[image]; ok && time.Since(e.at) < resolveTTL {
+		imageMu.Unlock()
+		return e.ep, nil
+	}
+	imageMu.Unlock()
+	host, repo, ref := parseImageRef(image)
+	r := &registry{host: host, repo: repo}
+	var m struct {
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	accept := "application/vnd.oci.image.index.v1+json, application/vnd.docker.distribution.manifest.list.v2+json, application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json"
+	if err := r.getJSON(ctx, "manifests/"+ref, accept, &m); err != nil {
+		return ImageEntrypoint{}, fmt.Errorf("image %s: %w", image, err)
+	}
+	if m.Config.Digest == "" && len(m.Manifests) > 0 {
+		digest := ""
+		for _, d := range m.Manifests {
+			if d.Platform.OS == "linux" && (digest == "" || d.Platform.Architecture == "amd64") {
+				digest = d.Digest
+			}
+		}
+		if digest == "" {
+			return ImageEntrypoint{}, fmt.Errorf("image %s: no linux platform", image)
+		}
+		m.Manifests = nil
+		if err := r.getJSON(ctx, "manifests/"+digest, accept, &m); err != nil {
+			return ImageEntrypoint{}, fmt.Errorf("image %s: %w", image, err)
+		}
+	}
+	if m.Config.Digest == "" {
+		return ImageEntrypoint{}, fmt.Errorf("image %s: manifest has no config", image)
+	}
+	var cfg struct {
+		Config struct {
+			Entrypoint []string `json:"Entrypoint"`
+			Cmd        []string `json:"Cmd"`
+		} `json:"config"`
+	}
+	if err := r.getJSON(ctx, "blobs/"+m.Config.Digest, "*/*", &cfg); err != nil {
+		return ImageEntrypoint{}, fmt.Errorf("image %s config: %w", image, err)
+	}
+	ep := ImageEntrypoint{Entrypoint: cfg.Config.Entrypoint, Cmd: cfg.Config.Cmd}
+	imageMu.Lock()
+	imageCache[image] = imageEntry{ep: ep, at: time.Now()}
+	imageMu.Unlock()
+	return ep, nil
+}
+
+// parseImageRef 拆分镜像引用：首段含 . 或 : 或为 localhost 时为仓库地址，否则为 Docker Hub（单段名称补 library/）
+func parseImageRef(image string) (host, repo, ref string) {
+	name := image
+	ref = "latest"
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref = name[:i], name[i+1:]
+	}
+	host = "registry-1.docker.io"
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		host, name = first, rest
+	}
+	if host == "docker.io" || host == "index.docker.io" {
+		host = "registry-1.docker.io"
+	}
+	if host == "registry-1.docker.io" && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	return host, name, ref
+}
+
+// registry 镜像仓库客户端：收到 401 时按 WWW-Authenticate 的 Bearer 质询获取匿名令牌后重试
+type registry struct {
+	host, repo, token string
+}
+
+func (r *registry) getJSON(ctx context.Context, path, accept string, v any) error {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+r.host+"/v2/"+r.repo+"/"+path, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", accept)
+		if r.token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.token)
+		}
+		resp, err := registryClient.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if err := r.authenticate(ctx, challenge); err != nil {
+				return err
+			}
+			continue
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return fmt.Errorf("GET %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+		}
+		return json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(v)
+	}
+}
+
+var challengeRe = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+func (r *registry) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("unsupported registry auth %q", challenge)
+	}
+	q := url.Values{}
+	realm := ""
+	for _, m := range challengeRe.FindAllStringSubmatch(params, -1) {
+		if m[1] == "realm" {
+			realm = m[2]
+		} else {
+			q.Set(m[1], m[2])
+		}
+	}
+	if realm == "" {
+		return fmt.Errorf("registry auth challenge without realm: %q", challenge)
+	}
+	if q.Get("scope") == "" {
+		q.Set("scope", "repository:"+r.repo+":pull")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := registryClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry token: %s", resp.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return fmt.Errorf("registry token: %w", err)
+	}
+	if r.token = tok.Token; r.token == "" {
+		r.token = tok.AccessToken
+	}
+	return nil
+}
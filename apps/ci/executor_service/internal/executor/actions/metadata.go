@@ -1,6 +1,7 @@
 package actions
 
 import (
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
@@ -39,10 +40,23 @@ func LoadMetadata(root string) (*ResolvedAction, error) {
 		return nil, os.ErrNotExist
 	}
 	var m struct {
+		Inputs map[string]struct {
+			Default string `yaml:"default"`
+		} `yaml:"inputs"`
 		Runs struct {
-			Using string              `yaml:"using"`
-			Main  string              `yaml:"main"`
-			Steps []CompositeStepMeta `yaml:"steps"`
+			Using          string              `yaml:"using"`
+			Main           string              `yaml:"main"`
+			Pre            string              `yaml:"pre"`
+			Post           string              `yaml:"post"`
+			PreIf          string              `yaml:"pre-if"`
+			PostIf         string              `yaml:"post-if"`
+			Image          string              `yaml:"image"`
+			Entrypoint     string              `yaml:"entrypoint"`
+			PreEntrypoint  string              `yaml:"pre-entrypoint"`
+			PostEntrypoint string              `yaml:"post-entrypoint"`
+			Args           []string            `yaml:"args"`
+			Env            map[string]string   `yaml:"env"`
+			Steps          []CompositeStepMeta `yaml:"steps"`
 		} `yaml:"runs"`
 	}
 	bs, err := os.ReadFile(found)
@@ -53,14 +67,45 @@ func LoadMetadata(root string) (*ResolvedAction, error) {
 		return nil, err
 	}
 	using := strings.TrimSpace(strings.ToLower(m.Runs.Using))
-	if using == "node12" || using == "node16" || using == "node20" {
+	if using == "node12" || using == "node16" || using == "node20" || using == "node24" {
 		using = "node"
 	}
 	ra := &ResolvedAction{Using: using, Path: filepath.Dir(found)}
-	if using == "composite" {
+	if len(m.Inputs) > 0 {
+		ra.Inputs = make(map[string]string, len(m.Inputs))
+		for name, in := range m.Inputs {
+			ra.Inputs[name] = in.Default
+		}
+	}
+	switch using {
+	case "composite":
 		ra.Composite = m.Runs.Steps
-	} else if using == "node" {
+	case "node":
 		ra.Main = strings.TrimSpace(m.Runs.Main)
+		ra.Pre = strings.TrimSpace(m.Runs.Pre)
+		ra.Post = strings.TrimSpace(m.Runs.Post)
+		ra.PreIf = strings.TrimSpace(m.Runs.PreIf)
+		ra.PostIf = strings.TrimSpace(m.Runs.PostIf)
+	case "docker":
+		ra.Image = strings.TrimSpace(m.Runs.Image)
+		ra.Entrypoint = strings.TrimSpace(m.Runs.Entrypoint)
+		ra.PreEntrypoint = strings.TrimSpace(m.Runs.PreEntrypoint)
+		ra.PostEntrypoint = strings.TrimSpace(m.Runs.PostEntrypoint)
+		ra.PreIf = strings.TrimSpace(m.Runs.PreIf)
+		ra.PostIf = strings.TrimSpace(m.Runs.PostIf)
+		ra.Args = m.Runs.Args
+		ra.Env = m.Runs.Env
+		if ra.Image == "" {
+			return nil, fmt.Errorf("docker action: runs.image is required")
+		}
+		// 非 docker:// 镜像为相对 Action 目录的 Dockerfile，读取内容以确定入口
+		if !strings.HasPrefix(ra.Image, DockerPrefix) {
+			df, err := os.ReadFile(filepath.Join(ra.Path, filepath.FromSlash(ra.Image)))
+			if err != nil {
+				return nil, fmt.Errorf("docker action: read %s: %w", ra.Image, err)
+			}
+			ra.Dockerfile = string(df)
+		}
 	}
 	return ra, nil
 }
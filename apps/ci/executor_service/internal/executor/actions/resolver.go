@@ -9,18 +9,21 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"time"
+	"xcoding/apps/ci/executor_service/expr"
 	"xcoding/apps/ci/executor_service/internal/config"
-	"xcoding/apps/ci/executor_service/internal/parser"
+	"xcoding/apps/ci/executor_service/parser"
 )
 
-// 支持带子路径的 uses：owner/name(/path...)?@version
-var usesRe = regexp.MustCompile(`^([A-Za-z0-9_.-]+)/([A-Za-z0-9_.-]+)(/[A-Za-z0-9_./-]+)?@([A-Za-z0-9_.-]+)$`)
+// 支持带子路径的 uses：owner/name(/path...)?@version；version 可为含 / 的分支或标签（如 releases/v1）
+var usesRe = regexp.MustCompile(`^([A-Za-z0-9_.-]+)/([A-Za-z0-9_.-]+)(/[A-Za-z0-9_./-]+)?@([A-Za-z0-9_.-]+(?:/[A-Za-z0-9_.-]+)*)$`)
 
 // ParseUsesRef 解析 uses 引用，返回结构化的 owner/name/version
 func ParseUsesRef(uses string) (ParsedRef, error) {
 	s := strings.TrimSpace(uses)
 	m := usesRe.FindStringSubmatch(s)
-	if m == nil {
+	if m == nil || strings.Contains(m[4], "..") {
 		return ParsedRef{}, fmt.Errorf("invalid uses ref: %s", uses)
 	}
 	path := strings.TrimSpace(m[3])
@@ -51,49 +54,124 @@ func shSingleQuote(s string) string {
 	return "'" + strings.ReplaceAll(s, "'", "'\\''") + "'"
 }
 
+// RuntimeRender 生成在 Job 运行时替换 s 中表达式的 Shell 字面量（双引号包裹的 xc-expr 命令替换），用于引用 steps 上下文的值（见 parser.Step.Deferred）
+// 用于赋值语句时，求值失败使赋值返回非零，在 set -e 下终止步骤
+func RuntimeRender(s string) string {
+	return "\"$(\"$XC_EXPR\" render " + shSingleQuote(s) + ")\""
+}
+
 // buildInputExportScript 工程化地将 with 映射为环境变量的注入脚本
 // 设计：
-// - 首选使用 base64 解码注入，可安全支持任意字符与换行
-// - 若容器内缺少 base64 命令，则回退为单引号安全包裹的直接 export
-// - 键名转换：kebab-case 转为大写下划线（如 message-id -> INPUT_MESSAGE_ID）
-func buildInputExportScript(with map[string]string) string {
+// - 值以单引号安全包裹后 export，支持任意字符与换行
+// - 键名转换：kebab-case 转为大写下划线（如 message-id -> INPUT_MESSAGE_ID）；原键名为合法变量名时同时以原名导出
+// - node/docker Action 另以 GitHub 的命名（INPUT_<名称大写>，保留 -）经进程环境传入，见 InputEnv
+// - deferred 标记的输入（键为 with.<name>）在运行时求值后先赋值再导出，求值失败时终止步骤
+func buildInputExportScript(inputs map[string]string, deferred map[string]bool) string {
 
-	if len(with) == 0 {
+	if len(inputs) == 0 {
 		return ""
 	}
 	b := strings.Builder{}
-	keys := make([]string, 0, len(with))
-	for k := range with {
+	keys := make([]string, 0, len(inputs))
+	for k := range inputs {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
 
 	for _, k := range keys {
-		v := with[k]
-		fmt.Fprintf(&b, "export %s=%s\n", k, v)
+		v := shSingleQuote(inputs[k])
+		name := inputExportName(k)
+		if deferred["with."+k] {
+			if shellNameRe.MatchString(name) {
+				fmt.Fprintf(&b, "%s=%s\nexport %s\n", name, RuntimeRender(inputs[k]), name)
+			}
+			v = inputWord(k, inputs[k], deferred)
+		} else if shellNameRe.MatchString(name) {
+			fmt.Fprintf(&b, "export %s=%s\n", name, v)
+		}
+		if shellNameRe.MatchString(k) {
+			fmt.Fprintf(&b, "export %s=%s\n", k, v)
+		}
 	}
 	return b.String()
 }
 
-// BuildUsesScript 构建 uses 步骤的脚本片段：先注入 INPUT_*，再拼接具体动作脚本
-func BuildUsesScript(step parser.Step, job parser.Job) (string, error) {
-	ref, err := ParseUsesRef(step.Uses)
+// inputExportName buildInputExportScript 导出输入时使用的变量名
+func inputExportName(k string) string {
+	return "INPUT_" + strings.ToUpper(strings.NewReplacer("-", "_", " ", "_").Replace(k))
+}
+
+// inputWord 输入值的 Shell 字面量：运行时求值的输入引用 buildInputExportScript 已赋值的变量（变量名不合法时就地求值）
+func inputWord(k, v string, deferred map[string]bool) string {
+	if !deferred["with."+k] {
+		return shSingleQuote(v)
+	}
+	if name := inputExportName(k); shellNameRe.MatchString(name) {
+		return "\"$" + name + "\""
+	}
+	return RuntimeRender(v)
+}
+
+var shellNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// DockerPrefix 直接引用镜像的 uses 前缀（uses: docker://alpine:3.20），with.entrypoint 与 with.args 为入口与参数
+const DockerPrefix = "docker://"
+
+// IsDockerUses uses 是否直接引用镜像
+func IsDockerUses(uses string) bool { return strings.HasPrefix(strings.TrimSpace(uses), DockerPrefix) }
+
+// resolveTTL 远端 Action 元数据的缓存时长：同一 Job 生成脚本与 Pod 规范时均需解析，矩阵子任务间亦可复用
+const resolveTTL = 10 * time.Minute
+
+var (
+	resolveMu    sync.Mutex
+	resolveCache = map[string]resolvedEntry{}
+)
+
+type resolvedEntry struct {
+	meta *ResolvedAction
+	at   time.Time
+}
+
+// Resolve 解析 uses 步骤对应 Action 的元数据（返回值共享，调用方不得修改）
+// 说明：docker://<image> 按 with 直接构造；owner/name(/path)@version 解析为提交 SHA 后在服务器侧下载仓库并读取 action.yml，结果按 uses 缓存 resolveTTL
+func Resolve(step parser.Step, job parser.Job) (*ResolvedAction, error) {
+	uses := strings.TrimSpace(step.Uses)
+	if IsDockerUses(uses) {
+		args, err := SplitArgs(step.With["args"])
+		if err != nil {
+			return nil, fmt.Errorf("with.args: %w", err)
+		}
+		return &ResolvedAction{Using: "docker", Image: uses, Entrypoint: strings.TrimSpace(step.With["entrypoint"]), Args: args}, nil
+	}
+	resolveMu.Lock()
+	if e, ok := resolveCache[uses]; ok && time.Since(e.at) < resolveTTL {
+		resolveMu.Unlock()
+		return e.meta, nil
+	}
+	resolveMu.Unlock()
+
+	ref, err := ParseUsesRef(uses)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	// 统一走远端仓库解析
-
 	tmpServerDir, terr := os.MkdirTemp("", "xc_action_")
 	if terr != nil {
-		return "", terr
+		return nil, terr
 	}
 	defer os.RemoveAll(tmpServerDir)
 	// 统一环境注入策略：优先使用 job.Env 中的值设置进程环境，供服务器侧下载使用
 	if tok := strings.TrimSpace(job.Env["XC_GITHUB_TOKEN"]); tok != "" {
 		_ = os.Setenv("XC_GITHUB_TOKEN", tok)
 	}
-	if err := FetchTarball(ref.Owner, ref.Name, ref.Version, tmpServerDir); err != nil {
-		return "", fmt.Errorf("download action tarball: %w", err)
+	// 先将 version 解析为提交 SHA，元数据与 Dockerfile 类 Action 的构建均基于同一提交
+	sha, err := ResolveCommitSHA(ref.Owner, ref.Name, ref.Version)
+	if err != nil {
+		return nil, fmt.Errorf("resolve action commit: %w", err)
+	}
+	if err := FetchTarball(ref.Owner, ref.Name, sha, tmpServerDir); err != nil {
+		return nil, fmt.Errorf("download action tarball: %w", err)
 	}
 	serverSearchRoot := tmpServerDir
 	if p := strings.TrimSpace(ref.Path); p != "" {
@@ -101,40 +179,82 @@ func BuildUsesScript(step parser.Step, job parser.Job) (string, error) {
 		if ss, serr := findSubdir(tmpServerDir, p); serr == nil {
 			serverSearchRoot = ss
 		} else {
-			return "", fmt.Errorf("subpath not found: %s", p)
+			return nil, fmt.Errorf("subpath not found: %s", p)
 		}
 	}
 	meta, err := LoadMetadata(serverSearchRoot)
 	if err != nil {
-		return "", fmt.Errorf("load action metadata: %w", err)
+		return nil, fmt.Errorf("load action metadata: %w", err)
+	}
+	meta.CommitSHA = sha
+	resolveMu.Lock()
+	resolveCache[uses] = resolvedEntry{meta: meta, at: time.Now()}
+	resolveMu.Unlock()
+	return meta, nil
+}
+
+// Inputs 合并 Action 声明的输入默认值与步骤 with（键为输入名）；默认值中的 ${{ }}（如 github.token）以 ectx 求值
+// docker://<image> 的 with.entrypoint 与 with.args 不作为输入
+func Inputs(meta *ResolvedAction, step parser.Step, ectx *expr.Context) (map[string]string, error) {
+	out := make(map[string]string, len(meta.Inputs)+len(step.With))
+	for name, def := range meta.Inputs {
+		v, err := expr.Interpolate(def, ectx)
+		if err != nil {
+			return nil, fmt.Errorf("default of input %s: %w", name, err)
+		}
+		out[name] = v
+	}
+	for k, v := range step.With {
+		if IsDockerUses(step.Uses) && (k == "entrypoint" || k == "args") {
+			continue
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// InputEnv 输入对应的环境变量（与 GitHub 一致：INPUT_<名称大写，空格替换为 _>）
+func InputEnv(inputs map[string]string) map[string]string {
+	out := make(map[string]string, len(inputs))
+	for k, v := range inputs {
+		out["INPUT_"+strings.ToUpper(strings.ReplaceAll(k, " ", "_"))] = v
+	}
+	return out
+}
+
+// BuildUsesScript 构建 uses 步骤的脚本片段：先注入 INPUT_*，再拼接具体动作脚本
+// dir 为 Action 的落地目录（Shell 字面量）：Action 含 pre/post 时由调用方指定，供前后置阶段复用；为空时下载到临时目录并在结束后删除
+// docker Action 在 Job Pod 的独立容器中执行，由执行器生成与之交互的脚本，不经此函数
+func BuildUsesScript(step parser.Step, job parser.Job, ectx *expr.Context, dir string) (string, error) {
+	ref, err := ParseUsesRef(step.Uses)
+	if err != nil {
+		return "", err
+	}
+	meta, err := Resolve(step, job)
+	if err != nil {
+		return "", err
+	}
+	inputs, err := Inputs(meta, step, ectx)
+	if err != nil {
+		return "", err
 	}
 
 	// 命令行脚本
 	var b strings.Builder
 	// 注入插件环境变量
-	b.WriteString(buildInputExportScript(step.With))
+	b.WriteString(buildInputExportScript(inputs, step.Deferred))
 
-	//fmt.Fprintf(&b, "echo ------------111111111111--------------------------\n")
-	//fmt.Fprintf(&b, "pwd\n")
-
-	//os.Setenv("ACTION_PATH", "actions")
-	//fmt.Fprintf(&b, "mkdir -p tmpdir=$ACTION_PATH\n")
-
-	down_script, _ := DownloadUsesScript(step)
-	fmt.Fprintf(&b, down_script)
+	b.WriteString(fetchActionScript(step, dir))
 
 	workSearchRoot := "$workdir"
 	if p := strings.TrimSpace(ref.Path); p != "" {
 		p = strings.TrimPrefix(p, "/")
 		workSearchRoot = filepath.Join("$workdir", p)
 	}
-	//fmt.Fprintf(&b, "pwd\n")
 	fmt.Fprintf(&b, "echo work plugin root: %s \n", workSearchRoot)
 
 	// 切换到插件目录
 	fmt.Fprintf(&b, "cd \"%s\"\n", workSearchRoot)
-	//fmt.Fprintf(&b, "echo ------------2222--------------------------\n")
-	//fmt.Fprintf(&b, "pwd\n")
 
 	fmt.Fprintf(&b, "echo ------------开始执行插件[%v]----------------------------------------------\n", step.Name)
 
@@ -158,26 +278,143 @@ func BuildUsesScript(step parser.Step, job parser.Job) (string, error) {
 			fmt.Fprintf(&b, "echo __step_end__ %s\n", name)
 		}
 	case "node":
-		fmt.Fprintf(&b, "if command -v node >/dev/null 2>&1; then\n")
-		fmt.Fprintf(&b, "  node \"%s\"\n", meta.Main)
-		fmt.Fprintf(&b, "else\n  echo \"node not available; please use composite or provide runtime\"\n  exit 1\nfi\n")
+		b.WriteString(nodeScript(meta.Main, inputs, step.Deferred))
 	case "docker":
-		fmt.Fprintf(&b, "echo \"docker action not supported in this runner\"\n")
+		return "", fmt.Errorf("docker action must run in its own container")
 	default:
 		fmt.Fprintf(&b, "echo \"[unknown using] %s\"\n", using)
 	}
 	fmt.Fprintf(&b, "echo ------------结束执行插件[%v]----------------------------------------------\n", step.Name)
-	//fmt.Fprintf(&b, "echo ------------333333--------------------------\n")
-	//fmt.Fprintf(&b, "pwd\n")
-	fmt.Fprintf(&b, "rm -rf \"$tmpdir\"\n")
+	if dir == "" {
+		fmt.Fprintf(&b, "rm -rf \"$tmpdir\"\n")
+	}
 	fmt.Fprintf(&b, "cd  %s \n", config.WORKDIR)
 	fmt.Fprintf(&b, "pwd \n")
-	//fmt.Fprintf(&b, "echo ------------444--------------------------\n")
-	//fmt.Fprintf(&b, "pwd\n")
-	//fmt.Fprintf(&b, "echo ------------step end--------------------------\n")
-	//fmt.Fprintf(&b, "env\n")
-	//fmt.Fprintf(&b, "echo ------------step end--------------------------\n")
-	//fmt.Fprintf(&b, "echo ------------555--------------------------\n")
-	//fmt.Fprintf(&b, "pwd\n")
 	return b.String(), nil
 }
+
+// BuildHookScript 构建 node Action 前置/后置阶段（HookPre/HookPost）的脚本片段；dir 同 BuildUsesScript，Action 尚未下载时先下载
+// Action 无对应阶段时返回空串
+func BuildHookScript(step parser.Step, job parser.Job, ectx *expr.Context, dir, hook string) (string, error) {
+	ref, err := ParseUsesRef(step.Uses)
+	if err != nil {
+		return "", err
+	}
+	meta, err := Resolve(step, job)
+	if err != nil {
+		return "", err
+	}
+	file := meta.Pre
+	if hook == HookPost {
+		file = meta.Post
+	}
+	if meta.Using != "node" || file == "" {
+		return "", nil
+	}
+	inputs, err := Inputs(meta, step, ectx)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(buildInputExportScript(inputs, step.Deferred))
+	b.WriteString(fetchActionScript(step, dir))
+	workSearchRoot := "$workdir"
+	if p := strings.TrimPrefix(strings.TrimSpace(ref.Path), "/"); p != "" {
+		workSearchRoot = filepath.Join("$workdir", p)
+	}
+	fmt.Fprintf(&b, "cd \"%s\"\n", workSearchRoot)
+	b.WriteString(nodeScript(file, inputs, step.Deferred))
+	fmt.Fprintf(&b, "cd  %s \n", config.WORKDIR)
+	return b.String(), nil
+}
+
+// fetchActionScript 下载 Action 仓库，结束后 $workdir 为仓库根目录
+// dir 非空时落地到该目录，已存在则直接复用（前置阶段已下载）
+func fetchActionScript(step parser.Step, dir string) string {
+	down, _ := DownloadUsesScript(step)
+	if dir == "" {
+		return down
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "if [ ! -d %s ]; then\n%s", dir, down)
+	fmt.Fprintf(&b, "mkdir -p \"$(dirname %s)\"\nmv \"$workdir\" %s\nrm -rf \"$tmpdir\"\nfi\n", dir, dir)
+	fmt.Fprintf(&b, "workdir=%s\n", dir)
+	return b.String()
+}
+
+// nodeScript 以 node 执行 Action 脚本；输入经 env 以 GitHub 的命名传入（名称可含 -，无法在 Shell 中 export）
+func nodeScript(file string, inputs map[string]string, deferred map[string]bool) string {
+	words := make(map[string]string, len(inputs))
+	for k, v := range inputs {
+		name := "INPUT_" + strings.ToUpper(strings.ReplaceAll(k, " ", "_"))
+		if deferred["with."+k] {
+			words[name] = shSingleQuote(name+"=") + inputWord(k, v, deferred)
+		} else {
+			words[name] = shSingleQuote(name + "=" + v)
+		}
+	}
+	keys := make([]string, 0, len(words))
+	for k := range words {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	fmt.Fprintf(&b, "if command -v node >/dev/null 2>&1; then\n")
+	b.WriteString("  env")
+	for _, k := range keys {
+		b.WriteString(" " + words[k])
+	}
+	fmt.Fprintf(&b, " node %s\n", shSingleQuote(file))
+	fmt.Fprintf(&b, "else\n  echo \"node not available; please use composite or provide runtime\"\n  exit 1\nfi\n")
+	return b.String()
+}
+
+// SplitArgs 按 Shell 规则拆分参数串（支持单/双引号与反斜杠转义，不做变量展开），用于 docker://<image> 的 with.args
+func SplitArgs(s string) ([]string, error) {
+	var (
+		out   []string
+		cur   strings.Builder
+		inTok bool
+		quote rune
+		esc   bool
+	)
+	for _, r := range s {
+		switch {
+		case esc:
+			cur.WriteRune(r)
+			esc = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\\':
+			esc, inTok = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inTok = r, true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inTok {
+				out = append(out, cur.String())
+				cur.Reset()
+				inTok = false
+			}
+		default:
+			cur.WriteRune(r)
+			inTok = true
+		}
+	}
+	if quote != 0 || esc {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", s)
+	}
+	if inTok {
+		out = append(out, cur.String())
+	}
+	return out, nil
+}
@@ -1,7 +1,7 @@
 package actions
 
 import (
-	"xcoding/apps/ci/executor_service/internal/parser"
+	"xcoding/apps/ci/executor_service/parser"
 )
 
 // Action 抽象接口：将一个 uses 步骤转为可执行脚本片段
@@ -27,4 +27,52 @@ type ResolvedAction struct {
 	Path      string
 	Main      string
 	Composite []CompositeStepMeta
+	// Inputs 声明的输入及其默认值
+	Inputs map[string]string
+	// Pre/Post node Action 的前置/后置脚本；PreIf/PostIf 为其执行条件，为空时等价于 always()
+	Pre    string
+	Post   string
+	PreIf  string
+	PostIf string
+	// Image docker Action 的镜像：docker://<image> 或相对 Action 目录的 Dockerfile 路径
+	Image string
+	// Dockerfile Image 为 Dockerfile 时的文件内容，用于确定构建出的镜像的 ENTRYPOINT/CMD
+	Dockerfile string
+	// Entrypoint/PreEntrypoint/PostEntrypoint docker Action 各阶段的入口，Args 为传给入口的参数，Env 为容器的额外环境变量
+	Entrypoint     string
+	PreEntrypoint  string
+	PostEntrypoint string
+	Args           []string
+	Env            map[string]string
+	// CommitSHA owner/name@version 解析得到的提交 SHA（docker://<image> 为空）
+	CommitSHA string
 }
+
+// HasPre 是否有前置阶段（node 的 pre 或 docker 的 pre-entrypoint）
+func (a *ResolvedAction) HasPre() bool {
+	return (a.Using == "node" && a.Pre != "") || (a.Using == "docker" && a.PreEntrypoint != "")
+}
+
+// HasPost 是否有后置阶段（node 的 post 或 docker 的 post-entrypoint）
+func (a *ResolvedAction) HasPost() bool {
+	return (a.Using == "node" && a.Post != "") || (a.Using == "docker" && a.PostEntrypoint != "")
+}
+
+// HookIf 前置/后置阶段（HookPre/HookPost）的执行条件，未配置时为 always()
+func (a *ResolvedAction) HookIf(hook string) string {
+	cond := a.PreIf
+	if hook == HookPost {
+		cond = a.PostIf
+	}
+	if cond == "" {
+		return "always()"
+	}
+	return cond
+}
+
+// Action 的执行阶段
+const (
+	HookPre  = "pre"
+	HookMain = "main"
+	HookPost = "post"
+)
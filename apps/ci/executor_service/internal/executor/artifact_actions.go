@@ -0,0 +1,120 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"xcoding/apps/ci/executor_service/internal/artifact"
+	"xcoding/apps/ci/executor_service/internal/cache"
+	"xcoding/apps/ci/executor_service/internal/config"
+	"xcoding/apps/ci/executor_service/parser"
+)
+
+// ArtifactEndpoint runner 访问制品与缓存服务的入口
+type ArtifactEndpoint struct {
+	URL   string                      // 执行器 HTTP 地址（runner 可达），如 http://ci-executor:10056
+	Token func(buildID uint64) string // 构建令牌，仅可访问该构建的制品
+}
+
+// errNoArtifactStore 工作流使用制品或缓存动作但执行器未配置制品存储
+var errNoArtifactStore = errors.New("artifact storage not configured")
+
+// hasArtifactSteps Job 是否包含制品上传/下载步骤
+func hasArtifactSteps(job parser.Job) bool {
+	for _, st := range job.Steps {
+		if parser.IsUploadArtifact(st.Uses) || parser.IsDownloadArtifact(st.Uses) {
+			return true
+		}
+	}
+	return false
+}
+
+// withArtifactEnv Job 包含制品或缓存步骤时注入服务地址、Job 名与构建令牌（XC_ARTIFACT_URL/JOB/TOKEN、XC_CACHE_URL）
+// 返回的 Job 使用新的 env map，不修改调用方的 Job
+func withArtifactEnv(ep *ArtifactEndpoint, buildID uint64, jobName string, job parser.Job) (parser.Job, error) {
+	caches := hasCacheSteps(job)
+	if !hasArtifactSteps(job) && !caches {
+		return job, nil
+	}
+	if ep == nil || ep.URL == "" || ep.Token == nil {
+		return job, errNoArtifactStore
+	}
+	env := make(map[string]string, len(job.Env)+3)
+	for k, v := range job.Env {
+		env[k] = v
+	}
+	env["XC_ARTIFACT_URL"] = fmt.Sprintf("%s%sbuilds/%d/artifacts", strings.TrimRight(ep.URL, "/"), artifact.RunnerPathPrefix, buildID)
+	env["XC_ARTIFACT_JOB"] = url.QueryEscape(jobName)
+	env["XC_ARTIFACT_TOKEN"] = ep.Token(buildID)
+	if caches {
+		env["XC_CACHE_URL"] = fmt.Sprintf("%s%sbuilds/%d", strings.TrimRight(ep.URL, "/"), cache.RunnerPathPrefix, buildID)
+	}
+	job.Env = env
+	return job, nil
+}
+
+// artifactPrelude 制品步骤的公共片段：检查制品服务地址并准备临时目录
+const artifactPrelude = "if [ -z \"${XC_ARTIFACT_URL:-}\" ]; then echo 'artifact storage not configured' >&2; exit 1; fi\n" +
+	"__xc_tmp=$(mktemp -d)\ntrap 'rm -rf \"$__xc_tmp\"' EXIT\n"
+
+// buildUploadArtifactScript 生成上传步骤脚本：将工作区内存在的路径打包为 tar.gz 并上传（curl PUT，无 curl 时 wget POST）
+// 路径均不存在时按 if-no-files-found 失败、警告或静默跳过
+func buildUploadArtifactScript(st parser.Step) (string, error) {
+	up, err := parser.ParseUploadArtifact(st.With)
+	if err != nil {
+		return "", err
+	}
+	quoted := make([]string, len(up.Paths))
+	for i, p := range up.Paths {
+		quoted[i] = shellQuote(p)
+	}
+	var b strings.Builder
+	b.WriteString(artifactPrelude)
+	fmt.Fprintf(&b, "cd \"${XC_WORKSPACE:-%s}\"\n", config.WORKDIR)
+	fmt.Fprintf(&b, "__xc_paths=()\nfor __xc_p in %s; do\n", strings.Join(quoted, " "))
+	b.WriteString("  if [ -e \"$__xc_p\" ]; then __xc_paths+=(\"$__xc_p\"); else echo \"Path not found: $__xc_p\"; fi\ndone\n")
+	b.WriteString("if [ ${#__xc_paths[@]} -eq 0 ]; then\n")
+	msg := fmt.Sprintf("No files were found for artifact %q", up.Name)
+	switch up.IfNoFilesFound {
+	case "error":
+		fmt.Fprintf(&b, "  echo %s >&2\n  exit 1\n", shellQuote(msg))
+	case "warn":
+		fmt.Fprintf(&b, "  echo %s\n  exit 0\n", shellQuote("warning: "+msg))
+	default:
+		b.WriteString("  exit 0\n")
+	}
+	b.WriteString("fi\n")
+	b.WriteString("tar -czf \"$__xc_tmp/artifact.tar.gz\" -- \"${__xc_paths[@]}\"\n")
+	fmt.Fprintf(&b, "__xc_url=\"$XC_ARTIFACT_URL/%s?job=$XC_ARTIFACT_JOB\"\n", up.Name)
+	b.WriteString("if command -v curl >/dev/null 2>&1; then\n")
+	b.WriteString("  curl -fsS --retry 3 -H \"Authorization: Bearer $XC_ARTIFACT_TOKEN\" -H 'Content-Type: application/gzip' -T \"$__xc_tmp/artifact.tar.gz\" \"$__xc_url\"\n")
+	b.WriteString("else\n")
+	b.WriteString("  wget -q -O - --header=\"Authorization: Bearer $XC_ARTIFACT_TOKEN\" --header='Content-Type: application/gzip' --post-file=\"$__xc_tmp/artifact.tar.gz\" \"$__xc_url\"\n")
+	b.WriteString("fi\n")
+	fmt.Fprintf(&b, "echo\necho \"Uploaded artifact %s ($(wc -c < \"$__xc_tmp/artifact.tar.gz\" | tr -d ' ') bytes)\"\n", up.Name)
+	return b.String(), nil
+}
+
+// buildDownloadArtifactScript 生成下载步骤脚本：下载同一构建内的制品并解压到工作区内的目录
+func buildDownloadArtifactScript(st parser.Step) (string, error) {
+	dl, err := parser.ParseDownloadArtifact(st.With)
+	if err != nil {
+		return "", err
+	}
+	dest := fmt.Sprintf("\"${XC_WORKSPACE:-%s}\"", config.WORKDIR)
+	if dl.Path != "" {
+		dest += "/" + shellQuote(dl.Path)
+	}
+	var b strings.Builder
+	b.WriteString(artifactPrelude)
+	fmt.Fprintf(&b, "__xc_url=\"$XC_ARTIFACT_URL/%s\"\n", dl.Name)
+	b.WriteString("if command -v curl >/dev/null 2>&1; then\n")
+	b.WriteString("  curl -fsS --retry 3 -H \"Authorization: Bearer $XC_ARTIFACT_TOKEN\" -o \"$__xc_tmp/artifact.tar.gz\" \"$__xc_url\"\n")
+	b.WriteString("else\n")
+	b.WriteString("  wget -q --header=\"Authorization: Bearer $XC_ARTIFACT_TOKEN\" -O \"$__xc_tmp/artifact.tar.gz\" \"$__xc_url\"\n")
+	b.WriteString("fi\n")
+	fmt.Fprintf(&b, "mkdir -p %s\ntar -xzf \"$__xc_tmp/artifact.tar.gz\" -C %s\n", dest, dest)
+	fmt.Fprintf(&b, "echo %s\n", shellQuote(fmt.Sprintf("Downloaded artifact %s", dl.Name)))
+	return b.String(), nil
+}
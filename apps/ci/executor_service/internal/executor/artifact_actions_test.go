@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/internal/artifact"
+	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
+	civ1 "xcoding/gen/go/ci/v1"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestArtifactSteps(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl not available")
+	}
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Build{}, &models.BuildArtifact{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Build{ID: 7, Name: "b", Status: int32(civ1.BuildStatus_BUILD_STATUS_RUNNING)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	store, err := artifact.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := artifact.NewHandler(db, store, "secret", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	ep := &ArtifactEndpoint{URL: srv.URL, Token: h.Token}
+
+	wf, err := parser.ValidateWorkflowYAML(`jobs:
+  build:
+    steps:
+      - run: mkdir -p dist && echo hello > dist/app.txt
+      - uses: xcoding/upload-artifact@v1
+        with:
+          name: dist
+          path: |
+            dist
+            missing.txt
+      - uses: xcoding/upload-artifact
+        with: {name: none, path: nothing, if-no-files-found: warn}
+  deploy:
+    needs: build
+    steps:
+      - uses: xcoding/download-artifact
+        with: {name: dist, path: out}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := withArtifactEnv(nil, 7, "build", wf.Jobs["build"]); err == nil {
+		t.Error("withArtifactEnv without endpoint: want error")
+	}
+	run := func(jobName string) string {
+		t.Helper()
+		job, err := withArtifactEnv(ep, 7, jobName, wf.Jobs[jobName])
+		if err != nil {
+			t.Fatal(err)
+		}
+		ws := t.TempDir()
+		cmd := exec.Command("/bin/bash", "-c", BuildScript(job, expr.NewContext()))
+		cmd.Dir = ws
+		cmd.Env = append(os.Environ(), "XC_WORKSPACE="+ws)
+		for k, v := range job.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s script failed: %v\n%s", jobName, err, out)
+		}
+		return ws
+	}
+	run("build")
+	var arts []models.BuildArtifact
+	db.Find(&arts)
+	if len(arts) != 1 || arts[0].Name != "dist" || arts[0].JobName != "build" || arts[0].Size == 0 {
+		t.Fatalf("artifacts = %+v, want dist from build (warn skips empty upload)", arts)
+	}
+	ws := run("deploy")
+	if b, _ := os.ReadFile(filepath.Join(ws, "out", "dist", "app.txt")); string(b) != "hello\n" {
+		t.Errorf("out/dist/app.txt = %q", b)
+	}
+
+	// 令牌错误、重名与超限
+	put := func(token, name, body string) int {
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+artifact.RunnerPathPrefix+"builds/7/artifacts/"+name, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := put("bad", "x", "x"); code != http.StatusUnauthorized {
+		t.Errorf("bad token: status %d", code)
+	}
+	if code := put(h.Token(7), "dist", "x"); code != http.StatusConflict {
+		t.Errorf("duplicate name: status %d", code)
+	}
+	if code := put(h.Token(7), "big", strings.Repeat("x", 1<<20+1)); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized: status %d", code)
+	}
+}
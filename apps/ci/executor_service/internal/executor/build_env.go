@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
+
+	"github.com/sirupsen/logrus"
+)
+
+// standardEnv 每个 Job 固定注入的构建元数据环境变量
+func standardEnv(buildID uint64, b *models.Build) map[string]string {
+	return map[string]string{
+		"XC_BUILD_ID":    strconv.FormatUint(buildID, 10),
+		"XC_PIPELINE_ID": strconv.FormatUint(b.PipelineID, 10),
+		"XC_COMMIT_SHA":  b.CommitSHA,
+		"XC_BRANCH":      b.Branch,
+	}
+}
+
+// envNamePattern 合法的环境变量名
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// buildVariables 触发构建时提供的变量（Build.Variables）；已声明为 workflow_dispatch 输入的键以 INPUT_* 注入，不在此重复
+// 键不是合法环境变量名的变量无法导出到 Shell，XC_ 前缀的键为执行器保留（构建元数据与控制变量），均跳过并记录告警
+func buildVariables(b *models.Build, wf *parser.Workflow) map[string]string {
+	if len(b.Variables) == 0 {
+		return nil
+	}
+	declared := make(map[string]bool, len(wf.On.Dispatch))
+	for _, in := range wf.On.Dispatch {
+		declared[in.Name] = true
+	}
+	out := make(map[string]string, len(b.Variables))
+	var skipped []string
+	for k, v := range b.Variables {
+		switch {
+		case declared[k]:
+		case !envNamePattern.MatchString(k), strings.HasPrefix(strings.ToUpper(k), "XC_"):
+			skipped = append(skipped, k)
+		default:
+			out[k] = fmt.Sprint(v)
+		}
+	}
+	if len(skipped) > 0 {
+		sort.Strings(skipped)
+		logrus.WithFields(logrus.Fields{"build_id": b.ID, "keys": skipped}).Warn("build variables are not valid environment variable names or use the reserved XC_ prefix, not injected into job env")
+	}
+	return out
+}
+
+// jobEnv 合并 Job 的环境变量，优先级从低到高：INPUT_* < 工作流 env < Job env < 构建变量 < 构建元数据（standardEnv）
+// 返回合并结果与取值来自 INPUT_* 或构建变量的键：这些值由触发方提供，只能按字面注入（见 parser.Job.LiteralEnv）
+func jobEnv(inputEnv, wfEnv, env, vars, std map[string]string) (map[string]string, map[string]bool) {
+	declared := mergeEnv(wfEnv, env)
+	literal := map[string]bool{}
+	for k := range inputEnv {
+		if _, ok := declared[k]; !ok {
+			literal[k] = true
+		}
+	}
+	for k := range vars {
+		if _, ok := std[k]; !ok {
+			literal[k] = true
+		}
+	}
+	return mergeEnv(inputEnv, declared, vars, std), literal
+}
+
+// mergeEnv 按顺序合并环境变量，后者覆盖前者
+func mergeEnv(layers ...map[string]string) map[string]string {
+	out := map[string]string{}
+	for _, l := range layers {
+		for k, v := range l {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package executor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestBuildVariables(t *testing.T) {
+	b := &models.Build{ID: 1, Variables: map[string]any{"DEPLOY_ENV": "prod", "_x1": 2, "env": "dev", "app.version": "1.0", "1ST": "a", "": "b", "XC_RESOURCE_CPU_LIMIT": "64", "xc_job_timeout_seconds": "1"}}
+	wf := &parser.Workflow{On: parser.Triggers{Dispatch: []parser.DispatchInput{{Name: "env"}}}}
+	got := buildVariables(b, wf)
+	want := map[string]string{"DEPLOY_ENV": "prod", "_x1": "2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("buildVariables = %v, want %v", got, want)
+	}
+}
+
+func TestJobEnvLiteral(t *testing.T) {
+	inputEnv := map[string]string{"INPUT_TARGET": "secret://db/password", "INPUT_NOTE": "${{ github.token }}", "INPUT_OVERRIDDEN": "x"}
+	wfEnv := map[string]string{"INPUT_OVERRIDDEN": "secret://ci/token", "REGION": "eu"}
+	env := map[string]string{"DB": "secret://db/password", "TAG": "${{ inputs.tag }}"}
+	vars := map[string]string{"REGION": "secret://db/password", "TAG": "${{ github.token }}"}
+	std := map[string]string{"XC_BUILD_ID": "7"}
+	merged, literal := jobEnv(inputEnv, wfEnv, env, vars, std)
+	want := map[string]string{
+		"INPUT_TARGET": "secret://db/password", "INPUT_NOTE": "${{ github.token }}", "INPUT_OVERRIDDEN": "secret://ci/token",
+		"REGION": "secret://db/password", "DB": "secret://db/password", "TAG": "${{ github.token }}", "XC_BUILD_ID": "7",
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("jobEnv = %v, want %v", merged, want)
+	}
+	wantLiteral := map[string]bool{"INPUT_TARGET": true, "INPUT_NOTE": true, "REGION": true, "TAG": true}
+	if !reflect.DeepEqual(literal, wantLiteral) {
+		t.Errorf("literal = %v, want %v", literal, wantLiteral)
+	}
+
+	// 触发方提供的值不做表达式替换，也不转换为 SecretKeyRef
+	ectx := expr.NewContext()
+	ectx.Set("github", map[string]any{"token": "t0ken"})
+	job, err := interpolateJob(parser.Job{Env: merged, LiteralEnv: literal}, ectx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Env["TAG"] != "${{ github.token }}" || job.Env["INPUT_NOTE"] != "${{ github.token }}" {
+		t.Errorf("literal env interpolated: %v", job.Env)
+	}
+	got := map[string]corev1.EnvVar{}
+	for _, ev := range BuildEnvVarsForJob(job) {
+		got[ev.Name] = ev
+	}
+	for _, k := range []string{"INPUT_TARGET", "REGION"} {
+		if ev := got[k]; ev.ValueFrom != nil || ev.Value != "secret://db/password" {
+			t.Errorf("%s = %+v, want literal value", k, ev)
+		}
+	}
+	for _, k := range []string{"DB", "INPUT_OVERRIDDEN"} {
+		if ev := got[k]; ev.ValueFrom == nil || ev.ValueFrom.SecretKeyRef == nil {
+			t.Errorf("%s = %+v, want secret reference", k, ev)
+		}
+	}
+
+	// 工作流中的表达式替换后才构成的 secret:// 引用被拒绝
+	ectx.Set("inputs", map[string]any{"tag": "secret://db/password"})
+	for _, job := range []parser.Job{
+		{Env: map[string]string{"TAG": "${{ inputs.tag }}"}},
+		{Steps: []parser.Step{{Name: "s", Run: "true", Env: map[string]string{"TAG": "${{ inputs.tag }}"}}}},
+	} {
+		if _, err := interpolateJob(job, ectx); err == nil || !strings.Contains(err.Error(), "secret://") {
+			t.Errorf("interpolateJob(%+v) error = %v, want secret reference rejected", job, err)
+		}
+	}
+}
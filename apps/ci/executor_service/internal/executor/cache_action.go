@@ -0,0 +1,168 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"xcoding/apps/ci/executor_service/internal/config"
+	act "xcoding/apps/ci/executor_service/internal/executor/actions"
+	"xcoding/apps/ci/executor_service/parser"
+)
+
+// 缓存动作的执行分两段：
+//   - 步骤执行时恢复：按 key/restore-keys 向执行器查找并解压归档，输出 cache-hit；未精确命中时在状态目录记录展开后的 key
+//   - Job 结束后保存（post）：此前步骤均成功且存在记录时打包 path 并上传，失败仅输出警告，不影响 Job 结论
+//
+// 归档为 tar，内含按根目录划分的 workspace.tar.gz（相对工作区）、home.tar.gz（~/）与 root.tar.gz（绝对路径），
+// 恢复时分别解压到当前的工作区、HOME 与 /，工作区或 HOME 位置不同的 runner 之间也可复用
+
+// cacheStateDir 记录待保存缓存 key 的状态目录（Job 含缓存步骤时在脚本开头创建）
+const cacheStateDir = "__XC_CACHE_STATE"
+
+// cacheStateFile 缓存步骤的状态文件（Shell 双引号字面量）：存在时表示 Job 结束后需以其中的 key 保存
+func cacheStateFile(idx int) string { return fmt.Sprintf("\"$%s/step-%d\"", cacheStateDir, idx+1) }
+
+// hasCacheSteps Job 是否包含缓存步骤
+func hasCacheSteps(job parser.Job) bool {
+	for _, st := range job.Steps {
+		if parser.IsCache(st.Uses) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheKeyWord 生成 key 的 Shell 双引号字面量：运行时展开 $VAR 与 $(...)（如对锁文件求摘要），其余字符原样保留
+func cacheKeyWord(key string) string {
+	return "\"" + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`").Replace(key) + "\""
+}
+
+// parseCacheStep 解析缓存步骤的 with；运行时求值的 key/restore-keys 以占位值校验，脚本中另行求值
+func parseCacheStep(st parser.Step) (parser.Cache, error) {
+	with := st.With
+	if len(st.Deferred) > 0 {
+		with = make(map[string]string, len(st.With))
+		for k, v := range st.With {
+			if st.Deferred["with."+k] {
+				v = "deferred"
+			}
+			with[k] = v
+		}
+	}
+	return parser.ParseCache(with)
+}
+
+// buildCacheRestoreScript 生成缓存步骤的恢复脚本；恢复失败仅输出警告
+// key/restore-keys 引用 steps 上下文时由 xc-expr 在运行时求值（见 runtime_expr.go），求值结果按字面使用
+func buildCacheRestoreScript(idx int, st parser.Step) (string, error) {
+	c, err := parseCacheStep(st)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString("if [ -z \"${XC_CACHE_URL:-}\" ]; then echo 'cache storage not configured' >&2; exit 1; fi\n")
+	b.WriteString("__xc_tmp=$(mktemp -d)\ntrap 'rm -rf \"$__xc_tmp\"' EXIT\n")
+	if st.Deferred["with.key"] {
+		fmt.Fprintf(&b, "__xc_key=%s\n", act.RuntimeRender(strings.TrimSpace(st.With["key"])))
+	} else {
+		fmt.Fprintf(&b, "__xc_key=%s\n", cacheKeyWord(c.Key))
+	}
+	b.WriteString("__xc_hdr=(-H \"Authorization: Bearer $XC_ARTIFACT_TOKEN\" -H \"X-Cache-Key: $__xc_key\")\n")
+	if st.Deferred["with.restore-keys"] {
+		for _, line := range strings.Split(st.With["restore-keys"], "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				fmt.Fprintf(&b, "__xc_rk=%s\n__xc_hdr+=(-H \"X-Cache-Restore-Key: $__xc_rk\")\n", act.RuntimeRender(line))
+			}
+		}
+	} else {
+		for _, rk := range c.RestoreKeys {
+			fmt.Fprintf(&b, "__xc_hdr+=(-H \"X-Cache-Restore-Key: \"%s)\n", cacheKeyWord(rk))
+		}
+	}
+	b.WriteString("__xc_ok=1\n")
+	b.WriteString("if command -v curl >/dev/null 2>&1; then\n")
+	b.WriteString("  curl -fsS --retry 3 \"${__xc_hdr[@]}\" -D \"$__xc_tmp/headers\" -o \"$__xc_tmp/cache.tar\" \"$XC_CACHE_URL\" || __xc_ok=0\n")
+	b.WriteString("else\n")
+	b.WriteString("  __xc_wget=()\n  for ((__xc_i = 1; __xc_i < ${#__xc_hdr[@]}; __xc_i += 2)); do __xc_wget+=(\"--header=${__xc_hdr[$__xc_i]}\"); done\n")
+	b.WriteString("  wget -q -S \"${__xc_wget[@]}\" -O \"$__xc_tmp/cache.tar\" \"$XC_CACHE_URL\" 2> \"$__xc_tmp/headers\" || __xc_ok=0\n")
+	b.WriteString("fi\n")
+	b.WriteString("__xc_hit=\"\"\n")
+	b.WriteString("if [ \"$__xc_ok\" = 1 ]; then __xc_hit=$(grep -i '^ *x-cache-key:' \"$__xc_tmp/headers\" | tail -n 1 | sed 's/^[^:]*: *//' | tr -d '\\r'); else echo 'warning: failed to restore cache'; fi\n")
+	b.WriteString("if [ -n \"$__xc_hit\" ]; then\n")
+	b.WriteString("  mkdir -p \"$__xc_tmp/parts\"\n  tar -xf \"$__xc_tmp/cache.tar\" -C \"$__xc_tmp/parts\"\n")
+	fmt.Fprintf(&b, "  if [ -f \"$__xc_tmp/parts/workspace.tar.gz\" ]; then tar -xzf \"$__xc_tmp/parts/workspace.tar.gz\" -C \"${XC_WORKSPACE:-%s}\"; fi\n", config.WORKDIR)
+	b.WriteString("  if [ -f \"$__xc_tmp/parts/home.tar.gz\" ]; then mkdir -p \"$HOME\" && tar -xzf \"$__xc_tmp/parts/home.tar.gz\" -C \"$HOME\"; fi\n")
+	b.WriteString("  if [ -f \"$__xc_tmp/parts/root.tar.gz\" ]; then tar -xzf \"$__xc_tmp/parts/root.tar.gz\" -C /; fi\n")
+	b.WriteString("  echo \"Cache restored from key: $__xc_hit\"\nelse\n  echo \"Cache not found for key: $__xc_key\"\nfi\n")
+	b.WriteString("if [ \"$__xc_hit\" = \"$__xc_key\" ]; then\n  echo 'cache-hit=true' >> \"$GITHUB_OUTPUT\"\nelse\n")
+	fmt.Fprintf(&b, "  echo 'cache-hit=false' >> \"$GITHUB_OUTPUT\"\n  printf '%%s' \"$__xc_key\" > %s\nfi\n", cacheStateFile(idx))
+	return b.String(), nil
+}
+
+// buildCacheSaveScript 生成缓存步骤的保存脚本（Job 结束后执行）：按根目录分组打包存在的路径并上传
+func buildCacheSaveScript(idx int, st parser.Step) (string, error) {
+	c, err := parseCacheStep(st)
+	if err != nil {
+		return "", err
+	}
+	groups := []struct {
+		part, base string
+		paths      []string
+	}{
+		{"workspace", fmt.Sprintf("\"${XC_WORKSPACE:-%s}\"", config.WORKDIR), nil},
+		{"home", "\"$HOME\"", nil},
+		{"root", "/", nil},
+	}
+	for _, p := range c.Paths {
+		switch {
+		case p == "~":
+			groups[1].paths = append(groups[1].paths, ".")
+		case strings.HasPrefix(p, "~/"):
+			groups[1].paths = append(groups[1].paths, strings.TrimPrefix(p, "~/"))
+		case strings.HasPrefix(p, "/"):
+			groups[2].paths = append(groups[2].paths, strings.TrimPrefix(p, "/"))
+		default:
+			groups[0].paths = append(groups[0].paths, p)
+		}
+	}
+	var b strings.Builder
+	b.WriteString("__xc_tmp=$(mktemp -d)\ntrap 'rm -rf \"$__xc_tmp\"' EXIT\nmkdir -p \"$__xc_tmp/parts\"\n")
+	fmt.Fprintf(&b, "__xc_key=$(cat %s)\n", cacheStateFile(idx))
+	for _, g := range groups {
+		if len(g.paths) == 0 {
+			continue
+		}
+		quoted := make([]string, len(g.paths))
+		for i, p := range g.paths {
+			quoted[i] = shellQuote(p)
+		}
+		fmt.Fprintf(&b, "if cd %s 2>/dev/null; then\n  __xc_paths=()\n  for __xc_p in %s; do if [ -e \"$__xc_p\" ]; then __xc_paths+=(\"$__xc_p\"); fi; done\n", g.base, strings.Join(quoted, " "))
+		fmt.Fprintf(&b, "  if [ ${#__xc_paths[@]} -gt 0 ]; then tar -czf \"$__xc_tmp/parts/%s.tar.gz\" -- \"${__xc_paths[@]}\"; fi\nfi\n", g.part)
+	}
+	b.WriteString("if [ -z \"$(ls -A \"$__xc_tmp/parts\")\" ]; then echo 'warning: no files found to cache, not saving'; exit 0; fi\n")
+	b.WriteString("tar -cf \"$__xc_tmp/cache.tar\" -C \"$__xc_tmp/parts\" .\n")
+	b.WriteString("if command -v curl >/dev/null 2>&1; then\n")
+	b.WriteString("  __xc_code=$(curl -sS --retry 3 -o \"$__xc_tmp/resp\" -w '%{http_code}' -H \"Authorization: Bearer $XC_ARTIFACT_TOKEN\" -H \"X-Cache-Key: $__xc_key\" -H 'Content-Type: application/x-tar' -T \"$__xc_tmp/cache.tar\" \"$XC_CACHE_URL\" || true)\n")
+	b.WriteString("else\n")
+	b.WriteString("  __xc_code=201\n  wget -q -O \"$__xc_tmp/resp\" --header=\"Authorization: Bearer $XC_ARTIFACT_TOKEN\" --header=\"X-Cache-Key: $__xc_key\" --header='Content-Type: application/x-tar' --post-file=\"$__xc_tmp/cache.tar\" \"$XC_CACHE_URL\" || __xc_code=error\n")
+	b.WriteString("fi\n")
+	b.WriteString("case \"$__xc_code\" in\n")
+	b.WriteString("  201) echo \"Cache saved with key: $__xc_key ($(wc -c < \"$__xc_tmp/cache.tar\" | tr -d ' ') bytes)\" ;;\n")
+	b.WriteString("  409) echo \"Cache already exists for key: $__xc_key, not saving\" ;;\n")
+	b.WriteString("  *) echo \"warning: failed to save cache ($__xc_code): $(cat \"$__xc_tmp/resp\" 2>/dev/null)\" ;;\n")
+	b.WriteString("esac\n")
+	return b.String(), nil
+}
+
+// buildCachePostStep 生成缓存步骤在 Job 结束后的保存段（见 buildPostSteps）：仅在此前步骤均成功且恢复时未精确命中时执行
+// 以 __step_post__ 标记将日志归入对应的缓存步骤，不改变步骤状态；保存失败仅输出警告
+func buildCachePostStep(idx int, st parser.Step) string {
+	frag, err := buildCacheSaveScript(idx, st)
+	if err != nil {
+		return "" // 恢复阶段已报告参数错误并判定步骤失败
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "if [ \"$__xc_failed\" = \"0\" ] && [ -f %s ]; then\n", cacheStateFile(idx))
+	fmt.Fprintf(&b, "echo %s %s\nset +e\n(\nset -e\n%s\n)\ncode=$?\nset -e\n", MarkerStepPost, shellQuote(st.Name), strings.TrimRight(frag, "\n"))
+	b.WriteString("if [ $code -ne 0 ]; then echo \"warning: failed to save cache (exit code $code)\"; fi\nfi\n")
+	return b.String()
+}
@@ -0,0 +1,132 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/internal/artifact"
+	"xcoding/apps/ci/executor_service/internal/cache"
+	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
+	civ1 "xcoding/gen/go/ci/v1"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestCacheStep(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl not available")
+	}
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Build{}, &models.CacheEntry{}); err != nil {
+		t.Fatal(err)
+	}
+	running := int32(civ1.BuildStatus_BUILD_STATUS_RUNNING)
+	for _, b := range []models.Build{{ID: 1, Name: "a", ProjectID: 1, PipelineID: 2, Branch: "feature", Status: running}, {ID: 2, Name: "b", ProjectID: 1, PipelineID: 2, Branch: "feature", Status: running}, {ID: 3, Name: "c", ProjectID: 1, PipelineID: 2, Branch: "other", Status: running}} {
+		if err := db.Create(&b).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	store, err := artifact.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ah, err := artifact.NewHandler(db, store, "secret", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := cache.NewManager(db, store, 1<<20)
+	mux := http.NewServeMux()
+	mux.Handle(artifact.RunnerPathPrefix, ah)
+	mux.Handle(cache.RunnerPathPrefix, cache.NewHandler(db, m, ah.Token, defaultBranch("feature")))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	wf, err := parser.ValidateWorkflowYAML(`jobs:
+  build:
+    steps:
+      - uses: xcoding/cache
+        with:
+          key: deps-$(cat lock.txt)
+          restore-keys: deps-
+          path: |
+            deps
+            ~/.tool
+      - run: |
+          if [ -f deps/a.txt ] && [ -f "$HOME/.tool/b.txt" ]; then touch restored; fi
+          mkdir -p deps "$HOME/.tool"
+          echo a > deps/a.txt
+          echo b > "$HOME/.tool/b.txt"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(buildID uint64) string {
+		t.Helper()
+		job, err := withArtifactEnv(&ArtifactEndpoint{URL: srv.URL, Token: ah.Token}, buildID, "build", wf.Jobs["build"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		ws, home := t.TempDir(), t.TempDir()
+		if err := os.WriteFile(filepath.Join(ws, "lock.txt"), []byte("v1"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command("/bin/bash", "-c", BuildScript(job, expr.NewContext()))
+		cmd.Dir = ws
+		cmd.Env = append(os.Environ(), "XC_WORKSPACE="+ws, "HOME="+home)
+		for k, v := range job.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("script failed: %v\n%s", err, out)
+		}
+		return ws
+	}
+
+	// 首次构建未命中，Job 结束后以展开后的 key 保存
+	ws := run(1)
+	if _, err := os.Stat(filepath.Join(ws, "restored")); err == nil {
+		t.Error("first run: cache restored unexpectedly")
+	}
+	var entries []models.CacheEntry
+	db.Find(&entries)
+	if len(entries) != 1 || entries[0].Key != "deps-v1" || entries[0].Branch != "feature" {
+		t.Fatalf("entries = %+v, want deps-v1 on feature", entries)
+	}
+	// 再次构建精确命中：工作区与 HOME 下的路径均被恢复，不重复保存
+	ws = run(2)
+	if _, err := os.Stat(filepath.Join(ws, "restored")); err != nil {
+		t.Error("second run: cache not restored")
+	}
+	var n int64
+	db.Model(&models.CacheEntry{}).Count(&n)
+	if n != 1 {
+		t.Errorf("entries after exact hit = %d, want 1", n)
+	}
+
+	// 其它分支的构建回退到项目仓库的默认分支命中
+	ws = run(3)
+	if _, err := os.Stat(filepath.Join(ws, "restored")); err != nil {
+		t.Error("run on another branch: cache not restored from the default branch")
+	}
+	db.Model(&models.CacheEntry{}).Count(&n)
+	if n != 1 {
+		t.Errorf("entries after default branch hit = %d, want 1", n)
+	}
+}
+
+// defaultBranch 固定的仓库默认分支
+type defaultBranch string
+
+func (b defaultBranch) DefaultBranch(context.Context, uint64) (string, error) { return string(b), nil }
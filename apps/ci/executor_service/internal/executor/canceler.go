@@ -19,3 +19,9 @@ func (e *K8sEnv) CancelBuild(ctx context.Context, buildID uint64) error {
 	}
 	return nil
 }
+
+// DeleteJob 删除单个 K8s Job 及其 Pod（用于矩阵 fail-fast 取消兄弟任务）
+func (e *K8sEnv) DeleteJob(ctx context.Context, name string) error {
+	policy := metav1.DeletePropagationBackground
+	return e.Clientset.BatchV1().Jobs(e.Namespace).Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &policy})
+}
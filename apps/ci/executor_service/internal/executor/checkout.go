@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"xcoding/apps/ci/executor_service/internal/config"
+	"xcoding/apps/ci/executor_service/parser"
+	coderepositoryv1 "xcoding/gen/go/code_repository/v1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// checkoutMountPath 检出凭据在 runner 容器中的挂载目录（本地后端经 XC_CHECKOUT_DIR 指定）
+const checkoutMountPath = "/var/run/xcoding/checkout"
+
+// RepositoryCredentials 仓库克隆地址与凭据；按认证方式仅填充用户名密码或 SSH 私钥
+type RepositoryCredentials struct {
+	GitURL   string
+	Username string
+	Password string
+	SSHKey   string
+}
+
+// CheckoutSource 检出凭据来源
+type CheckoutSource interface {
+	// RepositoryCredentials 返回项目内仓库的克隆凭据；repository 为空表示项目唯一的仓库，buildID 为申请凭据的构建（须正在执行）
+	RepositoryCredentials(ctx context.Context, projectID, buildID uint64, repository string) (*RepositoryCredentials, error)
+}
+
+// CodeRepositoryCheckout 经 code_repository 服务 gRPC 接口获取检出凭据
+type CodeRepositoryCheckout struct {
+	Client coderepositoryv1.CodeRepositoryServiceClient
+}
+
+func (c CodeRepositoryCheckout) RepositoryCredentials(ctx context.Context, projectID, buildID uint64, repository string) (*RepositoryCredentials, error) {
+	resp, err := c.Client.GetRepositoryCredentials(ctx, &coderepositoryv1.GetRepositoryCredentialsRequest{ProjectId: projectID, BuildId: buildID, Name: repository})
+	if err != nil {
+		return nil, err
+	}
+	return &RepositoryCredentials{GitURL: resp.GetGitUrl(), Username: resp.GetGitUsername(), Password: resp.GetGitPassword(), SSHKey: resp.GetGitSshKey()}, nil
+}
+
+// errNoCheckoutSource 工作流使用检出动作但执行器未配置 code_repository 服务
+var errNoCheckoutSource = errors.New("code_repository service not configured")
+
+// checkoutSecretName 存放检出凭据的 K8s Secret 名：<K8s Job 名>-checkout，随 Job 一同回收
+func checkoutSecretName(name string) string { return name + "-checkout" }
+
+// checkoutKey 检出步骤的凭据文件前缀：step-<步骤序号>（自 1 起）
+func checkoutKey(idx int) string { return fmt.Sprintf("step-%d", idx+1) }
+
+// hasCheckout Job 是否包含检出步骤
+func hasCheckout(job parser.Job) bool {
+	for _, st := range job.Steps {
+		if parser.IsCheckout(st.Uses) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveCheckout 为 Job 的每个检出步骤获取凭据，返回凭据文件（键为 <checkoutKey>.url/.username/.password/.ssh-key）
+// Job 不含检出步骤时返回 nil；构建未关联项目或凭据获取失败时返回 error
+func resolveCheckout(ctx context.Context, src CheckoutSource, projectID, buildID uint64, job parser.Job) (map[string][]byte, error) {
+	if !hasCheckout(job) {
+		return nil, nil
+	}
+	if src == nil {
+		return nil, errNoCheckoutSource
+	}
+	if projectID == 0 {
+		return nil, errors.New("build is not associated with a project")
+	}
+	files := map[string][]byte{}
+	for i, st := range job.Steps {
+		if !parser.IsCheckout(st.Uses) {
+			continue
+		}
+		co, err := parser.ParseCheckout(st.With)
+		if err != nil {
+			return nil, fmt.Errorf("step %q: %w", st.Name, err)
+		}
+		cred, err := src.RepositoryCredentials(ctx, projectID, buildID, co.Repository)
+		if err != nil {
+			return nil, fmt.Errorf("step %q: repository credentials: %w", st.Name, err)
+		}
+		k := checkoutKey(i)
+		files[k+".url"] = []byte(cred.GitURL)
+		files[k+".username"] = []byte(cred.Username)
+		files[k+".password"] = []byte(cred.Password)
+		files[k+".ssh-key"] = []byte(cred.SSHKey)
+	}
+	return files, nil
+}
+
+// ApplyCheckoutVolume Job 包含检出步骤时将凭据 Secret 以只读卷挂载到 runner 容器
+// Secret 在 Job 创建后生成（属主为该 Job），kubelet 在其就绪前不会启动容器
+func ApplyCheckoutVolume(spec *batchv1.Job, job parser.Job) {
+	if !hasCheckout(job) {
+		return
+	}
+	pod := &spec.Spec.Template.Spec
+	mode := int32(0o444) // runner 可能以非 root 运行（执行器类别 securityContext）；SSH 私钥由脚本复制为 0600 后使用
+	pod.Volumes = append(pod.Volumes, corev1.Volume{
+		Name:         "checkout",
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: checkoutSecretName(spec.Name), DefaultMode: &mode}},
+	})
+	for i := range pod.Containers {
+		if pod.Containers[i].Name == "runner" {
+			pod.Containers[i].VolumeMounts = append(pod.Containers[i].VolumeMounts, corev1.VolumeMount{Name: "checkout", MountPath: checkoutMountPath, ReadOnly: true})
+		}
+	}
+}
+
+// buildCheckoutScript 生成检出步骤脚本：git init + fetch 指定 ref 并以分离 HEAD 检出
+// 说明：
+//   - 凭据从挂载目录读取：SSH 私钥经 GIT_SSH_COMMAND 使用，用户名密码经 GIT_ASKPASS 提供，均不出现在命令行与日志中
+//   - ref 为空时依次使用 XC_COMMIT_SHA、XC_BRANCH，均为空时检出远端默认分支
+//   - fetch-depth 大于 0 时浅克隆；submodules 为 true/recursive 时以相同凭据与深度更新子模块
+func buildCheckoutScript(idx int, st parser.Step) (string, error) {
+	co, err := parser.ParseCheckout(st.With)
+	if err != nil {
+		return "", err
+	}
+	k := checkoutKey(idx)
+	dest := fmt.Sprintf("\"${XC_WORKSPACE:-%s}\"", config.WORKDIR)
+	if co.Path != "" {
+		dest += "/" + shellQuote(co.Path)
+	}
+	depth := ""
+	if co.Depth > 0 {
+		depth = fmt.Sprintf(" --depth=%d", co.Depth)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "__xc_co=\"${XC_CHECKOUT_DIR:-%s}/%s\"\n", checkoutMountPath, k)
+	fmt.Fprintf(&b, "__xc_ref=%s\n", shellQuote(co.Ref))
+	b.WriteString("if [ -z \"$__xc_ref\" ]; then __xc_ref=\"${XC_COMMIT_SHA:-${XC_BRANCH:-HEAD}}\"; fi\n")
+	b.WriteString("__xc_tmp=$(mktemp -d)\ntrap 'rm -rf \"$__xc_tmp\"' EXIT\nexport GIT_TERMINAL_PROMPT=0\n")
+	b.WriteString("if [ -s \"$__xc_co.ssh-key\" ]; then\n")
+	b.WriteString("  cp \"$__xc_co.ssh-key\" \"$__xc_tmp/id\" && chmod 600 \"$__xc_tmp/id\"\n")
+	b.WriteString("  export GIT_SSH_COMMAND=\"ssh -i $__xc_tmp/id -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new -o UserKnownHostsFile=$__xc_tmp/known_hosts\"\n")
+	b.WriteString("elif [ -s \"$__xc_co.password\" ]; then\n")
+	b.WriteString("  printf '#!/bin/sh\\ncase \"$1\" in Username*) cat \"%s.username\" ;; *) cat \"%s.password\" ;; esac\\n' \"$__xc_co\" \"$__xc_co\" > \"$__xc_tmp/askpass\"\n")
+	b.WriteString("  chmod 700 \"$__xc_tmp/askpass\"\n  export GIT_ASKPASS=\"$__xc_tmp/askpass\"\nfi\n")
+	fmt.Fprintf(&b, "mkdir -p %s\ncd %s\n", dest, dest)
+	b.WriteString("git init -q .\ngit remote remove origin >/dev/null 2>&1 || true\ngit remote add origin \"$(cat \"$__xc_co.url\")\"\n")
+	b.WriteString("echo \"Fetching $(git remote get-url origin | sed -E 's#//[^/@]*@#//#') at $__xc_ref\"\n")
+	fmt.Fprintf(&b, "git -c protocol.version=2 fetch --no-tags --prune%s origin \"$__xc_ref\"\n", depth)
+	b.WriteString("git checkout -q --force FETCH_HEAD\n")
+	if co.Submodules != "" {
+		rec := ""
+		if co.Submodules == "recursive" {
+			rec = " --recursive"
+		}
+		fmt.Fprintf(&b, "git submodule sync%s\ngit submodule update --init --force%s%s\n", rec, rec, depth)
+	}
+	b.WriteString("echo \"Checked out $(git rev-parse HEAD)\"\n")
+	return b.String(), nil
+}
@@ -0,0 +1,125 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/parser"
+)
+
+type fakeCheckoutSource map[string]string // 仓库名 → 克隆地址
+
+func (f fakeCheckoutSource) RepositoryCredentials(ctx context.Context, projectID, buildID uint64, repository string) (*RepositoryCredentials, error) {
+	return &RepositoryCredentials{GitURL: f[repository], Username: "ci", Password: "secret"}, nil
+}
+
+func TestCheckoutStep(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	src := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		out, err := exec.Command("git", append([]string{"-C", src, "-c", "user.name=ci", "-c", "user.email=ci@example.com"}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q", "-b", "main")
+	write := func(content string) {
+		if err := os.WriteFile(filepath.Join(src, "version.txt"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "version.txt")
+		git("commit", "-q", "-m", content)
+	}
+	write("v1")
+	first := git("rev-parse", "HEAD")
+	write("v2")
+
+	wf, err := parser.ValidateWorkflowYAML(`jobs:
+  build:
+    steps:
+      - uses: xcoding/checkout@v1
+        with: {path: head, ref: main}
+      - name: pinned
+        uses: xcoding/checkout
+        with: {repository: app, path: pinned, fetch-depth: "0"}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := wf.Jobs["build"]
+	if _, err := resolveCheckout(context.Background(), fakeCheckoutSource{}, 0, 1, job); err == nil {
+		t.Error("resolveCheckout without project: want error")
+	}
+	files, err := resolveCheckout(context.Background(), fakeCheckoutSource{"": "file://" + src, "app": "file://" + src}, 1, 1, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(files["step-2.url"]) != "file://"+src || string(files["step-2.password"]) != "secret" {
+		t.Fatalf("checkout files = %v", files)
+	}
+	coDir, err := writeCheckoutFiles("", "build-1-build", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(coDir)
+
+	ws := t.TempDir()
+	cmd := exec.Command("/bin/bash", "-c", BuildScript(job, expr.NewContext()))
+	cmd.Dir = ws
+	cmd.Env = append(os.Environ(), "XC_WORKSPACE="+ws, "XC_CHECKOUT_DIR="+coDir, "XC_COMMIT_SHA="+first)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+	if b, _ := os.ReadFile(filepath.Join(ws, "head", "version.txt")); string(b) != "v2" {
+		t.Errorf("head/version.txt = %q, want v2\n%s", b, out)
+	}
+	if b, _ := os.ReadFile(filepath.Join(ws, "pinned", "version.txt")); string(b) != "v1" {
+		t.Errorf("pinned/version.txt = %q, want v1 (XC_COMMIT_SHA)\n%s", b, out)
+	}
+	count := func(dir string) string {
+		out, _ := exec.Command("git", "-C", filepath.Join(ws, dir), "rev-list", "--count", "HEAD").Output()
+		return strings.TrimSpace(string(out))
+	}
+	if c := count("head"); c != "1" {
+		t.Errorf("head history = %s commits, want shallow 1", c)
+	}
+	if c := count("pinned"); c != "1" {
+		t.Errorf("pinned history = %s commits, want 1 (first commit, full depth)", c)
+	}
+
+	// K8s：凭据 Secret 以只读卷挂载到 runner 容器
+	spec, err := BuildJobSpecWithExtensions("ci", 1, "build-1-build", job, expr.NewContext(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := spec.Spec.Template.Spec
+	var secret string
+	for _, v := range pod.Volumes {
+		if v.Secret != nil {
+			secret = v.Secret.SecretName
+		}
+	}
+	if secret != "build-1-build-checkout" {
+		t.Errorf("checkout secret volume = %q", secret)
+	}
+	mounted := false
+	for _, m := range pod.Containers[0].VolumeMounts {
+		mounted = mounted || (m.MountPath == checkoutMountPath && m.ReadOnly)
+	}
+	if !mounted {
+		t.Errorf("runner mounts = %+v, want read-only %s", pod.Containers[0].VolumeMounts, checkoutMountPath)
+	}
+
+	if _, err := parser.ValidateWorkflowYAML("jobs:\n  a:\n    steps:\n      - uses: xcoding/checkout\n        with: {fetch-depth: shallow}\n"); err == nil {
+		t.Error("invalid fetch-depth: want validation error")
+	}
+}
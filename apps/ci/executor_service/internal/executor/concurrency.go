@@ -0,0 +1,161 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
+	civ1 "xcoding/gen/go/ci/v1"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// concurrencyPollInterval Job 等待并发组释放的轮询间隔
+var concurrencyPollInterval = 5 * time.Second
+
+// ConcurrencyLockName 并发组锁名：与 GitHub 按仓库划分一致，并发组在项目内生效（同一项目的不同流水线可共用组名互斥部署）
+// 工作流级与 Job 级共用组名空间
+func ConcurrencyLockName(projectID uint64, group string) string {
+	return fmt.Sprintf("%d/%s", projectID, group)
+}
+
+// WorkflowConcurrencyGroup 求值工作流级 concurrency.group（可引用 github 与 inputs 上下文），未配置时返回空串
+func WorkflowConcurrencyGroup(db *gorm.DB, b *models.Build, wf *parser.Workflow) (string, error) {
+	if wf.Concurrency == nil {
+		return "", nil
+	}
+	inputs, _ := loadInputs(db, b.ID, wf)
+	ectx := expr.NewContext()
+	ectx.Set("github", githubContext(b, wf))
+	ectx.Set("inputs", inputs)
+	group, err := expr.Interpolate(wf.Concurrency.Group, ectx)
+	return strings.TrimSpace(group), err
+}
+
+// AcquireConcurrency 以构建（jobName 为空）或 Job 的身份占用并发组锁，返回是否持有
+// - 锁已由同一持有者持有（执行器重启后恢复）时视为持有；Job 所在构建持有同名的工作流级并发组时同样视为持有
+// - 持有者的构建或 Job 已结束时清除失效的锁后重新占用
+func AcquireConcurrency(db *gorm.DB, name string, buildID uint64, jobName string) (bool, error) {
+	for i := 0; i < 2; i++ {
+		holder, err := concurrencyHolder(db, name)
+		if err != nil {
+			return false, err
+		}
+		if holder == nil {
+			// 主键冲突说明其它占用方抢先，重新读取持有者
+			if err := db.Create(&models.ConcurrencyLock{Name: name, BuildID: buildID, JobName: jobName}).Error; err == nil {
+				return true, nil
+			}
+			continue
+		}
+		if holder.BuildID == buildID && (holder.JobName == jobName || holder.JobName == "") {
+			return true, nil
+		}
+		if !lockStale(db, holder) {
+			return false, nil
+		}
+		if err := db.Where("name = ? AND build_id = ? AND job_name = ?", name, holder.BuildID, holder.JobName).
+			Delete(&models.ConcurrencyLock{}).Error; err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// ReleaseConcurrency 释放构建（jobName 为空）或 Job 持有的并发组锁
+func ReleaseConcurrency(db *gorm.DB, buildID uint64, jobName string) error {
+	return db.Where("build_id = ? AND job_name = ?", buildID, jobName).Delete(&models.ConcurrencyLock{}).Error
+}
+
+// concurrencyHolder 读取并发组锁的当前持有者；无持有者时返回 nil
+func concurrencyHolder(db *gorm.DB, name string) (*models.ConcurrencyLock, error) {
+	var l models.ConcurrencyLock
+	if err := db.Where("name = ?", name).First(&l).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &l, nil
+}
+
+// lockStale 判断锁是否失效：持有的构建已结束或不存在，或持有的 Job 已结束
+func lockStale(db *gorm.DB, l *models.ConcurrencyLock) bool {
+	var b models.Build
+	if err := db.Select("id", "status").First(&b, l.BuildID).Error; err != nil {
+		return errors.Is(err, gorm.ErrRecordNotFound)
+	}
+	switch civ1.BuildStatus(b.Status) {
+	case civ1.BuildStatus_BUILD_STATUS_SUCCEEDED, civ1.BuildStatus_BUILD_STATUS_FAILED, civ1.BuildStatus_BUILD_STATUS_CANCELLED:
+		return true
+	}
+	if l.JobName == "" {
+		return false
+	}
+	var j models.BuildJob
+	if err := db.Select("id", "status").Where("build_id = ? AND name = ?", l.BuildID, l.JobName).First(&j).Error; err != nil {
+		return errors.Is(err, gorm.ErrRecordNotFound)
+	}
+	return j.Status != "pending" && j.Status != "running"
+}
+
+// acquireJobConcurrency 运行 Job 前占用 Job 级并发组，组被占用时保持 pending 等待
+// cancel-in-progress 为 true 时取消组内进行中的 Job（由构建持有时取消该构建）后占用
+func (r *workflowRun) acquireJobConcurrency(ctx context.Context, name string, c *parser.Concurrency) error {
+	lock := ConcurrencyLockName(r.build.ProjectID, c.Group)
+	waiting := false
+	for {
+		ok, err := AcquireConcurrency(r.e.DB, lock, r.buildID, name)
+		if err != nil {
+			return fmt.Errorf("acquire concurrency group %q: %w", c.Group, err)
+		}
+		if ok {
+			return nil
+		}
+		if c.CancelInProgress && r.cancelHolder(lock) {
+			continue
+		}
+		if !waiting {
+			waiting = true
+			logrus.WithFields(logrus.Fields{"build_id": r.buildID, "job": name, "group": c.Group}).Info("waiting for concurrency group")
+		}
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(concurrencyPollInterval):
+		}
+	}
+}
+
+// cancelHolder 取消并发组锁的持有者，返回是否取消成功
+// - Job 持有：Job 及其未结束的步骤记为 cancelled，并通过 runner 终止运行中的 Job（所在构建按 Job 取消收敛）
+// - 构建持有：构建记为 CANCELLED，由运行该构建的副本续约时中止
+func (r *workflowRun) cancelHolder(lock string) bool {
+	holder, err := concurrencyHolder(r.e.DB, lock)
+	if err != nil || holder == nil || holder.BuildID == r.buildID {
+		return false
+	}
+	now := time.Now()
+	if holder.JobName == "" {
+		res := r.e.DB.Model(&models.Build{}).Where("id = ? AND status IN ?", holder.BuildID, []int32{
+			int32(civ1.BuildStatus_BUILD_STATUS_PENDING),
+			int32(civ1.BuildStatus_BUILD_STATUS_QUEUED),
+			int32(civ1.BuildStatus_BUILD_STATUS_RUNNING),
+		}).Updates(map[string]any{"status": int32(civ1.BuildStatus_BUILD_STATUS_CANCELLED), "finished_at": &now})
+		return res.Error == nil && res.RowsAffected == 1
+	}
+	res := r.e.DB.Model(&models.BuildJob{}).Where("build_id = ? AND name = ? AND status IN ?", holder.BuildID, holder.JobName, []string{"pending", "running"}).
+		Updates(map[string]any{"status": "cancelled", "finished_at": &now, "reason": "cancelled by concurrency group"})
+	if res.Error != nil || res.RowsAffected != 1 {
+		return false
+	}
+	finalizeSteps(r.e.DB, holder.BuildID, holder.JobName, "cancelled")
+	_ = r.e.Runner.Cancel(context.Background(), k8sJobName(holder.BuildID, holder.JobName))
+	logrus.WithFields(logrus.Fields{"build_id": r.buildID, "cancelled_build_id": holder.BuildID, "cancelled_job": holder.JobName}).Info("cancelled job in concurrency group")
+	return true
+}
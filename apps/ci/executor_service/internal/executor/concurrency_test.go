@@ -0,0 +1,68 @@
+package executor
+
+import (
+	"testing"
+	"xcoding/apps/ci/executor_service/models"
+	civ1 "xcoding/gen/go/ci/v1"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestAcquireConcurrency(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Build{}, &models.BuildJob{}, &models.ConcurrencyLock{}); err != nil {
+		t.Fatal(err)
+	}
+	running := int32(civ1.BuildStatus_BUILD_STATUS_RUNNING)
+	for _, b := range []models.Build{{ID: 1, Name: "a", Status: running}, {ID: 2, Name: "b", Status: running}} {
+		if err := db.Create(&b).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, j := range []models.BuildJob{{BuildID: 1, Name: "deploy", Status: "running"}, {BuildID: 2, Name: "deploy", Status: "pending"}} {
+		if err := db.Create(&j).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	lock := ConcurrencyLockName(7, "production")
+	acquire := func(buildID uint64, job string, want bool) {
+		t.Helper()
+		ok, err := AcquireConcurrency(db, lock, buildID, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Errorf("AcquireConcurrency(build %d, job %q) = %v, want %v", buildID, job, ok, want)
+		}
+	}
+
+	acquire(1, "", true)
+	acquire(1, "", true)       // 重复占用（恢复构建）
+	acquire(1, "deploy", true) // 构建已持有同名工作流级并发组
+	acquire(2, "", false)
+	acquire(2, "deploy", false)
+
+	// 构建 1 释放工作流级并发组后，其 Job 与其它构建竞争
+	if err := ReleaseConcurrency(db, 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	acquire(1, "deploy", true)
+	acquire(2, "deploy", false)
+
+	// 持有者 Job 结束后锁失效
+	if err := db.Model(&models.BuildJob{}).Where("build_id = 1").Update("status", "cancelled").Error; err != nil {
+		t.Fatal(err)
+	}
+	acquire(2, "deploy", true)
+
+	// 持有者构建结束后锁失效
+	if err := db.Model(&models.Build{}).Where("id = 2").Update("status", int32(civ1.BuildStatus_BUILD_STATUS_SUCCEEDED)).Error; err != nil {
+		t.Fatal(err)
+	}
+	acquire(1, "", true)
+}
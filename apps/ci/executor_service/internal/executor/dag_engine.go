@@ -2,137 +2,292 @@ package executor
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
-	"xcoding/apps/ci/executor_service/internal/parser"
+	"xcoding/apps/ci/executor_service/expr"
 	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
 	civ1 "xcoding/gen/go/ci/v1"
 
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+// ErrDetached 构建从本执行器脱离（如租约被其它副本接管）：停止跟随但不终止运行后端中的 Job，也不落库终态
+// 以 context.WithCancelCause 的 cause 传入 RunWorkflow/ResumeWorkflow 的 ctx
+var ErrDetached = errors.New("build detached from this executor")
+
+// ErrCancelled 构建被取消（取消接口或 concurrency.cancel-in-progress）：终止运行中的 Job，未结束的 Job 与步骤记为 cancelled
+// 以 context.WithCancelCause 的 cause 传入 RunWorkflow/ResumeWorkflow 的 ctx
+var ErrCancelled = errors.New("build cancelled")
+
+// EngineOptions 引擎运行参数
+type EngineOptions struct {
+	MaxParallelJobs int // 单个构建同时运行的 Job 上限，0 表示不限
+	// Checkout 检出凭据来源（code_repository 服务）；nil 时含 xcoding/checkout 步骤的 Job 失败
+	Checkout CheckoutSource
+	// Artifacts 制品与缓存服务入口；nil 时含制品或缓存步骤的 Job 失败
+	Artifacts *ArtifactEndpoint
+}
+
 type Engine struct {
-    Env *K8sEnv
-    DB  *gorm.DB
+	Runner  JobRunner
+	DB      *gorm.DB
+	Options EngineOptions
 }
 
-// NewEngine 创建工作流执行引擎：负责 DAG 并发运行
-func NewEngine(env *K8sEnv, db *gorm.DB, _ func(ctx context.Context, buildID uint64, seq uint64, line string)) *Engine {
-    return &Engine{Env: env, DB: db}
+// NewEngine 创建工作流执行引擎：负责 DAG 并发运行，Job 经由 runner 执行
+func NewEngine(runner JobRunner, db *gorm.DB, _ func(ctx context.Context, buildID uint64, seq uint64, line string)) *Engine {
+	return &Engine{Runner: runner, DB: db}
 }
 
-// RunWorkflow 并发运行工作流（按 needs 约束），基础版本：每个 Job 仅运行第一步的 run
+// RunWorkflow 并发运行工作流（按 needs 约束）
 // 主要职责：
-// - 基于 workflow 构建 DAG，识别就绪的 Job 并发执行
-// - 跟踪每个 Job 的运行状态（pending/running/succeeded/failed）
-// - Job 完成后检查其 dependents 是否满足依赖，从而推进下一批就绪 Job
+// - 基于 workflow 构建 DAG，交由 runDAG 事件驱动地调度：任务的 needs 全部进入终态即求值 if 条件并启动
+// - 上游失败/跳过向下游传播；矩阵子任务支持 fail-fast 与 max-parallel；单个构建受 MaxParallelJobs 限流
+// - Job 结束后对 outputs 映射求值，供下游通过 needs.<job>.outputs 引用
 // - 所有 Job 完成后，按严格规则计算构建终态：
-//   * 存在任意 failed → Build=FAILED
-//   * 全部 succeeded → Build=SUCCEEDED
-//   * 其它（仍有 running/pending）→ Build=RUNNING（不写 finished_at）
+//   - 存在任意 failed/cancelled，或存在始终无法就绪的 Job → Build=FAILED
+//   - 全部 succeeded/skipped → Build=SUCCEEDED
+//   - 构建已被取消时不覆盖 CANCELLED
+//
+// - ctx 以 ErrCancelled 取消时不再启动任务，终止运行中的 Job，未结束的 Job 与步骤记为 cancelled，构建记为 CANCELLED
 func (e *Engine) RunWorkflow(ctx context.Context, buildID uint64, wf *parser.Workflow) error {
+	return e.runWorkflow(ctx, buildID, wf, false)
+}
+
+// ResumeWorkflow 执行器重启后继续运行中的构建（依据 BuildJob 落库状态）：
+// - 已结束的 Job 保留终态与输出，不再运行
+// - running 的 Job 重新附着到运行后端中已存在的 Job，从最后落库的日志位置继续；Job 已消失时判定失败并记录原因
+// - 其余 Job 按 DAG 正常调度，构建终态计算与 RunWorkflow 相同
+func (e *Engine) ResumeWorkflow(ctx context.Context, buildID uint64, wf *parser.Workflow) error {
+	return e.runWorkflow(ctx, buildID, wf, true)
+}
+
+func (e *Engine) runWorkflow(ctx context.Context, buildID uint64, wf *parser.Workflow, resume bool) error {
 	dag := BuildDAG(wf)
-	// 将全局 workflow 环境变量合并到每个 job 的环境变量中
-	if len(wf.Env) > 0 {
-		for name, job := range dag.Jobs {
-			newEnv := make(map[string]string)
-			// 1. 添加全局环境变量
-			for k, v := range wf.Env {
-				newEnv[k] = v
-			}
-			// 2. 使用 Job 级环境变量覆盖（优先级更高）
-			for k, v := range job.Env {
-				newEnv[k] = v
-			}
-			job.Env = newEnv
-			dag.Jobs[name] = job
+	run := &workflowRun{
+		e:        e,
+		buildID:  buildID,
+		wf:       wf,
+		dag:      dag,
+		jobCtx:   map[string]*expr.Context{},
+		resolved: map[string]parser.Job{},
+		outputs:  map[string]map[string]any{},
+	}
+	// 读取构建元数据，用于构造 github 上下文
+	_ = e.DB.First(&run.build, buildID).Error
+	// 读取手动触发的输入，用于 inputs 上下文与 INPUT_* 环境变量
+	run.inputs, run.inputEnv = loadInputs(e.DB, buildID, wf)
+
+	// 合并每个 job 的环境变量，优先级从低到高：
+	// INPUT_* < 工作流 env < Job env < 构建变量 < XC_BUILD_ID/XC_PIPELINE_ID/XC_COMMIT_SHA/XC_BRANCH
+	// 步骤 env 在脚本中导出，对该步骤覆盖以上全部；INPUT_* 与构建变量的值按字面注入
+	vars := buildVariables(&run.build, wf)
+	std := standardEnv(buildID, &run.build)
+	for name, job := range dag.Jobs {
+		job.Env, job.LiteralEnv = jobEnv(run.inputEnv, wf.Env, job.Env, vars, std)
+		dag.Jobs[name] = job
+	}
+
+	var initial map[string]string
+	if resume {
+		initial = run.restore()
+	}
+	state := runDAG(ctx, dag, e.Options.MaxParallelJobs, run, initial)
+	if ctx.Err() != nil {
+		// 构建被取消时收尾；其它中止原因（如脱离本执行器）不计算终态，交由接管方收敛
+		cause := context.Cause(ctx)
+		if errors.Is(cause, ErrCancelled) {
+			run.cancelRemaining(state)
 		}
+		return cause
 	}
-	state := map[string]string{} // pending/running/succeeded/failed
-	ready := []string{}
+
+	// 结束状态更新：所有 Job 成功或跳过时标记构建为 SUCCEEDED；存在失败的 Job 时为 FAILED
+	// 否则存在被取消的 Job（如被并发组 cancel-in-progress 取代）时为 CANCELLED
+	now := time.Now()
+	status := civ1.BuildStatus_BUILD_STATUS_SUCCEEDED
+	failed := false
 	for name := range dag.Jobs {
-		if len(dag.Needs[name]) == 0 {
-			ready = append(ready, name)
-		} else {
-			state[name] = "pending"
+		switch state[name] {
+		case "succeeded", "skipped":
+		case "cancelled":
+			status = civ1.BuildStatus_BUILD_STATUS_CANCELLED
+		case "pending":
+			// 依赖无法满足（正常情况下已被工作流校验拦截），避免构建永久停留在 RUNNING
+			markJobSkipped(e.DB, buildID, name)
+			failed = true
+		default:
+			failed = true
 		}
 	}
-	var mu sync.Mutex
-	var wg sync.WaitGroup
+	if failed {
+		status = civ1.BuildStatus_BUILD_STATUS_FAILED
+	}
+	// 构建在最后一个 Job 结束后才被取消时保留 CANCELLED
+	_ = e.DB.Model(&models.Build{}).Where("id = ? AND status <> ?", buildID, int32(civ1.BuildStatus_BUILD_STATUS_CANCELLED)).
+		Updates(map[string]any{"status": int32(status), "finished_at": &now}).Error
+	return nil
+}
 
-	// 标记状态函数
-	mark := func(job string, st string) {
-		mu.Lock()
-		state[job] = st
-		mu.Unlock()
+// loadInputs 读取手动触发的输入，返回 inputs 上下文（boolean/number 已转换类型）与 INPUT_* 环境变量；非手动触发时均为 nil
+func loadInputs(db *gorm.DB, buildID uint64, wf *parser.Workflow) (map[string]any, map[string]string) {
+	var snap models.BuildSnapshot
+	if err := db.Select("inputs").Where("build_id = ?", buildID).First(&snap).Error; err != nil {
+		return nil, nil
+	}
+	resolved := make(map[string]string, len(snap.Inputs))
+	for k, v := range snap.Inputs {
+		resolved[k] = fmt.Sprint(v)
 	}
+	return parser.InputValues(wf.On.Dispatch, resolved), parser.InputEnv(resolved)
+}
 
-	runJob := func(name string) {
-		defer wg.Done()
-		j := dag.Jobs[name]
-		sched := NewScheduler(e.Env, e.DB)
-		if err := sched.RunSingleJob(ctx, buildID, name, j); err != nil {
-			mark(name, "failed")
-		} else {
-			mark(name, "succeeded")
+// cancelRemaining 构建取消后收尾：未结束的 Job 及其步骤记为 cancelled，构建记为 CANCELLED（取消方通常已写入）
+func (r *workflowRun) cancelRemaining(state map[string]string) {
+	for name := range r.dag.Jobs {
+		switch state[name] {
+		case "succeeded", "failed", "skipped", "cancelled":
+		default:
+			markJobCancelled(r.e.DB, r.buildID, name)
 		}
-		mu.Lock()
-		for _, dep := range dag.Dependents[name] {
-			// 检查 dep 的 needs 是否均完成
-			ok := true
-			for _, n := range dag.Needs[dep] {
-				if state[n] != "succeeded" {
-					ok = false
-					break
-				}
-			}
-			if ok && state[dep] == "pending" {
-				ready = append(ready, dep)
+	}
+	now := time.Now()
+	_ = r.e.DB.Model(&models.Build{}).Where("id = ? AND status IN ?", r.buildID, []int32{
+		int32(civ1.BuildStatus_BUILD_STATUS_PENDING),
+		int32(civ1.BuildStatus_BUILD_STATUS_QUEUED),
+		int32(civ1.BuildStatus_BUILD_STATUS_RUNNING),
+	}).Updates(map[string]any{"status": int32(civ1.BuildStatus_BUILD_STATUS_CANCELLED), "finished_at": &now}).Error
+}
+
+// workflowRun 单次构建的 jobHandler 实现：负责表达式求值、Job 运行与状态落库
+type workflowRun struct {
+	e       *Engine
+	buildID uint64
+	wf      *parser.Workflow
+	dag     *DAG
+	build   models.Build
+
+	inputs   map[string]any    // inputs 上下文
+	inputEnv map[string]string // INPUT_* 环境变量
+	attach   map[string]bool   // 恢复构建时需重新附着的运行中任务
+
+	mu       sync.Mutex
+	jobCtx   map[string]*expr.Context  // 每个任务的表达式上下文
+	resolved map[string]parser.Job     // 完成表达式替换后的任务定义
+	outputs  map[string]map[string]any // 已结束任务的 jobs.<id>.outputs
+}
+
+// restore 读取已落库的 Job 状态：返回已结束任务的终态并载入其输出，记录需重新附着的运行中任务
+func (r *workflowRun) restore() map[string]string {
+	var rows []models.BuildJob
+	_ = r.e.DB.Where("build_id = ?", r.buildID).Find(&rows).Error
+	initial := map[string]string{}
+	r.attach = map[string]bool{}
+	for _, row := range rows {
+		if _, ok := r.dag.Jobs[row.Name]; !ok {
+			continue
+		}
+		switch row.Status {
+		case "succeeded", "failed", "skipped", "cancelled":
+			initial[row.Name] = row.Status
+			out := map[string]any{}
+			for k, v := range row.Outputs {
+				out[k] = v
 			}
+			r.outputs[row.Name] = out
+		case "running":
+			r.attach[row.Name] = true
 		}
-		mu.Unlock()
-	}
-	for len(ready) > 0 {
-		mu.Lock()
-		batch := append([]string{}, ready...)
-		ready = ready[:0]
-		mu.Unlock()
-		for _, name := range batch {
-			wg.Add(1)
-			mark(name, "running")
-			go runJob(name)
+	}
+	return initial
+}
+
+// Prepare 构造表达式上下文并求值 if 条件；条件为真时完成 ${{ }} 替换
+func (r *workflowRun) Prepare(name string, up upstream, state map[string]string) (bool, error) {
+	job := r.dag.Jobs[name]
+	r.mu.Lock()
+	ectx := newJobContext(&r.build, r.wf, r.dag, name, state, r.outputs, r.inputs).With(up.Failed, false)
+	ectx.Skipped = up.Skipped
+	r.jobCtx[name] = ectx
+	r.mu.Unlock()
+	ok, err := expr.EvaluateCondition(job.If, ectx)
+	if err == nil && ok {
+		var resolved parser.Job
+		if resolved, err = interpolateJob(job, ectx); err == nil {
+			r.mu.Lock()
+			r.resolved[name] = resolved
+			r.mu.Unlock()
+			return true, nil
 		}
-		wg.Wait()
-	}
-    // 结束状态更新：仅当所有 Job 成功时才标记构建为 SUCCEEDED；存在失败则 FAILED；否则 RUNNING
-    now := time.Now()
-    total := len(dag.Jobs)
-    succeeded := 0
-    failed := false
-    for name := range dag.Jobs {
-        st := state[name]
-        switch st {
-        case "failed":
-            failed = true
-        case "succeeded":
-            succeeded++
-        case "running", "pending", "":
-            // 未完成或未调度，保持运行态
-        }
-        if failed {
-            break
-        }
-    }
-    var status civ1.BuildStatus = civ1.BuildStatus_BUILD_STATUS_RUNNING
-    if failed {
-        status = civ1.BuildStatus_BUILD_STATUS_FAILED
-    } else if succeeded == total {
-        status = civ1.BuildStatus_BUILD_STATUS_SUCCEEDED
-    }
-    if status == civ1.BuildStatus_BUILD_STATUS_SUCCEEDED || status == civ1.BuildStatus_BUILD_STATUS_FAILED {
-        _ = e.DB.Model(&models.Build{}).Where("id = ?", buildID).Updates(map[string]any{"status": int32(status), "finished_at": &now}).Error
-    } else {
-        _ = e.DB.Model(&models.Build{}).Where("id = ?", buildID).Updates(map[string]any{"status": int32(status)}).Error
-    }
-	return nil
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("job", name).Warn("evaluate job expressions failed")
+	}
+	return false, err
+}
+
+// Run 运行单个任务；被取消（矩阵 fail-fast 或构建取消）时立即通过 runner 终止 Job
+// 配置 Job 级 concurrency 时先占用并发组（组被占用时保持 pending 等待），Job 结束后释放
+// 配置 continue-on-error 的 Job 失败时返回 nil
+func (r *workflowRun) Run(ctx context.Context, name string) error {
+	r.mu.Lock()
+	job, ectx := r.resolved[name], r.jobCtx[name]
+	r.mu.Unlock()
+	stop := context.AfterFunc(ctx, func() {
+		if errors.Is(context.Cause(ctx), ErrDetached) {
+			return
+		}
+		_ = r.e.Runner.Cancel(context.Background(), k8sJobName(r.buildID, name))
+	})
+	defer stop()
+	if c := job.Concurrency; c != nil && c.Group != "" {
+		if err := r.acquireJobConcurrency(ctx, name, c); err != nil {
+			return err
+		}
+		defer func() {
+			if !errors.Is(context.Cause(ctx), ErrDetached) {
+				_ = ReleaseConcurrency(r.e.DB, r.buildID, name)
+			}
+		}()
+	}
+	sched := NewScheduler(r.e.Runner, r.e.DB)
+	sched.Checkout, sched.ProjectID = r.e.Options.Checkout, r.build.ProjectID
+	sched.Artifacts = r.e.Options.Artifacts
+	var err error
+	if r.attach[name] {
+		err = sched.ResumeSingleJob(ctx, r.buildID, name)
+	} else {
+		if keys := deprecatedEnvKeys(job); len(keys) > 0 {
+			logrus.WithFields(logrus.Fields{"build_id": r.buildID, "job": name, "keys": keys}).Warn("deprecated XC_* control variables, use timeout-minutes/continue-on-error instead")
+		}
+		err = sched.RunSingleJob(ctx, r.buildID, name, job, ectx)
+	}
+	out := collectJobOutputs(r.e.DB, r.buildID, name, job, ectx.With(err != nil, false))
+	r.mu.Lock()
+	r.outputs[name] = out
+	r.mu.Unlock()
+	// continue-on-error：Job 仍记为 failed，但按成功推进下游与构建结论（取消不受影响）
+	if err != nil && ctx.Err() == nil && !errors.Is(err, errJobCancelled) && job.ContinueOnError.Enabled() {
+		logrus.WithError(err).WithFields(logrus.Fields{"build_id": r.buildID, "job": name}).Info("job failed, continue-on-error")
+		return nil
+	}
+	return err
+}
+
+// Record 落库未经 Run 得出的终态
+func (r *workflowRun) Record(name, state string) {
+	switch state {
+	case "skipped":
+		markJobSkipped(r.e.DB, r.buildID, name)
+	case "cancelled":
+		markJobCancelled(r.e.DB, r.buildID, name)
+	case "failed":
+		now := time.Now()
+		_ = r.e.DB.Model(&models.BuildJob{}).Where("build_id = ? AND name = ?", r.buildID, name).Updates(map[string]any{"status": "failed", "finished_at": &now}).Error
+		finalizeSteps(r.e.DB, r.buildID, name, "failed")
+	}
 }
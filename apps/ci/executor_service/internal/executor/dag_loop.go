@@ -0,0 +1,194 @@
+package executor
+
+import (
+	"context"
+	"errors"
+)
+
+// upstream 任务上游结论汇总，用于驱动 success()/failure()
+type upstream struct {
+	Failed  bool // 上游（含传递）存在失败或取消的任务
+	Skipped bool // 直接依赖中存在被跳过的任务
+}
+
+// jobHandler 调度循环与任务执行之间的交互点
+// 引擎基于数据库与 K8s 实现；单元测试中替换为假实现
+type jobHandler interface {
+	// Prepare 在任务的 needs 全部进入终态后调用，返回是否运行（if 条件）；返回 error 时任务判定为失败
+	// state 为当前各任务状态快照，仅可在调用期间读取
+	Prepare(name string, up upstream, state map[string]string) (bool, error)
+	// Run 运行任务直至结束，返回 nil 表示成功；ctx 被取消时应尽快返回
+	Run(ctx context.Context, name string) error
+	// Record 记录未经 Run 得出的终态：skipped、failed（Prepare 出错）、cancelled
+	Record(name, state string)
+}
+
+// jobDone 任务结束事件
+type jobDone struct {
+	name      string
+	err       error
+	cancelled bool
+}
+
+// runDAG 事件驱动地调度 DAG，返回各任务终态
+// 说明：
+// - 任务的 needs 全部进入终态即求值 if 条件并启动，不等待无关分支
+// - limit 为单个构建同时运行的任务上限（0 表示不限）；矩阵子任务另受 max-parallel 约束
+// - 上游失败与跳过沿依赖向下游传播，由 Prepare 结合 if 条件决定下游是运行还是跳过
+// - 矩阵 fail-fast：子任务失败时取消同组尚未结束的子任务
+// - 因依赖无法满足而始终未进入就绪的任务保持 pending，由调用方判定
+// - initial 为预置终态的任务（恢复构建时已结束的任务），不再运行也不经 Record 落库
+// - ctx 被取消后不再推进与启动任务，等待运行中的任务退出后返回（其状态保持 running）
+func runDAG(ctx context.Context, dag *DAG, limit int, h jobHandler, initial map[string]string) map[string]string {
+	state := map[string]string{} // pending/ready/running/succeeded/failed/skipped/cancelled
+	upFailed := map[string]bool{}
+	cancels := map[string]context.CancelFunc{}
+	groupRunning := map[string]int{}
+	running := 0
+	var queue []string
+	done := make(chan jobDone)
+	for name := range dag.Jobs {
+		state[name] = "pending"
+		if st, ok := initial[name]; ok {
+			state[name] = st
+		}
+	}
+	// 预置终态的任务按依赖补全上游失败标记，供下游的 success()/failure() 使用
+	var inherit func(name string) bool
+	inherit = func(name string) bool {
+		if f, ok := upFailed[name]; ok {
+			return f
+		}
+		f := false
+		for _, n := range dag.Needs[name] {
+			if state[n] == "failed" || state[n] == "cancelled" || inherit(n) {
+				f = true
+			}
+		}
+		upFailed[name] = f
+		return f
+	}
+	for name := range initial {
+		inherit(name)
+	}
+
+	var resolve func(name string)
+	advance := func(name string) {
+		for _, dep := range dag.Dependents[name] {
+			resolve(dep)
+		}
+	}
+	settle := func(name, st string) {
+		state[name] = st
+		h.Record(name, st)
+		advance(name)
+	}
+	// resolve 在任务的 needs 全部进入终态后决定其去向
+	resolve = func(name string) {
+		if state[name] != "pending" {
+			return
+		}
+		up := upstream{}
+		for _, n := range dag.Needs[name] {
+			switch state[n] {
+			case "failed", "cancelled":
+				up.Failed = true
+			case "skipped":
+				up.Skipped = true
+			case "succeeded":
+			default:
+				return
+			}
+			if upFailed[n] {
+				up.Failed = true
+			}
+		}
+		upFailed[name] = up.Failed
+		run, err := h.Prepare(name, up, state)
+		switch {
+		case err != nil:
+			settle(name, "failed")
+		case run:
+			state[name] = "ready"
+			queue = append(queue, name)
+		default:
+			settle(name, "skipped")
+		}
+	}
+
+	// cancelSiblings 矩阵 fail-fast：取消同组尚未结束的其它子任务
+	cancelSiblings := func(name string) {
+		for _, sib := range dag.Groups[dag.Parent[name]] {
+			switch state[sib] {
+			case "pending", "ready":
+				settle(sib, "cancelled")
+			case "running":
+				if cancel := cancels[sib]; cancel != nil {
+					cancel()
+				}
+			}
+		}
+	}
+
+	// launch 按就绪顺序启动任务，受并发上限与 max-parallel 约束的任务留在队列中
+	launch := func() {
+		var rest []string
+		for _, name := range queue {
+			if state[name] != "ready" {
+				continue
+			}
+			parent := dag.Parent[name]
+			if limit > 0 && running >= limit {
+				rest = append(rest, name)
+				continue
+			}
+			if st := dag.Strategy(name); st != nil && st.MaxParallel > 0 && groupRunning[parent] >= st.MaxParallel {
+				rest = append(rest, name)
+				continue
+			}
+			jctx, cancel := context.WithCancel(ctx)
+			cancels[name] = cancel
+			state[name] = "running"
+			running++
+			groupRunning[parent]++
+			go func(name string) {
+				err := h.Run(jctx, name)
+				done <- jobDone{name: name, err: err, cancelled: jctx.Err() != nil && ctx.Err() == nil}
+			}(name)
+		}
+		queue = rest
+	}
+
+	// needs 已全部进入终态的任务（无依赖，或恢复构建时上游已结束）直接求值
+	for _, name := range dag.Names() {
+		resolve(name)
+	}
+	launch()
+	for running > 0 {
+		ev := <-done
+		running--
+		groupRunning[dag.Parent[ev.name]]--
+		cancels[ev.name]()
+		delete(cancels, ev.name)
+		if ctx.Err() != nil {
+			// 构建被中止：等待运行中的任务退出，不再推进下游
+			continue
+		}
+		switch {
+		case ev.err == nil:
+			state[ev.name] = "succeeded"
+		case ev.cancelled || errors.Is(ev.err, errJobCancelled):
+			// 矩阵 fail-fast 取消，或被并发组 cancel-in-progress 取代（此时 jctx 仍有效）：记为取消，不触发 fail-fast
+			h.Record(ev.name, "cancelled")
+			state[ev.name] = "cancelled"
+		default:
+			state[ev.name] = "failed"
+			if dag.Strategy(ev.name).FailFastEnabled() {
+				cancelSiblings(ev.name)
+			}
+		}
+		advance(ev.name)
+		launch()
+	}
+	return state
+}
@@ -0,0 +1,305 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/parser"
+)
+
+// fakeHandler jobHandler 的伪实现：按配置返回任务结果，并记录并发峰值
+type fakeHandler struct {
+	dag        *DAG
+	fail       map[string]bool          // 运行失败的任务
+	wait       map[string]string        // 任务 → 需等待其启动后才结束的任务（验证不存在批次屏障）
+	hold       map[string]bool          // 运行至被取消为止的任务
+	superseded map[string]bool          // 被并发组 cancel-in-progress 取代的任务（jctx 未取消即返回 errJobCancelled）
+	gate       map[string]chan struct{} // 任务启动信号
+	t          *testing.T
+
+	mu       sync.Mutex
+	running  int
+	peak     int
+	recorded map[string]string
+}
+
+func newFakeHandler(t *testing.T, dag *DAG) *fakeHandler {
+	h := &fakeHandler{dag: dag, t: t, fail: map[string]bool{}, wait: map[string]string{}, hold: map[string]bool{}, superseded: map[string]bool{}, gate: map[string]chan struct{}{}, recorded: map[string]string{}}
+	for name := range dag.Jobs {
+		h.gate[name] = make(chan struct{})
+	}
+	return h
+}
+
+func (h *fakeHandler) Prepare(name string, up upstream, state map[string]string) (bool, error) {
+	ctx := expr.NewContext().With(up.Failed, false)
+	ctx.Skipped = up.Skipped
+	return expr.EvaluateCondition(h.dag.Jobs[name].If, ctx)
+}
+
+func (h *fakeHandler) Run(ctx context.Context, name string) error {
+	h.mu.Lock()
+	h.running++
+	if h.running > h.peak {
+		h.peak = h.running
+	}
+	h.mu.Unlock()
+	close(h.gate[name])
+	defer func() {
+		h.mu.Lock()
+		h.running--
+		h.mu.Unlock()
+	}()
+	if other, ok := h.wait[name]; ok {
+		select {
+		case <-h.gate[other]:
+		case <-time.After(2 * time.Second):
+			h.t.Errorf("job %s blocked: %s never started", name, other)
+		}
+	}
+	if h.superseded[name] {
+		return fmt.Errorf("job %s: %w", name, errJobCancelled)
+	}
+	if h.hold[name] {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+			h.t.Errorf("job %s was not cancelled", name)
+		}
+	}
+	// 留出时间让并发任务重叠，便于统计并发峰值
+	time.Sleep(10 * time.Millisecond)
+	if h.fail[name] {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func (h *fakeHandler) Record(name, state string) {
+	h.mu.Lock()
+	h.recorded[name] = state
+	h.mu.Unlock()
+}
+
+func mustDAG(t *testing.T, content string) *DAG {
+	t.Helper()
+	wf, err := parser.ParseWorkflowYAML(content)
+	if err != nil {
+		t.Fatalf("parse workflow: %v", err)
+	}
+	return BuildDAG(wf)
+}
+
+func TestRunDAG(t *testing.T) {
+	cases := []struct {
+		name       string
+		workflow   string
+		limit      int
+		fail       []string
+		wait       map[string]string
+		hold       []string
+		superseded []string
+		initial    map[string]string // 预置终态（恢复构建）
+		want       map[string]string
+		peak       int // 并发峰值上限，0 表示不检查
+	}{
+		{
+			name: "linear chain",
+			workflow: `
+jobs:
+  a: {steps: [{run: x}]}
+  b: {needs: a, steps: [{run: x}]}
+  c: {needs: b, steps: [{run: x}]}
+`,
+			want: map[string]string{"a": "succeeded", "b": "succeeded", "c": "succeeded"},
+		},
+		{
+			// a 在 c 启动前不会结束：若存在批次屏障（c 需等待 a 所在批次结束），该用例会超时
+			name: "dependent starts without waiting for unrelated jobs",
+			workflow: `
+jobs:
+  a: {steps: [{run: x}]}
+  b: {steps: [{run: x}]}
+  c: {needs: b, steps: [{run: x}]}
+`,
+			wait: map[string]string{"a": "c"},
+			want: map[string]string{"a": "succeeded", "b": "succeeded", "c": "succeeded"},
+		},
+		{
+			name: "failure propagates to dependents",
+			workflow: `
+jobs:
+  a: {steps: [{run: x}]}
+  b: {needs: a, steps: [{run: x}]}
+  c: {needs: b, steps: [{run: x}]}
+  d: {needs: c, if: "failure()", steps: [{run: x}]}
+  e: {needs: a, if: "always()", steps: [{run: x}]}
+`,
+			fail: []string{"a"},
+			want: map[string]string{"a": "failed", "b": "skipped", "c": "skipped", "d": "succeeded", "e": "succeeded"},
+		},
+		{
+			name: "skip propagates to dependents",
+			workflow: `
+jobs:
+  a: {if: "false", steps: [{run: x}]}
+  b: {needs: a, steps: [{run: x}]}
+  c: {needs: a, if: "always()", steps: [{run: x}]}
+  d: {needs: a, if: "failure()", steps: [{run: x}]}
+`,
+			want: map[string]string{"a": "skipped", "b": "skipped", "c": "succeeded", "d": "skipped"},
+		},
+		{
+			name: "per-build concurrency limit",
+			workflow: `
+jobs:
+  a: {steps: [{run: x}]}
+  b: {steps: [{run: x}]}
+  c: {steps: [{run: x}]}
+  d: {steps: [{run: x}]}
+  e: {needs: [a, b, c, d], steps: [{run: x}]}
+`,
+			limit: 2,
+			peak:  2,
+			want:  map[string]string{"a": "succeeded", "b": "succeeded", "c": "succeeded", "d": "succeeded", "e": "succeeded"},
+		},
+		{
+			name: "matrix max-parallel",
+			workflow: `
+jobs:
+  t:
+    strategy: {max-parallel: 1, matrix: {v: [1, 2, 3]}}
+    steps: [{run: x}]
+  after: {needs: t, steps: [{run: x}]}
+`,
+			peak: 1,
+			want: map[string]string{"t (1)": "succeeded", "t (2)": "succeeded", "t (3)": "succeeded", "after": "succeeded"},
+		},
+		{
+			name: "matrix fail-fast cancels siblings",
+			workflow: `
+jobs:
+  t:
+    strategy: {max-parallel: 2, matrix: {v: [1, 2, 3]}}
+    steps: [{run: x}]
+  after: {needs: t, steps: [{run: x}]}
+`,
+			fail: []string{"t (1)"},
+			hold: []string{"t (2)"},
+			want: map[string]string{"t (1)": "failed", "t (2)": "cancelled", "t (3)": "cancelled", "after": "skipped"},
+		},
+		{
+			// 被并发组取代的任务记为 cancelled：不触发 fail-fast，下游按 if 条件求值
+			name: "job superseded by concurrency group",
+			workflow: `
+jobs:
+  t:
+    strategy: {matrix: {v: [1, 2]}}
+    steps: [{run: x}]
+  after: {needs: t, steps: [{run: x}]}
+  cleanup: {needs: t, if: "always()", steps: [{run: x}]}
+`,
+			wait:       map[string]string{"t (1)": "t (2)"},
+			superseded: []string{"t (1)"},
+			want:       map[string]string{"t (1)": "cancelled", "t (2)": "succeeded", "after": "skipped", "cleanup": "succeeded"},
+		},
+		{
+			name: "matrix without fail-fast",
+			workflow: `
+jobs:
+  t:
+    strategy: {fail-fast: false, matrix: {v: [1, 2]}}
+    steps: [{run: x}]
+`,
+			fail: []string{"t (1)"},
+			want: map[string]string{"t (1)": "failed", "t (2)": "succeeded"},
+		},
+		{
+			// 恢复构建：已结束的任务不再运行，上游失败经 always() 任务传递给下游
+			name: "resume from persisted states",
+			workflow: `
+jobs:
+  a: {steps: [{run: x}]}
+  b: {needs: a, if: "always()", steps: [{run: x}]}
+  c: {needs: b, steps: [{run: x}]}
+  d: {needs: b, if: "failure()", steps: [{run: x}]}
+  e: {steps: [{run: x}]}
+  f: {needs: e, steps: [{run: x}]}
+`,
+			initial: map[string]string{"a": "failed", "b": "succeeded", "e": "succeeded"},
+			want:    map[string]string{"a": "failed", "b": "succeeded", "c": "skipped", "d": "succeeded", "e": "succeeded", "f": "succeeded"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dag := mustDAG(t, tc.workflow)
+			h := newFakeHandler(t, dag)
+			for _, n := range tc.fail {
+				h.fail[n] = true
+			}
+			for _, n := range tc.hold {
+				h.hold[n] = true
+			}
+			for _, n := range tc.superseded {
+				h.superseded[n] = true
+			}
+			for k, v := range tc.wait {
+				h.wait[k] = v
+			}
+			got := runDAG(context.Background(), dag, tc.limit, h, tc.initial)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d jobs, want %d: %v", len(got), len(tc.want), got)
+			}
+			for name, want := range tc.want {
+				if got[name] != want {
+					t.Errorf("job %q: got %q, want %q", name, got[name], want)
+				}
+			}
+			if tc.peak > 0 && h.peak > tc.peak {
+				t.Errorf("peak concurrency %d exceeds %d", h.peak, tc.peak)
+			}
+			for name := range tc.initial {
+				select {
+				case <-h.gate[name]:
+					t.Errorf("job %q: preset job was run again", name)
+				default:
+				}
+			}
+			// 未运行即结束的任务需通过 Record 落库
+			for name, st := range got {
+				if _, preset := tc.initial[name]; !preset && (st == "skipped" || st == "cancelled") {
+					if h.recorded[name] != st {
+						t.Errorf("job %q: recorded %q, want %q", name, h.recorded[name], st)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRunDAGStopsWhenCancelled(t *testing.T) {
+	dag := mustDAG(t, `
+jobs:
+  a: {steps: [{run: x}]}
+  b: {needs: a, if: "always()", steps: [{run: x}]}
+`)
+	h := newFakeHandler(t, dag)
+	h.hold["a"] = true
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-h.gate["a"]
+		cancel()
+	}()
+	got := runDAG(ctx, dag, 0, h, nil)
+	if got["a"] != "running" || got["b"] != "pending" {
+		t.Errorf("got %v, want a running and b pending", got)
+	}
+	if len(h.recorded) != 0 {
+		t.Errorf("recorded %v after cancellation, want none", h.recorded)
+	}
+}
@@ -2,148 +2,211 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
-	"xcoding/apps/ci/executor_service/internal/parser"
+	"xcoding/apps/ci/executor_service/expr"
 	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
 	civ1 "xcoding/gen/go/ci/v1"
 
 	"gorm.io/gorm"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// errJobCancelled Job 已被并发组 cancel-in-progress 取消
+var errJobCancelled = errors.New("job cancelled by concurrency group")
+
 type Scheduler struct {
-	Env *K8sEnv
-	DB  *gorm.DB
+	Runner JobRunner
+	DB     *gorm.DB
+	// Checkout 检出凭据来源，ProjectID 为构建所属项目；Job 含 xcoding/checkout 步骤时使用
+	Checkout  CheckoutSource
+	ProjectID uint64
+	// Artifacts 制品与缓存服务入口；Job 含制品或缓存步骤时注入 XC_ARTIFACT_*、XC_CACHE_URL 环境变量
+	Artifacts *ArtifactEndpoint
 }
 
 // Scheduler 负责单个 Job 的生命周期管理：
-// - 创建 K8s Job 并等待容器就绪
+// - 通过 JobRunner 创建 Job 并等待就绪（K8s 或本地 Shell）
 // - 持续读取并解析日志，驱动 Step 状态变化
 // - 在 Job 结束时（成功/失败/异常）兜底收敛 Step 终态
 // 注意：不直接修改 Build 终态，构建结果由引擎在所有 Job 完成后统一计算
 
 // NewScheduler 创建调度器，负责单个 Job 的创建、日志采集与状态落库
-func NewScheduler(env *K8sEnv, db *gorm.DB) *Scheduler {
-	return &Scheduler{Env: env, DB: db}
+func NewScheduler(runner JobRunner, db *gorm.DB) *Scheduler {
+	return &Scheduler{Runner: runner, DB: db}
 }
 
 // finalizeSteps 在 Job 结束时兜底收敛步骤状态
-// 语义：
-// - failed=true：将该 Job 下所有处于 running 的步骤置为 failed，pending 置为 skipped
-// - failed=false：将 running 置为 succeeded；理论上不应有 pending，若存在可按 skipped 处理或维持一致性
+// 语义（result 为 Job 结论）：
+// - failed：将该 Job 下所有处于 running 的步骤置为 failed，pending 置为 skipped
+// - succeeded：将 running 置为 succeeded；理论上不应有 pending，若存在按 skipped 处理以维持一致性
+// - cancelled：running 与 pending 均置为 cancelled
 // 目的：避免日志标记缺失导致步骤卡在中间态，从而保证 Job/Step 状态与实际结论一致
-func finalizeSteps(db *gorm.DB, buildID uint64, jobName string, failed bool) {
+func finalizeSteps(db *gorm.DB, buildID uint64, jobName string, result string) {
 	now := time.Now()
-	if failed {
-		_ = db.Model(&models.BuildStep{}).Where("build_id = ? AND job_name = ? AND status = ?", buildID, jobName, "running").Updates(map[string]any{"status": "failed", "finished_at": &now}).Error
-		_ = db.Model(&models.BuildStep{}).Where("build_id = ? AND job_name = ? AND status = ?", buildID, jobName, "pending").Updates(map[string]any{"status": "skipped", "finished_at": &now}).Error
-		return
+	pending := "skipped"
+	if result == "cancelled" {
+		pending = "cancelled"
 	}
-	_ = db.Model(&models.BuildStep{}).Where("build_id = ? AND job_name = ? AND status = ?", buildID, jobName, "running").Updates(map[string]any{"status": "succeeded", "finished_at": &now}).Error
-	_ = db.Model(&models.BuildStep{}).Where("build_id = ? AND job_name = ? AND status = ?", buildID, jobName, "pending").Updates(map[string]any{"status": "skipped", "finished_at": &now}).Error
+	_ = db.Model(&models.BuildStep{}).Where("build_id = ? AND job_name = ? AND status = ?", buildID, jobName, "running").Updates(map[string]any{"status": result, "finished_at": &now}).Error
+	_ = db.Model(&models.BuildStep{}).Where("build_id = ? AND job_name = ? AND status = ?", buildID, jobName, "pending").Updates(map[string]any{"status": pending, "finished_at": &now}).Error
+}
+
+// markJobSkipped 将未运行的 Job 及其全部步骤标记为 skipped（if 条件为 false 时使用）
+func markJobSkipped(db *gorm.DB, buildID uint64, jobName string) {
+	now := time.Now()
+	_ = db.Model(&models.BuildJob{}).Where("build_id = ? AND name = ?", buildID, jobName).Updates(map[string]any{"status": "skipped", "finished_at": &now}).Error
+	_ = db.Model(&models.BuildStep{}).Where("build_id = ? AND job_name = ?", buildID, jobName).Updates(map[string]any{"status": "skipped", "finished_at": &now}).Error
+}
+
+// markJobCancelled 将被取消的 Job 及其未结束的步骤标记为 cancelled（矩阵 fail-fast 或构建取消）
+func markJobCancelled(db *gorm.DB, buildID uint64, jobName string) {
+	now := time.Now()
+	_ = db.Model(&models.BuildJob{}).Where("build_id = ? AND name = ?", buildID, jobName).Updates(map[string]any{"status": "cancelled", "finished_at": &now}).Error
+	finalizeSteps(db, buildID, jobName, "cancelled")
 }
 
 // RunSingleJob 运行指定 job（不处理 needs），并把日志写入 Append
+// ectx 为该 Job 的表达式上下文，用于步骤 if 条件求值
 // 返回：
 // - nil：job 成功完成
 // - error：job 失败或日志流出错（用于通知上层引擎标记失败）
-func (s *Scheduler) RunSingleJob(ctx context.Context, buildID uint64, jobName string, job parser.Job) error {
-	ns := s.Env.Namespace
-	name := fmt.Sprintf("build-%d-%s", buildID, jobName)
+func (s *Scheduler) RunSingleJob(ctx context.Context, buildID uint64, jobName string, job parser.Job, ectx *expr.Context) error {
+	name := k8sJobName(buildID, jobName)
 	nowStart := time.Now()
 	// 标记该 Job 为 running 并记录开始时间（用于前端实时展示）
-	_ = s.DB.Model(&models.BuildJob{}).Where("build_id = ? AND name = ?", buildID, jobName).Updates(map[string]any{"status": "running", "started_at": &nowStart}).Error
-	spec := BuildJobSpecWithExtensions(ns, buildID, name, job)
-	// 创建 K8s Job，失败则直接返回错误并由引擎判定该 Job 失败
-	if _, err := s.Env.Clientset.BatchV1().Jobs(ns).Create(ctx, spec, metav1.CreateOptions{}); err != nil {
-		return fmt.Errorf("create job: %w", err)
+	// 仅更新 pending 的 Job：已被并发组 cancel-in-progress 取消的 Job 不再启动
+	res := s.DB.Model(&models.BuildJob{}).Where("build_id = ? AND name = ? AND status = ?", buildID, jobName, "pending").Updates(map[string]any{"status": "running", "started_at": &nowStart})
+	if res.Error == nil && res.RowsAffected == 0 {
+		return fmt.Errorf("job %s: %w", jobName, errJobCancelled)
 	}
-	var podName string
-	// 轮询查找第一个 Pod 名（依据 job-name 标签），避免立即读取日志失败
-	for i := 0; i < 30; i++ {
-		time.Sleep(500 * time.Millisecond)
-		p, perr := s.Env.FirstPodNameByJob(ctx, name)
-		if perr == nil && p != "" {
-			podName = p
-			break
+	// 创建 Job，失败则直接返回错误并由引擎判定该 Job 失败
+	// 被取消时（ctx 已结束）不记录失败，由引擎记录 cancelled
+	// 检出凭据在创建 Job 前获取，仓库不存在或 code_repository 不可用时判定 Job 失败
+	files, err := resolveCheckout(ctx, s.Checkout, s.ProjectID, buildID, job)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.failJob(buildID, jobName, "checkout: "+err.Error())
 		}
+		return fmt.Errorf("checkout: %w", err)
 	}
-	if podName == "" {
-		return fmt.Errorf("pod not found for job %s", name)
+	job, err = withArtifactEnv(s.Artifacts, buildID, jobName, job)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.failJob(buildID, jobName, "artifacts: "+err.Error())
+		}
+		return fmt.Errorf("artifacts: %w", err)
+	}
+	if err := s.Runner.Create(ctx, JobSpec{BuildID: buildID, ProjectID: s.ProjectID, JobName: jobName, Name: name, Job: job, Ectx: ectx, Checkout: files}); err != nil {
+		if ctx.Err() == nil {
+			s.failJob(buildID, jobName, "create job: "+err.Error())
+		}
+		return fmt.Errorf("create job: %w", err)
 	}
-	// 等待容器就绪；若出现不可调度（Unschedulable）等错误或其它未就绪情况，判定 Job 失败
+	// 等待 Job 就绪；若出现不可调度（Unschedulable）等错误或其它未就绪情况，判定 Job 失败
 	// 注意：此处不写 Build 终态，让引擎在所有 Job 完成后统一计算构建结果
-	if err := s.Env.WaitForContainerReady(ctx, podName, ns, "runner", 15*time.Second); err != nil {
-		// 快速检查 Pod 条件，捕获不可调度场景
-		pod, _ := s.Env.Clientset.CoreV1().Pods(ns).Get(ctx, podName, metav1.GetOptions{})
-		if pod != nil {
-			if isUnschedulable(pod) {
-				now := time.Now()
-				// Job 标记为失败并兜底步骤终态
-				_ = s.DB.Model(&models.BuildJob{}).Where("build_id = ? AND name = ?", buildID, jobName).Updates(map[string]any{"status": "failed", "finished_at": &now}).Error
-				finalizeSteps(s.DB, buildID, jobName, true)
-				return fmt.Errorf("job unschedulable: %s", jobName)
+	if err := s.Runner.WaitReady(ctx, name); err != nil {
+		if ctx.Err() == nil {
+			s.failJob(buildID, jobName, "job not ready: "+err.Error())
+			// 服务未通过健康检查等情况下保留服务容器已有的日志，便于排查
+			if len(job.Services) > 0 {
+				sctx, cancel := context.WithTimeout(ctx, serviceLogGrace)
+				s.captureServiceLogs(sctx, buildID, jobName, name)()
+				cancel()
 			}
 		}
-		// 其它未就绪情况，继续按失败处理（仅更新 Job 状态；Build 终态由引擎统一落库）
-		now := time.Now()
-		_ = s.DB.Model(&models.BuildJob{}).Where("build_id = ? AND name = ?", buildID, jobName).Updates(map[string]any{"status": "failed", "finished_at": &now}).Error
-		finalizeSteps(s.DB, buildID, jobName, true)
-		return fmt.Errorf("container not ready: %s", jobName)
+		return fmt.Errorf("job not ready: %s: %w", jobName, err)
+	}
+	defer s.captureServiceLogs(ctx, buildID, jobName, name)()
+	return s.follow(ctx, buildID, jobName, name, NewLogProcessor(s.DB, buildID, jobName))
+}
+
+// ResumeSingleJob 执行器重启后重新附着到仍在运行的 Job：从最后落库的日志位置继续读取并收敛终态
+// Job 或其 Pod 已不存在时判定失败并记录原因
+func (s *Scheduler) ResumeSingleJob(ctx context.Context, buildID uint64, jobName string) error {
+	name := k8sJobName(buildID, jobName)
+	if err := s.Runner.Attach(ctx, name, lastLogTime(s.DB, buildID, jobName)); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("resume job %s: %w", jobName, err)
+		}
+		reason := "resume after executor restart: " + err.Error()
+		if errors.Is(err, ErrJobGone) {
+			reason = "lost during executor restart: " + err.Error()
+		}
+		s.failJob(buildID, jobName, reason)
+		return fmt.Errorf("resume job %s: %w", jobName, err)
 	}
 	proc := NewLogProcessor(s.DB, buildID, jobName)
-	// 持续读取 Pod 日志：
-	// - 识别内部标记驱动 Step 状态（begin/end/exit）
+	proc.RestoreCurrentStep()
+	defer s.captureServiceLogs(ctx, buildID, jobName, name)()
+	return s.follow(ctx, buildID, jobName, name, proc)
+}
+
+// follow 读取日志驱动步骤状态，日志结束后查询并落库 Job 终态
+func (s *Scheduler) follow(ctx context.Context, buildID uint64, jobName, name string, proc *LogProcessor) error {
+	// 持续读取日志：
+	// - 识别内部标记驱动 Step 状态（begin/end/exit/skip/output）
 	// - 非标记行按用户日志写入数据库
-	if err := s.Env.StreamPodLogs(ctx, podName, ns, func(line string) {
+	if err := s.Runner.StreamLogs(ctx, name, func(line string) {
 		// 更新数据库状态
 		statusEvent := proc.OnLine(ctx, line)
 
 		// 只记录普通日志（非状态事件）
 		if statusEvent == civ1.StepStatus_STEP_STATUS_UNSPECIFIED {
 			// 过滤内部标记
-			if !strings.HasPrefix(strings.TrimSpace(line), MarkerStepExit) {
+			if t := strings.TrimSpace(line); !strings.HasPrefix(t, MarkerStepExit) && !strings.HasPrefix(t, MarkerStepOutput) {
 				proc.SaveLog(ctx, line)
 			}
 		}
 	}); err != nil {
+		// 被取消（矩阵 fail-fast 或构建取消）时由引擎记录 cancelled
+		if ctx.Err() == nil {
+			s.failJob(buildID, jobName, "logs stream: "+err.Error())
+		}
 		return fmt.Errorf("logs stream: %w", err)
 	}
-	// 流日志结束后轮询 K8s Job 状态，直至观察到 Succeeded/Failed 或达到上限
-	var status civ1.BuildStatus = civ1.BuildStatus_BUILD_STATUS_RUNNING
-	for i := 0; i < 40; i++ { // 最长约 20s
-		j, _ := s.Env.Clientset.BatchV1().Jobs(ns).Get(ctx, name, metav1.GetOptions{})
-		if j != nil {
-			if j.Status.Succeeded > 0 {
-				status = civ1.BuildStatus_BUILD_STATUS_SUCCEEDED
-				break
-			}
-			if j.Status.Failed > 0 {
-				status = civ1.BuildStatus_BUILD_STATUS_FAILED
-				break
-			}
-		}
-		time.Sleep(500 * time.Millisecond)
+	if ctx.Err() != nil {
+		// 被取消或脱离本执行器：终态由引擎或接管方记录
+		return ctx.Err()
 	}
-	now := time.Now()
-	if status == civ1.BuildStatus_BUILD_STATUS_SUCCEEDED {
+	// 日志结束后查询 Job 终态
+	outcome, err := s.Runner.Status(ctx, name)
+	if err != nil {
+		// 状态仍未刷新，保守判定失败
+		s.failJob(buildID, jobName, "job status unknown after logs: "+err.Error())
+		return fmt.Errorf("job status unknown after logs: %s: %w", jobName, err)
+	}
+	if outcome.Succeeded {
 		// Job 成功：更新 Job 终态并兜底收敛步骤状态为成功/跳过
-		_ = s.DB.Model(&models.BuildJob{}).Where("build_id = ? AND name = ?", buildID, jobName).Updates(map[string]any{"status": "succeeded", "finished_at": &now}).Error
-		finalizeSteps(s.DB, buildID, jobName, false)
+		now := time.Now()
+		res := s.DB.Model(&models.BuildJob{}).Where("build_id = ? AND name = ? AND status <> ?", buildID, jobName, "cancelled").Updates(map[string]any{"status": "succeeded", "finished_at": &now})
+		if res.Error == nil && res.RowsAffected == 0 {
+			return fmt.Errorf("job %s: %w", jobName, errJobCancelled)
+		}
+		finalizeSteps(s.DB, buildID, jobName, "succeeded")
 		return nil
 	}
-	if status == civ1.BuildStatus_BUILD_STATUS_FAILED {
-		// Job 失败：更新 Job 终态并兜底收敛步骤状态为失败/跳过
-		_ = s.DB.Model(&models.BuildJob{}).Where("build_id = ? AND name = ?", buildID, jobName).Updates(map[string]any{"status": "failed", "finished_at": &now}).Error
-		finalizeSteps(s.DB, buildID, jobName, true)
-		return fmt.Errorf("job failed: %s", jobName)
+	// Job 失败：更新 Job 终态与原因并兜底收敛步骤状态为失败/跳过
+	s.failJob(buildID, jobName, outcome.Reason)
+	return fmt.Errorf("job failed: %s: %s", jobName, outcome.Reason)
+}
+
+// failJob 将 Job 标记为失败并记录原因，兜底收敛步骤终态
+// 已被并发组 cancel-in-progress 取消的 Job 保留 cancelled（终止 Job 导致的失败不覆盖取消结论）
+func (s *Scheduler) failJob(buildID uint64, jobName, reason string) {
+	now := time.Now()
+	if len(reason) > 1024 {
+		reason = strings.ToValidUTF8(reason[:1024], "")
+	}
+	res := s.DB.Model(&models.BuildJob{}).Where("build_id = ? AND name = ? AND status <> ?", buildID, jobName, "cancelled").Updates(map[string]any{"status": "failed", "finished_at": &now, "reason": reason})
+	if res.Error == nil && res.RowsAffected == 0 {
+		finalizeSteps(s.DB, buildID, jobName, "cancelled")
+		return
 	}
-	// 状态仍未刷新，保守返回错误以便引擎标记失败
-	finalizeSteps(s.DB, buildID, jobName, true)
-	return fmt.Errorf("job status unknown after logs: %s", jobName)
+	finalizeSteps(s.DB, buildID, jobName, "failed")
 }
 
 // isUnschedulable 判断 Pod 是否不可调度（根据 PodScheduled 条件）
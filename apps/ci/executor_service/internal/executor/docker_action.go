@@ -0,0 +1,440 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/internal/config"
+	act "xcoding/apps/ci/executor_service/internal/executor/actions"
+	"xcoding/apps/ci/executor_service/parser"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// docker Action（runs.using: docker 与 uses: docker://<image>）在 Job Pod 的步骤容器中执行：
+//   - 每个 docker 步骤对应一个与 runner 并列的容器 step-<序号>，挂载工作区（/workspace 与 /github/workspace），与 runner 共享网络
+//   - 步骤容器以共享卷中的静态 busybox 运行包装脚本（Action 镜像可不含 Shell），等待 runner 经共享目录发出执行请求：
+//     runner 创建日志 FIFO 并写入 req（pre/main/post），包装脚本以镜像入口 + args 执行对应阶段，输出写入 FIFO 由 runner 转发到 Job 日志，
+//     结束后写入 <阶段>.exit；runner 被 timeout 终止时写入 cancel，包装脚本随即终止该阶段
+//   - Job 脚本退出时写入 done，包装脚本退出（退出码恒为 0，不影响 Job 结论），Pod 随之结束
+//   - 以 Dockerfile 声明镜像的 Action 由 init 容器下载源码并以 kaniko 构建、推送到配置仓库下的项目路径，步骤容器拉取构建出的镜像；
+//     kaniko 在挂载推送凭据的容器中执行 Dockerfile 的 RUN 指令，凭据须按项目隔离（见 registrySecret）
+const (
+	// dockerActionsMount 共享目录：bin/busybox、step-<序号>/（握手文件）、build/<Action>/（待构建的 Action 源码）
+	dockerActionsMount = "/__xc"
+	// dockerActionsEnv 指向共享目录的环境变量；runner 中未设置（local 后端）时 docker 步骤直接失败
+	dockerActionsEnv    = "XC_DOCKER_ACTIONS"
+	dockerActionsVolume = "xc-docker"
+	// dockerStepPrefix 步骤容器名前缀
+	dockerStepPrefix = "step-"
+	// githubWorkspace docker Action 中的工作区路径（与 GitHub 一致）
+	githubWorkspace = "/github/workspace"
+)
+
+// dockerStepContainerName 步骤容器名：step-<序号>（从 1 开始）
+func dockerStepContainerName(idx int) string { return fmt.Sprintf("%s%d", dockerStepPrefix, idx+1) }
+
+// isDockerAction 步骤是否为 docker Action；元数据解析失败时返回 false，由步骤执行时报告错误
+func isDockerAction(st parser.Step, job parser.Job) (*act.ResolvedAction, bool) {
+	if strings.TrimSpace(st.Uses) == "" || parser.IsBuiltinAction(st.Uses) {
+		return nil, false
+	}
+	meta, err := act.Resolve(st, job)
+	if err != nil || meta.Using != "docker" {
+		return meta, false
+	}
+	return meta, true
+}
+
+// buildDockerPhaseScript 生成 runner 侧执行 docker Action 某一阶段（act.HookPre/HookMain/HookPost）的脚本：发出请求、转发日志并以阶段退出码结束
+// main 阶段结束后将容器内写入的 $GITHUB_OUTPUT 合并到步骤的输出文件
+func buildDockerPhaseScript(idx int, phase string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "if [ -z \"${%s:-}\" ]; then echo 'docker actions are only supported by the k8s runner backend' >&2; exit 1; fi\n", dockerActionsEnv)
+	fmt.Fprintf(&b, "__xc_d=\"$%s/%s\"\n", dockerActionsEnv, dockerStepContainerName(idx))
+	b.WriteString("if [ ! -f \"$__xc_d/ready\" ]; then echo 'Waiting for the action container to start...'; while [ ! -f \"$__xc_d/ready\" ]; do sleep 1; done; fi\n")
+	fmt.Fprintf(&b, "rm -f \"$__xc_d/%[1]s.exit\" \"$__xc_d/%[1]s.log\" \"$__xc_d/cancel\" \"$__xc_d/output\"\nmkfifo -m 666 \"$__xc_d/%[1]s.log\"\n", phase)
+	fmt.Fprintf(&b, "echo %s > \"$__xc_d/req.tmp\"\nmv \"$__xc_d/req.tmp\" \"$__xc_d/req\"\n", phase)
+	b.WriteString("trap 'touch \"$__xc_d/cancel\"; kill $__xc_cat 2>/dev/null' TERM INT\n")
+	fmt.Fprintf(&b, "cat \"$__xc_d/%s.log\" &\n__xc_cat=$!\nwait $__xc_cat || true\n", phase)
+	fmt.Fprintf(&b, "while [ ! -f \"$__xc_d/%s.exit\" ]; do sleep 0.2; done\n", phase)
+	if phase == act.HookMain {
+		b.WriteString("if [ -s \"$__xc_d/output\" ]; then cat \"$__xc_d/output\" >> \"$GITHUB_OUTPUT\"; fi\n")
+	}
+	fmt.Fprintf(&b, "exit \"$(cat \"$__xc_d/%s.exit\")\"\n", phase)
+	return b.String()
+}
+
+// dockerDoneTrap Job 脚本退出时通知所有步骤容器结束（含未执行的步骤）
+func dockerDoneTrap(job parser.Job) string {
+	var names []string
+	for i, st := range job.Steps {
+		if _, ok := isDockerAction(st, job); ok {
+			names = append(names, dockerStepContainerName(i))
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	cmd := fmt.Sprintf("if [ -n \"${%[1]s:-}\" ]; then for __xc_s in %[2]s; do mkdir -p \"$%[1]s/$__xc_s\" && touch \"$%[1]s/$__xc_s/done\"; done; fi", dockerActionsEnv, strings.Join(names, " "))
+	return fmt.Sprintf("trap %s EXIT\n", shellQuote(cmd))
+}
+
+// dockerWrapperScript 步骤容器的包装脚本（busybox sh）；cmds 为各阶段的命令（入口 + 参数）
+func dockerWrapperScript(idx int, cmds map[string][]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "B=\"$%[1]s/bin/busybox\"\nd=\"$%[1]s/%[2]s\"\n", dockerActionsEnv, dockerStepContainerName(idx))
+	b.WriteString("$B mkdir -p \"$d\" && $B chmod 777 \"$d\" && $B touch \"$d/ready\"\n")
+	b.WriteString("export GITHUB_OUTPUT=\"$d/output\"\n")
+	b.WriteString("while [ ! -f \"$d/done\" ]; do\n")
+	b.WriteString("  if [ ! -f \"$d/req\" ]; then $B sleep 1; continue; fi\n")
+	b.WriteString("  p=$($B cat \"$d/req\")\n  $B rm -f \"$d/req\"\n  case \"$p\" in\n")
+	for _, phase := range []string{act.HookPre, act.HookMain, act.HookPost} {
+		argv, ok := cmds[phase]
+		if !ok {
+			continue
+		}
+		quoted := make([]string, len(argv))
+		for i, a := range argv {
+			quoted[i] = shellQuote(a)
+		}
+		fmt.Fprintf(&b, "  %s) set -- %s ;;\n", phase, strings.Join(quoted, " "))
+	}
+	b.WriteString("  *) set -- \"$B\" false ;;\n  esac\n")
+	b.WriteString("  \"$@\" > \"$d/$p.log\" 2>&1 &\n  pid=$!\n")
+	b.WriteString("  while kill -0 $pid 2>/dev/null; do\n    if [ -f \"$d/cancel\" ]; then kill $pid 2>/dev/null; fi\n    $B sleep 1\n  done\n")
+	b.WriteString("  wait $pid\n  echo $? > \"$d/$p.tmp\"\n  $B mv \"$d/$p.tmp\" \"$d/$p.exit\"\ndone\n")
+	return b.String()
+}
+
+// dockerCommands 各阶段的命令：main 为 entrypoint（未配置时取镜像 ENTRYPOINT）+ args（为空时取镜像 CMD），pre/post 为对应入口 + args
+func dockerCommands(meta *act.ResolvedAction, img act.ImageEntrypoint, args []string) (map[string][]string, error) {
+	main := append([]string{}, img.Entrypoint...)
+	if meta.Entrypoint != "" {
+		main = []string{meta.Entrypoint}
+	}
+	if len(args) > 0 {
+		main = append(main, args...)
+	} else if meta.Entrypoint == "" {
+		main = append(main, img.Cmd...)
+	}
+	if len(main) == 0 {
+		return nil, fmt.Errorf("image has no entrypoint or command")
+	}
+	cmds := map[string][]string{act.HookMain: main}
+	if meta.PreEntrypoint != "" {
+		cmds[act.HookPre] = append([]string{meta.PreEntrypoint}, args...)
+	}
+	if meta.PostEntrypoint != "" {
+		cmds[act.HookPost] = append([]string{meta.PostEntrypoint}, args...)
+	}
+	return cmds, nil
+}
+
+// ApplyDockerActions 为 Job 中的 docker Action 步骤添加步骤容器（Job 不含 docker 步骤时不做修改）
+// 说明：
+//   - init 容器依次为：复制 busybox（tools_image）、各 Dockerfile 类 Action 的源码下载（runner 镜像）与 kaniko 构建，排在服务容器之前
+//   - 步骤容器的环境变量：Job 级变量、步骤 env、runs.env、INPUT_*、GITHUB_WORKSPACE；args 与 runs.env 中的 ${{ inputs.* }} 以 Action 输入求值
+//   - 镜像入口在此时确定（见 actions.ResolveImageEntrypoint），无法确定时返回 error
+func ApplyDockerActions(ctx context.Context, spec *batchv1.Job, job parser.Job, ectx *expr.Context, cfg config.DockerActionConfig, projectID uint64) error {
+	pod := &spec.Spec.Template.Spec
+	if len(pod.Containers) == 0 {
+		return nil
+	}
+	runner := &pod.Containers[0]
+	mount := corev1.VolumeMount{Name: dockerActionsVolume, MountPath: dockerActionsMount}
+	var (
+		inits []corev1.Container
+		steps []corev1.Container
+		built = map[string]bool{}
+	)
+	for i, st := range job.Steps {
+		meta, ok := isDockerAction(st, job)
+		if !ok {
+			continue
+		}
+		c, buildInits, err := dockerStepContainer(ctx, i, st, meta, runner, ectx, cfg, projectID)
+		if err != nil {
+			return fmt.Errorf("step %s: %w", st.Name, err)
+		}
+		for _, ic := range buildInits {
+			if !built[ic.Name] {
+				built[ic.Name] = true
+				inits = append(inits, ic)
+			}
+		}
+		steps = append(steps, c)
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+	tools := strings.TrimSpace(cfg.ToolsImage)
+	if tools == "" {
+		tools = "busybox:1.36-musl"
+	}
+	inits = append([]corev1.Container{{
+		Name:         "xc-tools",
+		Image:        tools,
+		Command:      []string{"/bin/busybox", "sh", "-c", fmt.Sprintf("mkdir -p %[1]s/bin && cp /bin/busybox %[1]s/bin/busybox", dockerActionsMount)},
+		VolumeMounts: []corev1.VolumeMount{mount},
+	}}, inits...)
+	pod.Volumes = append(pod.Volumes, corev1.Volume{Name: dockerActionsVolume, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}})
+	if secret, _ := registrySecret(cfg, projectID); secret != "" && len(built) > 0 {
+		pod.Volumes = append(pod.Volumes, corev1.Volume{Name: "xc-registry", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+			SecretName: secret,
+			Items:      []corev1.KeyToPath{{Key: corev1.DockerConfigJsonKey, Path: "config.json"}},
+		}}})
+		pod.ImagePullSecrets = append(pod.ImagePullSecrets, corev1.LocalObjectReference{Name: secret})
+	}
+	runner.VolumeMounts = append(runner.VolumeMounts, mount)
+	runner.Env = append(runner.Env, corev1.EnvVar{Name: dockerActionsEnv, Value: dockerActionsMount})
+	pod.InitContainers = append(inits, pod.InitContainers...)
+	pod.Containers = append(pod.Containers, steps...)
+	return nil
+}
+
+// dockerStepContainer 生成步骤容器；Dockerfile 类 Action 同时返回下载与构建镜像的 init 容器
+func dockerStepContainer(ctx context.Context, idx int, st parser.Step, meta *act.ResolvedAction, runner *corev1.Container, ectx *expr.Context, cfg config.DockerActionConfig, projectID uint64) (corev1.Container, []corev1.Container, error) {
+	// 步骤容器的环境在创建 Pod 时确定，无法引用运行时才有的 steps 上下文
+	if len(st.Deferred) > 0 {
+		return corev1.Container{}, nil, fmt.Errorf("with and env of docker actions cannot reference the steps context or hashFiles()")
+	}
+	inputs, err := act.Inputs(meta, st, ectx)
+	if err != nil {
+		return corev1.Container{}, nil, err
+	}
+	ictx := ectx.With(false, false)
+	ictx.Set("inputs", inputs)
+	args := make([]string, len(meta.Args))
+	for i, a := range meta.Args {
+		if args[i], err = expr.Interpolate(a, ictx); err != nil {
+			return corev1.Container{}, nil, fmt.Errorf("args: %w", err)
+		}
+	}
+	env := map[string]string{}
+	for k, v := range st.Env {
+		if !strings.HasPrefix(strings.TrimSpace(v), "secret://") && !deprecatedControlEnv[k] {
+			env[k] = v
+		}
+	}
+	for k, v := range meta.Env {
+		if env[k], err = expr.Interpolate(v, ictx); err != nil {
+			return corev1.Container{}, nil, fmt.Errorf("env %s: %w", k, err)
+		}
+	}
+	for k, v := range act.InputEnv(inputs) {
+		env[k] = v
+	}
+	env["GITHUB_WORKSPACE"] = githubWorkspace
+	env[dockerActionsEnv] = dockerActionsMount
+
+	var (
+		image  string
+		pull   corev1.PullPolicy
+		inits  []corev1.Container
+		imgCfg act.ImageEntrypoint
+	)
+	if act.IsDockerUses(meta.Image) {
+		image = strings.TrimPrefix(meta.Image, act.DockerPrefix)
+		if meta.Entrypoint == "" {
+			if imgCfg, err = act.ResolveImageEntrypoint(ctx, image); err != nil {
+				return corev1.Container{}, nil, fmt.Errorf("resolve entrypoint: %w", err)
+			}
+		}
+	} else {
+		if image, inits, err = dockerBuildContainers(st, meta, runner, cfg, projectID); err != nil {
+			return corev1.Container{}, nil, err
+		}
+		pull = corev1.PullAlways
+		df, err := act.ParseDockerfile(meta.Dockerfile)
+		if err != nil {
+			return corev1.Container{}, nil, fmt.Errorf("%s: %w", meta.Image, err)
+		}
+		imgCfg = df.ImageEntrypoint
+		if meta.Entrypoint == "" && df.Base != "" {
+			base, err := act.ResolveImageEntrypoint(ctx, df.Base)
+			if err != nil {
+				return corev1.Container{}, nil, fmt.Errorf("resolve entrypoint of base image: %w", err)
+			}
+			imgCfg.Entrypoint = base.Entrypoint
+			if !df.CmdSet {
+				imgCfg.Cmd = base.Cmd
+			}
+		}
+	}
+	cmds, err := dockerCommands(meta, imgCfg, args)
+	if err != nil {
+		return corev1.Container{}, nil, fmt.Errorf("%s: %w", image, err)
+	}
+
+	names := map[string]bool{}
+	for k := range env {
+		names[k] = true
+	}
+	var envs []corev1.EnvVar
+	for _, ev := range runner.Env {
+		if !names[ev.Name] {
+			envs = append(envs, ev)
+		}
+	}
+	for _, k := range sortedKeys(env) {
+		envs = append(envs, corev1.EnvVar{Name: k, Value: env[k]})
+	}
+	c := corev1.Container{
+		Name:            dockerStepContainerName(idx),
+		Image:           image,
+		ImagePullPolicy: pull,
+		Command:         []string{dockerActionsMount + "/bin/busybox", "sh", "-c", dockerWrapperScript(idx, cmds)},
+		WorkingDir:      githubWorkspace,
+		Env:             envs,
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "workspace", MountPath: config.WORKDIR},
+			{Name: "workspace", MountPath: githubWorkspace},
+			{Name: dockerActionsVolume, MountPath: dockerActionsMount},
+		},
+	}
+	if runner.SecurityContext != nil {
+		c.SecurityContext = runner.SecurityContext.DeepCopy()
+	}
+	return c, inits, nil
+}
+
+// registryProjectPlaceholder registry_secret 中的项目占位符，按构建所属项目替换为项目 ID
+const registryProjectPlaceholder = "{project_id}"
+
+// registrySecret 项目的镜像仓库凭据 Secret 名；未配置凭据时返回空
+// kaniko 执行 Action 的 Dockerfile 时凭据对其 RUN 指令可见，共享凭据会使任意 Action 可改写其它项目的镜像，
+// 故 registry_secret 须含 {project_id}，每个项目的凭据仅能推送 <registry>/project-<id>/ 下的镜像
+func registrySecret(cfg config.DockerActionConfig, projectID uint64) (string, error) {
+	secret := strings.TrimSpace(cfg.RegistrySecret)
+	if secret == "" {
+		return "", nil
+	}
+	if !strings.Contains(secret, registryProjectPlaceholder) {
+		return "", fmt.Errorf("registry secret %q is shared by all projects; it must contain %s (EXECUTOR_DOCKER_ACTION_REGISTRY_SECRET)", secret, registryProjectPlaceholder)
+	}
+	return strings.ReplaceAll(secret, registryProjectPlaceholder, fmt.Sprint(projectID)), nil
+}
+
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// actionImageTag 构建出的镜像标签：<清洗后的 version>-<提交 SHA>，version 本身即 SHA 时仅为 SHA
+// 标签随提交变化，分支或标签移动后不会复用旧镜像；version 中 / 等非法字符替换为 -，总长不超过 128
+func actionImageTag(version, sha string) string {
+	if strings.EqualFold(version, sha) {
+		return sha
+	}
+	v := strings.Trim(invalidTagChars.ReplaceAllString(version, "-"), ".-")
+	if max := 128 - len(sha) - 1; len(v) > max {
+		v = strings.TrimRight(v[:max], ".-")
+	}
+	if v == "" {
+		return sha
+	}
+	return v + "-" + sha
+}
+
+// dockerBuildContainers Dockerfile 类 Action 的镜像与构建用 init 容器：以 runner 镜像下载 Action 源码，再以 kaniko 构建并推送
+// 镜像名：<registry>/project-<项目 ID>/<owner>/<name>[/<path>]:<标签>（见 actionImageTag），源码按解析出的提交下载；
+// 同一 Action 在 Job 内只构建一次（init 容器按名称去重）
+func dockerBuildContainers(st parser.Step, meta *act.ResolvedAction, runner *corev1.Container, cfg config.DockerActionConfig, projectID uint64) (string, []corev1.Container, error) {
+	registry := strings.TrimRight(strings.TrimSpace(cfg.Registry), "/")
+	if registry == "" {
+		return "", nil, fmt.Errorf("building %s requires a registry for docker actions (EXECUTOR_DOCKER_ACTION_REGISTRY)", meta.Image)
+	}
+	if projectID == 0 {
+		return "", nil, fmt.Errorf("building %s requires a build associated with a project", meta.Image)
+	}
+	secret, err := registrySecret(cfg, projectID)
+	if err != nil {
+		return "", nil, err
+	}
+	if meta.CommitSHA == "" {
+		return "", nil, fmt.Errorf("building %s: commit of %s not resolved", meta.Image, st.Uses)
+	}
+	ref, err := act.ParseUsesRef(st.Uses)
+	if err != nil {
+		return "", nil, err
+	}
+	repo := strings.ToLower(path.Join(fmt.Sprintf("project-%d", projectID), ref.Owner, ref.Name, strings.TrimPrefix(ref.Path, "/")))
+	tag := actionImageTag(ref.Version, meta.CommitSHA)
+	image := registry + "/" + repo + ":" + tag
+	key := strings.NewReplacer("/", "-", ".", "-", "_", "-").Replace(strings.ToLower(path.Join(ref.Owner, ref.Name, strings.TrimPrefix(ref.Path, "/")) + "-" + tag))
+	src := path.Join(dockerActionsMount, "build", key)
+	ctxDir := path.Join(src, strings.TrimPrefix(ref.Path, "/"))
+	mount := corev1.VolumeMount{Name: dockerActionsVolume, MountPath: dockerActionsMount}
+
+	// 按解析出的提交下载，构建内容与镜像标签一致
+	pinned := st
+	pinned.Uses = strings.TrimSuffix(strings.TrimSpace(st.Uses), "@"+ref.Version) + "@" + meta.CommitSHA
+	download, err := act.DownloadUsesScript(pinned)
+	if err != nil {
+		return "", nil, err
+	}
+	fetch := corev1.Container{
+		Name:                     truncateName("xc-fetch-" + key),
+		Image:                    runner.Image,
+		Command:                  []string{"/bin/bash", "-c", fmt.Sprintf("set -e\n%smkdir -p %s\ncp -a \"$workdir/.\" %s/\n", download, shellQuote(src), shellQuote(src))},
+		Env:                      append([]corev1.EnvVar(nil), runner.Env...),
+		VolumeMounts:             []corev1.VolumeMount{mount},
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+	}
+	builder := strings.TrimSpace(cfg.BuilderImage)
+	if builder == "" {
+		builder = "gcr.io/kaniko-project/executor:v1.23.2"
+	}
+	build := corev1.Container{
+		Name:  truncateName("xc-build-" + key),
+		Image: builder,
+		Args: append([]string{
+			"--context=dir://" + ctxDir,
+			"--dockerfile=" + path.Join(ctxDir, meta.Image),
+			"--destination=" + image,
+		}, strings.Fields(cfg.BuilderArgs)...),
+		VolumeMounts:             []corev1.VolumeMount{mount},
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+	}
+	if secret != "" {
+		build.VolumeMounts = append(build.VolumeMounts, corev1.VolumeMount{Name: "xc-registry", MountPath: "/kaniko/.docker", ReadOnly: true})
+	}
+	return image, []corev1.Container{fetch, build}, nil
+}
+
+// truncateName 容器名不超过 63 字符（DNS-1123 label）
+func truncateName(name string) string {
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// dockerStepsNotStarted 尚未启动的步骤容器（镜像拉取中等）
+func dockerStepsNotStarted(p *corev1.Pod) []string {
+	var names []string
+	for _, c := range p.Spec.Containers {
+		if strings.HasPrefix(c.Name, dockerStepPrefix) && !containerStarted(p, c.Name) {
+			names = append(names, c.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// initContainerFailure 非服务的 init 容器（如 docker Action 镜像构建）失败的原因，包含其日志末尾
+func initContainerFailure(p *corev1.Pod) string {
+	for _, cs := range p.Status.InitContainerStatuses {
+		if t := cs.State.Terminated; t != nil && t.ExitCode != 0 && !strings.HasPrefix(cs.Name, serviceContainerPrefix) {
+			reason := fmt.Sprintf("init container %s failed: exit code %d", cs.Name, t.ExitCode)
+			if msg := strings.TrimSpace(t.Message); msg != "" {
+				reason += ": " + msg
+			}
+			return reason
+		}
+	}
+	return ""
+}
@@ -0,0 +1,233 @@
+package executor
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/internal/config"
+	act "xcoding/apps/ci/executor_service/internal/executor/actions"
+	"xcoding/apps/ci/executor_service/parser"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestDockerActionSteps(t *testing.T) {
+	if _, err := exec.LookPath("mkfifo"); err != nil {
+		t.Skip("mkfifo not available")
+	}
+	wf, err := parser.ValidateWorkflowYAML(`jobs:
+  build:
+    steps:
+      - name: hello
+        uses: docker://alpine:3.20
+        with:
+          who: world
+          entrypoint: sh
+          args: -c "echo hello $INPUT_WHO; echo greeting=hi >> $GITHUB_OUTPUT"
+      - name: fail
+        uses: docker://alpine:3.20
+        with:
+          entrypoint: sh
+          args: -c "exit 3"
+      - name: cleanup
+        if: failure()
+        run: echo cleanup
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, ectx := wf.Jobs["build"], expr.NewContext()
+	spec, err := BuildJobSpecWithExtensions("ci", 1, "build-1-build", job, ectx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyDockerActions(context.Background(), spec, job, ectx, config.DockerActionConfig{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	pod := spec.Spec.Template.Spec
+	if len(pod.Containers) != 3 || pod.Containers[1].Name != "step-1" || pod.Containers[2].Name != "step-2" {
+		t.Fatalf("containers = %+v, want runner, step-1, step-2", pod.Containers)
+	}
+	if len(pod.InitContainers) != 1 || pod.InitContainers[0].Name != "xc-tools" {
+		t.Fatalf("init containers = %+v, want xc-tools", pod.InitContainers)
+	}
+	step := pod.Containers[1]
+	if step.Image != "alpine:3.20" || step.WorkingDir != githubWorkspace || len(step.VolumeMounts) != 3 {
+		t.Errorf("step container = image %s, workdir %s, mounts %+v", step.Image, step.WorkingDir, step.VolumeMounts)
+	}
+
+	// 以 bash 执行 runner 脚本，以 sh 执行步骤容器的包装脚本；busybox 以直接执行参数的脚本代替
+	shared, ws := t.TempDir(), t.TempDir()
+	if err := os.MkdirAll(filepath.Join(shared, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(shared, "bin", "busybox"), []byte("#!/bin/sh\nexec \"$@\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var wrappers []*exec.Cmd
+	for _, c := range pod.Containers[1:] {
+		env := os.Environ()
+		for _, ev := range c.Env {
+			env = append(env, ev.Name+"="+ev.Value)
+		}
+		w := exec.Command("sh", "-c", c.Command[len(c.Command)-1])
+		w.Dir, w.Env = ws, append(env, dockerActionsEnv+"="+shared)
+		if err := w.Start(); err != nil {
+			t.Fatal(err)
+		}
+		wrappers = append(wrappers, w)
+	}
+	cmd := exec.Command("/bin/bash", "-c", BuildScript(job, ectx))
+	cmd.Dir, cmd.Env = ws, append(os.Environ(), dockerActionsEnv+"="+shared)
+	out, err := cmd.CombinedOutput()
+	if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 3 {
+		t.Errorf("script err = %v, want exit code 3\n%s", err, out)
+	}
+	for _, want := range []string{
+		"hello world\n",
+		MarkerStepOutput + " hello " + base64.StdEncoding.EncodeToString([]byte("greeting=hi\n")),
+		MarkerStepExit + " hello 0",
+		MarkerStepExit + " fail 3",
+		"cleanup\n",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	for _, w := range wrappers {
+		done := make(chan error, 1)
+		go func() { done <- w.Wait() }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("wrapper exited with %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			_ = w.Process.Kill()
+			t.Error("wrapper did not exit after the job script finished")
+		}
+	}
+}
+
+func TestDockerActionMetadata(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"action.yml": `name: lint
+inputs:
+  level:
+    default: warn
+runs:
+  using: docker
+  image: Dockerfile
+  args: ["--level", "${{ inputs.level }}"]
+  pre-entrypoint: /setup.sh
+  post-entrypoint: /cleanup.sh
+`,
+		"Dockerfile": `ARG VERSION=3.20
+FROM golang:1.22 AS build
+ENTRYPOINT ["/bin/false"]
+FROM alpine:${VERSION}
+COPY --from=build /out/lint /lint
+ENTRYPOINT ["/lint"]
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	meta, err := act.LoadMetadata(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !meta.HasPre() || !meta.HasPost() || meta.HookIf(act.HookPost) != "always()" || meta.Inputs["level"] != "warn" {
+		t.Errorf("metadata = %+v", meta)
+	}
+	df, err := act.ParseDockerfile(meta.Dockerfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if df.Base != "" || !reflect.DeepEqual(df.Entrypoint, []string{"/lint"}) {
+		t.Errorf("dockerfile = %+v, want entrypoint /lint from the final stage", df)
+	}
+	inherited, err := act.ParseDockerfile("ARG VERSION=3.20\nFROM alpine:${VERSION}\nCMD [\"run\"]\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inherited.Base != "alpine:3.20" || !inherited.CmdSet || !reflect.DeepEqual(inherited.Cmd, []string{"run"}) {
+		t.Errorf("dockerfile = %+v, want entrypoint inherited from alpine:3.20 and cmd run", inherited)
+	}
+	cmds, err := dockerCommands(meta, df.ImageEntrypoint, []string{"--level", "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		act.HookPre:  {"/setup.sh", "--level", "error"},
+		act.HookMain: {"/lint", "--level", "error"},
+		act.HookPost: {"/cleanup.sh", "--level", "error"},
+	}
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("commands = %v, want %v", cmds, want)
+	}
+}
+
+func TestDockerBuildContainers(t *testing.T) {
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	st := parser.Step{Name: "lint", Uses: "Acme/lint/tools@releases/v1"}
+	meta := &act.ResolvedAction{Using: "docker", Image: "Dockerfile", CommitSHA: sha}
+	runner := &corev1.Container{Image: "runner:latest"}
+	cfg := config.DockerActionConfig{Registry: "registry.example.com/ci-actions/", RegistrySecret: "ci-actions-{project_id}"}
+
+	image, inits, err := dockerBuildContainers(st, meta, runner, cfg, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "registry.example.com/ci-actions/project-7/acme/lint/tools:releases-v1-" + sha; image != want {
+		t.Errorf("image = %s, want %s", image, want)
+	}
+	if len(inits) != 2 {
+		t.Fatalf("init containers = %+v, want fetch and build", inits)
+	}
+	if fetch := inits[0].Command[len(inits[0].Command)-1]; !strings.Contains(fetch, "/tarball/"+sha) {
+		t.Errorf("fetch does not download the resolved commit:\n%s", fetch)
+	}
+	if mounts := inits[1].VolumeMounts; len(mounts) != 2 || mounts[1].Name != "xc-registry" {
+		t.Errorf("build mounts = %+v, want the project registry secret", mounts)
+	}
+	if secret, _ := registrySecret(cfg, 7); secret != "ci-actions-7" {
+		t.Errorf("registry secret = %s, want ci-actions-7", secret)
+	}
+
+	for name, c := range map[string]struct {
+		cfg       config.DockerActionConfig
+		projectID uint64
+		meta      *act.ResolvedAction
+	}{
+		"shared registry secret": {config.DockerActionConfig{Registry: "registry.example.com", RegistrySecret: "ci-actions"}, 7, meta},
+		"build without project":  {cfg, 0, meta},
+		"unresolved commit":      {cfg, 7, &act.ResolvedAction{Using: "docker", Image: "Dockerfile"}},
+	} {
+		if _, _, err := dockerBuildContainers(st, c.meta, runner, c.cfg, c.projectID); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+
+	for _, c := range []struct{ version, want string }{
+		{sha, sha},
+		{"v1.2.0", "v1.2.0-" + sha},
+		{"feature/a+b", "feature-a-b-" + sha},
+		{"-/-", sha},
+		{strings.Repeat("x", 100), strings.Repeat("x", 87) + "-" + sha},
+	} {
+		if got := actionImageTag(c.version, sha); got != c.want {
+			t.Errorf("actionImageTag(%q) = %s, want %s", c.version, got, c.want)
+		}
+	}
+}
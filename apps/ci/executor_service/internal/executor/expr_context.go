@@ -0,0 +1,255 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
+)
+
+// jobResult 将 Job 内部状态映射为表达式中 needs.<job>.result 的取值
+func jobResult(state string) string {
+	switch state {
+	case "succeeded":
+		return "success"
+	case "failed":
+		return "failure"
+	case "skipped":
+		return "skipped"
+	case "cancelled":
+		return "cancelled"
+	}
+	return ""
+}
+
+// groupResult 汇总矩阵子任务结果：任一失败为 failure，任一取消为 cancelled，全部跳过为 skipped，否则 success
+func groupResult(members []string, state map[string]string) string {
+	skipped := 0
+	result := "success"
+	for _, m := range members {
+		switch state[m] {
+		case "failed":
+			return "failure"
+		case "cancelled":
+			result = "cancelled"
+		case "skipped":
+			skipped++
+		}
+	}
+	if len(members) > 0 && skipped == len(members) {
+		return "skipped"
+	}
+	return result
+}
+
+// githubContext 基于构建元数据构造 github 上下文（与 GitHub 字段命名保持一致）
+func githubContext(b *models.Build, wf *parser.Workflow) map[string]any {
+	ctx := map[string]any{
+		"workflow":    wf.Name,
+		"run_id":      fmt.Sprintf("%d", b.ID),
+		"build_id":    fmt.Sprintf("%d", b.ID),
+		"pipeline_id": fmt.Sprintf("%d", b.PipelineID),
+		"sha":         b.CommitSHA,
+		"ref_name":    b.Branch,
+		"ref":         "",
+		"actor":       b.TriggeredBy,
+		"workspace":   "/workspace",
+	}
+	if b.Branch != "" {
+		ctx["ref"] = "refs/heads/" + b.Branch
+	}
+	return ctx
+}
+
+// newJobContext 构造单个任务的表达式上下文
+// 说明：env 为合并后的 Job 环境；inputs 为手动触发的输入（boolean/number 已转换类型）；needs 按工作流中声明的 Job 名汇总（矩阵任务汇总全部子任务，
+// outputs 按子任务顺序合并）；矩阵子任务额外提供 matrix 与 strategy 上下文
+func newJobContext(b *models.Build, wf *parser.Workflow, dag *DAG, name string, state map[string]string, outputs map[string]map[string]any, inputs map[string]any) *expr.Context {
+	job := dag.Jobs[name]
+	ectx := expr.NewContext()
+	ectx.Set("env", job.Env)
+	ectx.Set("github", githubContext(b, wf))
+	if inputs == nil {
+		inputs = map[string]any{}
+	}
+	ectx.Set("inputs", inputs)
+	nm := make(map[string]any, len(job.Needs))
+	for _, n := range job.Needs {
+		members, ok := dag.Groups[n]
+		if !ok {
+			members = []string{n}
+		}
+		out := map[string]any{}
+		for _, m := range members {
+			for k, v := range outputs[m] {
+				out[k] = v
+			}
+		}
+		nm[n] = map[string]any{"result": groupResult(members, state), "outputs": out}
+	}
+	ectx.Set("needs", nm)
+	ectx.Set("matrix", map[string]any{})
+	if parent, ok := dag.Parent[name]; ok {
+		ectx.Set("matrix", dag.Matrix[name])
+		members := dag.Groups[parent]
+		index := 0
+		for i, m := range members {
+			if m == name {
+				index = i
+			}
+		}
+		ectx.Set("strategy", map[string]any{
+			"fail-fast":    job.Strategy.FailFastEnabled(),
+			"max-parallel": job.Strategy.MaxParallel,
+			"job-index":    index,
+			"job-total":    len(members),
+		})
+	}
+	return ectx
+}
+
+// interpolateJob 替换 Job 中的 ${{ }} 表达式（容器镜像、runs-on、环境变量、服务镜像/环境变量/options、并发组、continue-on-error、步骤 run/with/env/continue-on-error）
+// 说明：步骤名与 if 不做替换，步骤名需与 BuildStep 记录保持一致；Job.LiteralEnv 标记的环境变量按字面保留；步骤 run/with/env 中引用 steps 上下文的值留待运行时求值（见 Step.Deferred）
+func interpolateJob(job parser.Job, ectx *expr.Context) (parser.Job, error) {
+	var err error
+	if job.Container, err = expr.Interpolate(job.Container, ectx); err != nil {
+		return job, fmt.Errorf("container: %w", err)
+	}
+	if job.Env, err = interpolateEnv(job.Env, job.LiteralEnv, ectx); err != nil {
+		return job, fmt.Errorf("env: %w", err)
+	}
+	runsOn, err := expr.Interpolate(string(job.RunsOn), ectx)
+	if err != nil {
+		return job, fmt.Errorf("runs-on: %w", err)
+	}
+	job.RunsOn = parser.RunsOn(strings.TrimSpace(runsOn))
+	if len(job.Services) > 0 {
+		services := make(map[string]parser.Service, len(job.Services))
+		for name, svc := range job.Services {
+			if svc.Image, err = expr.Interpolate(svc.Image, ectx); err != nil {
+				return job, fmt.Errorf("service %s image: %w", name, err)
+			}
+			if svc.Options, err = expr.Interpolate(svc.Options, ectx); err != nil {
+				return job, fmt.Errorf("service %s options: %w", name, err)
+			}
+			if svc.Env, err = interpolateMap(svc.Env, ectx); err != nil {
+				return job, fmt.Errorf("service %s env: %w", name, err)
+			}
+			services[name] = svc
+		}
+		job.Services = services
+	}
+	if job.Concurrency != nil {
+		c := *job.Concurrency
+		if c.Group, err = expr.Interpolate(c.Group, ectx); err != nil {
+			return job, fmt.Errorf("concurrency: %w", err)
+		}
+		c.Group = strings.TrimSpace(c.Group)
+		job.Concurrency = &c
+	}
+	if job.ContinueOnError, err = interpolateFlag(job.ContinueOnError, ectx); err != nil {
+		return job, fmt.Errorf("continue-on-error: %w", err)
+	}
+	steps := make([]parser.Step, len(job.Steps))
+	for i, st := range job.Steps {
+		if st.ContinueOnError, err = interpolateFlag(st.ContinueOnError, ectx); err != nil {
+			return job, fmt.Errorf("step %s continue-on-error: %w", st.Name, err)
+		}
+		st.Deferred = nil
+		if expr.NeedsRuntime(st.Run) {
+			st.Deferred = map[string]bool{"run": true}
+		} else if st.Run, err = expr.Interpolate(st.Run, ectx); err != nil {
+			return job, fmt.Errorf("step %s run: %w", st.Name, err)
+		}
+		if st.With, err = interpolateStepMap(&st, "with", st.With, ectx); err != nil {
+			return job, fmt.Errorf("step %s with: %w", st.Name, err)
+		}
+		if st.Env, err = interpolateStepMap(&st, "env", st.Env, ectx); err != nil {
+			return job, fmt.Errorf("step %s env: %w", st.Name, err)
+		}
+		steps[i] = st
+	}
+	job.Steps = steps
+	return job, nil
+}
+
+func interpolateMap(m map[string]string, ectx *expr.Context) (map[string]string, error) {
+	if m == nil {
+		return nil, nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		s, err := expr.Interpolate(v, ectx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		out[k] = s
+	}
+	return out, nil
+}
+
+// interpolateEnv 替换 Job 环境变量中的表达式，literal 标记的键按字面保留（见 parser.Job.LiteralEnv）
+func interpolateEnv(m map[string]string, literal map[string]bool, ectx *expr.Context) (map[string]string, error) {
+	if m == nil {
+		return nil, nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		if literal[k] {
+			out[k] = v
+			continue
+		}
+		s, err := expr.Interpolate(v, ectx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		if err := checkSecretRef(k, v, s); err != nil {
+			return nil, err
+		}
+		out[k] = s
+	}
+	return out, nil
+}
+
+// checkSecretRef secret:// 引用须在工作流中直接书写：表达式替换后才构成的引用（如取自 inputs）拒绝注入，避免触发方借此读取任意 Secret
+func checkSecretRef(name, raw, value string) error {
+	if isSecretRef(value) && !isSecretRef(raw) {
+		return fmt.Errorf("%s: expression result must not be a secret:// reference", name)
+	}
+	return nil
+}
+
+// interpolateStepMap 替换步骤 with/env 中的表达式；引用 steps 上下文的值原样保留并记入 st.Deferred（键为 <field>.<name>），由 Job 内的脚本求值
+func interpolateStepMap(st *parser.Step, field string, m map[string]string, ectx *expr.Context) (map[string]string, error) {
+	if m == nil {
+		return nil, nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		if expr.NeedsRuntime(v) {
+			if st.Deferred == nil {
+				st.Deferred = map[string]bool{}
+			}
+			st.Deferred[field+"."+k] = true
+			out[k] = v
+			continue
+		}
+		s, err := expr.Interpolate(v, ectx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		if field == "env" {
+			if err := checkSecretRef(k, v, s); err != nil {
+				return nil, err
+			}
+		}
+		out[k] = s
+	}
+	return out, nil
+}
+
+func interpolateFlag(f parser.BoolOrExpr, ectx *expr.Context) (parser.BoolOrExpr, error) {
+	v, err := expr.Interpolate(string(f), ectx)
+	return parser.BoolOrExpr(v), err
+}
@@ -8,4 +8,12 @@ const (
 	MarkerStepEnd = "__step_end__"
 	// MarkerStepExit 标记步骤退出码
 	MarkerStepExit = "__step_exit__"
+	// MarkerStepSkip 标记步骤因 if 条件不满足而跳过
+	MarkerStepSkip = "__step_skip__"
+	// MarkerStepOutput 标记步骤输出：__step_output__ <name> <base64(输出文件内容)>
+	MarkerStepOutput = "__step_output__"
+	// MarkerStepPost 标记步骤的后置阶段（如缓存保存）开始：此后的日志归入该步骤，不改变步骤状态
+	MarkerStepPost = "__step_post__"
+	// MarkerStepPre 标记步骤的前置阶段（Action pre）开始：语义同 MarkerStepPost
+	MarkerStepPre = "__step_pre__"
 )
@@ -2,7 +2,10 @@ package executor
 
 import (
 	"fmt"
-	"xcoding/apps/ci/executor_service/internal/parser"
+	"hash/fnv"
+	"strings"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/parser"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -11,14 +14,14 @@ import (
 
 // BuildJobSpec 构建单个 K8s Job 规范
 // 说明：合成容器镜像、环境变量、脚本与资源限制，并设置标签用于检索
-func BuildJobSpec(ns string, buildID uint64, jobName string, job parser.Job) *batchv1.Job {
+func BuildJobSpec(ns string, buildID uint64, jobName string, job parser.Job, ectx *expr.Context) *batchv1.Job {
 	backoff := int32(0)
 	image := job.Container
 	if image == "" {
 		image = "alpine:latest"
 	}
 	envs := BuildEnvVarsForJob(job)
-	script := BuildScript(job)
+	script := BuildScript(job, ectx)
 	pod := BuildPodSpec(image, script, envs)
 	if len(pod.Containers) > 0 {
 		pod.Containers[0].Resources = BuildResources(job)
@@ -29,3 +32,32 @@ func BuildJobSpec(ns string, buildID uint64, jobName string, job parser.Job) *ba
 		Spec:       batchv1.JobSpec{BackoffLimit: &backoff, Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}, Spec: pod}},
 	}
 }
+
+// k8sJobName 生成任务对应的 K8s Job 名称：build-<buildID>-<jobName>
+// 说明：矩阵子任务名（如 "test (1.22, linux)"）含空格与括号，需按 DNS-1123 规范清洗；
+// 名称被改写或超过 63 字符时追加原名哈希，避免不同任务清洗后重名
+func k8sJobName(buildID uint64, jobName string) string {
+	raw := fmt.Sprintf("build-%d-%s", buildID, jobName)
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(raw) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	name := strings.Trim(b.String(), "-")
+	if name == raw && len(name) <= 63 {
+		return name
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(jobName))
+	suffix := fmt.Sprintf("-%08x", h.Sum32())
+	if len(name) > 63-len(suffix) {
+		name = strings.TrimRight(name[:63-len(suffix)], "-")
+	}
+	return name + suffix
+}
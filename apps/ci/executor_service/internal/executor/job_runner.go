@@ -0,0 +1,78 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/internal/config"
+	"xcoding/apps/ci/executor_service/parser"
+)
+
+// JobSpec 运行单个 Job 所需的信息
+type JobSpec struct {
+	BuildID uint64
+	// ProjectID 构建所属项目，Dockerfile 类 docker Action 按项目隔离镜像路径与仓库凭据
+	ProjectID uint64
+	JobName   string        // 工作流中的任务名（矩阵子任务为展开后的名称）
+	Name      string        // 运行后端中的 Job 名，见 k8sJobName
+	Job       parser.Job    // 已完成表达式替换的任务定义
+	Ectx      *expr.Context // 表达式上下文，用于步骤 if 条件求值
+	// Checkout 检出步骤的凭据文件（见 resolveCheckout），不含检出步骤时为 nil
+	Checkout map[string][]byte
+}
+
+// JobOutcome Job 终态
+type JobOutcome struct {
+	Succeeded bool
+	Reason    string // 失败原因，成功时为空
+}
+
+// ErrJobGone 恢复构建时 Job 已不存在（被删除、Pod 消失或本地进程随执行器退出）
+var ErrJobGone = errors.New("job no longer exists")
+
+// JobRunner Job 运行后端
+// 生命周期：Create → WaitReady → StreamLogs → Status；任意阶段可调用 Cancel 终止并清理
+// 执行器重启后恢复构建时以 Attach 代替 Create → WaitReady
+type JobRunner interface {
+	// Create 创建并启动 Job
+	Create(ctx context.Context, spec JobSpec) error
+	// WaitReady 等待 Job 进入可读取日志的状态；返回 error 表示 Job 无法启动（如不可调度）
+	WaitReady(ctx context.Context, name string) error
+	// Attach 重新附着到已存在的 Job 并等待其可读取日志；之后的 StreamLogs 只回调 since 之后产生的日志（零值表示从头读取）
+	// Job 已不存在时返回包装 ErrJobGone 的错误
+	Attach(ctx context.Context, name string, since time.Time) error
+	// StreamLogs 逐行读取 Job 日志，直至日志结束
+	StreamLogs(ctx context.Context, name string, onLine func(line string)) error
+	// Status 在日志结束后查询 Job 终态；无法确定终态时返回 error
+	Status(ctx context.Context, name string) (JobOutcome, error)
+	// Cancel 终止 Job 并清理其资源
+	Cancel(ctx context.Context, name string) error
+}
+
+// NewJobRunner 按配置创建运行后端
+// 说明：backend 为空或 k8s 时使用 Kubernetes，启动 Job/Pod 监听直至 ctx 结束，按 classes 选择 Pod 模板；
+// local 时在本机 /bin/bash 子进程中运行（开发与测试用，忽略 runs-on 与执行器类别）
+func NewJobRunner(ctx context.Context, cfg config.RunnerConfig, classes *RunnerClassRegistry) (JobRunner, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Backend)) {
+	case "", "k8s", "kubernetes":
+		env, err := NewK8sEnv()
+		if err != nil {
+			return nil, fmt.Errorf("k8s env: %w", err)
+		}
+		watch := NewJobWatcher(env.Clientset, env.Namespace)
+		if err := watch.Start(ctx); err != nil {
+			return nil, fmt.Errorf("watch k8s jobs: %w", err)
+		}
+		r := NewK8sRunner(env, watch, time.Duration(cfg.ImagePullTimeoutSeconds)*time.Second)
+		r.Classes = classes
+		r.DockerActions = cfg.DockerActions
+		r.ExprImage = cfg.ExprImage
+		return r, nil
+	case "local":
+		return NewLocalRunner(cfg.Workspace), nil
+	}
+	return nil, fmt.Errorf("unknown runner backend %q", cfg.Backend)
+}
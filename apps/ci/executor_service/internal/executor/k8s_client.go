@@ -7,7 +7,7 @@ import (
 )
 
 type K8sEnv struct {
-	Clientset *kubernetes.Clientset
+	Clientset kubernetes.Interface
 	Namespace string
 }
 
@@ -0,0 +1,247 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// buildLabelSelector 构建 Job 及其 Pod 的公共标签（见 BuildJobSpecWithExtensions）
+const buildLabelSelector = "app=ci-executor-build"
+
+// errWaitTimeout JobWatcher.wait 等待超时
+var errWaitTimeout = errors.New("wait timeout")
+
+// JobWatcher 以共享 informer 监听命名空间内带 app=ci-executor-build 标签的 Job 与 Pod
+// 状态变化以事件通知等待方，替代逐个 Job 的轮询；查询均读取本地缓存
+type JobWatcher struct {
+	factory informers.SharedInformerFactory
+	jobs    batchlisters.JobLister
+	pods    corelisters.PodLister
+	ns      string
+
+	mu   sync.Mutex
+	subs map[string]map[chan struct{}]struct{} // K8s Job 名 → 等待方
+}
+
+// NewJobWatcher 创建监听器，需调用 Start 后使用
+func NewJobWatcher(cs kubernetes.Interface, namespace string) *JobWatcher {
+	f := informers.NewSharedInformerFactoryWithOptions(cs, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) { o.LabelSelector = buildLabelSelector }),
+	)
+	w := &JobWatcher{factory: f, ns: namespace, subs: map[string]map[chan struct{}]struct{}{}}
+	jobInf := f.Batch().V1().Jobs()
+	podInf := f.Core().V1().Pods()
+	w.jobs, w.pods = jobInf.Lister(), podInf.Lister()
+	_, _ = jobInf.Informer().AddEventHandler(w.handler(func(obj any) string {
+		if j, ok := obj.(*batchv1.Job); ok {
+			return j.Name
+		}
+		return ""
+	}))
+	_, _ = podInf.Informer().AddEventHandler(w.handler(func(obj any) string {
+		if p, ok := obj.(*corev1.Pod); ok {
+			return podJobName(p)
+		}
+		return ""
+	}))
+	return w
+}
+
+// Start 启动 informer 并等待缓存同步；ctx 结束时停止监听
+func (w *JobWatcher) Start(ctx context.Context) error {
+	w.factory.Start(ctx.Done())
+	for typ, ok := range w.factory.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			return fmt.Errorf("sync %v informer cache", typ)
+		}
+	}
+	return nil
+}
+
+func (w *JobWatcher) handler(key func(obj any) string) cache.ResourceEventHandler {
+	notify := func(obj any) {
+		if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = d.Obj
+		}
+		if name := key(obj); name != "" {
+			w.notify(name)
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    notify,
+		UpdateFunc: func(_, obj any) { notify(obj) },
+		DeleteFunc: notify,
+	}
+}
+
+func (w *JobWatcher) notify(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.subs[name] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// wait 在 Job 或其 Pod 每次变化时调用 check，直至其返回 true 或 error
+// timeout 为 0 表示不限时；超时返回 errWaitTimeout
+func (w *JobWatcher) wait(ctx context.Context, name string, timeout time.Duration, check func() (bool, error)) error {
+	ch := make(chan struct{}, 1)
+	w.mu.Lock()
+	if w.subs[name] == nil {
+		w.subs[name] = map[chan struct{}]struct{}{}
+	}
+	w.subs[name][ch] = struct{}{}
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.subs[name], ch)
+		if len(w.subs[name]) == 0 {
+			delete(w.subs, name)
+		}
+		w.mu.Unlock()
+	}()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	for {
+		// 先订阅再检查，避免漏掉两者之间的事件
+		if ok, err := check(); ok || err != nil {
+			return err
+		}
+		select {
+		case <-ch:
+		case <-expired:
+			return errWaitTimeout
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// job 从缓存读取 K8s Job；不存在时返回 nil
+func (w *JobWatcher) job(name string) *batchv1.Job {
+	j, err := w.jobs.Jobs(w.ns).Get(name)
+	if err != nil {
+		return nil
+	}
+	return j
+}
+
+// pod 从缓存读取 K8s Job 最新创建的 Pod；不存在时返回 nil
+func (w *JobWatcher) pod(name string) *corev1.Pod {
+	pods, err := w.pods.Pods(w.ns).List(labels.SelectorFromSet(labels.Set{"job-name": name}))
+	if err != nil || len(pods) == 0 {
+		return nil
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[j].CreationTimestamp.Before(&pods[i].CreationTimestamp)
+	})
+	return pods[0]
+}
+
+// podJobName Pod 所属 K8s Job 名（Job 控制器写入的 job-name 标签）
+func podJobName(p *corev1.Pod) string {
+	if n := p.Labels["batch.kubernetes.io/job-name"]; n != "" {
+		return n
+	}
+	return p.Labels["job-name"]
+}
+
+// 容器等待原因分类
+// - fatalWaitingReasons：重试无意义，立即判定失败（CrashLoopBackOff 说明容器已反复崩溃）
+// - pullWaitingReasons：镜像拉取失败，kubelet 会退避重试，超出镜像拉取等待时间后判定失败
+var (
+	fatalWaitingReasons = map[string]bool{
+		"CrashLoopBackOff":           true,
+		"InvalidImageName":           true,
+		"ErrImageNeverPull":          true,
+		"CreateContainerConfigError": true,
+		"CreateContainerError":       true,
+	}
+	pullWaitingReasons = map[string]bool{
+		"ErrImagePull":     true,
+		"ImagePullBackOff": true,
+	}
+)
+
+// containerWaiting 返回首个等待原因属于 reasons 的容器说明（含 init 容器），格式为 "<原因>: <容器>: <消息>"
+func containerWaiting(p *corev1.Pod, reasons map[string]bool) string {
+	statuses := append(append([]corev1.ContainerStatus{}, p.Status.InitContainerStatuses...), p.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		if w := cs.State.Waiting; w != nil && reasons[w.Reason] {
+			reason := w.Reason
+			// ImagePullBackOff 是 ErrImagePull 后的退避状态，统一以 ErrImagePull 记录
+			if reason == "ImagePullBackOff" {
+				reason = "ErrImagePull"
+			}
+			return strings.TrimSuffix(fmt.Sprintf("%s: %s: %s", reason, cs.Name, w.Message), ": ")
+		}
+	}
+	return ""
+}
+
+// containerStarted 判断容器已运行或已结束（可读取日志）
+func containerStarted(p *corev1.Pod, container string) bool {
+	for _, cs := range p.Status.ContainerStatuses {
+		if cs.Name == container && (cs.State.Running != nil || cs.State.Terminated != nil || cs.Ready) {
+			return true
+		}
+	}
+	return false
+}
+
+// podFailureReason 从 Pod 状态推断 Job 失败原因：CrashLoopBackOff/ErrImagePull 等等待原因优先，其次为 runner 容器的终止原因（如 OOMKilled）
+func podFailureReason(p *corev1.Pod) string {
+	if r := containerWaiting(p, fatalWaitingReasons); r != "" {
+		return r
+	}
+	if r := containerWaiting(p, pullWaitingReasons); r != "" {
+		return r
+	}
+	for _, cs := range p.Status.ContainerStatuses {
+		if t := cs.State.Terminated; cs.Name == "runner" && t != nil && t.ExitCode != 0 && t.Reason != "" && t.Reason != "Error" {
+			return fmt.Sprintf("%s: exit code %d", t.Reason, t.ExitCode)
+		}
+	}
+	return ""
+}
+
+// jobFailureReason K8s Job 失败条件的原因与说明
+func jobFailureReason(j *batchv1.Job) string {
+	for _, c := range j.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue && c.Reason != "" {
+			if c.Message != "" {
+				return c.Reason + ": " + c.Message
+			}
+			return c.Reason
+		}
+	}
+	for _, c := range j.Status.Conditions {
+		if c.Reason != "" {
+			return c.Reason
+		}
+	}
+	return "job failed"
+}
@@ -23,8 +23,14 @@ func FormatStepLog(line string) (string, bool) {
 		return "", false // 暂时不展示结束，保持简洁
 	}
 
-	// 处理步骤退出（不展示）
-	if strings.HasPrefix(s, MarkerStepExit+" ") {
+	// 处理步骤跳过（if 条件为 false）
+	if strings.HasPrefix(s, MarkerStepSkip+" ") {
+		name := strings.TrimSpace(strings.TrimPrefix(s, MarkerStepSkip+" "))
+		return fmt.Sprintf("🔹 Step [%s] Skipped", name), true
+	}
+
+	// 处理步骤退出与输出（不展示）
+	if strings.HasPrefix(s, MarkerStepExit+" ") || strings.HasPrefix(s, MarkerStepOutput+" ") {
 		return "", false
 	}
 
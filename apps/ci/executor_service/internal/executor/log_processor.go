@@ -8,6 +8,7 @@ import (
 	"xcoding/apps/ci/executor_service/models"
 	civ1 "xcoding/gen/go/ci/v1"
 
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -23,7 +24,39 @@ func NewLogProcessor(db *gorm.DB, buildID uint64, jobName string) *LogProcessor
 	return &LogProcessor{db: db, buildID: buildID, jobName: jobName}
 }
 
-// OnLine 处理日志行：识别 __step_begin__/__step_end__/__step_exit__ 并更新数据库
+// RestoreCurrentStep 恢复构建时从数据库还原当前运行中的步骤，使后续日志归属正确
+func (p *LogProcessor) RestoreCurrentStep() {
+	var step models.BuildStep
+	if err := p.db.Where("build_id = ? AND job_name = ? AND status = ?", p.buildID, p.jobName, "running").
+		Order("index desc").First(&step).Error; err == nil {
+		p.currentStepID = step.ID
+	}
+}
+
+// lastLogTime 返回 Job 最后一次落库的日志或步骤状态变化时间，恢复构建时从该位置之后继续读取日志
+func lastLogTime(db *gorm.DB, buildID uint64, jobName string) time.Time {
+	var steps []models.BuildStep
+	_ = db.Where("build_id = ? AND job_name = ?", buildID, jobName).Find(&steps).Error
+	var last time.Time
+	ids := make([]uint64, 0, len(steps))
+	for _, st := range steps {
+		ids = append(ids, st.ID)
+		for _, t := range []*time.Time{st.StartedAt, st.FinishedAt} {
+			if t != nil && t.After(last) {
+				last = *t
+			}
+		}
+	}
+	if len(ids) > 0 {
+		var chunk models.BuildStepLogChunk
+		if err := db.Where("build_step_id IN ?", ids).Order("id desc").First(&chunk).Error; err == nil && chunk.CreatedAt.After(last) {
+			last = chunk.CreatedAt
+		}
+	}
+	return last
+}
+
+// OnLine 处理日志行：识别 __step_begin__/__step_end__/__step_exit__/__step_skip__/__step_output__/__step_pre__/__step_post__ 并更新数据库
 // 返回值：status event (UNSPECIFIED if normal log)
 func (p *LogProcessor) OnLine(ctx context.Context, line string) civ1.StepStatus {
 	s := strings.TrimSpace(line)
@@ -39,32 +72,82 @@ func (p *LogProcessor) OnLine(ctx context.Context, line string) civ1.StepStatus
 		}
 		return civ1.StepStatus_STEP_STATUS_RUNNING
 	}
-    if strings.HasPrefix(s, MarkerStepEnd+" ") {
-        name := strings.TrimSpace(strings.TrimPrefix(s, MarkerStepEnd+" "))
-        now := time.Now()
-        var step models.BuildStep
-        if err := p.db.Model(&models.BuildStep{}).
-            Where("build_id = ? AND job_name = ? AND name = ?", p.buildID, p.jobName, name).
-            First(&step).Error; err == nil {
-            _ = p.db.Model(&step).Updates(map[string]any{"status": "succeeded", "finished_at": &now}).Error
-            if step.ID == p.currentStepID {
-                p.currentStepID = 0
-            }
-        }
-        return civ1.StepStatus_STEP_STATUS_SUCCEEDED
-    }
+	if strings.HasPrefix(s, MarkerStepEnd+" ") {
+		name := strings.TrimSpace(strings.TrimPrefix(s, MarkerStepEnd+" "))
+		now := time.Now()
+		var step models.BuildStep
+		if err := p.db.Model(&models.BuildStep{}).
+			Where("build_id = ? AND job_name = ? AND name = ?", p.buildID, p.jobName, name).
+			First(&step).Error; err == nil {
+			_ = p.db.Model(&step).Updates(map[string]any{"status": "succeeded", "finished_at": &now}).Error
+			if step.ID == p.currentStepID {
+				p.currentStepID = 0
+			}
+		}
+		return civ1.StepStatus_STEP_STATUS_SUCCEEDED
+	}
+	for _, marker := range []string{MarkerStepPre, MarkerStepPost} {
+		if !strings.HasPrefix(s, marker+" ") {
+			continue
+		}
+		name := strings.TrimSpace(strings.TrimPrefix(s, marker+" "))
+		var step models.BuildStep
+		if err := p.db.Where("build_id = ? AND job_name = ? AND name = ?", p.buildID, p.jobName, name).First(&step).Error; err == nil {
+			p.currentStepID = step.ID
+		}
+		return civ1.StepStatus_STEP_STATUS_UNSPECIFIED
+	}
+	if strings.HasPrefix(s, MarkerStepSkip+" ") {
+		name := strings.TrimSpace(strings.TrimPrefix(s, MarkerStepSkip+" "))
+		now := time.Now()
+		_ = p.db.Model(&models.BuildStep{}).
+			Where("build_id = ? AND job_name = ? AND name = ?", p.buildID, p.jobName, name).
+			Updates(map[string]any{"status": "skipped", "finished_at": &now}).Error
+		return civ1.StepStatus_STEP_STATUS_SKIPPED
+	}
+	if strings.HasPrefix(s, MarkerStepOutput+" ") {
+		// 格式：__step_output__ <name> <base64>，name 可能包含空格，载荷取最后一段
+		rest := strings.TrimSpace(strings.TrimPrefix(s, MarkerStepOutput+" "))
+		if i := strings.LastIndex(rest, " "); i > 0 {
+			name := strings.TrimSpace(rest[:i])
+			outputs, err := decodeStepOutput(rest[i+1:])
+			if err != nil {
+				p.SaveLog(ctx, "output error: "+err.Error())
+			}
+			var step models.BuildStep
+			if len(outputs) > 0 && p.db.Where("build_id = ? AND job_name = ? AND name = ?", p.buildID, p.jobName, name).First(&step).Error == nil {
+				merged := datatypes.JSONMap{}
+				for k, v := range step.Outputs {
+					merged[k] = v
+				}
+				for k, v := range outputs {
+					merged[k] = v
+				}
+				_ = p.db.Model(&step).Updates(map[string]any{"outputs": merged}).Error
+			}
+		}
+		return civ1.StepStatus_STEP_STATUS_UNSPECIFIED
+	}
 	if strings.HasPrefix(s, MarkerStepExit+" ") {
-		parts := strings.Split(s, " ")
-		if len(parts) >= 3 {
-			name := strings.TrimSpace(parts[1])
-			code := strings.TrimSpace(parts[2])
+		// 格式：__step_exit__ <name> <code>，name 可能包含空格，退出码取最后一段
+		rest := strings.TrimSpace(strings.TrimPrefix(s, MarkerStepExit+" "))
+		if i := strings.LastIndex(rest, " "); i > 0 {
+			name := strings.TrimSpace(rest[:i])
+			code := strings.TrimSpace(rest[i+1:])
 			var exit int32 = 0
 			if n, err := strconv.ParseInt(code, 10, 32); err == nil {
 				exit = int32(n)
 			}
+			updates := map[string]any{"exit_code": exit}
+			// 非零退出码即判定步骤失败；continue-on-error 的步骤随后会收到结束标记并被置为成功
+			if exit != 0 {
+				now := time.Now()
+				updates["status"] = "failed"
+				updates["finished_at"] = &now
+			}
 			_ = p.db.Model(&models.BuildStep{}).
 				Where("build_id = ? AND job_name = ? AND name = ?", p.buildID, p.jobName, name).
-				Updates(map[string]any{"exit_code": exit}).Error
+				Updates(updates).Error
 		}
 		return civ1.StepStatus_STEP_STATUS_UNSPECIFIED
 	}
@@ -88,20 +171,20 @@ func (p *LogProcessor) SaveLog(ctx context.Context, content string) {
 // failed=true: running->failed, pending->skipped
 // failed=false: running->succeeded (兜底), pending->skipped (理论上不应有 pending，除非逻辑错误，但保持一致性可设为 skipped 或忽略)
 func (p *LogProcessor) Finalize(ctx context.Context, failed bool) {
-    now := time.Now()
-    if failed {
-        _ = p.db.Model(&models.BuildStep{}).
-            Where("build_id = ? AND job_name = ? AND status = ?", p.buildID, p.jobName, "running").
-            Updates(map[string]any{"status": "failed", "finished_at": &now}).Error
-        _ = p.db.Model(&models.BuildStep{}).
-            Where("build_id = ? AND job_name = ? AND status = ?", p.buildID, p.jobName, "pending").
-            Updates(map[string]any{"status": "skipped", "finished_at": &now}).Error
-        return
-    }
-    _ = p.db.Model(&models.BuildStep{}).
-        Where("build_id = ? AND job_name = ? AND status = ?", p.buildID, p.jobName, "running").
-        Updates(map[string]any{"status": "succeeded", "finished_at": &now}).Error
-    _ = p.db.Model(&models.BuildStep{}).
-        Where("build_id = ? AND job_name = ? AND status = ?", p.buildID, p.jobName, "pending").
-        Updates(map[string]any{"status": "succeeded", "finished_at": &now}).Error
+	now := time.Now()
+	if failed {
+		_ = p.db.Model(&models.BuildStep{}).
+			Where("build_id = ? AND job_name = ? AND status = ?", p.buildID, p.jobName, "running").
+			Updates(map[string]any{"status": "failed", "finished_at": &now}).Error
+		_ = p.db.Model(&models.BuildStep{}).
+			Where("build_id = ? AND job_name = ? AND status = ?", p.buildID, p.jobName, "pending").
+			Updates(map[string]any{"status": "skipped", "finished_at": &now}).Error
+		return
+	}
+	_ = p.db.Model(&models.BuildStep{}).
+		Where("build_id = ? AND job_name = ? AND status = ?", p.buildID, p.jobName, "running").
+		Updates(map[string]any{"status": "succeeded", "finished_at": &now}).Error
+	_ = p.db.Model(&models.BuildStep{}).
+		Where("build_id = ? AND job_name = ? AND status = ?", p.buildID, p.jobName, "pending").
+		Updates(map[string]any{"status": "succeeded", "finished_at": &now}).Error
 }
@@ -0,0 +1,112 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/parser"
+)
+
+// expandMatrix 计算矩阵的全部组合
+// 规则（与 GitHub Actions 一致）：
+// - 先按维度声明顺序求笛卡尔积，再剔除与任一 exclude 项（部分）匹配的组合
+// - include 项若与某组合的原始维度取值不冲突，则将其附加键合并到该组合；无法合并到任何组合时作为新组合追加
+func expandMatrix(m parser.Matrix) []map[string]any {
+	combos := []map[string]any{}
+	if len(m.Keys) > 0 {
+		combos = append(combos, map[string]any{})
+		for _, k := range m.Keys {
+			next := make([]map[string]any, 0, len(combos)*len(m.Values[k]))
+			for _, c := range combos {
+				for _, v := range m.Values[k] {
+					nc := make(map[string]any, len(c)+1)
+					for ck, cv := range c {
+						nc[ck] = cv
+					}
+					nc[k] = v
+					next = append(next, nc)
+				}
+			}
+			combos = next
+		}
+	}
+	// exclude：组合包含 exclude 项的全部键值即剔除
+	kept := combos[:0]
+	for _, c := range combos {
+		excluded := false
+		for _, ex := range m.Exclude {
+			if matrixMatches(c, ex, nil) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			kept = append(kept, c)
+		}
+	}
+	combos = kept
+	// include：仅比较原始维度，附加键允许覆盖此前 include 添加的值
+	base := len(combos)
+	axes := make(map[string]bool, len(m.Keys))
+	for _, k := range m.Keys {
+		axes[k] = true
+	}
+	for _, inc := range m.Include {
+		merged := false
+		for i := 0; i < base; i++ {
+			if matrixMatches(combos[i], inc, axes) {
+				for k, v := range inc {
+					combos[i][k] = v
+				}
+				merged = true
+			}
+		}
+		if !merged {
+			nc := make(map[string]any, len(inc))
+			for k, v := range inc {
+				nc[k] = v
+			}
+			combos = append(combos, nc)
+		}
+	}
+	return combos
+}
+
+// matrixMatches 判断组合是否与条目匹配；only 非空时仅比较其中的键
+func matrixMatches(combo, entry map[string]any, only map[string]bool) bool {
+	for k, v := range entry {
+		if only != nil && !only[k] {
+			continue
+		}
+		cv, ok := combo[k]
+		if !ok || fmt.Sprint(expr.Normalize(cv)) != fmt.Sprint(expr.Normalize(v)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matrixJobName 生成矩阵子任务名：<job> (v1, v2, ...)
+// 取值顺序：先按维度声明顺序，再按 include 附加键的字典序
+func matrixJobName(base string, keys []string, combo map[string]any) string {
+	seen := make(map[string]bool, len(keys))
+	vals := make([]string, 0, len(combo))
+	for _, k := range keys {
+		if v, ok := combo[k]; ok {
+			vals = append(vals, expr.ToString(expr.Normalize(v)))
+			seen[k] = true
+		}
+	}
+	extra := make([]string, 0, len(combo))
+	for k := range combo {
+		if !seen[k] {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		vals = append(vals, expr.ToString(expr.Normalize(combo[k])))
+	}
+	return fmt.Sprintf("%s (%s)", base, strings.Join(vals, ", "))
+}
@@ -0,0 +1,120 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+	"xcoding/apps/ci/executor_service/parser"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpandMatrix(t *testing.T) {
+	cases := []struct {
+		name   string
+		matrix string
+		want   []map[string]any
+	}{
+		{
+			name:   "cartesian product in declaration order",
+			matrix: "os: [linux, windows]\nnode: [18, 20]",
+			want: []map[string]any{
+				{"os": "linux", "node": 18}, {"os": "linux", "node": 20},
+				{"os": "windows", "node": 18}, {"os": "windows", "node": 20},
+			},
+		},
+		{
+			// 部分键 exclude 剔除所有包含其键值的组合；取值按规范化后比较（"18" 与 18 相同）
+			name: "partial-key exclude",
+			matrix: `os: [linux, windows, mac]
+node: [18, 20]
+exclude:
+  - os: windows
+  - os: linux
+    node: "18"
+  - os: mac
+    arch: arm64`,
+			want: []map[string]any{
+				{"os": "linux", "node": 20},
+				{"os": "mac", "node": 18}, {"os": "mac", "node": 20},
+			},
+		},
+		{
+			// 与 GitHub Actions 文档示例一致：
+			// - 不含原始维度的 include 扩展全部组合，后续 include 可覆盖其附加键
+			// - 原始维度取值不匹配任何组合时追加为新组合，且新组合不参与后续合并
+			name: "include extends or adds combinations",
+			matrix: `fruit: [apple, pear]
+animal: [cat, dog]
+include:
+  - color: green
+  - color: pink
+    animal: cat
+  - fruit: apple
+    shape: circle
+  - fruit: banana
+  - fruit: banana
+    animal: cat`,
+			want: []map[string]any{
+				{"fruit": "apple", "animal": "cat", "color": "pink", "shape": "circle"},
+				{"fruit": "apple", "animal": "dog", "color": "green", "shape": "circle"},
+				{"fruit": "pear", "animal": "cat", "color": "pink"},
+				{"fruit": "pear", "animal": "dog", "color": "green"},
+				{"fruit": "banana"},
+				{"fruit": "banana", "animal": "cat"},
+			},
+		},
+		{
+			// exclude 先于 include：被剔除的组合不再接受 include 合并，改为追加
+			name: "include after exclude",
+			matrix: `os: [linux, windows]
+exclude:
+  - os: windows
+include:
+  - os: windows
+    experimental: true`,
+			want: []map[string]any{
+				{"os": "linux"},
+				{"os": "windows", "experimental": true},
+			},
+		},
+		{
+			name:   "include only",
+			matrix: "include:\n  - go: '1.22'\n  - go: '1.23'\n    latest: true",
+			want:   []map[string]any{{"go": "1.22"}, {"go": "1.23", "latest": true}},
+		},
+		{
+			name:   "all excluded",
+			matrix: "os: [linux]\nexclude:\n  - os: linux",
+			want:   []map[string]any{},
+		},
+	}
+	for _, c := range cases {
+		var m parser.Matrix
+		if err := yaml.Unmarshal([]byte(c.matrix), &m); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := expandMatrix(m); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: expandMatrix = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMatrixJobName(t *testing.T) {
+	keys := []string{"os", "node"}
+	cases := []struct {
+		combo map[string]any
+		want  string
+	}{
+		{map[string]any{"node": 18, "os": "linux"}, "build (linux, 18)"},
+		// include 附加键按字典序排在维度之后
+		{map[string]any{"os": "linux", "node": 20, "shape": "circle", "experimental": true}, "build (linux, 20, true, circle)"},
+		// include 追加的组合可能缺少部分维度
+		{map[string]any{"node": 1.5}, "build (1.5)"},
+		{map[string]any{"os": "mac", "arch": "arm64"}, "build (mac, arm64)"},
+	}
+	for _, c := range cases {
+		if got := matrixJobName("build", keys, c.combo); got != c.want {
+			t.Errorf("matrixJobName(%v) = %q, want %q", c.combo, got, c.want)
+		}
+	}
+}
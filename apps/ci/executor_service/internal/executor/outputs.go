@@ -0,0 +1,64 @@
+package executor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// decodeStepOutput 解析 __step_output__ 标记行的载荷（base64 编码的输出文件内容）
+func decodeStepOutput(payload string) (map[string]string, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(payload))
+	if err != nil {
+		return nil, fmt.Errorf("decode output: %w", err)
+	}
+	return parser.ParseOutputFile(string(raw))
+}
+
+// stepsContext 基于已落库的步骤构造 steps 上下文：steps.<id>.outputs/outcome/conclusion
+// 说明：仅声明了 id 的步骤可被引用；BuildStep.Index 与 job.Steps 下标一一对应（从 1 开始）
+func stepsContext(db *gorm.DB, buildID uint64, jobName string, job parser.Job) map[string]any {
+	var rows []models.BuildStep
+	// index 为 SQLite 关键字，加引号以兼容测试使用的 SQLite
+	_ = db.Where("build_id = ? AND job_name = ?", buildID, jobName).Order(`"index" asc`).Find(&rows).Error
+	steps := map[string]any{}
+	for _, row := range rows {
+		i := int(row.Index) - 1
+		if i < 0 || i >= len(job.Steps) || job.Steps[i].ID == "" {
+			continue
+		}
+		outputs := map[string]any{}
+		for k, v := range row.Outputs {
+			outputs[k] = v
+		}
+		result := jobResult(row.Status)
+		steps[job.Steps[i].ID] = map[string]any{"outputs": outputs, "outcome": result, "conclusion": result}
+	}
+	return steps
+}
+
+// collectJobOutputs 在 Job 结束后对 jobs.<id>.outputs 映射求值并落库
+// 说明：求值上下文在 Job 上下文基础上追加 steps；单个输出求值失败时记为空字符串
+func collectJobOutputs(db *gorm.DB, buildID uint64, jobName string, job parser.Job, ectx *expr.Context) map[string]any {
+	outputs := map[string]any{}
+	if len(job.Outputs) == 0 {
+		return outputs
+	}
+	octx := ectx.With(ectx.Failed, ectx.Cancelled)
+	octx.Set("steps", stepsContext(db, buildID, jobName, job))
+	for k, v := range job.Outputs {
+		s, err := expr.Interpolate(v, octx)
+		if err != nil {
+			s = ""
+		}
+		outputs[k] = s
+	}
+	_ = db.Model(&models.BuildJob{}).Where("build_id = ? AND name = ?", buildID, jobName).Updates(map[string]any{"outputs": datatypes.JSONMap(outputs)}).Error
+	return outputs
+}
@@ -2,47 +2,103 @@ package executor
 
 import (
 	"strconv"
-	"xcoding/apps/ci/executor_service/internal/parser"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/parser"
 
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 )
 
-// BuildJobSpecWithExtensions 注入扩展：Job 超时、NodeSelector 与 TTL 自动清理
-func BuildJobSpecWithExtensions(ns string, buildID uint64, jobName string, job parser.Job) *batchv1.Job {
-	spec := BuildJobSpec(ns, buildID, jobName, job)
+// BuildJobSpecWithExtensions 注入扩展：Job 超时、TTL 自动清理、执行器类别（class 为 nil 时使用内置 Pod 模板）、检出凭据卷与服务容器
+func BuildJobSpecWithExtensions(ns string, buildID uint64, jobName string, job parser.Job, ectx *expr.Context, class *RunnerClass) (*batchv1.Job, error) {
+	spec := BuildJobSpec(ns, buildID, jobName, job, ectx)
 
 	// TTL：仅在配置时设置，默认不清理，便于调试
 	ttl := ParseTTLFromEnv(job.Env)
 	ApplyTTLExtension(spec, ttl)
 
-	// Timeout 秒：与 TTL 区分，超时会使 Job 失败
-	// 读取超时：XC_JOB_TIMEOUT_SECONDS
+	// Timeout 秒：与 TTL 区分，超时会使 Job 失败（DeadlineExceeded）
+	if t := JobTimeoutSeconds(job); t > 0 {
+		spec.Spec.ActiveDeadlineSeconds = &t
+	}
+
+	ApplyRunnerClass(spec, job, class)
+	ApplyCheckoutVolume(spec, job)
+
+	// services：原生 sidecar，与 runner 共享网络（localhost）
+	services, err := BuildServiceContainers(job.Services)
+	if err != nil {
+		return nil, err
+	}
+	spec.Spec.Template.Spec.InitContainers = append(spec.Spec.Template.Spec.InitContainers, services...)
+	return spec, nil
+}
+
+// JobTimeoutSeconds Job 超时秒数：timeout-minutes 优先，未配置时回退到已废弃的 XC_JOB_TIMEOUT_SECONDS；0 表示不限
+func JobTimeoutSeconds(job parser.Job) int64 {
+	if t := parser.TimeoutSeconds(job.TimeoutMinutes); t > 0 {
+		return t
+	}
 	if v, ok := job.Env["XC_JOB_TIMEOUT_SECONDS"]; ok && v != "" {
 		if i, err := strconv.ParseInt(v, 10, 64); err == nil && i > 0 {
-			ii := i
-			spec.Spec.ActiveDeadlineSeconds = &ii
+			return i
 		}
 	}
+	return 0
+}
 
-	/*
-		// 当前集群节点不支持，所以关闭
-		// 场景：调度到特定硬件类型的节点
-		NodeSelector: map[string]string{
-		    "accelerator": "gpu-nvidia",
-		    "storage": "ssd",
+// ApplyRunnerClass 将执行器类别套用到 Job 的 Pod 模板
+// 说明：Job 未指定 container 时使用类别镜像；资源以类别为基础，已废弃的 XC_RESOURCE_* 按项覆盖，但不超过类别的 limits；
+// 类别配置了 securityContext / podSecurityContext 时替换内置的 root 运行设置
+func ApplyRunnerClass(spec *batchv1.Job, job parser.Job, class *RunnerClass) {
+	if class == nil {
+		return
+	}
+	pod := &spec.Spec.Template.Spec
+	pod.NodeSelector = class.NodeSelector
+	pod.Tolerations = class.Tolerations
+	pod.Affinity = class.Affinity
+	pod.ServiceAccountName = class.ServiceAccountName
+	if class.RuntimeClassName != "" {
+		rc := class.RuntimeClassName
+		pod.RuntimeClassName = &rc
+	}
+	if class.PodSecurityContext != nil {
+		pod.SecurityContext = class.PodSecurityContext
+	}
+	if len(pod.Containers) == 0 {
+		return
+	}
+	c := &pod.Containers[0]
+	if job.Container == "" && class.Image != "" {
+		c.Image = class.Image
+	}
+	if class.SecurityContext != nil {
+		c.SecurityContext = class.SecurityContext
+	}
+	res := class.Resources.DeepCopy()
+	for k, v := range c.Resources.Requests {
+		if res.Requests == nil {
+			res.Requests = corev1.ResourceList{}
 		}
-
-	*/
-
-	//// NodeSelector：约定 `XC_NODE_SELECTOR_KEY` and `XC_NODE_SELECTOR_VALUE`
-	//k, kOk := job.Env["XC_NODE_SELECTOR_KEY"]
-	//val, vOk := job.Env["XC_NODE_SELECTOR_VALUE"]
-	//if kOk && vOk && k != "" && val != "" {
-	//	if spec.Spec.Template.Spec.NodeSelector == nil {
-	//		spec.Spec.Template.Spec.NodeSelector = map[string]string{}
-	//	}
-	//	spec.Spec.Template.Spec.NodeSelector[k] = val
-	//}
-
-	return spec
+		res.Requests[k] = v
+	}
+	for k, v := range c.Resources.Limits {
+		if res.Limits == nil {
+			res.Limits = corev1.ResourceList{}
+		}
+		res.Limits[k] = v
+	}
+	// XC_RESOURCE_* 不能突破类别的 limits：超出的请求与限制截断为类别 limits，请求不超过最终的限制
+	for k, max := range class.Resources.Limits {
+		if v, ok := res.Limits[k]; ok && v.Cmp(max) > 0 {
+			res.Limits[k] = max.DeepCopy()
+		}
+	}
+	for k, v := range res.Requests {
+		if max, ok := res.Limits[k]; ok && v.Cmp(max) > 0 {
+			res.Requests[k] = max.DeepCopy()
+		}
+	}
+	c.Resources = *res
 }
@@ -1,29 +1,42 @@
 package executor
 
 import (
+	"xcoding/apps/ci/executor_service/parser"
+
+	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
-	"xcoding/apps/ci/executor_service/internal/parser"
 )
 
 // BuildResources 从约定的环境变量构造资源请求/限制
 // 说明：
 // - 支持 CPU/Memory 的 Request 与 Limit
 // - 键：XC_RESOURCE_CPU_REQUEST、XC_RESOURCE_MEMORY_REQUEST、XC_RESOURCE_CPU_LIMIT、XC_RESOURCE_MEMORY_LIMIT
+// - 取值无法解析为资源数量时忽略并记录告警
+// - 使用执行器类别时由 ApplyRunnerClass 合并并截断到类别的 limits
 func BuildResources(job parser.Job) corev1.ResourceRequirements {
 	req := corev1.ResourceList{}
 	lim := corev1.ResourceList{}
-	if v, ok := job.Env["XC_RESOURCE_CPU_REQUEST"]; ok && v != "" {
-		req[corev1.ResourceCPU] = resource.MustParse(v)
-	}
-	if v, ok := job.Env["XC_RESOURCE_MEMORY_REQUEST"]; ok && v != "" {
-		req[corev1.ResourceMemory] = resource.MustParse(v)
-	}
-	if v, ok := job.Env["XC_RESOURCE_CPU_LIMIT"]; ok && v != "" {
-		lim[corev1.ResourceCPU] = resource.MustParse(v)
-	}
-	if v, ok := job.Env["XC_RESOURCE_MEMORY_LIMIT"]; ok && v != "" {
-		lim[corev1.ResourceMemory] = resource.MustParse(v)
+	for _, r := range []struct {
+		key  string
+		list corev1.ResourceList
+		name corev1.ResourceName
+	}{
+		{"XC_RESOURCE_CPU_REQUEST", req, corev1.ResourceCPU},
+		{"XC_RESOURCE_MEMORY_REQUEST", req, corev1.ResourceMemory},
+		{"XC_RESOURCE_CPU_LIMIT", lim, corev1.ResourceCPU},
+		{"XC_RESOURCE_MEMORY_LIMIT", lim, corev1.ResourceMemory},
+	} {
+		v := job.Env[r.key]
+		if v == "" {
+			continue
+		}
+		q, err := resource.ParseQuantity(v)
+		if err != nil {
+			logrus.WithError(err).WithField("key", r.key).Warn("invalid resource quantity, ignored")
+			continue
+		}
+		r.list[r.name] = q
 	}
 	return corev1.ResourceRequirements{Requests: req, Limits: lim}
 }
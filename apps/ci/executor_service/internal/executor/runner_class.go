@@ -0,0 +1,237 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"xcoding/apps/ci/executor_service/models"
+
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// RunnerClass 执行器类别：runs-on 标签对应的 Pod 模板
+// 说明：
+// - image 为类别的默认镜像，Job 的 container 优先
+// - resources 为 runner 容器的资源请求/限制，已废弃的 XC_RESOURCE_* 环境变量按项覆盖
+// - securityContext / podSecurityContext 未配置时沿用 root 运行（与未定义类别时一致）
+type RunnerClass struct {
+	Image              string                      `json:"image,omitempty"`
+	Resources          corev1.ResourceRequirements `json:"resources,omitempty"`
+	NodeSelector       map[string]string           `json:"nodeSelector,omitempty"`
+	Tolerations        []corev1.Toleration         `json:"tolerations,omitempty"`
+	Affinity           *corev1.Affinity            `json:"affinity,omitempty"`
+	ServiceAccountName string                      `json:"serviceAccountName,omitempty"`
+	RuntimeClassName   string                      `json:"runtimeClassName,omitempty"`
+	SecurityContext    *corev1.SecurityContext     `json:"securityContext,omitempty"`
+	PodSecurityContext *corev1.PodSecurityContext  `json:"podSecurityContext,omitempty"`
+}
+
+// RunnerClassEntry 已定义的执行器类别
+type RunnerClassEntry struct {
+	Label     string
+	Class     RunnerClass
+	Source    string // config（配置文件，只读）或 api（管理接口）
+	UpdatedAt time.Time
+}
+
+const (
+	RunnerClassSourceConfig = "config"
+	RunnerClassSourceAPI    = "api"
+)
+
+var (
+	// ErrUnknownRunnerClass runs-on 指定的类别未定义
+	ErrUnknownRunnerClass = errors.New("unknown runner class")
+	// ErrRunnerClassReadOnly 类别由配置文件定义，不能经管理接口修改或删除
+	ErrRunnerClassReadOnly = errors.New("runner class is read-only")
+	// ErrInvalidRunnerClass 类别标签或定义非法
+	ErrInvalidRunnerClass = errors.New("invalid runner class")
+)
+
+// runnerClassesFile 类别配置文件格式
+//
+//	default: linux          # runs-on 未配置时使用的类别（可选）
+//	classes:
+//	  linux:
+//	    image: ubuntu:24.04
+//	    resources: {requests: {cpu: 500m, memory: 1Gi}}
+//	  gpu:
+//	    nodeSelector: {accelerator: nvidia}
+//	    tolerations: [{key: nvidia.com/gpu, operator: Exists, effect: NoSchedule}]
+type runnerClassesFile struct {
+	Default string                 `json:"default"`
+	Classes map[string]RunnerClass `json:"classes"`
+}
+
+// RunnerClassRegistry 执行器类别注册表：配置文件中的类别（只读）与经管理接口落库的类别
+// 说明：未定义任何类别时注册表不生效，runs-on 被忽略（兼容 runs-on: ubuntu-latest 等 GitHub 写法）；
+// 定义类别后 runs-on 必须为已定义的标签，未配置 runs-on 的 Job 使用默认类别（未配置默认类别时沿用内置 Pod 模板）
+type RunnerClassRegistry struct {
+	db     *gorm.DB
+	static map[string]RunnerClass
+	def    string
+}
+
+// LoadRunnerClasses 读取类别配置文件（path 为空时仅使用管理接口定义的类别）
+func LoadRunnerClasses(db *gorm.DB, path string) (*RunnerClassRegistry, error) {
+	r := &RunnerClassRegistry{db: db, static: map[string]RunnerClass{}}
+	if strings.TrimSpace(path) == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read runner classes: %w", err)
+	}
+	var f runnerClassesFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("parse runner classes %s: %w", path, err)
+	}
+	for label := range f.Classes {
+		if err := validateRunnerLabel(label); err != nil {
+			return nil, fmt.Errorf("runner classes %s: %w", path, err)
+		}
+	}
+	if f.Default != "" {
+		if _, ok := f.Classes[f.Default]; !ok {
+			return nil, fmt.Errorf("runner classes %s: default class %q is not defined", path, f.Default)
+		}
+	}
+	r.static, r.def = f.Classes, f.Default
+	if r.static == nil {
+		r.static = map[string]RunnerClass{}
+	}
+	return r, nil
+}
+
+// ParseRunnerClass 解析类别定义（YAML 或 JSON），字段见 RunnerClass
+func ParseRunnerClass(spec string) (RunnerClass, error) {
+	var c RunnerClass
+	if err := yaml.UnmarshalStrict([]byte(spec), &c); err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidRunnerClass, err)
+	}
+	return c, nil
+}
+
+// validateRunnerLabel 类别标签：字母、数字、'-'、'_'、'.'，不超过 63 个字符
+func validateRunnerLabel(label string) error {
+	if errs := validation.IsValidLabelValue(label); label == "" || len(errs) > 0 {
+		return fmt.Errorf("%w label %q: %s", ErrInvalidRunnerClass, label, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Default 默认类别标签，未配置时为空
+func (r *RunnerClassRegistry) Default() string {
+	if r == nil {
+		return ""
+	}
+	return r.def
+}
+
+// List 列出全部类别（配置文件中的同名类别优先），按标签排序
+func (r *RunnerClassRegistry) List() ([]RunnerClassEntry, error) {
+	if r == nil {
+		return nil, nil
+	}
+	out := make([]RunnerClassEntry, 0, len(r.static))
+	for label, c := range r.static {
+		out = append(out, RunnerClassEntry{Label: label, Class: c, Source: RunnerClassSourceConfig})
+	}
+	if r.db != nil {
+		var rows []models.RunnerClass
+		if err := r.db.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if _, ok := r.static[row.Label]; ok {
+				continue
+			}
+			var c RunnerClass
+			if err := json.Unmarshal([]byte(row.Spec), &c); err != nil {
+				return nil, fmt.Errorf("runner class %q: %w", row.Label, err)
+			}
+			out = append(out, RunnerClassEntry{Label: row.Label, Class: c, Source: RunnerClassSourceAPI, UpdatedAt: row.UpdatedAt})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Label < out[j].Label })
+	return out, nil
+}
+
+// Labels 已定义的类别标签；未定义任何类别（注册表不生效）时返回 nil
+func (r *RunnerClassRegistry) Labels() ([]string, error) {
+	entries, err := r.List()
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	labels := make([]string, len(entries))
+	for i, e := range entries {
+		labels[i] = e.Label
+	}
+	return labels, nil
+}
+
+// Resolve 按 runs-on 标签查找类别：标签为空时使用默认类别；
+// 注册表不生效或未配置默认类别时返回 nil（使用内置 Pod 模板），标签未定义时返回 ErrUnknownRunnerClass
+func (r *RunnerClassRegistry) Resolve(label string) (*RunnerClass, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		label = r.Default()
+	}
+	if label == "" {
+		return nil, nil
+	}
+	entries, err := r.List()
+	if err != nil {
+		return nil, fmt.Errorf("load runner classes: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	for _, e := range entries {
+		if e.Label == label {
+			c := e.Class
+			return &c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownRunnerClass, label)
+}
+
+// Put 经管理接口新增或更新类别；配置文件中的类别只读
+func (r *RunnerClassRegistry) Put(label, spec string) (RunnerClassEntry, error) {
+	if err := validateRunnerLabel(label); err != nil {
+		return RunnerClassEntry{}, err
+	}
+	if _, ok := r.static[label]; ok {
+		return RunnerClassEntry{}, fmt.Errorf("%w: %q is defined in the config file", ErrRunnerClassReadOnly, label)
+	}
+	c, err := ParseRunnerClass(spec)
+	if err != nil {
+		return RunnerClassEntry{}, err
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return RunnerClassEntry{}, err
+	}
+	row := models.RunnerClass{Label: label, Spec: string(data)}
+	if err := r.db.Save(&row).Error; err != nil {
+		return RunnerClassEntry{}, err
+	}
+	return RunnerClassEntry{Label: label, Class: c, Source: RunnerClassSourceAPI, UpdatedAt: row.UpdatedAt}, nil
+}
+
+// Delete 删除经管理接口定义的类别，返回是否存在；配置文件中的类别（含默认类别）只读
+// 说明：引用该类别的工作流在下次保存或构建时校验失败
+func (r *RunnerClassRegistry) Delete(label string) (bool, error) {
+	if _, ok := r.static[label]; ok {
+		return false, fmt.Errorf("%w: %q is defined in the config file", ErrRunnerClassReadOnly, label)
+	}
+	res := r.db.Where("label = ?", label).Delete(&models.RunnerClass{})
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,140 @@
+package executor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRunnerClassRegistry(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.RunnerClass{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未定义任何类别时注册表不生效
+	empty, err := LoadRunnerClasses(db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err := empty.Resolve("ubuntu-latest"); c != nil || err != nil {
+		t.Errorf("Resolve on empty registry = %v, %v; want nil, nil", c, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "classes.yaml")
+	file := `default: small
+classes:
+  small:
+    image: ubuntu:24.04
+    resources:
+      requests: {cpu: 500m, memory: 1Gi}
+      limits: {cpu: "2", memory: 4Gi}
+  gpu:
+    nodeSelector: {accelerator: nvidia}
+    tolerations: [{key: nvidia.com/gpu, operator: Exists, effect: NoSchedule}]
+    runtimeClassName: nvidia
+    securityContext: {runAsUser: 1000, runAsNonRoot: true}
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadRunnerClasses(db, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Put("gpu", "image: x"); !errors.Is(err, ErrRunnerClassReadOnly) {
+		t.Errorf("Put config class: %v, want ErrRunnerClassReadOnly", err)
+	}
+	if _, err := r.Put("arm", "nodeSelectr: {}"); !errors.Is(err, ErrInvalidRunnerClass) {
+		t.Errorf("Put with unknown field: %v, want ErrInvalidRunnerClass", err)
+	}
+	if _, err := r.Put("arm", `{"nodeSelector": {"kubernetes.io/arch": "arm64"}}`); err != nil {
+		t.Fatal(err)
+	}
+	labels, err := r.Labels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"arm", "gpu", "small"}; len(labels) != 3 || labels[0] != want[0] || labels[1] != want[1] || labels[2] != want[2] {
+		t.Errorf("Labels = %v, want %v", labels, want)
+	}
+	if _, err := r.Resolve("windows-latest"); !errors.Is(err, ErrUnknownRunnerClass) {
+		t.Errorf("Resolve unknown: %v, want ErrUnknownRunnerClass", err)
+	}
+
+	// 未配置 runs-on 时使用默认类别；XC_RESOURCE_* 按项覆盖类别资源
+	job := parser.Job{Env: map[string]string{"XC_RESOURCE_MEMORY_REQUEST": "2Gi"}, Steps: []parser.Step{{Name: "a", Run: "true"}}}
+	class, err := r.Resolve("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := BuildJobSpecWithExtensions("ci", 1, "build-1-a", job, expr.NewContext(), class)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := spec.Spec.Template.Spec.Containers[0]
+	if c.Image != "ubuntu:24.04" {
+		t.Errorf("image = %q, want class image", c.Image)
+	}
+	if cpu, mem := c.Resources.Requests[corev1.ResourceCPU], c.Resources.Requests[corev1.ResourceMemory]; cpu.String() != "500m" || mem.String() != "2Gi" {
+		t.Errorf("requests = %v, want cpu 500m and memory 2Gi", c.Resources.Requests)
+	}
+	if class.Resources.Requests.Memory().String() != "1Gi" {
+		t.Errorf("class resources modified: %v", class.Resources.Requests)
+	}
+
+	// XC_RESOURCE_* 不能突破类别 limits；无法解析的取值被忽略
+	job.Env = map[string]string{"XC_RESOURCE_CPU_REQUEST": "8", "XC_RESOURCE_MEMORY_LIMIT": "64Gi", "XC_RESOURCE_MEMORY_REQUEST": "lots"}
+	spec, err = BuildJobSpecWithExtensions("ci", 1, "build-1-a", job, expr.NewContext(), class)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = spec.Spec.Template.Spec.Containers[0]
+	if cpu, mem := c.Resources.Requests[corev1.ResourceCPU], c.Resources.Requests[corev1.ResourceMemory]; cpu.String() != "2" || mem.String() != "1Gi" {
+		t.Errorf("requests = %v, want cpu 2 and memory 1Gi", c.Resources.Requests)
+	}
+	if mem := c.Resources.Limits[corev1.ResourceMemory]; mem.String() != "4Gi" {
+		t.Errorf("limits = %v, want memory 4Gi", c.Resources.Limits)
+	}
+	job.Env = nil
+
+	// container 优先于类别镜像，类别的调度与安全设置替换内置模板
+	job.Container = "nvidia/cuda:12.4"
+	class, err = r.Resolve("gpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err = BuildJobSpecWithExtensions("ci", 1, "build-1-a", job, expr.NewContext(), class)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := spec.Spec.Template.Spec
+	if pod.Containers[0].Image != "nvidia/cuda:12.4" {
+		t.Errorf("image = %q, want job container", pod.Containers[0].Image)
+	}
+	if pod.NodeSelector["accelerator"] != "nvidia" || len(pod.Tolerations) != 1 || pod.RuntimeClassName == nil || *pod.RuntimeClassName != "nvidia" {
+		t.Errorf("scheduling not applied: nodeSelector=%v tolerations=%v runtimeClass=%v", pod.NodeSelector, pod.Tolerations, pod.RuntimeClassName)
+	}
+	if sc := pod.Containers[0].SecurityContext; sc == nil || sc.RunAsUser == nil || *sc.RunAsUser != 1000 {
+		t.Errorf("securityContext = %+v, want runAsUser 1000", sc)
+	}
+
+	if ok, err := r.Delete("arm"); !ok || err != nil {
+		t.Errorf("Delete(arm) = %v, %v", ok, err)
+	}
+	if _, err := r.Delete("small"); !errors.Is(err, ErrRunnerClassReadOnly) {
+		t.Errorf("Delete config class: %v, want ErrRunnerClassReadOnly", err)
+	}
+}
@@ -0,0 +1,223 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"xcoding/apps/ci/executor_service/internal/config"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// K8sRunner 基于 K8s Job 的运行后端：每个任务对应一个 K8s Job（单 Pod）
+// Job 与 Pod 的状态变化经 JobWatcher 的 informer 事件获取，不逐个轮询
+type K8sRunner struct {
+	Env   *K8sEnv
+	Watch *JobWatcher
+	// ImagePullTimeout runner 容器启动（调度与镜像拉取）的等待上限；0 表示不限
+	ImagePullTimeout time.Duration
+	// Classes 执行器类别注册表，按 runs-on 选择 Pod 模板；nil 时使用内置 Pod 模板
+	Classes *RunnerClassRegistry
+	// DockerActions docker Action 步骤容器的配置
+	DockerActions config.DockerActionConfig
+	// ExprImage 提供 xc-expr 的镜像（执行器镜像），步骤表达式引用 steps 上下文时由 init 容器复制到 Job Pod
+	ExprImage string
+
+	mu    sync.Mutex
+	pods  map[string]string    // K8s Job 名 → Pod 名
+	since map[string]time.Time // 恢复构建时日志的起始时间
+}
+
+// jobStatusTimeout 日志结束后等待 K8s Job 写入终态的上限
+const jobStatusTimeout = 2 * time.Minute
+
+// NewK8sRunner 创建 K8s 运行后端；watch 需已启动
+func NewK8sRunner(env *K8sEnv, watch *JobWatcher, imagePullTimeout time.Duration) *K8sRunner {
+	return &K8sRunner{Env: env, Watch: watch, ImagePullTimeout: imagePullTimeout, pods: map[string]string{}, since: map[string]time.Time{}}
+}
+
+// Create 按 runs-on 选择执行器类别，生成 K8s Job 规范（含 TTL/超时等扩展）并提交
+// runs-on 在运行时才确定（如 ${{ matrix.os }}）且类别未定义时返回 ErrUnknownRunnerClass
+func (r *K8sRunner) Create(ctx context.Context, spec JobSpec) error {
+	class, err := r.Classes.Resolve(string(spec.Job.RunsOn))
+	if err != nil {
+		return err
+	}
+	ns := r.Env.Namespace
+	job, err := BuildJobSpecWithExtensions(ns, spec.BuildID, spec.Name, spec.Job, spec.Ectx, class)
+	if err != nil {
+		return err
+	}
+	if err := ApplyDockerActions(ctx, job, spec.Job, spec.Ectx, r.DockerActions, spec.ProjectID); err != nil {
+		return fmt.Errorf("docker actions: %w", err)
+	}
+	if err := ApplyRuntimeExpr(job, spec.Job, r.ExprImage); err != nil {
+		return err
+	}
+	created, err := r.Env.Clientset.BatchV1().Jobs(ns).Create(ctx, job, metav1.CreateOptions{})
+	if err != nil || spec.Checkout == nil {
+		return err
+	}
+	if err := r.createCheckoutSecret(ctx, created, spec.Checkout); err != nil {
+		_ = r.Cancel(context.Background(), spec.Name)
+		return fmt.Errorf("checkout secret: %w", err)
+	}
+	return nil
+}
+
+// createCheckoutSecret 创建存放检出凭据的临时 Secret，属主为 Job：Job 被删除（TTL、取消）时由垃圾回收一并清理
+func (r *K8sRunner) createCheckoutSecret(ctx context.Context, job *batchv1.Job, files map[string][]byte) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      checkoutSecretName(job.Name),
+			Namespace: job.Namespace,
+			Labels:    job.Labels,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "batch/v1",
+				Kind:       "Job",
+				Name:       job.Name,
+				UID:        job.UID,
+			}},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: files,
+	}
+	_, err := r.Env.Clientset.CoreV1().Secrets(job.Namespace).Create(ctx, secret, metav1.CreateOptions{})
+	return err
+}
+
+// WaitReady 等待 Job 的 Pod 出现且 runner 容器与 docker Action 的步骤容器启动
+// 说明：
+// - 容器进入 CrashLoopBackOff、InvalidImageName 等不可恢复的等待状态，或 init 容器（如 docker Action 镜像构建）失败时立即判定无法启动
+// - 镜像拉取失败（ErrImagePull/ImagePullBackOff）由 kubelet 退避重试，超出 ImagePullTimeout 仍未启动时以拉取错误判定失败
+// - 超时时 Pod 不可调度（Unschedulable）、服务容器健康检查未通过或 Pod 尚未创建分别给出对应原因
+func (r *K8sRunner) WaitReady(ctx context.Context, name string) error {
+	var pod *corev1.Pod
+	err := r.Watch.wait(ctx, name, r.ImagePullTimeout, func() (bool, error) {
+		if pod = r.Watch.pod(name); pod == nil {
+			return false, nil
+		}
+		r.mu.Lock()
+		r.pods[name] = pod.Name
+		r.mu.Unlock()
+		if reason := containerWaiting(pod, fatalWaitingReasons); reason != "" {
+			return false, errors.New(reason)
+		}
+		if reason := initContainerFailure(pod); reason != "" {
+			return false, errors.New(reason)
+		}
+		return containerStarted(pod, "runner") && len(dockerStepsNotStarted(pod)) == 0, nil
+	})
+	if !errors.Is(err, errWaitTimeout) {
+		return err
+	}
+	switch {
+	case pod == nil:
+		return fmt.Errorf("pod not found for job %s after %s", name, r.ImagePullTimeout)
+	case containerWaiting(pod, pullWaitingReasons) != "":
+		return fmt.Errorf("%s (image pull wait %s exceeded)", containerWaiting(pod, pullWaitingReasons), r.ImagePullTimeout)
+	case isUnschedulable(pod):
+		return fmt.Errorf("pod unschedulable: %s", pod.Name)
+	case len(servicesNotStarted(pod)) > 0:
+		return fmt.Errorf("services not healthy after %s: %s", r.ImagePullTimeout, strings.Join(servicesNotStarted(pod), ", "))
+	}
+	return fmt.Errorf("container not ready after %s: pod=%s container=runner", r.ImagePullTimeout, pod.Name)
+}
+
+// Attach 按名称查找已存在的 K8s Job 及其 Pod（标签 job-name），等待 runner 容器启动或已结束
+// Job 或 Pod 已被删除时返回 ErrJobGone
+func (r *K8sRunner) Attach(ctx context.Context, name string, since time.Time) error {
+	if _, err := r.Env.Clientset.BatchV1().Jobs(r.Env.Namespace).Get(ctx, name, metav1.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("%w: k8s job %s was deleted", ErrJobGone, name)
+		}
+		return err
+	}
+	if r.Watch.pod(name) == nil {
+		return fmt.Errorf("%w: pod of k8s job %s disappeared", ErrJobGone, name)
+	}
+	r.mu.Lock()
+	r.since[name] = since
+	r.mu.Unlock()
+	return r.WaitReady(ctx, name)
+}
+
+// StreamLogs 跟随 runner 容器日志直至结束
+func (r *K8sRunner) StreamLogs(ctx context.Context, name string, onLine func(line string)) error {
+	r.mu.Lock()
+	podName, since := r.pods[name], r.since[name]
+	r.mu.Unlock()
+	if podName == "" {
+		return fmt.Errorf("pod not found for job %s", name)
+	}
+	return r.Env.StreamPodLogs(ctx, podName, r.Env.Namespace, "runner", since, onLine)
+}
+
+// Services Job 的 Pod 中定义的服务名
+func (r *K8sRunner) Services(name string) []string {
+	if p := r.Watch.pod(name); p != nil {
+		return podServices(p)
+	}
+	return nil
+}
+
+// StreamServiceLogs 跟随服务容器日志直至结束（runner 退出后 sidecar 被终止）
+func (r *K8sRunner) StreamServiceLogs(ctx context.Context, name, service string, since time.Time, onLine func(line string)) error {
+	r.mu.Lock()
+	podName := r.pods[name]
+	r.mu.Unlock()
+	if podName == "" {
+		return fmt.Errorf("pod not found for job %s", name)
+	}
+	return r.Env.StreamPodLogs(ctx, podName, r.Env.Namespace, ServiceContainerName(service), since, onLine)
+}
+
+// Status 等待 K8s Job 写入 Succeeded/Failed（至多 jobStatusTimeout）
+// 失败原因优先取自 Pod（CrashLoopBackOff、ErrImagePull、OOMKilled 等），其次为 Job 的失败条件
+func (r *K8sRunner) Status(ctx context.Context, name string) (JobOutcome, error) {
+	defer r.forget(name)
+	var out JobOutcome
+	err := r.Watch.wait(ctx, name, jobStatusTimeout, func() (bool, error) {
+		j := r.Watch.job(name)
+		if j == nil {
+			return false, fmt.Errorf("k8s job %s was deleted", name)
+		}
+		switch {
+		case j.Status.Succeeded > 0:
+			out = JobOutcome{Succeeded: true}
+			return true, nil
+		case j.Status.Failed > 0:
+			out = JobOutcome{Reason: jobFailureReason(j)}
+			if p := r.Watch.pod(name); p != nil {
+				if reason := podFailureReason(p); reason != "" {
+					out.Reason = reason
+				}
+			}
+			return true, nil
+		}
+		return false, nil
+	})
+	if errors.Is(err, errWaitTimeout) {
+		return JobOutcome{}, fmt.Errorf("job status unknown: %s", name)
+	}
+	return out, err
+}
+
+// Cancel 删除 K8s Job 及其 Pod
+func (r *K8sRunner) Cancel(ctx context.Context, name string) error {
+	defer r.forget(name)
+	return r.Env.DeleteJob(ctx, name)
+}
+
+func (r *K8sRunner) forget(name string) {
+	r.mu.Lock()
+	delete(r.pods, name)
+	delete(r.since, name)
+	r.mu.Unlock()
+}
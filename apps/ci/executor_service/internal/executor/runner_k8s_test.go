@@ -0,0 +1,178 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testNS = "ci"
+
+// newFakeK8sRunner 基于 fake clientset 创建 K8sRunner，并等待 Job/Pod 两个 watch 建立后返回
+// （fake clientset 不按 resourceVersion 补发事件，watch 建立前的变更会丢失）
+func newFakeK8sRunner(t *testing.T, pullTimeout time.Duration) (*K8sRunner, *fake.Clientset) {
+	t.Helper()
+	cs := fake.NewClientset()
+	watching := make(chan struct{}, 2)
+	cs.PrependWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		gvr := action.GetResource()
+		w, err := cs.Tracker().Watch(gvr, action.GetNamespace())
+		if err != nil {
+			return false, nil, err
+		}
+		watching <- struct{}{}
+		return true, w, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	w := NewJobWatcher(cs, testNS)
+	if err := w.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-watching:
+		case <-time.After(5 * time.Second):
+			t.Fatal("informers did not start watching")
+		}
+	}
+	return NewK8sRunner(&K8sEnv{Clientset: cs, Namespace: testNS}, w, pullTimeout), cs
+}
+
+func testJob(name string) *batchv1.Job {
+	return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNS, Labels: map[string]string{"app": "ci-executor-build"}}}
+}
+
+func testPod(job string, status ...corev1.ContainerStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: job + "-abcde", Namespace: testNS, Labels: map[string]string{"app": "ci-executor-build", "job-name": job}},
+		Status:     corev1.PodStatus{Phase: corev1.PodPending, ContainerStatuses: status},
+	}
+}
+
+func waiting(reason, msg string) corev1.ContainerStatus {
+	return corev1.ContainerStatus{Name: "runner", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: msg}}}
+}
+
+func TestK8sRunnerWaitReady(t *testing.T) {
+	r, cs := newFakeK8sRunner(t, 5*time.Second)
+	ctx := context.Background()
+	pods := cs.CoreV1().Pods(testNS)
+
+	done := make(chan error, 1)
+	go func() { done <- r.WaitReady(ctx, "build-1-a") }()
+	pod := testPod("build-1-a", waiting("ContainerCreating", ""))
+	if _, err := pods.Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "runner", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}}
+	if _, err := pods.UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("WaitReady: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("WaitReady did not observe the running container")
+	}
+	if r.pods["build-1-a"] != pod.Name {
+		t.Errorf("pod = %q, want %q", r.pods["build-1-a"], pod.Name)
+	}
+}
+
+func TestK8sRunnerWaitReadyFailures(t *testing.T) {
+	cases := []struct {
+		name   string
+		status corev1.ContainerStatus
+		want   string
+	}{
+		{"crash loop", waiting("CrashLoopBackOff", "back-off 10s restarting failed container"), "CrashLoopBackOff: runner: back-off 10s"},
+		{"image pull", waiting("ErrImagePull", `pull "nope:1": not found`), "ErrImagePull: runner: pull"},
+		{"image pull backoff", waiting("ImagePullBackOff", ""), "ErrImagePull: runner (image pull wait"},
+		{"invalid image", waiting("InvalidImageName", ""), "InvalidImageName"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, cs := newFakeK8sRunner(t, 200*time.Millisecond)
+			ctx := context.Background()
+			if _, err := cs.CoreV1().Pods(testNS).Create(ctx, testPod("build-1-a", c.status), metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			err := r.WaitReady(ctx, "build-1-a")
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("WaitReady error = %v, want containing %q", err, c.want)
+			}
+		})
+	}
+}
+
+func TestK8sRunnerStatus(t *testing.T) {
+	r, cs := newFakeK8sRunner(t, time.Second)
+	ctx := context.Background()
+	jobs := cs.BatchV1().Jobs(testNS)
+	for _, name := range []string{"build-1-ok", "build-1-crash", "build-1-deadline"} {
+		if _, err := jobs.Create(ctx, testJob(name), metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	crash := testPod("build-1-crash", waiting("CrashLoopBackOff", ""))
+	if _, err := cs.CoreV1().Pods(testNS).Create(ctx, crash, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// Status 读取缓存，等待 informer 收到上述创建事件
+	for deadline := time.Now().Add(3 * time.Second); r.Watch.job("build-1-deadline") == nil || r.Watch.pod("build-1-crash") == nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("informer cache did not observe the jobs")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	finish := func(name string, j func(*batchv1.Job)) {
+		time.Sleep(50 * time.Millisecond)
+		job := testJob(name)
+		j(job)
+		if _, err := jobs.UpdateStatus(ctx, job, metav1.UpdateOptions{}); err != nil {
+			t.Error(err)
+		}
+	}
+	cases := []struct {
+		name   string
+		update func(*batchv1.Job)
+		want   JobOutcome
+	}{
+		{"build-1-ok", func(j *batchv1.Job) { j.Status.Succeeded = 1 }, JobOutcome{Succeeded: true}},
+		{"build-1-crash", func(j *batchv1.Job) { j.Status.Failed = 1 }, JobOutcome{Reason: "CrashLoopBackOff: runner"}},
+		{"build-1-deadline", func(j *batchv1.Job) {
+			j.Status.Failed = 1
+			j.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "DeadlineExceeded", Message: "Job was active longer than specified deadline"}}
+		}, JobOutcome{Reason: "DeadlineExceeded: Job was active longer than specified deadline"}},
+	}
+	for _, c := range cases {
+		go finish(c.name, c.update)
+		got, err := r.Status(ctx, c.name)
+		if err != nil {
+			t.Fatalf("Status(%s): %v", c.name, err)
+		}
+		if got != c.want {
+			t.Errorf("Status(%s) = %+v, want %+v", c.name, got, c.want)
+		}
+	}
+
+	if err := jobs.Delete(ctx, "build-1-ok", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := r.Status(ctx, "build-1-ok"); err == nil || !strings.Contains(err.Error(), "deleted") {
+		t.Errorf("Status of deleted job: %v, want deleted error", err)
+	}
+}
@@ -0,0 +1,226 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LocalRunner 本地 Shell 运行后端：在本机 /bin/bash 子进程中执行 BuildScript 生成的脚本
+// 说明：
+// - 每个 Job 使用独立的临时工作区（GITHUB_WORKSPACE），Job 结束后删除
+// - 仅注入明文环境变量，secret:// 引用在本地不可用
+// - 检出凭据写入工作区之外的临时目录（XC_CHECKOUT_DIR），Job 结束后删除
+// - 不隔离容器镜像（container 字段被忽略），用于开发与测试
+// - 步骤表达式引用 steps 上下文时需要 xc-expr（见 ExprPath）
+// - Job timeout-minutes 到期时终止整个进程组
+type LocalRunner struct {
+	Root string // 临时工作区所在目录，空表示系统临时目录
+	// ExprPath xc-expr 的路径，步骤表达式引用 steps 上下文时使用；默认取执行器所在目录或 PATH 中的 xc-expr
+	ExprPath string
+
+	mu    sync.Mutex
+	procs map[string]*localProc
+}
+
+type localProc struct {
+	cmd      *exec.Cmd
+	out      *os.File // 合并后的 stdout/stderr 读端
+	dir      string
+	coDir    string // 检出凭据目录，无检出步骤时为空
+	done     chan struct{}
+	err      error
+	timedOut atomic.Bool // 超出 Job timeout-minutes 后被终止
+}
+
+// NewLocalRunner 创建本地运行后端
+func NewLocalRunner(root string) *LocalRunner {
+	return &LocalRunner{Root: root, ExprPath: findExprBinary(), procs: map[string]*localProc{}}
+}
+
+// Create 创建临时工作区并启动 bash 子进程
+func (r *LocalRunner) Create(ctx context.Context, spec JobSpec) error {
+	if jobNeedsRuntimeExpr(spec.Job) && r.ExprPath == "" {
+		return fmt.Errorf("job has step expressions evaluated at runtime (steps context or hashFiles()), but %s was not found next to the executor or in PATH", exprBinName)
+	}
+	if r.Root != "" {
+		if err := os.MkdirAll(r.Root, 0o755); err != nil {
+			return fmt.Errorf("workspace root: %w", err)
+		}
+	}
+	dir, err := os.MkdirTemp(r.Root, spec.Name+"-")
+	if err != nil {
+		return fmt.Errorf("workspace: %w", err)
+	}
+	coDir, err := writeCheckoutFiles(r.Root, spec.Name, spec.Checkout)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return fmt.Errorf("checkout credentials: %w", err)
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		_ = os.RemoveAll(coDir)
+		return fmt.Errorf("pipe: %w", err)
+	}
+	cmd := exec.Command("/bin/bash", "-c", BuildScript(spec.Job, spec.Ectx))
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "CI=true", "GITHUB_WORKSPACE="+dir, "XC_WORKSPACE="+dir)
+	if coDir != "" {
+		cmd.Env = append(cmd.Env, "XC_CHECKOUT_DIR="+coDir)
+	}
+	if r.ExprPath != "" {
+		cmd.Env = append(cmd.Env, exprBinEnv+"="+r.ExprPath)
+	}
+	for _, ev := range BuildEnvVarsForJob(spec.Job) {
+		if ev.ValueFrom == nil {
+			cmd.Env = append(cmd.Env, ev.Name+"="+ev.Value)
+		}
+	}
+	cmd.Stdout, cmd.Stderr = pw, pw
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		_ = pr.Close()
+		_ = pw.Close()
+		_ = os.RemoveAll(dir)
+		_ = os.RemoveAll(coDir)
+		return fmt.Errorf("start bash: %w", err)
+	}
+	// 父进程关闭写端，子进程退出后读端即可读到 EOF
+	_ = pw.Close()
+	p := &localProc{cmd: cmd, out: pr, dir: dir, coDir: coDir, done: make(chan struct{})}
+	var deadline *time.Timer
+	if t := JobTimeoutSeconds(spec.Job); t > 0 {
+		deadline = time.AfterFunc(time.Duration(t)*time.Second, func() {
+			p.timedOut.Store(true)
+			killProcessGroup(cmd)
+		})
+	}
+	go func() {
+		p.err = cmd.Wait()
+		if deadline != nil {
+			deadline.Stop()
+		}
+		close(p.done)
+	}()
+	r.mu.Lock()
+	r.procs[spec.Name] = p
+	r.mu.Unlock()
+	return nil
+}
+
+// WaitReady 子进程启动即就绪
+func (r *LocalRunner) WaitReady(ctx context.Context, name string) error {
+	_, err := r.proc(name)
+	return err
+}
+
+// Attach 本地子进程的输出管道随执行器退出而关闭，无法重新附着
+func (r *LocalRunner) Attach(ctx context.Context, name string, since time.Time) error {
+	if _, err := r.proc(name); err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: local process of %s did not survive the executor restart", ErrJobGone, name)
+}
+
+// StreamLogs 逐行读取子进程输出直至结束；ctx 取消时关闭读端以中断读取
+func (r *LocalRunner) StreamLogs(ctx context.Context, name string, onLine func(line string)) error {
+	p, err := r.proc(name)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = p.out.Close() })
+	defer stop()
+	scanner := bufio.NewScanner(p.out)
+	scanner.Buffer(make([]byte, 0, 1024), 1024*1024)
+	for scanner.Scan() {
+		onLine(scanner.Text())
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
+
+// Status 等待子进程退出，按退出码判定终态并清理工作区
+func (r *LocalRunner) Status(ctx context.Context, name string) (JobOutcome, error) {
+	p, err := r.proc(name)
+	if err != nil {
+		return JobOutcome{}, err
+	}
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return JobOutcome{}, ctx.Err()
+	}
+	r.release(name, p)
+	if p.timedOut.Load() {
+		return JobOutcome{Reason: "DeadlineExceeded: Job was active longer than specified deadline"}, nil
+	}
+	if p.err != nil {
+		var exit *exec.ExitError
+		if errors.As(p.err, &exit) {
+			return JobOutcome{Reason: fmt.Sprintf("exit code %d", exit.ExitCode())}, nil
+		}
+		return JobOutcome{Reason: p.err.Error()}, nil
+	}
+	return JobOutcome{Succeeded: true}, nil
+}
+
+// Cancel 终止子进程（含其派生进程）并清理工作区
+func (r *LocalRunner) Cancel(ctx context.Context, name string) error {
+	p, err := r.proc(name)
+	if err != nil {
+		return nil
+	}
+	killProcessGroup(p.cmd)
+	<-p.done
+	r.release(name, p)
+	return nil
+}
+
+func (r *LocalRunner) proc(name string) (*localProc, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.procs[name]
+	if !ok {
+		return nil, fmt.Errorf("local job not found: %s", name)
+	}
+	return p, nil
+}
+
+func (r *LocalRunner) release(name string, p *localProc) {
+	r.mu.Lock()
+	if r.procs[name] == p {
+		delete(r.procs, name)
+	}
+	r.mu.Unlock()
+	_ = p.out.Close()
+	_ = os.RemoveAll(p.dir)
+	_ = os.RemoveAll(p.coDir)
+}
+
+// writeCheckoutFiles 将检出凭据写入仅当前用户可读的临时目录；files 为空时返回空路径
+func writeCheckoutFiles(root, name string, files map[string][]byte) (string, error) {
+	if len(files) == 0 {
+		return "", nil
+	}
+	dir, err := os.MkdirTemp(root, name+"-checkout-")
+	if err != nil {
+		return "", err
+	}
+	for k, v := range files {
+		if err := os.WriteFile(filepath.Join(dir, k), v, 0o600); err != nil {
+			_ = os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
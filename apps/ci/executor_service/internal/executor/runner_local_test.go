@@ -0,0 +1,274 @@
+//go:build unix
+
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
+	civ1 "xcoding/gen/go/ci/v1"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestLocalRunner(t *testing.T) {
+	ctx := context.Background()
+	r := NewLocalRunner(t.TempDir())
+	job := parser.Job{
+		Env: map[string]string{"GREETING": "hello"},
+		Steps: []parser.Step{
+			{Name: "greet", Run: `echo "$GREETING from $(basename "$PWD")"; echo v=1 >> "$GITHUB_OUTPUT"`},
+			{Name: "fail", Run: "exit 3"},
+			{Name: "after", Run: "echo unreachable"},
+		},
+	}
+	if err := r.Create(ctx, JobSpec{Name: "build-1-a", Job: job, Ectx: expr.NewContext()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WaitReady(ctx, "build-1-a"); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	if err := r.StreamLogs(ctx, "build-1-a", func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatal(err)
+	}
+	out := strings.Join(lines, "\n")
+	for _, want := range []string{
+		MarkerStepBegin + " greet",
+		"hello from build-1-a-",
+		MarkerStepOutput + " greet ",
+		MarkerStepExit + " greet 0",
+		MarkerStepEnd + " greet",
+		MarkerStepExit + " fail 3",
+		MarkerStepSkip + " after",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("logs missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, MarkerStepEnd+" fail") {
+		t.Errorf("failed step reported as ended:\n%s", out)
+	}
+	outcome, err := r.Status(ctx, "build-1-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome.Succeeded || outcome.Reason != "exit code 3" {
+		t.Errorf("outcome = %+v, want exit code 3", outcome)
+	}
+	if _, err := r.Status(ctx, "build-1-a"); err == nil {
+		t.Error("job still known after Status released it")
+	}
+
+	// Cancel 终止整个进程组：步骤派生的后台进程同样被终止
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	job = parser.Job{Steps: []parser.Step{{Name: "sleep", Run: "sleep 300 &\necho $! > " + shellQuote(pidFile) + "\nwait"}}}
+	if err := r.Create(ctx, JobSpec{Name: "build-1-b", Job: job, Ectx: expr.NewContext()}); err != nil {
+		t.Fatal(err)
+	}
+	var pid int
+	for deadline := time.Now().Add(5 * time.Second); pid == 0 && time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if b, err := os.ReadFile(pidFile); err == nil && strings.HasSuffix(string(b), "\n") {
+			pid, _ = strconv.Atoi(strings.TrimSpace(string(b)))
+		}
+	}
+	if pid == 0 {
+		t.Fatal("background process did not start")
+	}
+	if err := r.Cancel(ctx, "build-1-b"); err != nil {
+		t.Fatal(err)
+	}
+	if !processGone(pid, 5*time.Second) {
+		t.Errorf("background process %d survived Cancel", pid)
+	}
+}
+
+func TestLocalRunnerRuntimeExpressions(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available to build xc-expr")
+	}
+	bin := filepath.Join(t.TempDir(), exprBinName)
+	if out, err := exec.Command("go", "build", "-o", bin, "xcoding/apps/ci/executor_service/cmd/xc-expr").CombinedOutput(); err != nil {
+		t.Fatalf("build xc-expr: %v\n%s", err, out)
+	}
+	ectx := expr.NewContext()
+	ectx.Set("github", map[string]any{"sha": "abc"})
+	job, err := interpolateJob(parser.Job{Steps: []parser.Step{
+		{ID: "meta", Name: "meta", Run: "printf x > go.sum\necho 'version=1.2.3' >> \"$GITHUB_OUTPUT\"\necho 'notes<<EOF' >> \"$GITHUB_OUTPUT\"\necho \"it's multi\" >> \"$GITHUB_OUTPUT\"\necho EOF >> \"$GITHUB_OUTPUT\""},
+		{ID: "flaky", Name: "flaky", Run: "exit 3", ContinueOnError: "true"},
+		{Name: "use", If: "steps.meta.outputs.version == '1.2.3' && steps.flaky.outcome == 'failure'",
+			Run: "echo \"v=${{ steps.meta.outputs.version }} c=${{ steps.flaky.conclusion }} sha=${{ github.sha }} $V\"\necho \"${{ steps.meta.outputs.notes }}\"\necho \"deps=${{ hashFiles('**/go.sum') }}\"",
+			Env: map[string]string{"V": "${{ format('v{0}', steps.meta.outputs.version) }}"}},
+		{ID: "skipped", Name: "skipped", If: "${{ steps.meta.outputs.version == '2' }}", Run: "echo unreachable"},
+		{ID: "bad", Name: "bad", If: "fromJSON(steps.meta.outputs.version)", Run: "echo unreachable"},
+		{Name: "after", If: "failure() && steps.bad.outcome == 'failure' && steps.skipped.outcome == 'skipped'", Run: "echo recovered"},
+	}}, ectx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewLocalRunner(t.TempDir())
+	r.ExprPath = bin
+	ctx := context.Background()
+	if err := r.Create(ctx, JobSpec{Name: "build-2-a", Job: job, Ectx: ectx}); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	if err := r.StreamLogs(ctx, "build-2-a", func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatal(err)
+	}
+	out := strings.Join(lines, "\n")
+	fileHash := sha256.Sum256([]byte("x"))
+	depsHash := sha256.Sum256(fileHash[:])
+	for _, want := range []string{
+		MarkerStepEnd + " flaky",
+		"v=1.2.3 c=success sha=abc v1.2.3",
+		"it's multi",
+		"deps=" + hex.EncodeToString(depsHash[:]),
+		MarkerStepSkip + " skipped",
+		MarkerStepBegin + " bad",
+		"fromJSON",
+		MarkerStepExit + " bad 1",
+		"recovered",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("logs missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "unreachable") {
+		t.Errorf("skipped step ran:\n%s", out)
+	}
+	outcome, err := r.Status(ctx, "build-2-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome.Succeeded || outcome.Reason != "exit code 1" {
+		t.Errorf("outcome = %+v, want exit code 1", outcome)
+	}
+
+	// 找不到 xc-expr 时拒绝创建
+	r.ExprPath = ""
+	if err := r.Create(ctx, JobSpec{Name: "build-2-b", Job: job, Ectx: ectx}); err == nil || !strings.Contains(err.Error(), exprBinName) {
+		t.Errorf("Create without xc-expr error = %v", err)
+	}
+}
+
+// processGone 进程在 timeout 内退出（不存在或已成为僵尸进程）
+func processGone(pid int, timeout time.Duration) bool {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if syscall.Kill(pid, 0) != nil {
+			return true
+		}
+		if b, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat")); err == nil {
+			if f := strings.Fields(string(b)); len(f) > 2 && f[2] == "Z" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestEngineWithLocalRunner(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Build{}, &models.BuildSnapshot{}, &models.BuildJob{}, &models.BuildJobEdge{}, &models.BuildStep{}, &models.BuildStepLogChunk{}, &models.BuildServiceLogChunk{}, &models.ConcurrencyLock{}); err != nil {
+		t.Fatal(err)
+	}
+	wf, err := parser.ValidateWorkflowYAML(`jobs:
+  build:
+    outputs:
+      version: ${{ steps.meta.outputs.version }}
+    steps:
+      - id: meta
+        name: meta
+        run: echo "version=1.2.3" >> "$GITHUB_OUTPUT"
+  test:
+    needs: build
+    env:
+      VERSION: ${{ needs.build.outputs.version }}
+    steps:
+      - name: show
+        run: echo "testing $VERSION"
+      - name: fail
+        run: exit 3
+      - name: after
+        run: echo unreachable
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Build{ID: 1, Name: "b", Status: int32(civ1.BuildStatus_BUILD_STATUS_RUNNING)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"build", "test"} {
+		if err := db.Create(&models.BuildJob{BuildID: 1, Name: name, Status: "pending", Index: int32(i + 1)}).Error; err != nil {
+			t.Fatal(err)
+		}
+		for j, st := range wf.Jobs[name].Steps {
+			if err := db.Create(&models.BuildStep{BuildID: 1, JobName: name, Index: int32(j + 1), Name: st.Name, Status: "pending"}).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := NewEngine(NewLocalRunner(t.TempDir()), db, nil).RunWorkflow(ctx, 1, wf); err != nil {
+		t.Fatal(err)
+	}
+
+	var build models.Build
+	_ = db.First(&build, 1).Error
+	if build.Status != int32(civ1.BuildStatus_BUILD_STATUS_FAILED) {
+		t.Errorf("build status = %d, want FAILED", build.Status)
+	}
+	jobs := map[string]models.BuildJob{}
+	var jobRows []models.BuildJob
+	_ = db.Find(&jobRows).Error
+	for _, j := range jobRows {
+		jobs[j.Name] = j
+	}
+	if j := jobs["build"]; j.Status != "succeeded" || j.Outputs["version"] != "1.2.3" {
+		t.Errorf("build job = %s, outputs %v", j.Status, j.Outputs)
+	}
+	if j := jobs["test"]; j.Status != "failed" || j.Reason != "exit code 3" {
+		t.Errorf("test job = %s (%s), want failed with exit code 3", j.Status, j.Reason)
+	}
+	var steps []models.BuildStep
+	_ = db.Where("job_name = ?", "test").Order(`"index" asc`).Find(&steps).Error
+	want := []string{"succeeded", "failed", "skipped"}
+	if len(steps) != len(want) {
+		t.Fatalf("steps = %+v, want %d", steps, len(want))
+	}
+	for i, st := range steps {
+		if st.Status != want[i] {
+			t.Errorf("step %s = %s, want %s", st.Name, st.Status, want[i])
+		}
+	}
+	if steps[1].ExitCode == nil || *steps[1].ExitCode != 3 {
+		t.Errorf("failed step exit code = %v, want 3", steps[1].ExitCode)
+	}
+	var chunks []models.BuildStepLogChunk
+	_ = db.Where("build_step_id = ?", steps[0].ID).Find(&chunks).Error
+	var logs strings.Builder
+	for _, c := range chunks {
+		logs.WriteString(c.Content)
+	}
+	if !strings.Contains(logs.String(), "testing 1.2.3") {
+		t.Errorf("step logs = %q, want job output passed through needs", logs.String())
+	}
+}
@@ -0,0 +1,137 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/internal/config"
+	"xcoding/apps/ci/executor_service/parser"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// 引用 steps 上下文或 hashFiles() 的步骤表达式（if 与 Step.Deferred 标记的 run/env/with）在 Job 运行时由 xc-expr（cmd/xc-expr）求值：
+//   - 脚本开头在状态目录 $XC_EXPR_STATE 写入 Job 的表达式上下文（context.json），并定义 __xc_record；hashFiles() 相对 $XC_EXPR_WORKSPACE
+//   - 声明了 id 的步骤结束或跳过后，以 __xc_record 记录其 outcome/conclusion 与输出文件（steps/<id>/）
+//   - xc-expr 读取上下文与步骤记录求值：if 以退出码返回结果，其余替换后的文本写到标准输出
+//   - k8s 后端经 init 容器将 xc-expr 从执行器镜像复制到共享卷；local 后端使用执行器所在目录或 PATH 中的 xc-expr
+
+const (
+	exprBinEnv   = "XC_EXPR"           // xc-expr 的路径
+	exprStateEnv = "XC_EXPR_STATE"     // 运行时求值的状态目录
+	exprWorkEnv  = "XC_EXPR_WORKSPACE" // hashFiles() 的根目录
+	exprBinName  = "xc-expr"
+	exprVolume   = "xc-expr"
+	exprMount    = "/xc/expr"
+)
+
+// jobNeedsRuntimeExpr Job 是否包含需在运行时求值的步骤表达式
+func jobNeedsRuntimeExpr(job parser.Job) bool {
+	for _, st := range job.Steps {
+		if len(st.Deferred) > 0 || expr.NeedsRuntime(st.If) {
+			return true
+		}
+	}
+	return false
+}
+
+// runtimeExprPreamble 生成脚本开头的状态目录、表达式上下文文件与 __xc_record 函数
+// __xc_record <id> <退出码|skipped> [输出文件] [continue-on-error]：continue-on-error 时失败步骤的 conclusion 记为 success
+func runtimeExprPreamble(ectx *expr.Context) string {
+	var values map[string]any
+	if ectx != nil {
+		values = ectx.Values
+	}
+	ctx, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Sprintf("echo %s >&2\nexit 1\n", shellQuote("expression context: "+err.Error()))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "export %s=$(mktemp -d)\n", exprStateEnv)
+	fmt.Fprintf(&b, "export %s=\"${XC_WORKSPACE:-%s}\"\n", exprWorkEnv, config.WORKDIR)
+	fmt.Fprintf(&b, "cat > \"$%s/context.json\" <<'__XC_EXPR_CONTEXT__'\n%s\n__XC_EXPR_CONTEXT__\n", exprStateEnv, ctx)
+	fmt.Fprintf(&b, "__xc_record() {\n  local d=\"$%s/steps/$1\" outcome=success conclusion=success\n  mkdir -p \"$d\"\n", exprStateEnv)
+	b.WriteString("  if [ \"$2\" = skipped ]; then outcome=skipped conclusion=skipped\n")
+	b.WriteString("  elif [ \"$2\" != 0 ]; then outcome=failure; if [ \"${4:-}\" != true ]; then conclusion=failure; fi; fi\n")
+	b.WriteString("  printf '%s' \"$outcome\" > \"$d/outcome\"\n  printf '%s' \"$conclusion\" > \"$d/conclusion\"\n")
+	b.WriteString("  rm -f \"$d/outputs\"\n  if [ -n \"${3:-}\" ] && [ -s \"$3\" ]; then cp \"$3\" \"$d/outputs\"; fi\n}\n")
+	return b.String()
+}
+
+// recordStepScript 记录步骤结果的片段（wrapStepBody 在删除输出文件前调用）；步骤无 id 时为空，未启用运行时求值（未定义 __xc_record）时不执行
+func recordStepScript(st parser.Step) string {
+	if st.ID == "" {
+		return ""
+	}
+	return fmt.Sprintf("if declare -F __xc_record >/dev/null; then __xc_record %s $code \"$__xc_out\" %t; fi\n", shellQuote(st.ID), stepContinueOnError(st))
+}
+
+// runtimeCondition 生成运行时求值 if 的分支：xc-expr 退出码 0 执行、1 跳过，求值出错时步骤失败并输出错误
+func runtimeCondition(st parser.Step, run, skip string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "set +e\n\"$%s\" if %s \"$__xc_failed\" 2> \"$%s/if.err\"\n__xc_cond=$?\nset -e\n", exprBinEnv, shellQuote(st.If), exprStateEnv)
+	fmt.Fprintf(&b, "if [ $__xc_cond -eq 0 ]; then\n%selif [ $__xc_cond -eq 1 ]; then\n%selse\n", run, skip)
+	fmt.Fprintf(&b, "echo %s %s\n%s", MarkerStepBegin, shellQuote(st.Name), wrapStepBody(st, fmt.Sprintf("cat \"$%s/if.err\" >&2\nexit 1", exprStateEnv)))
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+// runtimeRunScript 运行时替换 run 中的表达式后在当前子 Shell 中执行
+func runtimeRunScript(run string) string {
+	return fmt.Sprintf("\"$%s\" render %s > \"$%s/run.sh\"\n. \"$%s/run.sh\"\n", exprBinEnv, shellQuote(run), exprStateEnv, exprStateEnv)
+}
+
+// deferredWithError 步骤 with 中无法在运行时求值的输入（见 parser.RuntimeWithAllowed）；校验已拒绝此类工作流，此处防御未经校验的任务定义
+func deferredWithError(st parser.Step) error {
+	for _, k := range sortedKeys(st.With) {
+		if st.Deferred["with."+k] && !parser.RuntimeWithAllowed(st.Uses, k) {
+			return fmt.Errorf("with.%s references the steps context or hashFiles(), which are not available before the job starts", k)
+		}
+	}
+	return nil
+}
+
+// ApplyRuntimeExpr 为包含运行时求值表达式的 Job 注入 xc-expr：init 容器从 image 复制到共享卷，runner 容器经 XC_EXPR 引用
+func ApplyRuntimeExpr(spec *batchv1.Job, job parser.Job, image string) error {
+	if !jobNeedsRuntimeExpr(job) {
+		return nil
+	}
+	if strings.TrimSpace(image) == "" {
+		return fmt.Errorf("job has step expressions evaluated at runtime (steps context or hashFiles()), but runner.expr_image is not configured")
+	}
+	pod := &spec.Spec.Template.Spec
+	if len(pod.Containers) == 0 {
+		return nil
+	}
+	mount := corev1.VolumeMount{Name: exprVolume, MountPath: exprMount}
+	pod.Volumes = append(pod.Volumes, corev1.Volume{Name: exprVolume, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}})
+	pod.InitContainers = append([]corev1.Container{{
+		Name:         exprVolume,
+		Image:        image,
+		Command:      []string{"cp", "/app/" + exprBinName, exprMount + "/" + exprBinName},
+		VolumeMounts: []corev1.VolumeMount{mount},
+	}}, pod.InitContainers...)
+	runner := &pod.Containers[0]
+	runner.VolumeMounts = append(runner.VolumeMounts, mount)
+	runner.Env = append(runner.Env, corev1.EnvVar{Name: exprBinEnv, Value: exprMount + "/" + exprBinName})
+	return nil
+}
+
+// findExprBinary local 后端使用的 xc-expr：优先执行器可执行文件所在目录，其次 PATH；未找到时返回空串
+func findExprBinary() string {
+	if exe, err := os.Executable(); err == nil {
+		p := filepath.Join(filepath.Dir(exe), exprBinName)
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+			return p
+		}
+	}
+	if p, err := exec.LookPath(exprBinName); err == nil {
+		return p
+	}
+	return ""
+}
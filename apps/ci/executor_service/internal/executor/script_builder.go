@@ -2,50 +2,152 @@ package executor
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"xcoding/apps/ci/executor_service/expr"
 	act "xcoding/apps/ci/executor_service/internal/executor/actions"
-	"xcoding/apps/ci/executor_service/internal/parser"
+	"xcoding/apps/ci/executor_service/parser"
 )
 
 // BuildScript 生成在容器中执行的 Bash 脚本
 // 说明：
-// - 顶层启用 set -e；step 层按需覆盖
+// - 顶层启用 set -e；每个步骤在子 Shell 中执行，失败记录到 __xc_failed 而不立即退出
 // - 导出 Job 级非敏感环境变量
-// - 按步骤输出 __step_begin__/__step_end__/__step_exit__ 标记，便于日志解析
-func BuildScript(job parser.Job) string {
+// - 按步骤输出 __step_begin__/__step_end__/__step_exit__/__step_skip__ 标记，便于日志解析
+// - Action 的前置阶段在所有步骤之前、后置阶段与缓存保存段在所有步骤之后逆序执行（见 buildPreSteps/buildPostSteps）
+// - docker Action 由 Job Pod 中的步骤容器执行，脚本退出时通知其结束（见 dockerDoneTrap）
+// - 步骤 if 在生成脚本时按“此前无失败/此前有失败”两种情形求值，运行时依据 __xc_failed 选择分支
+// - 引用 steps 上下文的 if 与 run/env/with 由 Job 内的 xc-expr 在步骤执行前求值（见 runtime_expr.go）
+func BuildScript(job parser.Job, ectx *expr.Context) string {
 	var b strings.Builder
 	fmt.Fprintf(&b, "set -e\n")
+	fmt.Fprintf(&b, "__xc_failed=0\n__xc_exit=0\n")
 	//b.WriteString("mkdir -p /workspace\n")
 	//b.WriteString("cd /workspace\n")
 
 	// 统一：不在脚本中 export Job 级 env，均通过 K8s EnvVar 注入
+	if hasCacheSteps(job) {
+		fmt.Fprintf(&b, "export %s=$(mktemp -d)\n", cacheStateDir)
+	}
+	if hasActionHooks(job) {
+		fmt.Fprintf(&b, "export %s=$(mktemp -d)\n", actionStateDir)
+	}
+	runtime := jobNeedsRuntimeExpr(job)
+	if runtime {
+		b.WriteString(runtimeExprPreamble(ectx))
+	}
+	b.WriteString(dockerDoneTrap(job))
+	b.WriteString(buildPreSteps(job, ectx))
 
 	//  添加step
-	for _, st := range job.Steps {
-		fmt.Fprintf(&b, "echo %s %s\n", MarkerStepBegin, st.Name)
+	for i, st := range job.Steps {
+		name := shellQuote(st.Name)
+		skip := fmt.Sprintf("echo %s %s\n", MarkerStepSkip, name)
+		if runtime && st.ID != "" {
+			skip += fmt.Sprintf("__xc_record %s skipped\n", shellQuote(st.ID))
+		}
+		if expr.NeedsRuntime(st.If) {
+			run := fmt.Sprintf("echo %s %s\n%s", MarkerStepBegin, name, buildStepBody(i, st, job, ectx))
+			b.WriteString(runtimeCondition(st, run, skip))
+			continue
+		}
+		onSuccess, onFailure, cerr := stepConditions(st, ectx)
+		var body string
+		if cerr != nil {
+			body = wrapStepBody(st, fmt.Sprintf("echo %s >&2\nexit 1", shellQuote(cerr.Error())))
+		} else {
+			body = buildStepBody(i, st, job, ectx)
+		}
+		run := fmt.Sprintf("echo %s %s\n%s", MarkerStepBegin, name, body)
+		switch {
+		case onSuccess && onFailure:
+			b.WriteString(run)
+		case onSuccess:
+			fmt.Fprintf(&b, "if [ \"$__xc_failed\" = \"0\" ]; then\n%selse\n%sfi\n", run, skip)
+		case onFailure:
+			fmt.Fprintf(&b, "if [ \"$__xc_failed\" != \"0\" ]; then\n%selse\n%sfi\n", run, skip)
+		default:
+			b.WriteString(skip)
+		}
+	}
+	b.WriteString(buildPostSteps(job, ectx))
+	fmt.Fprintf(&b, "exit $__xc_exit\n")
+	return b.String()
+}
 
-		if strings.TrimSpace(st.Uses) != "" {
-			frag, err := act.BuildUsesScript(st, job)
+// buildStepBody 生成单个步骤的执行片段（内置检出/制品/缓存动作、uses 或 run）；idx 为步骤序号
+func buildStepBody(idx int, st parser.Step, job parser.Job, ectx *expr.Context) string {
+	if err := deferredWithError(st); err != nil {
+		return wrapStepBody(st, fmt.Sprintf("echo %s >&2\nexit 1", shellQuote(st.Uses+": "+err.Error())))
+	}
+	if parser.IsCheckout(st.Uses) {
+		frag, err := buildCheckoutScript(idx, st)
+		if err != nil {
+			frag = fmt.Sprintf("echo %s >&2\nexit 1", shellQuote(parser.CheckoutAction+": "+err.Error()))
+		}
+		return wrapStepBody(st, frag)
+	}
+	if parser.IsCache(st.Uses) {
+		frag, err := buildCacheRestoreScript(idx, st)
+		if err != nil {
+			frag = fmt.Sprintf("echo %s >&2\nexit 1", shellQuote(parser.CacheAction+": "+err.Error()))
+		}
+		return wrapStepBody(st, frag)
+	}
+	if parser.IsUploadArtifact(st.Uses) || parser.IsDownloadArtifact(st.Uses) {
+		build := buildUploadArtifactScript
+		if parser.IsDownloadArtifact(st.Uses) {
+			build = buildDownloadArtifactScript
+		}
+		frag, err := build(st)
+		if err != nil {
+			frag = fmt.Sprintf("echo %s >&2\nexit 1", shellQuote(st.Uses+": "+err.Error()))
+		}
+		return wrapStepBody(st, frag)
+	}
+	if strings.TrimSpace(st.Uses) != "" {
+		meta, docker := isDockerAction(st, job)
+		var frag string
+		var err error
+		if docker {
+			frag = buildDockerPhaseScript(idx, act.HookMain)
+		} else {
+			frag, err = act.BuildUsesScript(st, job, ectx, actionDir(idx, meta))
+		}
+		if err != nil {
+			frag = fmt.Sprintf("echo \"action error: %s\"\nexit 1", strings.ReplaceAll(err.Error(), "\"", "\\\""))
+		} else if meta != nil && meta.HasPost() {
+			frag = actionRanScript(idx) + frag
+		}
+		return wrapStepBody(st, frag)
+	}
+	if strings.TrimSpace(st.Run) != "" {
+		return BuildStepCommand(st)
+	}
+	return wrapStepBody(st, ":")
+}
 
-			if err != nil {
-				fmt.Fprintf(&b, "echo \"action error: %s\"\nexit 1\n", strings.ReplaceAll(err.Error(), "\"", "\\\""))
-			} else {
-				fmt.Fprintf(&b, "%s\n", frag)
-			}
-		} else if strings.TrimSpace(st.Run) != "" {
-			var inline []string
-			for k, v := range st.Env {
-				if strings.HasPrefix(strings.TrimSpace(v), "secret://") {
-					continue
-				}
-				inline = append(inline, k+"="+v)
-			}
-			if len(inline) > 0 {
-				fmt.Fprintf(&b, "%s ", strings.Join(inline, " "))
-			}
-			fmt.Fprintf(&b, "%s\n", BuildStepCommand(st))
-		}
-		fmt.Fprintf(&b, "echo %s %s\n", MarkerStepEnd, st.Name)
+// stepConditions 计算步骤在“此前步骤均成功”与“此前存在失败”两种情形下是否执行
+// 条件无法解析或求值出错时返回 error，由调用方将该步骤判定为失败
+func stepConditions(st parser.Step, ectx *expr.Context) (onSuccess, onFailure bool, err error) {
+	if strings.TrimSpace(st.If) == "" {
+		return true, false, nil
 	}
-	return b.String()
+	if onSuccess, err = expr.EvaluateCondition(st.If, ectx.With(false, false)); err != nil {
+		return true, false, err
+	}
+	if onFailure, err = expr.EvaluateCondition(st.If, ectx.With(true, false)); err != nil {
+		return true, false, err
+	}
+	return onSuccess, onFailure, nil
+}
+
+// sortedKeys 返回 map 的有序键，保证生成脚本稳定
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
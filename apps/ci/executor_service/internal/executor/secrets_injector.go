@@ -3,16 +3,16 @@ package executor
 import (
 	corev1 "k8s.io/api/core/v1"
 	"strings"
-	"xcoding/apps/ci/executor_service/internal/parser"
+	"xcoding/apps/ci/executor_service/parser"
 )
 
 // BuildEnvVars 将 env 映射转换为 K8s EnvVar，支持 secret:// 注入
-// 说明：secret://<name>/<key> 转换为 SecretKeyRef；其它值直接作为明文
-func BuildEnvVars(env map[string]string) []corev1.EnvVar {
+// 说明：secret://<name>/<key> 转换为 SecretKeyRef；literal 标记的键（触发方提供的值）与其它值直接作为明文
+func BuildEnvVars(env map[string]string, literal map[string]bool) []corev1.EnvVar {
 	out := make([]corev1.EnvVar, 0, len(env))
 	for k, v := range env {
 		s := strings.TrimSpace(v)
-		if strings.HasPrefix(s, "secret://") {
+		if !literal[k] && isSecretRef(s) {
 			p := strings.TrimPrefix(s, "secret://")
 			parts := strings.SplitN(p, "/", 2)
 			if len(parts) == 2 {
@@ -28,6 +28,11 @@ func BuildEnvVars(env map[string]string) []corev1.EnvVar {
 	return out
 }
 
+// isSecretRef 值是否为 secret:// 引用
+func isSecretRef(v string) bool {
+	return strings.HasPrefix(strings.TrimSpace(v), "secret://")
+}
+
 // CollectSecretEnvVars 收集包含 secret:// 前缀的环境变量
 func CollectSecretEnvVars(env map[string]string) map[string]string {
 	out := map[string]string{}
@@ -39,18 +44,52 @@ func CollectSecretEnvVars(env map[string]string) map[string]string {
 	return out
 }
 
-// BuildEnvVarsForJob 合并 Job 与 Step 的敏感环境变量并转换为 EnvVar
+// deprecatedControlEnv 已废弃的执行器控制变量：仍由执行器读取（timeout-minutes、continue-on-error 等字段未配置时回退），不注入容器
+var deprecatedControlEnv = map[string]bool{
+	"XC_JOB_TIMEOUT_SECONDS":     true,
+	"XC_JOB_TTL_SECONDS":         true,
+	"XC_CONTINUE_ON_ERROR":       true,
+	"XC_RESOURCE_CPU_REQUEST":    true,
+	"XC_RESOURCE_MEMORY_REQUEST": true,
+	"XC_RESOURCE_CPU_LIMIT":      true,
+	"XC_RESOURCE_MEMORY_LIMIT":   true,
+}
+
+// deprecatedEnvKeys 返回 Job 或其步骤中使用的已废弃控制变量（有序）
+func deprecatedEnvKeys(job parser.Job) []string {
+	var keys []string
+	for _, k := range sortedKeys(job.Env) {
+		if deprecatedControlEnv[k] {
+			keys = append(keys, k)
+		}
+	}
+	for _, st := range job.Steps {
+		if _, ok := st.Env["XC_CONTINUE_ON_ERROR"]; ok {
+			keys = append(keys, "steps."+st.Name+".env.XC_CONTINUE_ON_ERROR")
+		}
+	}
+	return keys
+}
+
+// BuildEnvVarsForJob 合并 Job 与 Step 的敏感环境变量并转换为 EnvVar（不含执行器控制变量）
 func BuildEnvVarsForJob(job parser.Job) []corev1.EnvVar {
 	merged := map[string]string{}
 	for k, v := range job.Env {
-		merged[k] = v
+		if !deprecatedControlEnv[k] {
+			merged[k] = v
+		}
+	}
+	literal := map[string]bool{}
+	for k := range job.LiteralEnv {
+		literal[k] = true
 	}
 	for _, st := range job.Steps {
 		for k, v := range st.Env {
-			if strings.HasPrefix(strings.TrimSpace(v), "secret://") {
+			if isSecretRef(v) {
 				merged[k] = v
+				delete(literal, k)
 			}
 		}
 	}
-	return BuildEnvVars(merged)
+	return BuildEnvVars(merged, literal)
 }
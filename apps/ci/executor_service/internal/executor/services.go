@@ -0,0 +1,174 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// serviceContainerPrefix 服务容器名前缀，与 runner 容器区分
+const serviceContainerPrefix = "svc-"
+
+// serviceLogGrace Job 结束后等待服务容器日志读完的上限（sidecar 在 runner 退出后才被终止）
+var serviceLogGrace = 10 * time.Second
+
+// ServiceContainerName 服务容器名：svc-<服务名>
+func ServiceContainerName(service string) string { return serviceContainerPrefix + service }
+
+// ServiceLogStreamer 支持服务容器（services）的运行后端
+type ServiceLogStreamer interface {
+	// Services 返回 Job 的服务名（WaitReady/Attach 之后可用）
+	Services(name string) []string
+	// StreamServiceLogs 跟随服务容器日志直至结束；since 非零值时只回调其后产生的日志
+	StreamServiceLogs(ctx context.Context, name, service string, since time.Time, onLine func(line string)) error
+}
+
+// BuildServiceContainers 将 services 转换为原生 sidecar（restartPolicy: Always 的 init 容器，需 K8s 1.29+），按服务名排序
+// 说明：
+//   - 健康检查：配置 --health-cmd 时以 /bin/sh -c 执行，否则对首个端口做 TCP 探测；未声明端口时不设探针
+//   - 探针作为 startupProbe：kubelet 在 sidecar 启动探针通过后才启动后续容器，即 runner 在全部服务就绪后才执行步骤；
+//     同一探针作为 readinessProbe 反映运行期间的健康状态
+func BuildServiceContainers(services map[string]parser.Service) ([]corev1.Container, error) {
+	always := corev1.ContainerRestartPolicyAlways
+	out := make([]corev1.Container, 0, len(services))
+	for _, name := range parser.ServiceNames(services) {
+		svc := services[name]
+		ports, err := svc.ContainerPorts()
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		health, err := parser.ParseServiceOptions(svc.Options)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		c := corev1.Container{
+			Name:          ServiceContainerName(name),
+			Image:         svc.Image,
+			RestartPolicy: &always,
+		}
+		for _, k := range sortedKeys(svc.Env) {
+			c.Env = append(c.Env, corev1.EnvVar{Name: k, Value: svc.Env[k]})
+		}
+		for _, p := range ports {
+			c.Ports = append(c.Ports, corev1.ContainerPort{ContainerPort: p})
+		}
+		if probe := serviceProbe(health, ports); probe != nil {
+			c.StartupProbe = probe
+			ready := *probe
+			ready.InitialDelaySeconds = 0
+			c.ReadinessProbe = &ready
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// serviceProbe 健康检查探针：命令检查按 docker 语义换算（间隔、超时、重试次数、启动宽限期）；TCP 探测每 2 秒一次，至多等待 2 分钟
+func serviceProbe(h parser.ServiceHealth, ports []int32) *corev1.Probe {
+	if h.Cmd != "" {
+		return &corev1.Probe{
+			ProbeHandler:        corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"/bin/sh", "-c", h.Cmd}}},
+			InitialDelaySeconds: probeSeconds(h.StartPeriod, 0),
+			PeriodSeconds:       probeSeconds(h.Interval, 1),
+			TimeoutSeconds:      probeSeconds(h.Timeout, 1),
+			FailureThreshold:    int32(h.Retries),
+		}
+	}
+	if len(ports) == 0 {
+		return nil
+	}
+	return &corev1.Probe{
+		ProbeHandler:     corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(ports[0])}},
+		PeriodSeconds:    2,
+		TimeoutSeconds:   1,
+		FailureThreshold: 60,
+	}
+}
+
+// probeSeconds 向上取整为秒，不小于 min
+func probeSeconds(d time.Duration, min int32) int32 {
+	s := int32(math.Ceil(d.Seconds()))
+	if s < min {
+		return min
+	}
+	return s
+}
+
+// podServices Pod 中服务容器对应的服务名
+func podServices(p *corev1.Pod) []string {
+	var names []string
+	for _, c := range p.Spec.InitContainers {
+		if strings.HasPrefix(c.Name, serviceContainerPrefix) {
+			names = append(names, strings.TrimPrefix(c.Name, serviceContainerPrefix))
+		}
+	}
+	return names
+}
+
+// servicesNotStarted 尚未通过启动探针的服务名
+func servicesNotStarted(p *corev1.Pod) []string {
+	var names []string
+	for _, cs := range p.Status.InitContainerStatuses {
+		if strings.HasPrefix(cs.Name, serviceContainerPrefix) && (cs.Started == nil || !*cs.Started) {
+			names = append(names, strings.TrimPrefix(cs.Name, serviceContainerPrefix))
+		}
+	}
+	return names
+}
+
+// captureServiceLogs 为 Job 的每个服务容器启动日志采集，写入 BuildServiceLogChunk（每个服务一条日志流）
+// 返回的 stop 在 Job 结束后调用：等待日志读完（至多 serviceLogGrace）后停止采集
+func (s *Scheduler) captureServiceLogs(ctx context.Context, buildID uint64, jobName, name string) (stop func()) {
+	st, ok := s.Runner.(ServiceLogStreamer)
+	if !ok {
+		return func() {}
+	}
+	services := st.Services(name)
+	if len(services) == 0 {
+		return func() {}
+	}
+	sctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for _, svc := range services {
+		since := lastServiceLogTime(s.DB, buildID, jobName, svc)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := st.StreamServiceLogs(sctx, name, svc, since, func(line string) {
+				_ = s.DB.Create(&models.BuildServiceLogChunk{BuildID: buildID, JobName: jobName, Service: svc, Content: line, CreatedAt: time.Now()}).Error
+			})
+			if err != nil && sctx.Err() == nil {
+				logrus.WithFields(logrus.Fields{"build_id": buildID, "job": jobName, "service": svc}).WithError(err).Warn("service logs stream")
+			}
+		}()
+	}
+	return func() {
+		done := make(chan struct{})
+		go func() { wg.Wait(); close(done) }()
+		select {
+		case <-done:
+		case <-time.After(serviceLogGrace):
+		}
+		cancel()
+		<-done
+	}
+}
+
+// lastServiceLogTime 服务最后落库日志的时间，恢复构建时从此处继续读取
+func lastServiceLogTime(db *gorm.DB, buildID uint64, jobName, service string) time.Time {
+	var c models.BuildServiceLogChunk
+	if err := db.Where("build_id = ? AND job_name = ? AND service = ?", buildID, jobName, service).Order("id desc").First(&c).Error; err != nil {
+		return time.Time{}
+	}
+	return c.CreatedAt
+}
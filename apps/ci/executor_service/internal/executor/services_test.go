@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"testing"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/parser"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestBuildJobSpecServices(t *testing.T) {
+	wf, err := parser.ValidateWorkflowYAML(`jobs:
+  test:
+    services:
+      redis:
+        image: redis:7
+        ports: [6379]
+      postgres:
+        image: postgres:16
+        env: {POSTGRES_PASSWORD: pw}
+        ports: ["5432:5432"]
+        options: --health-cmd "pg_isready -U postgres" --health-interval 2s --health-retries=10 --health-start-period 1500ms
+    steps:
+      - run: psql -h localhost -U postgres -c 'select 1'
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := BuildJobSpecWithExtensions("ci", 1, "build-1-test", wf.Jobs["test"], expr.NewContext(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := spec.Spec.Template.Spec
+	if len(pod.Containers) != 1 || pod.Containers[0].Name != "runner" {
+		t.Fatalf("containers = %+v, want runner only", pod.Containers)
+	}
+	if len(pod.InitContainers) != 2 {
+		t.Fatalf("init containers = %d, want 2 sidecars", len(pod.InitContainers))
+	}
+	pg, redis := pod.InitContainers[0], pod.InitContainers[1]
+	if pg.Name != "svc-postgres" || redis.Name != "svc-redis" {
+		t.Errorf("sidecar names = %s, %s", pg.Name, redis.Name)
+	}
+	for _, c := range pod.InitContainers {
+		if c.RestartPolicy == nil || *c.RestartPolicy != corev1.ContainerRestartPolicyAlways {
+			t.Errorf("%s: restartPolicy = %v, want Always (native sidecar)", c.Name, c.RestartPolicy)
+		}
+	}
+	p := pg.StartupProbe
+	if p == nil || p.Exec == nil || p.Exec.Command[2] != "pg_isready -U postgres" {
+		t.Fatalf("postgres startup probe = %+v, want health command", p)
+	}
+	if p.PeriodSeconds != 2 || p.FailureThreshold != 10 || p.InitialDelaySeconds != 2 || p.TimeoutSeconds != 5 {
+		t.Errorf("postgres probe timing = period %d, retries %d, delay %d, timeout %d", p.PeriodSeconds, p.FailureThreshold, p.InitialDelaySeconds, p.TimeoutSeconds)
+	}
+	if len(pg.Env) != 1 || pg.Env[0].Value != "pw" || pg.Ports[0].ContainerPort != 5432 {
+		t.Errorf("postgres env/ports = %+v, %+v", pg.Env, pg.Ports)
+	}
+	if p := redis.StartupProbe; p == nil || p.TCPSocket == nil || p.TCPSocket.Port.IntValue() != 6379 {
+		t.Errorf("redis startup probe = %+v, want TCP 6379", p)
+	}
+	if redis.ReadinessProbe == nil {
+		t.Error("redis readiness probe missing")
+	}
+}
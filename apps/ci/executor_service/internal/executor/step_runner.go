@@ -3,31 +3,93 @@ package executor
 import (
 	"fmt"
 	"strings"
-	"xcoding/apps/ci/executor_service/internal/parser"
+	act "xcoding/apps/ci/executor_service/internal/executor/actions"
+	"xcoding/apps/ci/executor_service/parser"
 )
 
 // BuildStepCommand 将单步命令包装为捕获退出码并输出标记
-// 约定：支持 continue_on_error（通过 step.Env["XC_CONTINUE_ON_ERROR"] == "true"）
 func BuildStepCommand(st parser.Step) string {
 	cmd := strings.TrimSpace(st.Run)
 	if cmd == "" {
 		return ""
 	}
-	// 捕获退出码并输出特定标记，供日志处理器识别
-	// 退出码输出格式：__step_exit__ <name> <code>
 	var b strings.Builder
-	// 若允许继续错误，需要暂时关闭 set -e，以便命令失败不立即终止脚本
-	if strings.EqualFold(strings.TrimSpace(st.Env["XC_CONTINUE_ON_ERROR"]), "true") {
-		fmt.Fprintf(&b, "set +e\n")
-	}
-	fmt.Fprintf(&b, "%s\n", cmd)
-	fmt.Fprintf(&b, "code=$?; echo %s %s $code\n", MarkerStepExit, st.Name)
-	// 若允许继续，则不 set -e 失败；脚本顶层已有 set -e
-	if strings.EqualFold(strings.TrimSpace(st.Env["XC_CONTINUE_ON_ERROR"]), "true") {
-		// 恢复 set -e，后续步骤仍旧严格
-		fmt.Fprintf(&b, "set -e\n")
+	// Step 级非敏感 env 在子 Shell 内导出，仅对当前步骤生效（执行器控制变量不导出）
+	// 引用 steps 上下文的 env 与 run 在运行时求值（见 runtime_expr.go），求值失败即步骤失败
+	for _, k := range sortedKeys(st.Env) {
+		v := st.Env[k]
+		if deprecatedControlEnv[k] {
+			continue
+		}
+		if st.Deferred["env."+k] {
+			fmt.Fprintf(&b, "%s=%s\nexport %s\n", k, act.RuntimeRender(v), k)
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(v), "secret://") {
+			continue
+		}
+		fmt.Fprintf(&b, "export %s=%s\n", k, shellQuote(v))
+	}
+	if st.Deferred["run"] {
+		b.WriteString(runtimeRunScript(cmd))
+	} else {
+		fmt.Fprintf(&b, "%s\n", cmd)
+	}
+	return wrapStepBody(st, b.String())
+}
+
+// wrapStepBody 在子 Shell 中执行步骤主体并记录结果
+// 说明：
+// - 子 Shell 内启用 set -e，任一命令失败即结束该步骤
+// - 退出码输出格式：__step_exit__ <name> <code>
+// - 每个步骤分配独立的 $GITHUB_OUTPUT 文件，步骤结束后以 base64 编码经 __step_output__ 标记回传
+// - 失败时记录 __xc_failed/__xc_exit 而非立即退出脚本，以便后续 if: failure()/always() 步骤仍可执行
+// - timeout-minutes：主体改由 timeout 命令以 bash -c 执行，超时先发 TERM、10 秒后 KILL，退出码 124
+// - retry：失败后按 backoff*2^(n-1) 秒退避重试，每次执行前清空 $GITHUB_OUTPUT，仅最后一次的结果生效
+// - continue-on-error 时失败不计入 __xc_failed，步骤仍输出结束标记
+func wrapStepBody(st parser.Step, body string) string {
+	var b strings.Builder
+	name := shellQuote(st.Name)
+	body = strings.TrimRight(body, "\n")
+	run := fmt.Sprintf("(\nset -e\n%s\n)", body)
+	timeout := parser.TimeoutSeconds(st.TimeoutMinutes)
+	if timeout > 0 {
+		run = fmt.Sprintf("timeout -s TERM -k 10 %d bash -c %s", timeout, shellQuote("set -e\n"+body))
+	}
+	run = fmt.Sprintf("set +e\n%s\ncode=$?\nset -e\n", run)
+	if timeout > 0 {
+		run += fmt.Sprintf("if [ $code -eq 124 ]; then echo %s >&2; fi\n", shellQuote(fmt.Sprintf("Step timed out after %g minute(s)", st.TimeoutMinutes)))
+	}
+	fmt.Fprintf(&b, "__xc_out=$(mktemp)\nexport GITHUB_OUTPUT=\"$__xc_out\"\n")
+	if st.Retry != nil && st.Retry.Max > 0 {
+		fmt.Fprintf(&b, "__xc_try=0\nwhile :; do\n: > \"$__xc_out\"\n%s", run)
+		fmt.Fprintf(&b, "if [ $code -eq 0 ] || [ $__xc_try -ge %d ]; then break; fi\n", st.Retry.Max)
+		fmt.Fprintf(&b, "__xc_try=$((__xc_try + 1))\n__xc_wait=$((%d << (__xc_try - 1)))\n", st.Retry.Backoff)
+		fmt.Fprintf(&b, "echo \"Step failed with exit code $code, retry $__xc_try/%d in ${__xc_wait}s\"\nsleep $__xc_wait\ndone\n", st.Retry.Max)
 	} else {
-		fmt.Fprintf(&b, "if [ $code -ne 0 ]; then exit $code; fi\n")
+		b.WriteString(run)
+	}
+	fmt.Fprintf(&b, "if [ -s \"$__xc_out\" ]; then echo %s %s \"$(base64 < \"$__xc_out\" | tr -d '\\n')\"; fi\n", MarkerStepOutput, name)
+	b.WriteString(recordStepScript(st))
+	b.WriteString("rm -f \"$__xc_out\"\n")
+	fmt.Fprintf(&b, "echo %s %s $code\n", MarkerStepExit, name)
+	if stepContinueOnError(st) {
+		fmt.Fprintf(&b, "echo %s %s\n", MarkerStepEnd, name)
+	} else {
+		fmt.Fprintf(&b, "if [ $code -ne 0 ]; then __xc_failed=1; __xc_exit=$code; else echo %s %s; fi\n", MarkerStepEnd, name)
 	}
 	return b.String()
 }
+
+// stepContinueOnError 步骤的 continue-on-error；未配置时回退到已废弃的 env.XC_CONTINUE_ON_ERROR
+func stepContinueOnError(st parser.Step) bool {
+	if st.ContinueOnError != "" {
+		return st.ContinueOnError.Enabled()
+	}
+	return strings.EqualFold(strings.TrimSpace(st.Env["XC_CONTINUE_ON_ERROR"]), "true")
+}
+
+// shellQuote 对任意字符串进行 Shell 单引号安全包裹
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "'\\''") + "'"
+}
@@ -0,0 +1,49 @@
+package executor
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"xcoding/apps/ci/executor_service/expr"
+	"xcoding/apps/ci/executor_service/parser"
+)
+
+func TestBuildScriptStepControls(t *testing.T) {
+	if _, err := exec.LookPath("timeout"); err != nil {
+		t.Skip("timeout command not available")
+	}
+	job := parser.Job{Steps: []parser.Step{
+		{Name: "flaky", Run: `n=$(cat count 2>/dev/null || echo 0); echo $((n + 1)) > count; [ "$n" -ge 2 ]`, Retry: &parser.Retry{Max: 3}},
+		{Name: "slow", Run: "sleep 30", TimeoutMinutes: 0.01, ContinueOnError: "true"},
+		{Name: "legacy", Run: "echo legacy=$XC_CONTINUE_ON_ERROR; exit 3", Env: map[string]string{"XC_CONTINUE_ON_ERROR": "true"}},
+	}}
+	cmd := exec.Command("/bin/bash", "-c", BuildScript(job, expr.NewContext()))
+	cmd.Dir = t.TempDir()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+	for _, want := range []string{
+		"retry 2/3 in 0s",
+		MarkerStepExit + " flaky 0",
+		"Step timed out after 0.01 minute(s)",
+		MarkerStepExit + " slow 124",
+		MarkerStepEnd + " slow",
+		MarkerStepExit + " legacy 3",
+		MarkerStepEnd + " legacy",
+		"legacy=\n",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(string(out), "retry 3/3") {
+		t.Errorf("flaky step retried after succeeding:\n%s", out)
+	}
+
+	job = parser.Job{Steps: []parser.Step{{Name: "fail", Run: "exit 2", Retry: &parser.Retry{Max: 1}}}}
+	err = exec.Command("/bin/bash", "-c", BuildScript(job, expr.NewContext())).Run()
+	if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 2 {
+		t.Errorf("exhausted retries: err = %v, want exit code 2", err)
+	}
+}
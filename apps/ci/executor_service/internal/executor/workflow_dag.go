@@ -1,27 +1,90 @@
 package executor
 
 import (
-	"xcoding/apps/ci/executor_service/internal/parser"
+	"sort"
+	"xcoding/apps/ci/executor_service/parser"
 
 	"github.com/sirupsen/logrus"
 )
 
 type DAG struct {
-	Jobs       map[string]parser.Job // 任务信息
-	Needs      map[string][]string   // 前置依赖
-	Dependents map[string][]string   // 后置依赖
+	Jobs       map[string]parser.Job     // 任务信息（矩阵任务已展开为子任务）
+	Needs      map[string][]string       // 前置依赖（矩阵父任务已替换为其全部子任务）
+	Dependents map[string][]string       // 后置依赖
+	Groups     map[string][]string       // 工作流中的 Job 名 → 展开后的任务（非矩阵任务映射到自身）
+	Parent     map[string]string         // 矩阵子任务 → 矩阵父任务
+	Matrix     map[string]map[string]any // 矩阵子任务 → 组合取值
 }
 
 // BuildDAG 根据工作流构造简单 DAG 结构（用于并发调度）
+// 说明：带 strategy.matrix 的 Job 展开为多个子任务，每个子任务独立运行；
+// 依赖矩阵父任务的 needs 等待其全部子任务结束
 func BuildDAG(wf *parser.Workflow) *DAG {
-	d := &DAG{Jobs: map[string]parser.Job{}, Needs: map[string][]string{}, Dependents: map[string][]string{}}
+	d := &DAG{
+		Jobs:       map[string]parser.Job{},
+		Needs:      map[string][]string{},
+		Dependents: map[string][]string{},
+		Groups:     map[string][]string{},
+		Parent:     map[string]string{},
+		Matrix:     map[string]map[string]any{},
+	}
 	for name, j := range wf.Jobs {
-		d.Jobs[name] = j
-		d.Needs[name] = append([]string{}, j.Needs...)
+		var combos []map[string]any
+		if j.Strategy != nil {
+			combos = expandMatrix(j.Strategy.Matrix)
+			if len(combos) == 0 {
+				logrus.Warnf("job %s: matrix has no combinations, run as a single job", name)
+			}
+		}
+		if len(combos) == 0 {
+			d.Jobs[name] = j
+			d.Groups[name] = []string{name}
+			continue
+		}
+		for _, c := range combos {
+			child := matrixJobName(name, j.Strategy.Matrix.Keys, c)
+			d.Jobs[child] = j
+			d.Groups[name] = append(d.Groups[name], child)
+			d.Parent[child] = name
+			d.Matrix[child] = c
+		}
+	}
+	for name, j := range d.Jobs {
+		needs := []string{}
 		for _, n := range j.Needs {
+			if members, ok := d.Groups[n]; ok {
+				needs = append(needs, members...)
+			} else {
+				needs = append(needs, n)
+			}
+		}
+		d.Needs[name] = needs
+		for _, n := range needs {
 			d.Dependents[n] = append(d.Dependents[n], name)
 		}
 	}
+	// 下游按名称排序，保证调度顺序稳定
+	for _, deps := range d.Dependents {
+		sort.Strings(deps)
+	}
 	logrus.Infof("build dag: %v", d)
 	return d
 }
+
+// Names 返回按名称排序的全部任务名，保证落库顺序稳定
+func (d *DAG) Names() []string {
+	names := make([]string, 0, len(d.Jobs))
+	for name := range d.Jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Strategy 返回任务所属矩阵的策略；非矩阵任务返回 nil
+func (d *DAG) Strategy(name string) *parser.Strategy {
+	if _, ok := d.Parent[name]; !ok {
+		return nil
+	}
+	return d.Jobs[name].Strategy
+}
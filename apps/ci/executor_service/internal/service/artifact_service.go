@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+
+	"xcoding/apps/ci/executor_service/models"
+	civ1 "xcoding/gen/go/ci/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ListBuildArtifacts 列出构建上传的制品（按上传时间）
+func (s *ExecutorService) ListBuildArtifacts(ctx context.Context, req *civ1.ListBuildArtifactsRequest) (*civ1.ListBuildArtifactsResponse, error) {
+	var rows []models.BuildArtifact
+	if err := s.db.Where("build_id = ?", req.GetBuildId()).Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, status.Errorf(codes.Internal, "list artifacts: %v", err)
+	}
+	out := make([]*civ1.BuildArtifact, 0, len(rows))
+	for _, a := range rows {
+		out = append(out, &civ1.BuildArtifact{
+			Id:        a.ID,
+			BuildId:   a.BuildID,
+			JobName:   a.JobName,
+			Name:      a.Name,
+			Size:      a.Size,
+			Sha256:    a.SHA256,
+			CreatedAt: timestamppb.New(a.CreatedAt),
+		})
+	}
+	return &civ1.ListBuildArtifactsResponse{Data: out}, nil
+}
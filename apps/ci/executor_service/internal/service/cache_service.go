@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+
+	"xcoding/apps/ci/executor_service/internal/cache"
+	civ1 "xcoding/gen/go/ci/v1"
+	projectv1 "xcoding/gen/go/project/v1"
+	"xcoding/pkg/auth"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SetCaches 注入缓存管理器；未配置制品存储时为空，缓存接口返回 FailedPrecondition
+func (s *ExecutorService) SetCaches(m *cache.Manager) { s.caches = m }
+
+// SetProjectClient 注入项目服务客户端；未配置时仅超级管理员可管理缓存
+func (s *ExecutorService) SetProjectClient(c projectv1.ProjectServiceClient) { s.projects = c }
+
+// ensureOwnerOrAdmin 校验调用者为项目 owner/admin（超级管理员放行）；未指定项目时仅超级管理员可操作
+func (s *ExecutorService) ensureOwnerOrAdmin(ctx context.Context, projectID uint64) error {
+	if auth.IsUserRoleSuperAdmin(ctx) {
+		return nil
+	}
+	if projectID == 0 {
+		return auth.MustSuperAdmin(ctx)
+	}
+	actorID, err := auth.GetUserIDFromCtx(ctx)
+	if err != nil {
+		return err
+	}
+	if s.projects == nil {
+		return status.Errorf(codes.PermissionDenied, "project service not configured; only super admins allowed")
+	}
+	resp, err := s.projects.GetProject(ctx, &projectv1.GetProjectRequest{ProjectId: projectID})
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to get project: %v", err)
+	}
+	p := resp.GetProject()
+	if p == nil {
+		return status.Errorf(codes.NotFound, "project not found")
+	}
+	if p.OwnerId == actorID {
+		return nil
+	}
+	members, err := s.projects.ListProjectMembers(ctx, &projectv1.ListProjectMembersRequest{ProjectId: projectID})
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to list project members: %v", err)
+	}
+	for _, m := range members.GetData() {
+		if m.GetUserId() == actorID {
+			role := m.GetRole()
+			if role == projectv1.ProjectMemberRole_PROJECT_MEMBER_ROLE_OWNER || role == projectv1.ProjectMemberRole_PROJECT_MEMBER_ROLE_ADMIN {
+				return nil
+			}
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "only owner or admin can perform this action")
+}
+
+// ListCaches 列出项目的缓存条目（最近使用的在前），并返回项目缓存总量与配额；仅项目 owner/admin 与超级管理员可调用
+func (s *ExecutorService) ListCaches(ctx context.Context, req *civ1.ListCachesRequest) (*civ1.ListCachesResponse, error) {
+	if err := s.ensureOwnerOrAdmin(ctx, req.GetProjectId()); err != nil {
+		return nil, err
+	}
+	if s.caches == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "cache storage not configured")
+	}
+	entries, total, err := s.caches.List(ctx, cache.Filter{ProjectID: req.GetProjectId(), PipelineID: req.GetPipelineId(), Branch: req.GetBranch(), KeyPrefix: req.GetKeyPrefix()})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list caches: %v", err)
+	}
+	out := make([]*civ1.CacheEntry, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, &civ1.CacheEntry{
+			Id:         e.ID,
+			ProjectId:  e.ProjectID,
+			PipelineId: e.PipelineID,
+			Branch:     e.Branch,
+			Key:        e.Key,
+			Size:       e.Size,
+			BuildId:    e.BuildID,
+			CreatedAt:  timestamppb.New(e.CreatedAt),
+			LastUsedAt: timestamppb.New(e.LastUsedAt),
+		})
+	}
+	return &civ1.ListCachesResponse{Data: out, TotalSize: total, Quota: s.caches.Quota()}, nil
+}
+
+// PurgeCaches 删除项目内符合条件的缓存条目及其归档；条件均为空时删除项目的全部缓存；仅项目 owner/admin 与超级管理员可调用
+func (s *ExecutorService) PurgeCaches(ctx context.Context, req *civ1.PurgeCachesRequest) (*civ1.PurgeCachesResponse, error) {
+	if err := s.ensureOwnerOrAdmin(ctx, req.GetProjectId()); err != nil {
+		return nil, err
+	}
+	if s.caches == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "cache storage not configured")
+	}
+	n, freed, err := s.caches.Purge(ctx, cache.Filter{ProjectID: req.GetProjectId(), PipelineID: req.GetPipelineId(), Branch: req.GetBranch(), KeyPrefix: req.GetKeyPrefix(), IDs: req.GetIds()})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "purge caches: deleted %d before error: %v", n, err)
+	}
+	return &civ1.PurgeCachesResponse{Deleted: int32(n), FreedSize: freed}, nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+
+	civ1 "xcoding/gen/go/ci/v1"
+	"xcoding/pkg/auth"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DeadLetterQueue 死信队列管理（由队列消费者实现）
+type DeadLetterQueue interface {
+	DeadLetters(ctx context.Context, limit int) ([]*civ1.DeadLetterBuild, int, error)
+	RequeueDeadLetters(ctx context.Context, buildIDs []uint64, all bool) ([]uint64, error)
+}
+
+// SetDeadLetterQueue 注入死信队列；未启用队列消费时为空，相关接口返回 FailedPrecondition
+func (s *ExecutorService) SetDeadLetterQueue(q DeadLetterQueue) { s.deadLetters = q }
+
+// ListDeadLetterBuilds 查看死信队列中的构建消息（仅超级管理员）
+func (s *ExecutorService) ListDeadLetterBuilds(ctx context.Context, req *civ1.ListDeadLetterBuildsRequest) (*civ1.ListDeadLetterBuildsResponse, error) {
+	if err := auth.MustSuperAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if s.deadLetters == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "build queue not configured")
+	}
+	limit := int(req.GetLimit())
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	items, total, err := s.deadLetters.DeadLetters(ctx, limit)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "list dead letters: %v", err)
+	}
+	return &civ1.ListDeadLetterBuildsResponse{Data: items, Total: int32(total)}, nil
+}
+
+// RequeueDeadLetterBuilds 将死信队列中的构建重新入队（仅超级管理员）
+func (s *ExecutorService) RequeueDeadLetterBuilds(ctx context.Context, req *civ1.RequeueDeadLetterBuildsRequest) (*civ1.RequeueDeadLetterBuildsResponse, error) {
+	if err := auth.MustSuperAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if s.deadLetters == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "build queue not configured")
+	}
+	if !req.GetAll() && len(req.GetBuildIds()) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "build_ids is required unless all is set")
+	}
+	ids, err := s.deadLetters.RequeueDeadLetters(ctx, req.GetBuildIds(), req.GetAll())
+	if err != nil {
+		// 部分构建可能已重新入队，返回错误以便重试剩余部分
+		return nil, status.Errorf(codes.Unavailable, "requeue dead letters (requeued %v): %v", ids, err)
+	}
+	return &civ1.RequeueDeadLetterBuildsResponse{BuildIds: ids}, nil
+}
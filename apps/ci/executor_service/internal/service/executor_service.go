@@ -4,16 +4,24 @@ import (
 	"context"
 	"fmt"
 	"time"
+	"xcoding/apps/ci/executor_service/internal/cache"
 	"xcoding/apps/ci/executor_service/internal/executor"
 	"xcoding/apps/ci/executor_service/models"
 	civ1 "xcoding/gen/go/ci/v1"
+	projectv1 "xcoding/gen/go/project/v1"
 
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"gorm.io/gorm"
 )
 
 type ExecutorService struct {
 	civ1.UnimplementedExecutorServiceServer
-	db *gorm.DB
+	db          *gorm.DB
+	deadLetters DeadLetterQueue
+	canceler    BuildCanceler
+	classes     *executor.RunnerClassRegistry
+	caches      *cache.Manager
+	projects    projectv1.ProjectServiceClient
 }
 
 // New 创建执行器服务实例
@@ -98,7 +106,40 @@ func (s *ExecutorService) GetBuildLogs(ctx context.Context, req *civ1.GetBuildLo
 	return &civ1.GetBuildLogsResponse{Lines: lines, NextOffset: next}, nil
 }
 
-// CancelBuild 取消未完成的构建：删除 K8s Job 并标记为 CANCELLED
+// GetBuildServiceLogs 按偏移分页读取服务容器日志，可按 Job 与服务名过滤
+func (s *ExecutorService) GetBuildServiceLogs(ctx context.Context, req *civ1.GetBuildServiceLogsRequest) (*civ1.GetBuildServiceLogsResponse, error) {
+	limit := int(req.GetLimit())
+	if limit <= 0 {
+		limit = 100
+	}
+	q := s.db.Model(&models.BuildServiceLogChunk{}).Where("build_id = ?", req.GetBuildId())
+	if v := req.GetJobName(); v != "" {
+		q = q.Where("job_name = ?", v)
+	}
+	if v := req.GetService(); v != "" {
+		q = q.Where("service = ?", v)
+	}
+	var rows []models.BuildServiceLogChunk
+	if err := q.Order("id ASC").Offset(int(req.GetOffset())).Limit(limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	lines := make([]*civ1.ServiceLogLine, 0, len(rows))
+	for _, r := range rows {
+		lines = append(lines, &civ1.ServiceLogLine{JobName: r.JobName, Service: r.Service, Content: r.Content, CreatedAt: timestamppb.New(r.CreatedAt)})
+	}
+	return &civ1.GetBuildServiceLogsResponse{Lines: lines, NextOffset: req.GetOffset() + uint64(len(rows))}, nil
+}
+
+// BuildCanceler 取消构建（由队列消费者实现，见 consumer.QueueConsumer.CancelBuild）
+type BuildCanceler interface {
+	CancelBuild(ctx context.Context, buildID uint64) (bool, error)
+}
+
+// SetBuildCanceler 注入构建取消实现；未启用队列消费时为空，取消时直接删除 K8s Job 并标记状态
+func (s *ExecutorService) SetBuildCanceler(c BuildCanceler) { s.canceler = c }
+
+// CancelBuild 取消未完成的构建
+// 启用队列消费时中止引擎（运行中的 Job 被终止，Job/Step 记为 cancelled，仍在队列中的构建出队时跳过）；否则删除 K8s Job 并标记为 CANCELLED
 func (s *ExecutorService) CancelBuild(ctx context.Context, req *civ1.CancelExecutorBuildRequest) (*civ1.CancelExecutorBuildResponse, error) {
 	var b models.Build
 	if err := s.db.First(&b, req.GetBuildId()).Error; err != nil {
@@ -107,6 +148,16 @@ func (s *ExecutorService) CancelBuild(ctx context.Context, req *civ1.CancelExecu
 	if civ1.BuildStatus(b.Status) == civ1.BuildStatus_BUILD_STATUS_SUCCEEDED || civ1.BuildStatus(b.Status) == civ1.BuildStatus_BUILD_STATUS_FAILED || civ1.BuildStatus(b.Status) == civ1.BuildStatus_BUILD_STATUS_CANCELLED {
 		return &civ1.CancelExecutorBuildResponse{Success: false, Build: b.ToProto()}, nil
 	}
+	if s.canceler != nil {
+		ok, err := s.canceler.CancelBuild(ctx, b.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.db.First(&b, b.ID).Error; err != nil {
+			return nil, err
+		}
+		return &civ1.CancelExecutorBuildResponse{Success: ok, Build: b.ToProto()}, nil
+	}
 	now := time.Now()
 	// 删除 K8s Job
 	// 注意：删除容忍 Job 不存在错误
@@ -115,7 +166,7 @@ func (s *ExecutorService) CancelBuild(ctx context.Context, req *civ1.CancelExecu
 	}
 	b.Status = int32(civ1.BuildStatus_BUILD_STATUS_CANCELLED)
 	b.FinishedAt = &now
-	if err := s.db.Save(&b).Error; err != nil {
+	if err := s.db.Model(&models.Build{}).Where("id = ?", b.ID).Updates(map[string]any{"status": b.Status, "finished_at": &now}).Error; err != nil {
 		return nil, err
 	}
 	return &civ1.CancelExecutorBuildResponse{Success: true, Build: b.ToProto()}, nil
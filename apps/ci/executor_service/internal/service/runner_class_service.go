@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"xcoding/apps/ci/executor_service/internal/executor"
+	civ1 "xcoding/gen/go/ci/v1"
+	"xcoding/pkg/auth"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"sigs.k8s.io/yaml"
+)
+
+// SetRunnerClasses 注入执行器类别注册表
+func (s *ExecutorService) SetRunnerClasses(r *executor.RunnerClassRegistry) { s.classes = r }
+
+// ListRunnerClasses 列出执行器类别（配置文件与管理接口定义的类别）
+func (s *ExecutorService) ListRunnerClasses(ctx context.Context, req *civ1.ListRunnerClassesRequest) (*civ1.ListRunnerClassesResponse, error) {
+	entries, err := s.classes.List()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list runner classes: %v", err)
+	}
+	out := make([]*civ1.RunnerClass, 0, len(entries))
+	for _, e := range entries {
+		pb, err := runnerClassToProto(e, s.classes.Default())
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "runner class %q: %v", e.Label, err)
+		}
+		out = append(out, pb)
+	}
+	return &civ1.ListRunnerClassesResponse{Data: out}, nil
+}
+
+// PutRunnerClass 新增或更新执行器类别（仅超级管理员）
+func (s *ExecutorService) PutRunnerClass(ctx context.Context, req *civ1.PutRunnerClassRequest) (*civ1.PutRunnerClassResponse, error) {
+	if err := auth.MustSuperAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if s.classes == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "runner classes not configured")
+	}
+	e, err := s.classes.Put(req.GetLabel(), req.GetSpec())
+	if err != nil {
+		return nil, runnerClassError(err)
+	}
+	pb, err := runnerClassToProto(e, s.classes.Default())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "runner class %q: %v", e.Label, err)
+	}
+	return &civ1.PutRunnerClassResponse{RunnerClass: pb}, nil
+}
+
+// DeleteRunnerClass 删除经管理接口定义的执行器类别（仅超级管理员）
+func (s *ExecutorService) DeleteRunnerClass(ctx context.Context, req *civ1.DeleteRunnerClassRequest) (*civ1.DeleteRunnerClassResponse, error) {
+	if err := auth.MustSuperAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if s.classes == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "runner classes not configured")
+	}
+	ok, err := s.classes.Delete(req.GetLabel())
+	if err != nil {
+		return nil, runnerClassError(err)
+	}
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "runner class %q not found", req.GetLabel())
+	}
+	return &civ1.DeleteRunnerClassResponse{Success: true}, nil
+}
+
+// runnerClassError 将注册表错误映射为 gRPC 状态码
+func runnerClassError(err error) error {
+	switch {
+	case errors.Is(err, executor.ErrRunnerClassReadOnly):
+		return status.Errorf(codes.FailedPrecondition, "%v", err)
+	case errors.Is(err, executor.ErrInvalidRunnerClass):
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	return status.Errorf(codes.Internal, "%v", err)
+}
+
+func runnerClassToProto(e executor.RunnerClassEntry, def string) (*civ1.RunnerClass, error) {
+	spec, err := yaml.Marshal(e.Class)
+	if err != nil {
+		return nil, err
+	}
+	pb := &civ1.RunnerClass{Label: e.Label, Spec: string(spec), Source: e.Source, IsDefault: e.Label == def}
+	if !e.UpdatedAt.IsZero() {
+		pb.UpdatedAt = timestamppb.New(e.UpdatedAt)
+	}
+	return pb, nil
+}
@@ -186,6 +186,7 @@ func (h *Handler) sendBuildStatus(conn *websocket.Conn, buildID uint64, afterID
 			"id":         j.ID,
 			"name":       j.Name,
 			"status":     j.Status,
+			"reason":     j.Reason,
 			"created_at": ct,
 			"step":       []map[string]any{},
 		}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// BuildArtifact 构建制品（xcoding/upload-artifact 上传的 tar.gz 归档），同一构建内按名称唯一
+type BuildArtifact struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement"`
+	BuildID    uint64 `gorm:"uniqueIndex:ux_build_artifact_name,priority:1"`
+	Name       string `gorm:"size:128;uniqueIndex:ux_build_artifact_name,priority:2"`
+	JobName    string `gorm:"size:255"` // 上传制品的 Job
+	Size       int64  // 归档字节数
+	SHA256     string `gorm:"size:64"`
+	StorageKey string `gorm:"size:512"` // 制品存储中的对象键
+	CreatedAt  time.Time
+}
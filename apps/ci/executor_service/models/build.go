@@ -11,6 +11,7 @@ import (
 type Build struct {
 	ID          uint64            `gorm:"primaryKey;autoIncrement" json:"id"`                              // 构建 ID, 加json是websocket
 	PipelineID  uint64            `gorm:"index;index:idx_build_pid_created,priority:1" json:"pipeline_id"` // 流水线 ID
+	ProjectID   uint64            `gorm:"index" json:"project_id"`                                         // 流水线所属项目 ID（检出代码时限定仓库范围）
 	Name        string            `gorm:"size:255;not null" json:"name"`                                   // 构建名称
 	Status      int32             `gorm:"not null" json:"status"`                                          // civ1.BuildStatus 枚举值
 	TriggeredBy string            `gorm:"size:128" json:"triggered_by"`                                    // 触发者
@@ -20,6 +21,11 @@ type Build struct {
 	CreatedAt   time.Time         `gorm:"autoCreateTime;index:idx_build_pid_created,priority:2" json:"created_at"`
 	StartedAt   *time.Time        `gorm:"" json:"started_at"`
 	FinishedAt  *time.Time        `gorm:"" json:"finished_at"`
+	// 构建租约：持有者（执行器副本标识）与最近一次续约时间，持有者超过租约有效期未续约时可被其它副本接管
+	LeaseOwner       string     `gorm:"size:128;index" json:"lease_owner"`
+	LeaseHeartbeatAt *time.Time `gorm:"" json:"lease_heartbeat_at"`
+	// 工作流 concurrency.group（出队时写入），同一项目内同组构建互斥
+	ConcurrencyGroup string `gorm:"size:255;index" json:"concurrency_group"`
 }
 
 func (b *Build) ToProto() *civ1.Build {
@@ -38,6 +44,7 @@ func (b *Build) ToProto() *civ1.Build {
 	pb := &civ1.Build{
 		Id:          b.ID,
 		PipelineId:  b.PipelineID,
+		ProjectId:   b.ProjectID,
 		Name:        b.Name,
 		Status:      civ1.BuildStatus(b.Status),
 		TriggeredBy: b.TriggeredBy,
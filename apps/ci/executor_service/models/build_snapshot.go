@@ -1,15 +1,21 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
 
 // BuildSnapshot 保存一次构建时的 Workflow YAML 快照
 // 设计意图：保证可重现性，不改动外部 proto，仅在内部持久化。
 type BuildSnapshot struct {
-	ID           uint64    `gorm:"primaryKey;autoIncrement"`
-	BuildID      uint64    `gorm:"uniqueIndex;not null"` // 每个构建仅一份快照
-	PipelineID   uint64    `gorm:"index;not null"`
-	Name         string    `gorm:"size:255;not null"` // 构建名称
-	WorkflowYAML string    `gorm:"type:text"`
-	YamlSHA256   string    `gorm:"size:64;index"`
-	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	ID           uint64            `gorm:"primaryKey;autoIncrement"`
+	BuildID      uint64            `gorm:"uniqueIndex;not null"` // 每个构建仅一份快照
+	PipelineID   uint64            `gorm:"index;not null"`
+	Name         string            `gorm:"size:255;not null"` // 构建名称
+	WorkflowYAML string            `gorm:"type:text"`
+	YamlSHA256   string            `gorm:"size:64;index"`
+	Variables    datatypes.JSONMap `gorm:"type:jsonb"` // 触发构建时提供的变量（与 Build.Variables 一致）
+	Inputs       datatypes.JSONMap `gorm:"type:jsonb"` // 手动触发时按 on.workflow_dispatch.inputs 解析后的输入（含默认值）
+	CreatedAt    time.Time         `gorm:"autoCreateTime"`
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// CacheEntry 依赖缓存条目（xcoding/cache 保存的归档），按 项目/流水线/分支 划分作用域，作用域内 key 唯一
+type CacheEntry struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement"`
+	ProjectID  uint64    `gorm:"uniqueIndex:ux_cache_scope_key,priority:1;index"`
+	PipelineID uint64    `gorm:"uniqueIndex:ux_cache_scope_key,priority:2"`
+	Branch     string    `gorm:"size:255;uniqueIndex:ux_cache_scope_key,priority:3"`
+	Key        string    `gorm:"size:512;uniqueIndex:ux_cache_scope_key,priority:4"`
+	BuildID    uint64    // 保存缓存的构建
+	Size       int64     // 归档字节数
+	SHA256     string    `gorm:"size:64"`
+	StorageKey string    `gorm:"size:512"` // 制品存储中的对象键
+	CreatedAt  time.Time `gorm:"index"`
+	LastUsedAt time.Time `gorm:"index"` // 最近一次保存或恢复的时间，超出配额时按此淘汰（LRU）
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// ConcurrencyLock 并发组锁：同一项目同一并发组同时只有一个持有者（构建或 Job）
+// Name 为 "<project_id>/<group>"，以主键冲突保证多副本间互斥；持有者进入终态后锁失效，由下一个占用方清除
+type ConcurrencyLock struct {
+	Name      string    `gorm:"primaryKey;size:320"`
+	BuildID   uint64    `gorm:"index;not null"`
+	JobName   string    `gorm:"size:255"` // 为空表示由构建持有（工作流级 concurrency）
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
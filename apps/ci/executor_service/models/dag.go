@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
 
 type BuildJob struct {
 	ID         uint64 `gorm:"primaryKey;autoIncrement"`
@@ -10,6 +14,8 @@ type BuildJob struct {
 	StartedAt  *time.Time
 	FinishedAt *time.Time
 	Index      int32
+	Outputs    datatypes.JSONMap `gorm:"type:jsonb"` // jobs.<id>.outputs 求值结果
+	Reason     string            `gorm:"size:1024"`  // 失败原因（如 Job 无法启动、Pod 消失）
 }
 type BuildJobEdge struct {
 	ID      uint64 `gorm:"primaryKey;autoIncrement"`
@@ -27,6 +33,7 @@ type BuildStep struct {
 	StartedAt  *time.Time
 	FinishedAt *time.Time
 	ExitCode   *int32
+	Outputs    datatypes.JSONMap `gorm:"type:jsonb"` // 步骤写入 $GITHUB_OUTPUT 的键值
 }
 
 type BuildStepLogChunk struct {
@@ -35,3 +42,13 @@ type BuildStepLogChunk struct {
 	Content     string `gorm:"type:text"`
 	CreatedAt   time.Time
 }
+
+// BuildServiceLogChunk 服务容器（services）日志，按构建、Job 与服务名区分日志流
+type BuildServiceLogChunk struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement"`
+	BuildID   uint64 `gorm:"index:idx_service_log"`
+	JobName   string `gorm:"size:255;index:idx_service_log"`
+	Service   string `gorm:"size:64;index:idx_service_log"`
+	Content   string `gorm:"type:text"`
+	CreatedAt time.Time
+}
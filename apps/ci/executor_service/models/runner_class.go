@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RunnerClass 经管理接口定义的执行器类别：runs-on 标签 → Pod 模板
+// Spec 为类别定义的 JSON（见 executor.RunnerClass）；配置文件中定义的类别不落库
+type RunnerClass struct {
+	Label     string    `gorm:"primaryKey;size:128"`
+	Spec      string    `gorm:"type:text;not null"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
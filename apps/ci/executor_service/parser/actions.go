@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// actionName 去除 uses 的版本后缀（owner/name@v1 → owner/name）
+func actionName(uses string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(uses), "@")
+	return name
+}
+
+// IsBuiltinAction uses 是否为执行器内置动作（不下载远端仓库，由执行器生成脚本）
+func IsBuiltinAction(uses string) bool {
+	switch actionName(uses) {
+	case CheckoutAction, UploadArtifactAction, DownloadArtifactAction, CacheAction:
+		return true
+	}
+	return false
+}
+
+// RuntimeWithAllowed 步骤 with 中的输入 name 是否可引用 Job 运行时才能求值的表达式（见 Step.Deferred）
+// 内置动作的参数在生成脚本时使用，仅缓存动作的 key 与 restore-keys 在运行时求值；docker:// 镜像的参数在创建 Pod 时使用
+func RuntimeWithAllowed(uses, name string) bool {
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(uses), "docker://"):
+		return false
+	case IsCache(uses):
+		return name == "key" || name == "restore-keys"
+	}
+	return !IsBuiltinAction(uses)
+}
+
+// builtinActionError 校验内置动作的 with 参数；非内置动作返回 nil
+func builtinActionError(st Step) error {
+	var err error
+	switch actionName(st.Uses) {
+	case CheckoutAction:
+		_, err = ParseCheckout(st.With)
+	case UploadArtifactAction:
+		_, err = ParseUploadArtifact(st.With)
+	case DownloadArtifactAction:
+		_, err = ParseDownloadArtifact(st.With)
+	case CacheAction:
+		_, err = ParseCache(st.With)
+	}
+	return err
+}
+
+// workspacePath 校验相对工作区的路径并规范化；空值与 "." 返回空串，绝对路径或越出工作区返回错误
+func workspacePath(v string) (string, error) {
+	if v = strings.TrimSpace(v); v == "" {
+		return "", nil
+	}
+	p := path.Clean(v)
+	if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return "", fmt.Errorf("path must be relative to the workspace, got %q", v)
+	}
+	if p == "." {
+		return "", nil
+	}
+	return p, nil
+}
+
+// sortedStringKeys 有序键，保证错误信息稳定
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 内置制品动作：同一构建内的 Job 之间经执行器的制品存储传递文件
+const (
+	UploadArtifactAction   = "xcoding/upload-artifact"
+	DownloadArtifactAction = "xcoding/download-artifact"
+)
+
+// ArtifactNamePattern 制品名：字母、数字与 . _ -，同一构建内唯一
+var ArtifactNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// UploadArtifact 上传参数（步骤 with）
+type UploadArtifact struct {
+	Name           string   // name：制品名
+	Paths          []string // path：相对工作区的文件或目录，每行一个
+	IfNoFilesFound string   // if-no-files-found：error、warn（默认）或 ignore
+}
+
+// DownloadArtifact 下载参数（步骤 with）
+type DownloadArtifact struct {
+	Name string // name：制品名
+	Path string // path：解压到的目录（相对工作区），空表示工作区根目录
+}
+
+// IsUploadArtifact uses 是否为内置上传动作
+func IsUploadArtifact(uses string) bool { return actionName(uses) == UploadArtifactAction }
+
+// IsDownloadArtifact uses 是否为内置下载动作
+func IsDownloadArtifact(uses string) bool { return actionName(uses) == DownloadArtifactAction }
+
+// ParseUploadArtifact 解析上传动作的 with 参数
+func ParseUploadArtifact(with map[string]string) (UploadArtifact, error) {
+	up := UploadArtifact{IfNoFilesFound: "warn"}
+	for _, k := range sortedStringKeys(with) {
+		v := strings.TrimSpace(with[k])
+		switch k {
+		case "name":
+			up.Name = v
+		case "path":
+			for _, line := range strings.Split(v, "\n") {
+				p, err := workspacePath(line)
+				if err != nil {
+					return UploadArtifact{}, err
+				}
+				if p == "" && strings.TrimSpace(line) != "" {
+					p = "."
+				}
+				if p != "" {
+					up.Paths = append(up.Paths, p)
+				}
+			}
+		case "if-no-files-found":
+			switch v {
+			case "":
+			case "error", "warn", "ignore":
+				up.IfNoFilesFound = v
+			default:
+				return UploadArtifact{}, fmt.Errorf("if-no-files-found must be error, warn or ignore, got %q", v)
+			}
+		default:
+			return UploadArtifact{}, fmt.Errorf("unknown input %q (supported: name, path, if-no-files-found)", k)
+		}
+	}
+	if err := validArtifactName(up.Name); err != nil {
+		return UploadArtifact{}, err
+	}
+	if len(up.Paths) == 0 {
+		return UploadArtifact{}, fmt.Errorf("path is required")
+	}
+	return up, nil
+}
+
+// ParseDownloadArtifact 解析下载动作的 with 参数
+func ParseDownloadArtifact(with map[string]string) (DownloadArtifact, error) {
+	var dl DownloadArtifact
+	for _, k := range sortedStringKeys(with) {
+		v := strings.TrimSpace(with[k])
+		switch k {
+		case "name":
+			dl.Name = v
+		case "path":
+			p, err := workspacePath(v)
+			if err != nil {
+				return DownloadArtifact{}, err
+			}
+			dl.Path = p
+		default:
+			return DownloadArtifact{}, fmt.Errorf("unknown input %q (supported: name, path)", k)
+		}
+	}
+	if err := validArtifactName(dl.Name); err != nil {
+		return DownloadArtifact{}, err
+	}
+	return dl, nil
+}
+
+func validArtifactName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !ArtifactNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid artifact name %q: use letters, digits, '.', '_' and '-' (at most 128 characters)", name)
+	}
+	return nil
+}
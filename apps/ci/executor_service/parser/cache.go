@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// CacheAction 内置缓存动作：步骤执行时按 key/restore-keys 恢复缓存，Job 成功结束后未精确命中时保存
+const CacheAction = "xcoding/cache"
+
+// CacheKeyMaxLen 缓存 key 的最大长度
+const CacheKeyMaxLen = 512
+
+// Cache 缓存参数（步骤 with）
+type Cache struct {
+	Key         string   // key：缓存键，精确匹配
+	RestoreKeys []string // restore-keys：key 未命中时依次按前缀匹配（取最新的条目），每行一个
+	Paths       []string // path：缓存的文件或目录，每行一个；相对路径基于工作区，支持 ~/ 与绝对路径
+}
+
+// IsCache uses 是否为内置缓存动作
+func IsCache(uses string) bool { return actionName(uses) == CacheAction }
+
+// ParseCache 解析缓存动作的 with 参数
+func ParseCache(with map[string]string) (Cache, error) {
+	var c Cache
+	for _, k := range sortedStringKeys(with) {
+		v := strings.TrimSpace(with[k])
+		switch k {
+		case "key":
+			c.Key = v
+		case "restore-keys":
+			for _, line := range strings.Split(v, "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					if err := ValidCacheKey(line); err != nil {
+						return Cache{}, fmt.Errorf("restore-keys: %w", err)
+					}
+					c.RestoreKeys = append(c.RestoreKeys, line)
+				}
+			}
+		case "path":
+			for _, line := range strings.Split(v, "\n") {
+				p, err := cachePath(line)
+				if err != nil {
+					return Cache{}, err
+				}
+				if p != "" {
+					c.Paths = append(c.Paths, p)
+				}
+			}
+		default:
+			return Cache{}, fmt.Errorf("unknown input %q (supported: key, restore-keys, path)", k)
+		}
+	}
+	if c.Key == "" {
+		return Cache{}, fmt.Errorf("key is required")
+	}
+	if err := ValidCacheKey(c.Key); err != nil {
+		return Cache{}, err
+	}
+	if len(c.Paths) == 0 {
+		return Cache{}, fmt.Errorf("path is required")
+	}
+	return c, nil
+}
+
+// ValidCacheKey 缓存 key：可打印 ASCII、不含逗号，最长 CacheKeyMaxLen 个字符
+// 生成脚本时 key 中的 $VAR 与 $(...) 在运行时展开，执行器保存与恢复时再次校验展开后的 key
+func ValidCacheKey(key string) error {
+	if len(key) > CacheKeyMaxLen {
+		return fmt.Errorf("cache key is longer than %d characters", CacheKeyMaxLen)
+	}
+	for i := 0; i < len(key); i++ {
+		if c := key[i]; c < 0x20 || c > 0x7e || c == ',' {
+			return fmt.Errorf("invalid cache key %q: use printable ASCII characters other than ','", key)
+		}
+	}
+	return nil
+}
+
+// cachePath 校验缓存路径：~/ 开头与绝对路径原样保留（规范化），其余按相对工作区路径处理；工作区根目录记为 "."
+func cachePath(v string) (string, error) {
+	if v = strings.TrimSpace(v); v == "" {
+		return "", nil
+	}
+	if v == "~" || strings.HasPrefix(v, "~/") {
+		rel, err := workspacePath(strings.TrimPrefix(strings.TrimPrefix(v, "~"), "/"))
+		if err != nil {
+			return "", fmt.Errorf("path must stay inside the home directory, got %q", v)
+		}
+		if rel == "" {
+			return "~", nil
+		}
+		return "~/" + rel, nil
+	}
+	if path.IsAbs(v) {
+		if p := path.Clean(v); p != "/" {
+			return p, nil
+		}
+		return "", fmt.Errorf("cannot cache the root directory")
+	}
+	p, err := workspacePath(v)
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return ".", nil
+	}
+	return p, nil
+}
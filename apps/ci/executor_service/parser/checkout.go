@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CheckoutAction 内置检出动作：将构建所属项目的代码仓库检出到工作区，凭据由 code_repository 服务提供
+const CheckoutAction = "xcoding/checkout"
+
+// Checkout 检出参数（步骤 with）
+type Checkout struct {
+	Repository string // repository：项目内的仓库名，空表示项目唯一启用中的仓库
+	Ref        string // ref：分支、标签或提交 SHA，空表示构建的提交（XC_COMMIT_SHA），其次为构建分支
+	Depth      int    // fetch-depth：浅克隆深度，0 表示完整历史，默认 1
+	Submodules string // submodules：空（不检出）、"true"（仅顶层）或 "recursive"
+	Path       string // path：相对工作区的检出目录，空表示工作区根目录
+}
+
+// IsCheckout uses 是否为内置检出动作（xcoding/checkout 或 xcoding/checkout@<版本>）
+func IsCheckout(uses string) bool { return actionName(uses) == CheckoutAction }
+
+// ParseCheckout 解析检出动作的 with 参数；不支持的参数名或非法取值返回错误
+func ParseCheckout(with map[string]string) (Checkout, error) {
+	co := Checkout{Depth: 1}
+	for _, k := range sortedStringKeys(with) {
+		v := strings.TrimSpace(with[k])
+		switch k {
+		case "repository":
+			co.Repository = v
+		case "ref":
+			co.Ref = v
+		case "fetch-depth":
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return Checkout{}, fmt.Errorf("fetch-depth must be a non-negative integer, got %q", v)
+			}
+			co.Depth = n
+		case "submodules":
+			switch strings.ToLower(v) {
+			case "", "false":
+			case "true":
+				co.Submodules = "true"
+			case "recursive":
+				co.Submodules = "recursive"
+			default:
+				return Checkout{}, fmt.Errorf("submodules must be true, false or recursive, got %q", v)
+			}
+		case "path":
+			p, err := workspacePath(v)
+			if err != nil {
+				return Checkout{}, err
+			}
+			co.Path = p
+		default:
+			return Checkout{}, fmt.Errorf("unknown input %q (supported: repository, ref, fetch-depth, submodules, path)", k)
+		}
+	}
+	return co, nil
+}
@@ -0,0 +1,175 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// InputError 单个输入的校验错误
+type InputError struct {
+	Input   string // 输入名
+	Message string
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("inputs.%s: %s", e.Input, e.Message)
+}
+
+// InputErrors 多个输入错误，按输入声明顺序排列
+type InputErrors []*InputError
+
+func (es InputErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateDispatchInputs 校验输入定义：类型合法、choice 提供 options、默认值与类型/选项一致
+// 错误附带输入名所在行列号
+func ValidateDispatchInputs(defs []DispatchInput) ValidationErrors {
+	var errs ValidationErrors
+	seen := map[string]bool{}
+	for _, in := range defs {
+		fail := func(format string, args ...any) {
+			errs = append(errs, &ValidationError{Line: in.Line, Column: in.Column, Message: fmt.Sprintf("on.workflow_dispatch.inputs.%s: ", in.Name) + fmt.Sprintf(format, args...)})
+		}
+		if in.Name == "" {
+			fail("input name is required")
+			continue
+		}
+		if seen[in.Name] {
+			fail("duplicate input")
+			continue
+		}
+		seen[in.Name] = true
+		switch in.Type {
+		case "", InputTypeString, InputTypeBoolean, InputTypeNumber:
+			if len(in.Options) > 0 {
+				fail("options are only allowed for choice inputs")
+			}
+		case InputTypeChoice:
+			if len(in.Options) == 0 {
+				fail("choice input requires options")
+			}
+		default:
+			fail("unsupported type %q (want string, boolean, choice or number)", in.Type)
+			continue
+		}
+		if in.HasDefault {
+			if _, err := normalizeInput(in, in.Default); err != nil {
+				fail("invalid default: %v", err)
+			}
+		}
+	}
+	return errs
+}
+
+// ResolveDispatchInputs 按输入定义校验手动触发提供的值并补全默认值
+// 规则：
+// - 未提供的输入使用默认值；必填且无默认值时报错
+// - boolean 接受 true/false（不区分大小写），number 需为数字，choice 需为 options 之一
+// - 结果按类型归一化为字符串（boolean 为 true/false），未声明的键不在结果中
+func ResolveDispatchInputs(defs []DispatchInput, supplied map[string]string) (map[string]string, error) {
+	var errs InputErrors
+	out := make(map[string]string, len(defs))
+	for _, in := range defs {
+		v, ok := supplied[in.Name]
+		if !ok || (v == "" && in.Type != "" && in.Type != InputTypeString) {
+			if !in.HasDefault {
+				if in.Required {
+					errs = append(errs, &InputError{Input: in.Name, Message: "is required"})
+				} else if in.Type == InputTypeBoolean {
+					out[in.Name] = "false"
+				}
+				continue
+			}
+			v = in.Default
+		}
+		if in.Required && in.Type == InputTypeString && strings.TrimSpace(v) == "" {
+			errs = append(errs, &InputError{Input: in.Name, Message: "is required"})
+			continue
+		}
+		nv, err := normalizeInput(in, v)
+		if err != nil {
+			errs = append(errs, &InputError{Input: in.Name, Message: err.Error()})
+			continue
+		}
+		out[in.Name] = nv
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return out, nil
+}
+
+// InputValues 将已解析的输入转换为表达式上下文中的取值：boolean 为 bool，number 为 float64，其余为字符串
+// 未声明的键按字符串保留
+func InputValues(defs []DispatchInput, resolved map[string]string) map[string]any {
+	out := make(map[string]any, len(resolved))
+	for k, v := range resolved {
+		out[k] = v
+	}
+	for _, in := range defs {
+		v, ok := resolved[in.Name]
+		if !ok {
+			continue
+		}
+		switch in.Type {
+		case InputTypeBoolean:
+			out[in.Name] = v == "true"
+		case InputTypeNumber:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				out[in.Name] = f
+			}
+		}
+	}
+	return out
+}
+
+// InputEnv 返回输入对应的环境变量：INPUT_<名称大写，非字母数字替换为 _>
+func InputEnv(resolved map[string]string) map[string]string {
+	out := make(map[string]string, len(resolved))
+	for k, v := range resolved {
+		name := strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z':
+				return r - 'a' + 'A'
+			case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+				return r
+			}
+			return '_'
+		}, k)
+		out["INPUT_"+name] = v
+	}
+	return out
+}
+
+func normalizeInput(in DispatchInput, v string) (string, error) {
+	switch in.Type {
+	case InputTypeBoolean:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true":
+			return "true", nil
+		case "false":
+			return "false", nil
+		}
+		return "", fmt.Errorf("%q is not a boolean (want true or false)", v)
+	case InputTypeNumber:
+		s := strings.TrimSpace(v)
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return "", fmt.Errorf("%q is not a number", v)
+		}
+		return s, nil
+	case InputTypeChoice:
+		for _, o := range in.Options {
+			if o == v {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("%q is not one of [%s]", v, strings.Join(in.Options, ", "))
+	}
+	return v, nil
+}
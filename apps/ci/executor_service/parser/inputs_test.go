@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateDispatchInputs(t *testing.T) {
+	errs := validationErrors(t, `on:
+  workflow_dispatch:
+    inputs:
+      env:
+        type: choice
+        options: [dev, prod]
+        default: staging
+      debug:
+        type: boolean
+        default: maybe
+      count:
+        type: number
+        default: ten
+      mode:
+        type: enum
+      pick:
+        type: choice
+      name:
+        options: [a]
+      ok:
+        type: boolean
+        default: "True"
+jobs:
+  a:
+    steps: [{run: a}]
+`)
+	want := []struct {
+		line    int
+		message string
+	}{
+		{4, `on.workflow_dispatch.inputs.env: invalid default: "staging" is not one of [dev, prod]`},
+		{8, `on.workflow_dispatch.inputs.debug: invalid default: "maybe" is not a boolean (want true or false)`},
+		{11, `on.workflow_dispatch.inputs.count: invalid default: "ten" is not a number`},
+		{14, `on.workflow_dispatch.inputs.mode: unsupported type "enum" (want string, boolean, choice or number)`},
+		{16, `on.workflow_dispatch.inputs.pick: choice input requires options`},
+		{18, `on.workflow_dispatch.inputs.name: options are only allowed for choice inputs`},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %v, want %d", errs, len(want))
+	}
+	for i, w := range want {
+		if e := errs[i]; e.Line != w.line || e.Column != 7 || e.Message != w.message {
+			t.Errorf("error %d = %d:%d %q, want %d:7 %q", i, e.Line, e.Column, e.Message, w.line, w.message)
+		}
+	}
+
+	errs = ValidateDispatchInputs([]DispatchInput{{Name: "a"}, {Name: "a", Type: InputTypeNumber}, {Type: InputTypeString}})
+	if len(errs) != 2 || errs[0].Message != "on.workflow_dispatch.inputs.a: duplicate input" || errs[1].Message != "on.workflow_dispatch.inputs.: input name is required" {
+		t.Errorf("errors = %v, want duplicate and missing name", errs)
+	}
+}
+
+func TestResolveDispatchInputs(t *testing.T) {
+	defs := []DispatchInput{
+		{Name: "env", Type: InputTypeChoice, Options: []string{"dev", "prod"}, Default: "dev", HasDefault: true},
+		{Name: "debug", Type: InputTypeBoolean},
+		{Name: "count", Type: InputTypeNumber, Required: true},
+		{Name: "tag", Type: InputTypeString, Required: true},
+		{Name: "note", Type: InputTypeString, Default: "none", HasDefault: true},
+	}
+	cases := []struct {
+		name     string
+		supplied map[string]string
+		want     map[string]string
+		wantErr  []string
+	}{
+		{
+			// 未声明的键不进入结果
+			name:     "typed values and defaults",
+			supplied: map[string]string{"debug": "TRUE", "count": " 3 ", "tag": "v1", "extra": "x"},
+			want:     map[string]string{"env": "dev", "debug": "true", "count": "3", "tag": "v1", "note": "none"},
+		},
+		{
+			// 非 string 类型的空值视为未提供；string 类型保留空字符串
+			name:     "empty values",
+			supplied: map[string]string{"env": "", "debug": "", "count": "1.5", "tag": "t", "note": ""},
+			want:     map[string]string{"env": "dev", "debug": "false", "count": "1.5", "tag": "t", "note": ""},
+		},
+		{
+			name:     "missing required",
+			supplied: map[string]string{"count": ""},
+			wantErr:  []string{"inputs.count: is required", "inputs.tag: is required"},
+		},
+		{
+			name:     "invalid values",
+			supplied: map[string]string{"env": "staging", "debug": "yes", "count": "x", "tag": "  "},
+			wantErr: []string{
+				`inputs.env: "staging" is not one of [dev, prod]`,
+				`inputs.debug: "yes" is not a boolean (want true or false)`,
+				`inputs.count: "x" is not a number`,
+				"inputs.tag: is required",
+			},
+		},
+	}
+	for _, c := range cases {
+		got, err := ResolveDispatchInputs(defs, c.supplied)
+		if c.wantErr == nil {
+			if err != nil || !reflect.DeepEqual(got, c.want) {
+				t.Errorf("%s: ResolveDispatchInputs = %v, %v, want %v", c.name, got, err, c.want)
+			}
+			continue
+		}
+		var ies InputErrors
+		if !errors.As(err, &ies) {
+			t.Errorf("%s: error = %v, want InputErrors", c.name, err)
+			continue
+		}
+		var msgs []string
+		for _, ie := range ies {
+			msgs = append(msgs, ie.Error())
+		}
+		if !reflect.DeepEqual(msgs, c.wantErr) {
+			t.Errorf("%s: errors = %q, want %q", c.name, msgs, c.wantErr)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseOutputFile 解析 $GITHUB_OUTPUT 文件内容
+// 支持两种格式（与 GitHub 一致）：
+// - 单行：name=value
+// - 多行：name<<DELIMITER ... DELIMITER
+func ParseOutputFile(content string) (map[string]string, error) {
+	out := map[string]string{}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		eq := strings.Index(line, "=")
+		hd := strings.Index(line, "<<")
+		if hd > 0 && (eq < 0 || hd < eq) {
+			name, delim := line[:hd], line[hd+2:]
+			var vals []string
+			closed := false
+			for i++; i < len(lines); i++ {
+				if lines[i] == delim {
+					closed = true
+					break
+				}
+				vals = append(vals, lines[i])
+			}
+			if !closed {
+				return out, fmt.Errorf("output %q: missing delimiter %q", name, delim)
+			}
+			out[name] = strings.Join(vals, "\n")
+			continue
+		}
+		if eq <= 0 {
+			return out, fmt.Errorf("invalid output line %q", line)
+		}
+		out[line[:eq]] = line[eq+1:]
+	}
+	return out, nil
+}
@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOutputFile(t *testing.T) {
+	cases := []struct {
+		name, content string
+		want          map[string]string
+		err           string
+	}{
+		{name: "single line", content: "version=1.2.3\nempty=\nurl=http://x/?a=b\n", want: map[string]string{"version": "1.2.3", "empty": "", "url": "http://x/?a=b"}},
+		{name: "crlf and blank lines", content: "a=1\r\n\r\nb=2\r\n", want: map[string]string{"a": "1", "b": "2"}},
+		{name: "heredoc", content: "notes<<EOF\nline 1\nx=y\n\nEOF\nafter=1\n", want: map[string]string{"notes": "line 1\nx=y\n", "after": "1"}},
+		{name: "heredoc delimiter with equals", content: "k<<A=B\nv\nA=B\n", want: map[string]string{"k": "v"}},
+		{name: "duplicate key keeps the last value", content: "k=1\nk<<EOF\n2\nEOF\nk=3\n", want: map[string]string{"k": "3"}},
+		{name: "missing delimiter", content: "ok=1\nnotes<<EOF\nline\n", want: map[string]string{"ok": "1"}, err: `output "notes": missing delimiter "EOF"`},
+		{name: "invalid line", content: "novalue\n", want: map[string]string{}, err: `invalid output line "novalue"`},
+		{name: "empty name", content: "=v\n", want: map[string]string{}, err: "invalid output line"},
+	}
+	for _, c := range cases {
+		got, err := ParseOutputFile(c.content)
+		if c.err == "" && err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("%s: error = %v, want %q", c.name, err, c.err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: outputs = %#v, want %#v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Service 服务容器（数据库、缓存等），以 sidecar 形式与 runner 运行在同一 Pod，Job 内经 localhost 访问
+type Service struct {
+	Image   string            `yaml:"image"`
+	Env     map[string]string `yaml:"env"`
+	Ports   []string          `yaml:"ports"`   // 容器端口，如 5432、"5432:5432"、"6379/tcp"；同一 Pod 内不支持端口映射
+	Options string            `yaml:"options"` // docker 风格的健康检查参数，见 ParseServiceOptions
+}
+
+// ServiceHealth 服务健康检查；Cmd 为空时不配置命令检查
+type ServiceHealth struct {
+	Cmd         string
+	Interval    time.Duration
+	Timeout     time.Duration
+	Retries     int
+	StartPeriod time.Duration
+}
+
+// serviceNamePattern 服务名需可作为容器名（DNS-1123 标签，容器名追加 "svc-" 前缀后不超过 63 个字符）
+var serviceNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,57}[a-z0-9])?$`)
+
+// ServiceNames 按名称排序的服务名
+func ServiceNames(services map[string]Service) []string {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ContainerPorts 解析服务端口：支持 "<port>"、"<port>:<port>" 与 "/tcp"、"/udp" 后缀
+// 服务与 runner 共享网络命名空间，宿主端口与容器端口不一致的映射无法实现，返回错误
+func (s Service) ContainerPorts() ([]int32, error) {
+	ports := make([]int32, 0, len(s.Ports))
+	for _, p := range s.Ports {
+		spec, _, _ := strings.Cut(strings.TrimSpace(p), "/")
+		host, container, mapped := strings.Cut(spec, ":")
+		if !mapped {
+			container = host
+		}
+		n, err := strconv.ParseUint(container, 10, 16)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid port %q", p)
+		}
+		if mapped && host != container {
+			return nil, fmt.Errorf("port mapping %q is not supported: services share the job's network and are reachable on localhost:%s", p, container)
+		}
+		ports = append(ports, int32(n))
+	}
+	return ports, nil
+}
+
+// ParseServiceOptions 解析 options 中的健康检查参数（与 docker run 一致，支持 --opt value 与 --opt=value）：
+// --health-cmd、--health-interval（默认 10s）、--health-timeout（默认 5s）、--health-retries（默认 3）、--health-start-period
+func ParseServiceOptions(options string) (ServiceHealth, error) {
+	h := ServiceHealth{Interval: 10 * time.Second, Timeout: 5 * time.Second, Retries: 3}
+	args, err := splitShellWords(options)
+	if err != nil {
+		return h, err
+	}
+	for i := 0; i < len(args); i++ {
+		opt, val, hasVal := strings.Cut(args[i], "=")
+		if !hasVal {
+			if i+1 >= len(args) {
+				return h, fmt.Errorf("option %s requires a value", opt)
+			}
+			i++
+			val = args[i]
+		}
+		switch opt {
+		case "--health-cmd":
+			h.Cmd = val
+		case "--health-interval", "--health-timeout", "--health-start-period":
+			d, err := time.ParseDuration(val)
+			if err != nil || d < 0 {
+				return h, fmt.Errorf("invalid %s %q", opt, val)
+			}
+			switch opt {
+			case "--health-interval":
+				h.Interval = d
+			case "--health-timeout":
+				h.Timeout = d
+			default:
+				h.StartPeriod = d
+			}
+		case "--health-retries":
+			n, err := strconv.Atoi(val)
+			if err != nil || n <= 0 {
+				return h, fmt.Errorf("invalid %s %q", opt, val)
+			}
+			h.Retries = n
+		default:
+			return h, fmt.Errorf("unsupported option %s (only --health-* options are supported)", opt)
+		}
+	}
+	return h, nil
+}
+
+// splitShellWords 按 shell 规则切分参数：空白分隔，支持单引号、双引号与反斜杠转义
+func splitShellWords(s string) ([]string, error) {
+	var (
+		words []string
+		cur   strings.Builder
+		inArg bool
+		quote rune
+	)
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\\' && i+1 < len(runes) && (quote == 0 || strings.ContainsRune("\"\\$`", runes[i+1])):
+			i++
+			cur.WriteRune(runes[i])
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				words = append(words, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inArg {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Triggers 工作流触发配置（on:）
+// 支持三种写法（与 GitHub Actions 一致）：
+// - 字符串：on: push
+// - 列表：on: [push, workflow_dispatch]
+// - 映射：on: { push: {...}, schedule: [{cron: ...}] }
+type Triggers struct {
+	Events      []string           // 声明的事件名，保持声明顺序
+	Push        *RefFilter         // on.push 过滤条件；声明了 push 但未配置过滤时为空值结构
+	PullRequest *PullRequestFilter // on.pull_request 过滤条件；规则同 Push
+	Schedule    []ScheduleTrigger  // on.schedule 定时触发
+	Dispatch    []DispatchInput    // on.workflow_dispatch.inputs，保持声明顺序
+}
+
+// RefFilter push 等事件的分支/标签/路径过滤（GitHub Actions 语义）
+// 列表中以 ! 开头的模式为否定，按声明顺序生效
+type RefFilter struct {
+	Branches       StringOrSlice `yaml:"branches"`
+	BranchesIgnore StringOrSlice `yaml:"branches-ignore"`
+	Tags           StringOrSlice `yaml:"tags"`
+	TagsIgnore     StringOrSlice `yaml:"tags-ignore"`
+	Paths          StringOrSlice `yaml:"paths"`
+	PathsIgnore    StringOrSlice `yaml:"paths-ignore"`
+	Line           int           `yaml:"-"` // 事件键所在行列号，用于校验错误定位
+	Column         int           `yaml:"-"`
+}
+
+// PullRequestFilter on.pull_request 过滤条件
+// branches/branches-ignore 匹配目标（base）分支；types 为空时默认 opened、synchronize、reopened
+type PullRequestFilter struct {
+	RefFilter `yaml:",inline"`
+	Types     StringOrSlice `yaml:"types"`
+}
+
+// 手动触发输入类型
+const (
+	InputTypeString  = "string"
+	InputTypeBoolean = "boolean"
+	InputTypeChoice  = "choice"
+	InputTypeNumber  = "number"
+)
+
+// DispatchInput on.workflow_dispatch.inputs 中的单个输入定义
+type DispatchInput struct {
+	Name        string
+	Description string
+	Type        string // string（默认）| boolean | choice | number
+	Required    bool
+	Default     string // 默认值的标量文本；HasDefault 区分未声明与空字符串
+	HasDefault  bool
+	Options     []string // choice 可选值
+	Line        int      // 输入名所在行列号，用于校验错误定位
+	Column      int
+}
+
+// ScheduleTrigger on.schedule 中的单个定时规则
+type ScheduleTrigger struct {
+	Cron     string `yaml:"cron"`
+	Timezone string `yaml:"timezone"` // 可选，默认 UTC
+	Line     int    `yaml:"-"`        // cron 所在行列号，用于校验错误定位
+	Column   int    `yaml:"-"`
+}
+
+// Has 判断是否声明了指定事件
+func (t *Triggers) Has(event string) bool {
+	for _, e := range t.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Triggers) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		if value.Value != "" {
+			t.addEvent(value.Value)
+		}
+		return nil
+	case yaml.SequenceNode:
+		for _, n := range value.Content {
+			if n.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: on: event name must be a string", n.Line)
+			}
+			t.addEvent(n.Value)
+		}
+		return nil
+	case yaml.MappingNode:
+	default:
+		return fmt.Errorf("line %d: on must be a string, list or mapping", value.Line)
+	}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		keyNode, val := value.Content[i], value.Content[i+1]
+		key := keyNode.Value
+		t.addEvent(key)
+		switch key {
+		case "schedule":
+			if err := t.decodeSchedule(val); err != nil {
+				return err
+			}
+		case "push":
+			if val.Kind == yaml.MappingNode {
+				if err := val.Decode(t.Push); err != nil {
+					return fmt.Errorf("line %d: on.push: %w", val.Line, err)
+				}
+			}
+			t.Push.Line, t.Push.Column = keyNode.Line, keyNode.Column
+		case "pull_request":
+			if val.Kind == yaml.MappingNode {
+				if err := val.Decode(t.PullRequest); err != nil {
+					return fmt.Errorf("line %d: on.pull_request: %w", val.Line, err)
+				}
+			}
+			t.PullRequest.Line, t.PullRequest.Column = keyNode.Line, keyNode.Column
+		case "workflow_dispatch":
+			if err := t.decodeDispatch(val); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addEvent 记录事件名；push/pull_request 即使未配置过滤也初始化过滤结构，表示不做过滤
+func (t *Triggers) addEvent(name string) {
+	t.Events = append(t.Events, name)
+	if name == "push" && t.Push == nil {
+		t.Push = &RefFilter{}
+	}
+	if name == "pull_request" && t.PullRequest == nil {
+		t.PullRequest = &PullRequestFilter{}
+	}
+}
+
+// decodeSchedule 解析 on.schedule 列表，记录每条 cron 的位置
+func (t *Triggers) decodeSchedule(val *yaml.Node) error {
+	if val.Kind != yaml.SequenceNode {
+		return fmt.Errorf("line %d: on.schedule must be a list", val.Line)
+	}
+	for _, item := range val.Content {
+		var st ScheduleTrigger
+		if err := item.Decode(&st); err != nil {
+			return fmt.Errorf("line %d: on.schedule: %w", item.Line, err)
+		}
+		st.Line, st.Column = item.Line, item.Column
+		if item.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(item.Content); j += 2 {
+				if item.Content[j].Value == "cron" {
+					st.Line, st.Column = item.Content[j+1].Line, item.Content[j+1].Column
+				}
+			}
+		}
+		t.Schedule = append(t.Schedule, st)
+	}
+	return nil
+}
+
+// decodeDispatch 解析 on.workflow_dispatch.inputs，保持声明顺序并记录位置
+func (t *Triggers) decodeDispatch(val *yaml.Node) error {
+	if val.Kind != yaml.MappingNode {
+		return nil
+	}
+	var inputs *yaml.Node
+	for i := 0; i+1 < len(val.Content); i += 2 {
+		if val.Content[i].Value == "inputs" {
+			inputs = val.Content[i+1]
+		}
+	}
+	if inputs == nil || (inputs.Kind == yaml.ScalarNode && inputs.Tag == "!!null") {
+		return nil
+	}
+	if inputs.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: on.workflow_dispatch.inputs must be a mapping", inputs.Line)
+	}
+	for i := 0; i+1 < len(inputs.Content); i += 2 {
+		name, def := inputs.Content[i], inputs.Content[i+1]
+		in := DispatchInput{Name: name.Value, Type: InputTypeString, Line: name.Line, Column: name.Column}
+		if def.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(def.Content); j += 2 {
+				k, v := def.Content[j].Value, def.Content[j+1]
+				var err error
+				switch k {
+				case "description":
+					in.Description = v.Value
+				case "type":
+					in.Type = v.Value
+				case "required":
+					err = v.Decode(&in.Required)
+				case "default":
+					if v.Kind != yaml.ScalarNode {
+						err = fmt.Errorf("default must be a scalar")
+					}
+					in.Default, in.HasDefault = v.Value, v.Tag != "!!null"
+				case "options":
+					err = v.Decode(&in.Options)
+				}
+				if err != nil {
+					return fmt.Errorf("line %d: on.workflow_dispatch.inputs.%s.%s: %w", v.Line, in.Name, k, err)
+				}
+			}
+		} else if def.Kind != yaml.ScalarNode || def.Tag != "!!null" {
+			return fmt.Errorf("line %d: on.workflow_dispatch.inputs.%s must be a mapping", def.Line, in.Name)
+		}
+		t.Dispatch = append(t.Dispatch, in)
+	}
+	return nil
+}
@@ -0,0 +1,385 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"xcoding/apps/ci/executor_service/expr"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError 工作流校验错误，携带 YAML 源码中的行列号（从 1 开始）
+type ValidationError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// ValidationErrors 一次校验中发现的全部错误，按行列号排序
+type ValidationErrors []*ValidationError
+
+func (es ValidationErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateWorkflowYAML 解析并校验工作流，返回解析结果
+// 校验内容：
+// - YAML 语法与结构（jobs 必须为非空映射）
+// - needs 引用的 Job 必须存在，且依赖关系不能成环
+// - 同一 Job 内步骤名不能重复（日志标记按步骤名定位 BuildStep）
+// - Job 与步骤 if 条件的表达式语法与函数参数个数
+// - steps 上下文仅可在步骤的 if/run/env/with 与 jobs.<id>.outputs 中引用（其余表达式在 Job 启动前求值，步骤尚未执行），hashFiles() 同理但不可用于 outputs；内置动作与 docker:// 镜像的 with 在生成脚本或创建 Pod 时使用，仅缓存动作的 key/restore-keys 例外（见 RuntimeWithAllowed）
+// - on.workflow_dispatch.inputs 的类型、options 与默认值
+// - 工作流级与 Job 级 concurrency 需指定 group
+// - services 的服务名、镜像、端口与健康检查参数
+// - Job 与步骤的 timeout-minutes（0 表示不限）、步骤 retry 的 max/backoff 不能为负
+// 错误类型为 ValidationErrors，可逐条读取行列号
+func ValidateWorkflowYAML(content string) (*Workflow, error) {
+	return ValidateWorkflowYAMLWith(content, ValidateOptions{})
+}
+
+// ValidateOptions 依赖执行环境的校验项
+// - RunnerLabels：已定义的执行器类别标签，非 nil 时 runs-on 必须为其中之一（含 ${{ }} 表达式的值在运行时检查）
+type ValidateOptions struct {
+	RunnerLabels []string
+}
+
+// ValidateWorkflowYAMLWith 在 ValidateWorkflowYAML 的基础上执行 opts 指定的校验
+func ValidateWorkflowYAMLWith(content string, opts ValidateOptions) (*Workflow, error) {
+	wf, err := ParseWorkflowYAML(content)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &root); err != nil {
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	if errs := validateWorkflowNode(&root, wf, opts); len(errs) > 0 {
+		return nil, errs
+	}
+	return wf, nil
+}
+
+// jobNode 校验过程中使用的 Job 节点信息
+type jobNode struct {
+	name  string
+	key   *yaml.Node   // jobs 下的键节点，用于定位 Job
+	needs []*yaml.Node // needs 中的每个标量节点
+}
+
+func validateWorkflowNode(root *yaml.Node, wf *Workflow, opts ValidateOptions) ValidationErrors {
+	var errs ValidationErrors
+	add := func(n *yaml.Node, format string, args ...any) {
+		errs = append(errs, &ValidationError{Line: n.Line, Column: n.Column, Message: fmt.Sprintf(format, args...)})
+	}
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		add(doc, "workflow must be a mapping")
+		return errs
+	}
+	if key, val := mappingValue(doc, "concurrency"); val != nil && wf.Concurrency != nil && strings.TrimSpace(wf.Concurrency.Group) == "" {
+		add(key, "concurrency.group is required")
+	}
+	_, jobsVal := mappingValue(doc, "jobs")
+	if jobsVal == nil || jobsVal.Kind != yaml.MappingNode || len(jobsVal.Content) == 0 {
+		n := doc
+		if jobsVal != nil {
+			n = jobsVal
+		}
+		add(n, "workflow must define at least one job under \"jobs\"")
+		return errs
+	}
+
+	jobs := map[string]*jobNode{}
+	order := []string{}
+	for i := 0; i+1 < len(jobsVal.Content); i += 2 {
+		key, val := jobsVal.Content[i], jobsVal.Content[i+1]
+		jn := &jobNode{name: key.Value, key: key}
+		jobs[key.Value] = jn
+		order = append(order, key.Value)
+		if val.Kind != yaml.MappingNode {
+			add(val, "job %q must be a mapping", key.Value)
+			continue
+		}
+		if _, iv := mappingValue(val, "if"); iv != nil {
+			if err := expr.Validate(iv.Value); err != nil {
+				add(iv, "invalid if condition of job %q: %v", key.Value, err)
+			}
+		}
+		for i := 0; i+1 < len(val.Content); i += 2 {
+			field := val.Content[i].Value
+			switch field {
+			case "steps":
+				continue
+			case "outputs":
+				// outputs 在 Job 结束后由执行器求值：steps 上下文可用，工作区已不存在
+				runtimeReferences(val.Content[i], val.Content[i+1], func(n *yaml.Node, ref string) {
+					if ref == "hashFiles" {
+						add(n, "job %q %s in outputs: %s", key.Value, runtimeRefPhrase(ref), runtimeRefHint(ref))
+					}
+				})
+				continue
+			}
+			runtimeReferences(val.Content[i], val.Content[i+1], func(n *yaml.Node, ref string) {
+				add(n, "job %q %s in %s: %s", key.Value, runtimeRefPhrase(ref), field, runtimeRefHint(ref))
+			})
+		}
+		if tk, tv := mappingValue(val, "timeout-minutes"); tv != nil {
+			if j, ok := wf.Jobs[key.Value]; ok && j.TimeoutMinutes < 0 {
+				add(tk, "timeout-minutes of job %q must not be negative", key.Value)
+			}
+		}
+		if rk, rv := mappingValue(val, "runs-on"); rv != nil && opts.RunnerLabels != nil {
+			if j, ok := wf.Jobs[key.Value]; ok && j.RunsOn != "" && !strings.Contains(string(j.RunsOn), "${{") && !containsString(opts.RunnerLabels, string(j.RunsOn)) {
+				add(rk, "job %q runs-on unknown runner class %q (available: %s)", key.Value, j.RunsOn, strings.Join(opts.RunnerLabels, ", "))
+			}
+		}
+		if _, sv := mappingValue(val, "services"); sv != nil && sv.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(sv.Content); i += 2 {
+				sk := sv.Content[i]
+				svc := wf.Jobs[key.Value].Services[sk.Value]
+				if !serviceNamePattern.MatchString(sk.Value) {
+					add(sk, "service name %q in job %q must consist of lower case letters, digits and '-' (at most 59 characters)", sk.Value, key.Value)
+				}
+				if strings.TrimSpace(svc.Image) == "" {
+					add(sk, "service %q in job %q requires an image", sk.Value, key.Value)
+				}
+				if pk, _ := mappingValue(sv.Content[i+1], "ports"); pk != nil {
+					if _, err := svc.ContainerPorts(); err != nil {
+						add(pk, "service %q in job %q: %v", sk.Value, key.Value, err)
+					}
+				}
+				if ok, _ := mappingValue(sv.Content[i+1], "options"); ok != nil && !strings.Contains(svc.Options, "${{") {
+					if _, err := ParseServiceOptions(svc.Options); err != nil {
+						add(ok, "service %q in job %q: %v", sk.Value, key.Value, err)
+					}
+				}
+			}
+		}
+		if ck, cv := mappingValue(val, "concurrency"); cv != nil {
+			if j, ok := wf.Jobs[key.Value]; ok && j.Concurrency != nil && strings.TrimSpace(j.Concurrency.Group) == "" {
+				add(ck, "concurrency.group is required in job %q", key.Value)
+			}
+		}
+		if _, needs := mappingValue(val, "needs"); needs != nil {
+			switch needs.Kind {
+			case yaml.ScalarNode:
+				// 兼容空格分隔写法，列号按各名称在标量中的偏移计算
+				off := 0
+				for _, f := range strings.Fields(needs.Value) {
+					idx := strings.Index(needs.Value[off:], f) + off
+					jn.needs = append(jn.needs, &yaml.Node{Kind: yaml.ScalarNode, Value: f, Line: needs.Line, Column: needs.Column + idx})
+					off = idx + len(f)
+				}
+			case yaml.SequenceNode:
+				jn.needs = append(jn.needs, needs.Content...)
+			}
+		}
+		if _, steps := mappingValue(val, "steps"); steps != nil && steps.Kind == yaml.SequenceNode {
+			seen := map[string]*yaml.Node{}
+			for idx, st := range steps.Content {
+				if st.Kind != yaml.MappingNode {
+					add(st, "step %d in job %q must be a mapping", idx+1, key.Value)
+					continue
+				}
+				nameKey, nameVal := mappingValue(st, "name")
+				name, pos := "", st
+				if nameVal != nil {
+					name, pos = nameVal.Value, nameKey
+				} else if j, ok := wf.Jobs[key.Value]; ok && idx < len(j.Steps) {
+					name = j.Steps[idx].Name
+				}
+				if _, iv := mappingValue(st, "if"); iv != nil {
+					if err := expr.Validate(iv.Value); err != nil {
+						add(iv, "invalid if condition of step %q in job %q: %v", name, key.Value, err)
+					}
+				}
+				uses := ""
+				if _, uv := mappingValue(st, "uses"); uv != nil {
+					uses = uv.Value
+				}
+				for i := 0; i+1 < len(st.Content); i += 2 {
+					fk, fv := st.Content[i], st.Content[i+1]
+					switch fk.Value {
+					case "if", "run", "env":
+						continue
+					case "with":
+						if fv.Kind != yaml.MappingNode {
+							continue
+						}
+						for j := 0; j+1 < len(fv.Content); j += 2 {
+							wk := fv.Content[j]
+							if RuntimeWithAllowed(uses, wk.Value) {
+								continue
+							}
+							runtimeReferences(wk, fv.Content[j+1], func(n *yaml.Node, ref string) {
+								add(n, "step %q in job %q %s in with.%s: inputs of %s are resolved before the job starts", name, key.Value, runtimeRefPhrase(ref), wk.Value, actionName(uses))
+							})
+						}
+						continue
+					}
+					runtimeReferences(fk, fv, func(n *yaml.Node, ref string) {
+						add(n, "step %q in job %q %s in %s: %s", name, key.Value, runtimeRefPhrase(ref), fk.Value, runtimeRefHint(ref))
+					})
+				}
+				if j, ok := wf.Jobs[key.Value]; ok && idx < len(j.Steps) {
+					step := j.Steps[idx]
+					if tk, tv := mappingValue(st, "timeout-minutes"); tv != nil && step.TimeoutMinutes < 0 {
+						add(tk, "timeout-minutes of step %q in job %q must not be negative", name, key.Value)
+					}
+					if rk, rv := mappingValue(st, "retry"); rv != nil && step.Retry != nil && (step.Retry.Max < 0 || step.Retry.Backoff < 0) {
+						add(rk, "retry.max and retry.backoff of step %q in job %q must not be negative", name, key.Value)
+					}
+					if uk, _ := mappingValue(st, "uses"); uk != nil && IsBuiltinAction(step.Uses) && !withHasExpression(step.With) {
+						if err := builtinActionError(step); err != nil {
+							add(uk, "step %q in job %q: %s: %v", name, key.Value, actionName(step.Uses), err)
+						}
+					}
+				}
+				if first, ok := seen[name]; ok {
+					add(pos, "duplicate step name %q in job %q (first defined at line %d)", name, key.Value, first.Line)
+					continue
+				}
+				seen[name] = pos
+			}
+		}
+	}
+
+	// needs 引用检查
+	for _, name := range order {
+		for _, n := range jobs[name].needs {
+			if n.Value == name {
+				add(n, "job %q cannot depend on itself", name)
+			} else if _, ok := jobs[n.Value]; !ok {
+				add(n, "job %q needs unknown job %q", name, n.Value)
+			}
+		}
+	}
+
+	// 环检测：DFS 三色标记，报告闭合环的 needs 节点
+	const (
+		white = iota
+		gray
+		black
+	)
+	color := map[string]int{}
+	var path []string
+	var visit func(name string)
+	visit = func(name string) {
+		color[name] = gray
+		path = append(path, name)
+		for _, n := range jobs[name].needs {
+			dep := n.Value
+			if _, ok := jobs[dep]; !ok || dep == name {
+				continue
+			}
+			switch color[dep] {
+			case white:
+				visit(dep)
+			case gray:
+				start := 0
+				for i, p := range path {
+					if p == dep {
+						start = i
+					}
+				}
+				cycle := append(append([]string{}, path[start:]...), dep)
+				add(n, "dependency cycle: %s", strings.Join(cycle, " -> "))
+			}
+		}
+		path = path[:len(path)-1]
+		color[name] = black
+	}
+	for _, name := range order {
+		if color[name] == white {
+			visit(name)
+		}
+	}
+	errs = append(errs, ValidateDispatchInputs(wf.On.Dispatch)...)
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+	return errs
+}
+
+// runtimeRefPhrase 运行时引用（见 expr.RuntimeReference）在错误信息中的描述
+func runtimeRefPhrase(ref string) string {
+	if ref == "hashFiles" {
+		return "uses hashFiles()"
+	}
+	return "references the steps context"
+}
+
+// runtimeRefHint 运行时引用的可用位置说明：其余字段在 Job 启动前求值，步骤尚未执行；hashFiles() 还需要工作区，Job 结束后不可用
+func runtimeRefHint(ref string) string {
+	if ref == "hashFiles" {
+		return "hashFiles() is only available in step if, run, env and with"
+	}
+	return "the steps context is only available in step if, run, env and with, and in job outputs"
+}
+
+// runtimeReferences 对需在 Job 运行时求值的表达式标量调用 fn（ref 见 expr.RuntimeReference）；键为 if 的标量按条件表达式（可不带 ${{ }}）解析
+// 语法错误由 if 校验报告，此处忽略
+func runtimeReferences(key, val *yaml.Node, fn func(n *yaml.Node, ref string)) {
+	switch val.Kind {
+	case yaml.ScalarNode:
+		if (key != nil && key.Value == "if") || strings.Contains(val.Value, "${{") {
+			if ref := expr.RuntimeReference(val.Value); ref != "" {
+				fn(val, ref)
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(val.Content); i += 2 {
+			runtimeReferences(val.Content[i], val.Content[i+1], fn)
+		}
+	case yaml.SequenceNode:
+		for _, c := range val.Content {
+			runtimeReferences(nil, c, fn)
+		}
+	}
+}
+
+// withHasExpression with 中是否含运行时才能求值的表达式
+func withHasExpression(with map[string]string) bool {
+	for _, v := range with {
+		if strings.Contains(v, "${{") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// mappingValue 在映射节点中查找键，返回键节点与值节点
+func mappingValue(m *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i], m.Content[i+1]
+		}
+	}
+	return nil, nil
+}
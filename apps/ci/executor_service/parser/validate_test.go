@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// validationErrors 校验 content 并返回 ValidationErrors（校验通过时为空）
+func validationErrors(t *testing.T, content string) ValidationErrors {
+	t.Helper()
+	_, err := ValidateWorkflowYAML(content)
+	if err == nil {
+		return nil
+	}
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("error %v is not ValidationErrors", err)
+	}
+	return errs
+}
+
+func TestValidateWorkflowIfConditions(t *testing.T) {
+	errs := validationErrors(t, `jobs:
+  build:
+    if: github.ref == 'refs/heads/main' &&
+    steps:
+      - name: ok
+        if: ${{ always() }}
+        run: echo ok
+      - name: bad
+        if: ${{ contains('a') }}
+        run: echo bad
+      - name: empty
+        if: ""
+        run: echo empty
+`)
+	if len(errs) != 2 {
+		t.Fatalf("errors = %v, want 2", errs)
+	}
+	if e := errs[0]; e.Line != 3 || e.Column != 9 || !strings.Contains(e.Message, `invalid if condition of job "build"`) {
+		t.Errorf("job if error = %+v", e)
+	}
+	if e := errs[1]; e.Line != 9 || e.Column != 13 || !strings.Contains(e.Message, `invalid if condition of step "bad" in job "build": function contains() got 1 arguments`) {
+		t.Errorf("step if error = %+v", e)
+	}
+}
+
+func TestValidateWorkflowStepsContext(t *testing.T) {
+	errs := validationErrors(t, `jobs:
+  build:
+    outputs:
+      version: ${{ steps.meta.outputs.version }}
+    env:
+      V: ${{ steps.meta.outputs.version }}
+    steps:
+      - id: meta
+        run: echo "version=1.2.3" >> "$GITHUB_OUTPUT"
+      - name: use
+        if: steps.meta.outputs.version != ''
+        run: echo ${{ steps.meta.outputs.version }}
+        env:
+          V: ${{ format('v{0}', steps.meta.outputs.version) }}
+      - name: action
+        uses: org/action@v1
+        with:
+          version: ${{ steps.meta.outputs.version }}
+        continue-on-error: ${{ steps.meta.outcome == 'failure' }}
+      - name: cache
+        uses: xcoding/cache@v1
+        with:
+          key: deps-${{ steps.meta.outputs.version }}
+          path: ${{ steps.meta.outputs.dir }}
+      - name: docker
+        uses: docker://alpine:3
+        with:
+          args: ${{ steps.meta.outputs.version }}
+      - name: plain
+        run: echo "${{ github.sha }} steps.meta"
+`)
+	want := []struct {
+		line, column int
+		message      string
+	}{
+		{6, 10, `job "build" references the steps context in env: ` + runtimeRefHint("steps")},
+		{19, 28, `step "action" in job "build" references the steps context in continue-on-error: ` + runtimeRefHint("steps")},
+		{24, 17, `step "cache" in job "build" references the steps context in with.path: inputs of xcoding/cache are resolved before the job starts`},
+		{28, 17, `step "docker" in job "build" references the steps context in with.args: inputs of docker://alpine:3 are resolved before the job starts`},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %v, want %d", errs, len(want))
+	}
+	for i, w := range want {
+		if e := errs[i]; e.Line != w.line || e.Column != w.column || e.Message != w.message {
+			t.Errorf("error %d = %d:%d %q, want %d:%d %q", i, e.Line, e.Column, e.Message, w.line, w.column, w.message)
+		}
+	}
+}
+
+func TestValidateWorkflowHashFiles(t *testing.T) {
+	errs := validationErrors(t, `jobs:
+  build:
+    if: hashFiles('go.sum') != ''
+    outputs:
+      deps: ${{ hashFiles('go.sum') }}
+      version: ${{ steps.meta.outputs.version }}
+    steps:
+      - id: meta
+        if: hashFiles('go.sum') != ''
+        run: echo "version=${{ hashFiles('go.sum') }}" >> "$GITHUB_OUTPUT"
+      - uses: xcoding/cache@v1
+        with:
+          key: deps-${{ hashFiles('**/go.sum') }}
+          path: ~/go/pkg/mod
+      - uses: xcoding/upload-artifact@v1
+        with:
+          name: deps-${{ hashFiles('**/go.sum') }}
+          path: dist
+`)
+	want := []struct {
+		line, column int
+		message      string
+	}{
+		{3, 9, `job "build" uses hashFiles() in if: ` + runtimeRefHint("hashFiles")},
+		{5, 13, `job "build" uses hashFiles() in outputs: ` + runtimeRefHint("hashFiles")},
+		{17, 17, `step "Run xcoding/upload-artifact@v1" in job "build" uses hashFiles() in with.name: inputs of xcoding/upload-artifact are resolved before the job starts`},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %v, want %d", errs, len(want))
+	}
+	for i, w := range want {
+		if e := errs[i]; e.Line != w.line || e.Column != w.column || e.Message != w.message {
+			t.Errorf("error %d = %d:%d %q, want %d:%d %q", i, e.Line, e.Column, e.Message, w.line, w.column, w.message)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Workflow struct {
+	Name        string            `yaml:"name"`
+	On          Triggers          `yaml:"on"` // 触发配置
+	Env         map[string]string `yaml:"env"`
+	Concurrency *Concurrency      `yaml:"concurrency"` // 并发组，同组构建互斥
+	Jobs        map[string]Job    `yaml:"jobs"`
+}
+
+// Concurrency 并发组配置（工作流级或 Job 级）
+// 支持简写 `concurrency: <group>`，group 可包含 ${{ }} 表达式；同一项目内同组的构建或 Job 互斥，
+// 后来者等待组内进行中的构建或 Job 结束；cancel-in-progress 为 true 时改为取消组内进行中的构建或 Job
+type Concurrency struct {
+	Group            string `yaml:"group"`
+	CancelInProgress bool   `yaml:"cancel-in-progress"`
+}
+
+func (c *Concurrency) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		c.Group = value.Value
+		return nil
+	case yaml.MappingNode:
+		type plain Concurrency
+		return value.Decode((*plain)(c))
+	}
+	return fmt.Errorf("line %d: concurrency must be a string or a mapping", value.Line)
+}
+
+type Job struct {
+	Name            string             `yaml:"name"`
+	Needs           StringOrSlice      `yaml:"needs"`
+	If              string             `yaml:"if"`                // 条件表达式，false 时 Job 记为 skipped
+	Strategy        *Strategy          `yaml:"strategy"`          // 矩阵策略，非空时展开为多个子任务
+	Concurrency     *Concurrency       `yaml:"concurrency"`       // Job 级并发组，与工作流级并发组共用组名空间
+	TimeoutMinutes  float64            `yaml:"timeout-minutes"`   // Job 超时（分钟），超时后 Job 失败；未配置或为 0 时回退到已废弃的 XC_JOB_TIMEOUT_SECONDS
+	ContinueOnError BoolOrExpr         `yaml:"continue-on-error"` // Job 失败时不影响构建结论与下游（BuildJob 仍记为 failed）
+	RunsOn          RunsOn             `yaml:"runs-on"`           // 执行器类别标签，对应管理员定义的 Pod 模板；未配置时使用默认类别
+	Container       string             `yaml:"container"`
+	Services        map[string]Service `yaml:"services"` // 服务容器，键为服务名
+	Env             map[string]string  `yaml:"env"`
+	Outputs         map[string]string  `yaml:"outputs"` // Job 输出映射，值通常引用 ${{ steps.<id>.outputs.<name> }}
+	Steps           []Step             `yaml:"steps"`
+
+	// LiteralEnv Env 中取值来自触发方（INPUT_*、构建变量）的键，由执行器在合并环境变量时标记：
+	// 其值按字面注入，不做表达式替换，也不解析 secret:// 引用
+	LiteralEnv map[string]bool `yaml:"-" json:"-"`
+}
+
+// Strategy 矩阵策略
+type Strategy struct {
+	Matrix      Matrix `yaml:"matrix"`
+	FailFast    *bool  `yaml:"fail-fast"`    // 默认 true：任一子任务失败时取消其余子任务
+	MaxParallel int    `yaml:"max-parallel"` // 同时运行的子任务上限，0 表示不限
+}
+
+// FailFastEnabled 返回 fail-fast 是否生效（未配置时默认开启）
+func (s *Strategy) FailFastEnabled() bool {
+	return s != nil && (s.FailFast == nil || *s.FailFast)
+}
+
+// Matrix 矩阵定义
+// 说明：Keys 保留维度的声明顺序，用于生成稳定的子任务名；include/exclude 语义与 GitHub Actions 一致
+type Matrix struct {
+	Keys    []string
+	Values  map[string][]any
+	Include []map[string]any
+	Exclude []map[string]any
+}
+
+func (m *Matrix) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: matrix must be a mapping", value.Line)
+	}
+	m.Values = map[string][]any{}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key, val := value.Content[i].Value, value.Content[i+1]
+		switch key {
+		case "include":
+			if err := val.Decode(&m.Include); err != nil {
+				return fmt.Errorf("line %d: matrix include: %w", val.Line, err)
+			}
+		case "exclude":
+			if err := val.Decode(&m.Exclude); err != nil {
+				return fmt.Errorf("line %d: matrix exclude: %w", val.Line, err)
+			}
+		default:
+			if val.Kind != yaml.SequenceNode {
+				return fmt.Errorf("line %d: matrix %q must be a list", val.Line, key)
+			}
+			var vs []any
+			if err := val.Decode(&vs); err != nil {
+				return fmt.Errorf("line %d: matrix %q: %w", val.Line, key, err)
+			}
+			m.Keys = append(m.Keys, key)
+			m.Values[key] = vs
+		}
+	}
+	return nil
+}
+
+type Step struct {
+	ID   string            `yaml:"id"` // 步骤标识，用于 steps.<id>.outputs 引用
+	Name string            `yaml:"name"`
+	If   string            `yaml:"if"` // 条件表达式，false 时 Step 记为 skipped
+	Run  string            `yaml:"run"`
+	Uses string            `yaml:"uses"`
+	With map[string]string `yaml:"with"`
+	Env  map[string]string `yaml:"env"`
+
+	TimeoutMinutes  float64    `yaml:"timeout-minutes"`   // 单次执行超时（分钟，0 表示不限），由生成的脚本以 timeout 命令强制
+	ContinueOnError BoolOrExpr `yaml:"continue-on-error"` // 步骤失败时不计入 Job 失败；未配置时回退到已废弃的 env.XC_CONTINUE_ON_ERROR
+	Retry           *Retry     `yaml:"retry"`             // 失败重试（适用于不稳定的步骤）
+
+	// Deferred 留待 Job 运行时求值的字段（run、env.<name>、with.<name>），由执行器在表达式替换时标记：
+	// 其中的表达式引用了 steps 上下文，原样保留并由生成的脚本在步骤执行前求值
+	Deferred map[string]bool `yaml:"-" json:"-"`
+}
+
+// Retry 步骤失败重试
+// 最多重试 Max 次（共执行 Max+1 次）；第 n 次重试前等待 Backoff*2^(n-1) 秒
+type Retry struct {
+	Max     int `yaml:"max"`
+	Backoff int `yaml:"backoff"`
+}
+
+// BoolOrExpr 布尔值或 ${{ }} 表达式（如 continue-on-error: ${{ matrix.experimental }}），表达式在 Job 启动前替换
+type BoolOrExpr string
+
+// Enabled 替换表达式后的取值是否为 true
+func (b BoolOrExpr) Enabled() bool {
+	return strings.EqualFold(strings.TrimSpace(string(b)), "true")
+}
+
+// TimeoutSeconds 将 timeout-minutes 换算为秒（向上取整），未配置时返回 0
+func TimeoutSeconds(minutes float64) int64 {
+	if minutes <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(minutes * 60))
+}
+
+// RunsOn 执行器类别标签：字符串或单元素列表（一个 Job 只能选择一个类别），可包含 ${{ }} 表达式
+type RunsOn string
+
+func (r *RunsOn) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		*r = RunsOn(strings.TrimSpace(value.Value))
+		return nil
+	case yaml.SequenceNode:
+		if len(value.Content) == 1 && value.Content[0].Kind == yaml.ScalarNode {
+			*r = RunsOn(strings.TrimSpace(value.Content[0].Value))
+			return nil
+		}
+	}
+	return fmt.Errorf("line %d: runs-on must be a single runner class label", value.Line)
+}
+
+// StringOrSlice 处理可以是单个字符串或字符串列表的 YAML 字段。
+// 它还支持空格分隔的字符串以实现向后兼容。
+type StringOrSlice []string
+
+func (s *StringOrSlice) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		// 处理空格分隔的字符串以实现向后兼容（例如 "job1 job2"）
+		*s = strings.Fields(value.Value)
+		return nil
+	}
+	if value.Kind == yaml.SequenceNode {
+		var temp []string
+		if err := value.Decode(&temp); err != nil {
+			return err
+		}
+		*s = temp
+		return nil
+	}
+	return fmt.Errorf("expected string or list of strings")
+}
+
+func ParseWorkflowYAML(content string) (*Workflow, error) {
+	var wf Workflow
+	if err := yaml.Unmarshal([]byte(content), &wf); err != nil {
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	for name, j := range wf.Jobs {
+		for i := range j.Steps {
+			if j.Steps[i].Name == "" {
+				j.Steps[i].Name = defaultStepName(j.Steps[i], i)
+			}
+		}
+		wf.Jobs[name] = j
+	}
+	return &wf, nil
+}
+
+// defaultStepName 为未命名步骤生成名称（与 GitHub 一致：Run <命令首行>/Run <action>）
+// 说明：日志标记与 BuildStep 均按步骤名关联，步骤名不能为空
+func defaultStepName(st Step, idx int) string {
+	if run := strings.TrimSpace(st.Run); run != "" {
+		return "Run " + strings.TrimSpace(strings.SplitN(run, "\n", 2)[0])
+	}
+	if uses := strings.TrimSpace(st.Uses); uses != "" {
+		return "Run " + uses
+	}
+	return fmt.Sprintf("Step %d", idx+1)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"time"
 
@@ -16,9 +17,12 @@ import (
 	"xcoding/apps/ci/pipeline_service/internal/grpc/interceptors"
 	"xcoding/apps/ci/pipeline_service/internal/models"
 	"xcoding/apps/ci/pipeline_service/internal/service"
+	"xcoding/apps/ci/pipeline_service/internal/webhook"
 	civ1 "xcoding/gen/go/ci/v1"
 	civ1exec "xcoding/gen/go/ci/v1"
+	coderepositoryv1 "xcoding/gen/go/code_repository/v1"
 	projectv1 "xcoding/gen/go/project/v1"
+	"xcoding/pkg/auth"
 	cddb "xcoding/pkg/db"
 	"xcoding/pkg/server"
 )
@@ -52,6 +56,7 @@ func main() {
 	if err := gormDB.AutoMigrate(
 		&models.Pipeline{},
 		&models.PipelineSchedule{},
+		&models.WebhookDelivery{},
 	); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
@@ -80,8 +85,21 @@ func main() {
 	}
 	executorClient := civ1exec.NewExecutorServiceClient(executorConn)
 
+	// 拨号 CodeRepository 服务（Webhook 解析仓库与签名密钥）
+	codeRepoAddr := fmt.Sprintf("%s:%d", cfg.CodeRepository.Address, cfg.CodeRepository.Port)
+	codeRepoConn, err := grpc.DialContext(
+		context.Background(),
+		codeRepoAddr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(auth.ServiceTokenClientInterceptor(cfg.CodeRepository.ServiceToken)),
+	)
+	if err != nil {
+		log.Fatalf("Failed to dial CodeRepository service: %v", err)
+	}
+	codeRepoClient := coderepositoryv1.NewCodeRepositoryServiceClient(codeRepoConn)
+
 	// 初始化服务
-	svc := service.NewPipelineService(gormDB.GetDB(), projectClient, executorClient)
+	svc := service.NewPipelineService(gormDB.GetDB(), projectClient, executorClient, codeRepoClient)
 
 	var rmqClose func(context.Context) error
 	{
@@ -107,6 +125,20 @@ func main() {
 		}
 	}
 
+	// 启动定时调度（依赖构建队列；多副本通过行锁认领，互不重复触发）
+	schedCtx, schedCancel := context.WithCancel(context.Background())
+	defer schedCancel()
+	if cfg.Schedule.Enabled && rmqClose != nil {
+		runner := service.NewScheduleRunner(gormDB.GetDB(), service.ScheduleRunnerOptions{
+			Interval:     time.Duration(cfg.Schedule.IntervalSeconds) * time.Second,
+			MisfireGrace: time.Duration(cfg.Schedule.MisfireGraceSeconds) * time.Second,
+		})
+		go runner.Run(schedCtx)
+		log.Printf("定时调度已启用")
+	} else {
+		log.Printf("定时调度未启用")
+	}
+
 	// 计算地址
 	grpcAddr := fmt.Sprintf("%s:%d", cfg.GRPC.Address, cfg.GRPC.Port)
 	httpAddr := fmt.Sprintf("%s:%d", cfg.HTTP.Address, cfg.HTTP.Port)
@@ -134,7 +166,11 @@ func main() {
 	if err := civ1.RegisterPipelineServiceHandler(context.Background(), mux, conn); err != nil {
 		log.Fatalf("Failed to register pipeline service handler: %v", err)
 	}
-	httpServer := server.StartHTTPServerDefault(httpAddr, mux)
+	// Webhook 入口不经过网关（需要原始请求体验签），其余路径交给网关
+	rootMux := http.NewServeMux()
+	rootMux.Handle("/ci_service/api/v1/webhooks/git", webhook.NewHandler(svc))
+	rootMux.Handle("/", mux)
+	httpServer := server.StartHTTPServerDefault(httpAddr, rootMux)
 
 	// 优雅关闭
 	// 优雅关闭：数据库 + 队列
@@ -143,6 +179,7 @@ func main() {
 			grpcServer,
 			httpServer,
 			30*time.Second,
+			func(ctx context.Context) error { schedCancel(); return nil },
 			func(ctx context.Context) error { return gormDB.Close() },
 			rmqClose,
 		)
@@ -8,18 +8,20 @@ import (
 )
 
 type Config struct {
-	Database DatabaseConfig       `mapstructure:"database"`
-	GRPC     GRPCConfig           `mapstructure:"grpc"`
-	HTTP     HTTPConfig           `mapstructure:"http"`
-	Log      LogConfig            `mapstructure:"log"`
-	Metrics  MetricsConfig        `mapstructure:"metrics"`
-	Health   HealthConfig         `mapstructure:"health"`
-	Auth     AuthConfig           `mapstructure:"auth"`
-	App      AppConfig            `mapstructure:"app"`
-	Logging  LoggingConfig        `mapstructure:"logging"`
-	Project  ProjectClientConfig  `mapstructure:"project"`
-	Executor ExecutorClientConfig `mapstructure:"executor"`
-	Queue    QueueConfig          `mapstructure:"queue"`
+	Database       DatabaseConfig             `mapstructure:"database"`
+	GRPC           GRPCConfig                 `mapstructure:"grpc"`
+	HTTP           HTTPConfig                 `mapstructure:"http"`
+	Log            LogConfig                  `mapstructure:"log"`
+	Metrics        MetricsConfig              `mapstructure:"metrics"`
+	Health         HealthConfig               `mapstructure:"health"`
+	Auth           AuthConfig                 `mapstructure:"auth"`
+	App            AppConfig                  `mapstructure:"app"`
+	Logging        LoggingConfig              `mapstructure:"logging"`
+	Project        ProjectClientConfig        `mapstructure:"project"`
+	Executor       ExecutorClientConfig       `mapstructure:"executor"`
+	Queue          QueueConfig                `mapstructure:"queue"`
+	Schedule       ScheduleConfig             `mapstructure:"schedule"`
+	CodeRepository CodeRepositoryClientConfig `mapstructure:"code_repository"`
 }
 
 type DatabaseConfig struct {
@@ -90,6 +92,14 @@ type ExecutorClientConfig struct {
 	Port    int    `mapstructure:"port"`
 }
 
+// CodeRepository 客户端配置（Webhook 按仓库地址解析仓库与签名密钥）
+// ServiceToken 为服务间共享令牌（INTERNAL_SERVICE_TOKEN），VerifyWebhook 为内部接口须携带
+type CodeRepositoryClientConfig struct {
+	Address      string `mapstructure:"address"`
+	Port         int    `mapstructure:"port"`
+	ServiceToken string `mapstructure:"service_token"`
+}
+
 // 队列配置（RabbitMQ等）
 type QueueConfig struct {
 	Enabled bool   `mapstructure:"enabled"`
@@ -97,6 +107,13 @@ type QueueConfig struct {
 	Queue   string `mapstructure:"queue"`
 }
 
+// 定时调度配置（cron 计划扫描）
+type ScheduleConfig struct {
+	Enabled             bool `mapstructure:"enabled"`
+	IntervalSeconds     int  `mapstructure:"interval_seconds"`      // 扫描周期
+	MisfireGraceSeconds int  `mapstructure:"misfire_grace_seconds"` // 错过触发点的容忍时长，超出则跳过
+}
+
 func Load() (*Config, error) {
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -117,12 +134,21 @@ func Load() (*Config, error) {
 	viper.BindEnv("project.port", "PROJECT_GRPC_PORT")
 	viper.BindEnv("executor.address", "EXECUTOR_GRPC_ADDRESS")
 	viper.BindEnv("executor.port", "EXECUTOR_GRPC_PORT")
+	viper.BindEnv("code_repository.address", "CODE_REPOSITORY_GRPC_ADDRESS")
+	viper.BindEnv("code_repository.port", "CODE_REPOSITORY_GRPC_PORT")
+	viper.BindEnv("code_repository.service_token", "INTERNAL_SERVICE_TOKEN")
 
 	// 队列（RabbitMQ）配置
 	viper.BindEnv("queue.enabled", "RABBITMQ_ENABLED")
 	viper.BindEnv("queue.url", "RABBITMQ_URL")
 	viper.BindEnv("queue.queue", "RABBITMQ_QUEUE")
 
+	// 定时调度配置（默认启用；依赖构建队列）
+	viper.SetDefault("schedule.enabled", true)
+	viper.BindEnv("schedule.enabled", "SCHEDULE_ENABLED")
+	viper.BindEnv("schedule.interval_seconds", "SCHEDULE_INTERVAL_SECONDS")
+	viper.BindEnv("schedule.misfire_grace_seconds", "SCHEDULE_MISFIRE_GRACE_SECONDS")
+
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("解析配置失败: %w", err)
@@ -0,0 +1,120 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q): expected error", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	newYork, _ := time.LoadLocation("America/New_York")
+	cases := []struct {
+		spec string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			spec: "*/15 * * * *",
+			from: time.Date(2024, 1, 1, 10, 7, 30, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC),
+				time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			// 严格晚于起点：恰好位于触发点时取下一次
+			spec: "0 2 * * *",
+			from: time.Date(2024, 1, 1, 2, 0, 0, 0, shanghai),
+			want: []time.Time{time.Date(2024, 1, 2, 2, 0, 0, 0, shanghai)},
+		},
+		{
+			spec: "30 9 * * MON-FRI",
+			from: time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC), // 周五
+			want: []time.Time{
+				time.Date(2024, 1, 8, 9, 30, 0, 0, time.UTC),
+				time.Date(2024, 1, 9, 9, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			// 日与周同时受限：取并集
+			spec: "0 0 13 * 5",
+			from: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2024, 9, 6, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 9, 13, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 9, 20, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "0 0 29 2 *",
+			from: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			spec: "@weekly",
+			from: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			spec: "0 12 * * 7",
+			from: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{time.Date(2024, 1, 7, 12, 0, 0, 0, time.UTC)},
+		},
+		{
+			// 夏令时开始：02:30 不存在，当日不触发
+			spec: "30 2 * * *",
+			from: time.Date(2024, 3, 9, 12, 0, 0, 0, newYork),
+			want: []time.Time{time.Date(2024, 3, 11, 2, 30, 0, 0, newYork)},
+		},
+		{
+			// 夏令时结束：01:30 出现两次，仅触发一次
+			spec: "30 1 * * *",
+			from: time.Date(2024, 11, 3, 1, 30, 0, 0, newYork),
+			want: []time.Time{time.Date(2024, 11, 4, 1, 30, 0, 0, newYork)},
+		},
+		{
+			spec: "0 0 30 2 *",
+			from: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: nil,
+		},
+	}
+	for _, tc := range cases {
+		s, err := Parse(tc.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tc.spec, err)
+		}
+		got := s.NextN(tc.from, len(tc.want))
+		if len(tc.want) == 0 {
+			if next := s.Next(tc.from); !next.IsZero() {
+				t.Errorf("%q: expected no run time, got %v", tc.spec, next)
+			}
+			continue
+		}
+		for i := range tc.want {
+			if i >= len(got) || !got[i].Equal(tc.want[i]) {
+				t.Errorf("%q from %v: got %v, want %v", tc.spec, tc.from, got, tc.want)
+				break
+			}
+		}
+	}
+}
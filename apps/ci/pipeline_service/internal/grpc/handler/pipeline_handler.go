@@ -32,3 +32,16 @@ func (h *PipelineGRPCHandler) UpdatePipeline(ctx context.Context, req *civ1.Upda
 func (h *PipelineGRPCHandler) DeletePipeline(ctx context.Context, req *civ1.DeletePipelineRequest) (*civ1.DeletePipelineResponse, error) {
 	return h.pipelineService.DeletePipeline(ctx, req)
 }
+
+func (h *PipelineGRPCHandler) LintPipelineTriggers(ctx context.Context, req *civ1.LintPipelineTriggersRequest) (*civ1.LintPipelineTriggersResponse, error) {
+	return h.pipelineService.LintPipelineTriggers(ctx, req)
+}
+
+// Webhook 投递记录的 gRPC 接口（接收入口为独立的 HTTP 处理器）
+func (h *PipelineGRPCHandler) ListWebhookDeliveries(ctx context.Context, req *civ1.ListWebhookDeliveriesRequest) (*civ1.ListWebhookDeliveriesResponse, error) {
+	return h.pipelineService.ListWebhookDeliveries(ctx, req)
+}
+
+func (h *PipelineGRPCHandler) ReplayWebhookDelivery(ctx context.Context, req *civ1.ReplayWebhookDeliveryRequest) (*civ1.ReplayWebhookDeliveryResponse, error) {
+	return h.pipelineService.ReplayWebhookDelivery(ctx, req)
+}
@@ -7,6 +7,12 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// 定时计划来源
+const (
+	ScheduleSourceAPI  = "api"  // 通过接口创建，可编辑
+	ScheduleSourceYAML = "yaml" // 由工作流 on.schedule 同步，随 YAML 增删，接口只读
+)
+
 // PipelineSchedule 表示流水线的定时（cron）计划（与 proto 保持一致）
 type PipelineSchedule struct {
 	ID              uint64 `gorm:"primaryKey;autoIncrement"`
@@ -14,9 +20,11 @@ type PipelineSchedule struct {
 	Cron            string `gorm:"size:128"`
 	Timezone        string `gorm:"size:64;default:UTC"`
 	Enabled         bool   `gorm:"default:true"`
+	Source          string `gorm:"size:16;default:api;index"`
 	LastTriggeredAt *time.Time
-	CreatedAt       time.Time `gorm:"autoCreateTime"`
-	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
+	NextRunAt       *time.Time `gorm:"index"` // 下次触发时间；调度循环据此认领到期计划，推进后不会重复触发
+	CreatedAt       time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time  `gorm:"autoUpdateTime"`
 }
 
 func (s *PipelineSchedule) FromProto(ps *civ1.PipelineSchedule) {
@@ -28,6 +36,7 @@ func (s *PipelineSchedule) FromProto(ps *civ1.PipelineSchedule) {
 	s.Cron = ps.GetCron()
 	s.Timezone = ps.GetTimezone()
 	s.Enabled = ps.GetEnabled()
+	s.Source = ps.GetSource()
 	if ps.GetCreatedAt() != nil {
 		s.CreatedAt = ps.GetCreatedAt().AsTime()
 	}
@@ -38,6 +47,10 @@ func (s *PipelineSchedule) FromProto(ps *civ1.PipelineSchedule) {
 		t := ps.GetLastTriggeredAt().AsTime()
 		s.LastTriggeredAt = &t
 	}
+	if ps.GetNextRunAt() != nil {
+		t := ps.GetNextRunAt().AsTime()
+		s.NextRunAt = &t
+	}
 }
 
 func (s *PipelineSchedule) ToProto() *civ1.PipelineSchedule {
@@ -50,11 +63,15 @@ func (s *PipelineSchedule) ToProto() *civ1.PipelineSchedule {
 		Cron:       s.Cron,
 		Timezone:   s.Timezone,
 		Enabled:    s.Enabled,
+		Source:     s.Source,
 		CreatedAt:  timestamppb.New(s.CreatedAt),
 		UpdatedAt:  timestamppb.New(s.UpdatedAt),
 	}
 	if s.LastTriggeredAt != nil {
 		ps.LastTriggeredAt = timestamppb.New(*s.LastTriggeredAt)
 	}
+	if s.NextRunAt != nil {
+		ps.NextRunAt = timestamppb.New(*s.NextRunAt)
+	}
 	return ps
 }
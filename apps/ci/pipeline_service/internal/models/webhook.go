@@ -0,0 +1,82 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+	civ1 "xcoding/gen/go/ci/v1"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Webhook 投递处理状态
+const (
+	WebhookStatusReceived     = "received"      // 已接收，处理中
+	WebhookStatusIgnored      = "ignored"       // 非推送事件或分支/标签删除，不处理
+	WebhookStatusNoRepository = "no_repository" // 未找到对应的代码仓库
+	WebhookStatusUnauthorized = "unauthorized"  // 签名校验失败
+	WebhookStatusNoMatch      = "no_match"      // 没有流水线的 on.push 命中
+	WebhookStatusTriggered    = "triggered"     // 已触发构建
+	WebhookStatusFailed       = "failed"        // 处理失败（解析负载、创建构建等）
+)
+
+// WebhookDelivery 记录每次入站 Webhook 投递（含请求头与负载，用于排查与重放）
+// 负载仅在验签通过后保存；已验签的非重放投递按 (provider, delivery_id) 唯一，平台重复投递时不再触发构建
+type WebhookDelivery struct {
+	ID           uint64 `gorm:"primaryKey;autoIncrement"`
+	Provider     string `gorm:"size:16;uniqueIndex:ux_webhook_delivery,priority:1,where:verified AND replay_of = 0 AND delivery_id <> ''"`
+	Event        string `gorm:"size:64"`
+	DeliveryID   string `gorm:"size:128;index;uniqueIndex:ux_webhook_delivery,priority:2"`
+	RepositoryID uint64 `gorm:"index"`
+	ProjectID    uint64 `gorm:"index"`
+	Ref          string `gorm:"size:255"`
+	CommitSHA    string `gorm:"size:64"`
+	Verified     bool
+	Status       string `gorm:"size:32;index"`
+	Message      string `gorm:"type:text"`
+	BuildIDs     string `gorm:"type:text"` // JSON 数组
+	Headers      string `gorm:"type:text"` // JSON 对象（仅平台相关请求头）
+	Payload      string `gorm:"type:text"`
+	ReplayOf     uint64
+	CreatedAt    time.Time `gorm:"autoCreateTime;index"`
+}
+
+// SetBuildIDs 以 JSON 保存触发的构建 ID
+func (d *WebhookDelivery) SetBuildIDs(ids []uint64) {
+	if len(ids) == 0 {
+		d.BuildIDs = ""
+		return
+	}
+	b, _ := json.Marshal(ids)
+	d.BuildIDs = string(b)
+}
+
+// GetBuildIDs 解析触发的构建 ID
+func (d *WebhookDelivery) GetBuildIDs() []uint64 {
+	var ids []uint64
+	if d.BuildIDs != "" {
+		_ = json.Unmarshal([]byte(d.BuildIDs), &ids)
+	}
+	return ids
+}
+
+func (d *WebhookDelivery) ToProto() *civ1.WebhookDelivery {
+	if d == nil {
+		return nil
+	}
+	return &civ1.WebhookDelivery{
+		Id:           d.ID,
+		Provider:     d.Provider,
+		Event:        d.Event,
+		DeliveryId:   d.DeliveryID,
+		RepositoryId: d.RepositoryID,
+		ProjectId:    d.ProjectID,
+		Ref:          d.Ref,
+		CommitSha:    d.CommitSHA,
+		Verified:     d.Verified,
+		Status:       d.Status,
+		Message:      d.Message,
+		BuildIds:     d.GetBuildIDs(),
+		ReplayOf:     d.ReplayOf,
+		CreatedAt:    timestamppb.New(d.CreatedAt),
+	}
+}
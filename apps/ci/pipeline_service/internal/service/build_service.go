@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 
 	"crypto/sha256"
 	execmodels "xcoding/apps/ci/executor_service/models"
+	"xcoding/apps/ci/executor_service/parser"
 	"xcoding/apps/ci/pipeline_service/internal/models"
 	civ1 "xcoding/gen/go/ci/v1"
 )
@@ -43,30 +46,47 @@ func (s *pipelineService) StartPipelineBuild(ctx context.Context, req *civ1.Star
 	}
 
 	// 校验变量：仅允许非空键与字符串值；限制映射大小
-	validatedVars, verr := validateBuildVariables(req.GetVariables())
+	// 工作流声明了 on.workflow_dispatch.inputs 时，同名变量按输入定义校验类型并补全默认值
+	var dispatch []parser.DispatchInput
+	if wf, err := parser.ParseWorkflowYAML(p.WorkflowYAML); err == nil {
+		dispatch = wf.On.Dispatch
+	}
+	validatedVars, inputs, verr := validateBuildVariables(req.GetVariables(), dispatch)
 	if verr != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "invalid variables: %v", verr)
+		return nil, invalidVariablesError(verr)
+	}
+
+	triggeredBy := req.GetTriggeredBy()
+	if triggeredBy == "" {
+		username, err := getUsernameFromCtx(ctx)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to get username: %v", err)
+		}
+		triggeredBy = username
+	}
+	b, err := s.startBuild(ctx, &p, triggeredBy, req.GetCommitSha(), req.GetBranch(), validatedVars, inputs)
+	if err != nil {
+		return nil, err
 	}
+	return &civ1.StartPipelineBuildResponse{Build: b.ToProto()}, nil
+}
 
+// startBuild 创建构建记录与 YAML 快照并投递到构建队列
+// 手动触发与定时触发（TriggeredBy="schedule:<id>"）共用此路径；inputs 仅手动触发时提供，随快照保存
+func (s *pipelineService) startBuild(ctx context.Context, p *models.Pipeline, triggeredBy, commitSHA, branch string, vars, inputs map[string]string) (*execmodels.Build, error) {
 	// 直接在数据库创建构建记录（不再调用 Executor RPC）
 	now := time.Now()
 	b := execmodels.Build{
 		PipelineID:  p.ID,
+		ProjectID:   p.ProjectID,
 		Name:        p.Name,
 		Status:      int32(civ1.BuildStatus_BUILD_STATUS_PENDING),
-		TriggeredBy: req.GetTriggeredBy(),
-		CommitSHA:   req.GetCommitSha(),
-		Branch:      req.GetBranch(),
+		TriggeredBy: triggeredBy,
+		CommitSHA:   commitSHA,
+		Branch:      branch,
+		Variables:   toJSONMap(vars),
 		CreatedAt:   now,
 	}
-	if b.TriggeredBy == "" {
-		username, err := getUsernameFromCtx(ctx)
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "failed to get username: %v", err)
-		}
-		b.TriggeredBy = username
-	}
-
 	if err := s.db.WithContext(ctx).Create(&b).Error; err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to create build: %v", err)
 	}
@@ -77,53 +97,93 @@ func (s *pipelineService) StartPipelineBuild(ctx context.Context, req *civ1.Star
 		Name:         b.Name,
 		WorkflowYAML: p.WorkflowYAML,
 		YamlSHA256:   sha256Hex(p.WorkflowYAML),
+		Variables:    toJSONMap(vars),
+		Inputs:       toJSONMap(inputs),
 		CreatedAt:    now,
 	}
 	if err := s.db.WithContext(ctx).Create(&snap).Error; err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to create snapshot: %v", err)
 	}
 
-	created := b.ToProto()
-
 	q := getBuildQueue()
 	if q == nil {
 		return nil, status.Errorf(codes.FailedPrecondition, "build queue not configured")
 	}
 	if e := q.Enqueue(ctx, BuildJob{
-		BuildID:    created.GetId(),
+		BuildID:    b.ID,
 		PipelineID: p.ID,
 		ProjectID:  p.ProjectID,
-		CommitSHA:  created.GetCommitSha(),
-		Branch:     created.GetBranch(),
-		Variables:  validatedVars,
+		CommitSHA:  b.CommitSHA,
+		Branch:     b.Branch,
+		Variables:  vars,
 	}); e != nil {
 		return nil, status.Errorf(codes.Internal, "enqueue failed: %v", e)
 	}
-	return &civ1.StartPipelineBuildResponse{Build: created}, nil
+	return &b, nil
 }
 
-// validateBuildVariables 保证变量为字符串并控制合理上限
-func validateBuildVariables(in map[string]string) (map[string]string, error) {
-	if len(in) == 0 {
-		return nil, nil
+// toJSONMap 将字符串映射转换为 jsonb 列的值；空映射返回 nil
+func toJSONMap(m map[string]string) datatypes.JSONMap {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(datatypes.JSONMap, len(m))
+	for k, v := range m {
+		out[k] = v
 	}
+	return out
+}
+
+// validateBuildVariables 保证变量为字符串并控制合理上限；按 workflow_dispatch 输入定义解析输入
+// 未声明为输入的变量由执行器注入 Job 环境；键不是合法环境变量名时仍随构建保存，仅注入时跳过（执行器记录告警）
+// 返回的 inputs 仅包含声明过的输入（缺省时取默认值）；输入错误类型为 parser.InputErrors
+func validateBuildVariables(in map[string]string, dispatch []parser.DispatchInput) (vars, inputs map[string]string, err error) {
 	if len(in) > 100 {
-		return nil, errors.New("too many variables; max 100")
+		return nil, nil, errors.New("too many variables; max 100")
+	}
+	if len(in) > 0 {
+		vars = make(map[string]string, len(in))
 	}
-	out := make(map[string]string, len(in))
 	for k, v := range in {
 		if k == "" {
-			return nil, errors.New("empty variable key")
+			return nil, nil, errors.New("empty variable key")
 		}
 		if len(k) > 128 {
-			return nil, errors.New("variable key too long")
+			return nil, nil, errors.New("variable key too long")
 		}
 		if len(v) > 4096 {
-			return nil, errors.New("variable value too long")
+			return nil, nil, errors.New("variable value too long")
 		}
-		out[k] = v
+		vars[k] = v
+	}
+	if len(dispatch) == 0 {
+		return vars, nil, nil
+	}
+	inputs, err = parser.ResolveDispatchInputs(dispatch, vars)
+	if err != nil {
+		return nil, nil, err
+	}
+	return vars, inputs, nil
+}
+
+// invalidVariablesError 将变量校验错误转换为 InvalidArgument；输入错误附带 BadRequest 字段级详情（variables.<输入名>）
+func invalidVariablesError(verr error) error {
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("invalid variables: %v", verr))
+	var ies parser.InputErrors
+	if !errors.As(verr, &ies) {
+		return st.Err()
+	}
+	br := &errdetails.BadRequest{}
+	for _, ie := range ies {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       "variables." + ie.Input,
+			Description: ie.Message,
+		})
+	}
+	if ds, err := st.WithDetails(br); err == nil {
+		st = ds
 	}
-	return out, nil
+	return st.Err()
 }
 
 // 队列与执行器最小接口及访问方法
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"xcoding/apps/ci/executor_service/parser"
+	"xcoding/apps/ci/pipeline_service/internal/models"
+	civ1 "xcoding/gen/go/ci/v1"
+)
+
+func TestStartPipelineBuildInvalidInputs(t *testing.T) {
+	db := newTestDB(t, &models.Pipeline{})
+	s := &pipelineService{db: db}
+	p := models.Pipeline{ProjectID: 1, Name: "deploy", WorkflowYAML: `on:
+  workflow_dispatch:
+    inputs:
+      env:
+        type: choice
+        options: [dev, prod]
+      count:
+        type: number
+        required: true
+jobs:
+  a:
+    steps: [{run: a}]
+`}
+	if err := db.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.StartPipelineBuild(adminContext(), &civ1.StartPipelineBuildRequest{PipelineId: p.ID, Variables: map[string]string{"env": "staging"}})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("error = %v, want InvalidArgument", err)
+	}
+	var violations [][2]string
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				violations = append(violations, [2]string{v.GetField(), v.GetDescription()})
+			}
+		}
+	}
+	want := [][2]string{
+		{"variables.env", `"staging" is not one of [dev, prod]`},
+		{"variables.count", "is required"},
+	}
+	if len(violations) != len(want) {
+		t.Fatalf("field violations = %q, want %q", violations, want)
+	}
+	for i := range want {
+		if violations[i] != want[i] {
+			t.Errorf("field violation %d = %q, want %q", i, violations[i], want[i])
+		}
+	}
+}
+
+func TestValidateBuildVariables(t *testing.T) {
+	// 不是合法环境变量名的键同样接受：随构建保存，由执行器在注入时跳过
+	in := map[string]string{"app.version": "1.0", "DEPLOY_ENV": "prod", "env": "dev"}
+	vars, inputs, err := validateBuildVariables(in, []parser.DispatchInput{{Name: "env", Type: parser.InputTypeString}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 3 || vars["app.version"] != "1.0" {
+		t.Errorf("vars = %v, want all supplied keys", vars)
+	}
+	if len(inputs) != 1 || inputs["env"] != "dev" {
+		t.Errorf("inputs = %v, want env=dev", inputs)
+	}
+	for _, bad := range []map[string]string{{"": "x"}, {strings.Repeat("k", 129): "x"}, {"K": strings.Repeat("v", 4097)}} {
+		if _, _, err := validateBuildVariables(bad, nil); err == nil {
+			t.Errorf("validateBuildVariables(%.20v) accepted", bad)
+		}
+	}
+}
@@ -2,13 +2,18 @@ package service
 
 import (
 	"context"
+	"log"
+	"net/http"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"gorm.io/gorm"
 
+	"xcoding/apps/ci/executor_service/parser"
 	"xcoding/apps/ci/pipeline_service/internal/models"
+	"xcoding/apps/ci/pipeline_service/internal/webhook"
 	civ1 "xcoding/gen/go/ci/v1"
+	coderepositoryv1 "xcoding/gen/go/code_repository/v1"
 	projectv1 "xcoding/gen/go/project/v1"
 	"xcoding/pkg/auth"
 )
@@ -27,16 +32,22 @@ type PipelineService interface {
 	DeleteSchedule(ctx context.Context, req *civ1.DeletePipelineScheduleRequest) (*civ1.DeletePipelineScheduleResponse, error)
 
 	StartPipelineBuild(ctx context.Context, req *civ1.StartPipelineBuildRequest) (*civ1.StartPipelineBuildResponse, error)
+	LintPipelineTriggers(ctx context.Context, req *civ1.LintPipelineTriggersRequest) (*civ1.LintPipelineTriggersResponse, error)
+
+	ReceiveWebhook(ctx context.Context, d *webhook.Delivery, header http.Header) (*models.WebhookDelivery, error)
+	ListWebhookDeliveries(ctx context.Context, req *civ1.ListWebhookDeliveriesRequest) (*civ1.ListWebhookDeliveriesResponse, error)
+	ReplayWebhookDelivery(ctx context.Context, req *civ1.ReplayWebhookDeliveryRequest) (*civ1.ReplayWebhookDeliveryResponse, error)
 }
 
 type pipelineService struct {
-	db             *gorm.DB
-	projectClient  projectv1.ProjectServiceClient
-	executorClient civ1.ExecutorServiceClient
+	db                   *gorm.DB
+	projectClient        projectv1.ProjectServiceClient
+	executorClient       civ1.ExecutorServiceClient
+	codeRepositoryClient coderepositoryv1.CodeRepositoryServiceClient
 }
 
-func NewPipelineService(db *gorm.DB, projectClient projectv1.ProjectServiceClient, executorClient civ1.ExecutorServiceClient) PipelineService {
-	return &pipelineService{db: db, projectClient: projectClient, executorClient: executorClient}
+func NewPipelineService(db *gorm.DB, projectClient projectv1.ProjectServiceClient, executorClient civ1.ExecutorServiceClient, codeRepositoryClient coderepositoryv1.CodeRepositoryServiceClient) PipelineService {
+	return &pipelineService{db: db, projectClient: projectClient, executorClient: executorClient, codeRepositoryClient: codeRepositoryClient}
 }
 
 // ==== 权限辅助函数 ====
@@ -44,6 +55,43 @@ func getUserIDFromCtx(ctx context.Context) (uint64, error)   { return auth.GetUs
 func getUsernameFromCtx(ctx context.Context) (string, error) { return auth.GetUsernameFromCtx(ctx) }
 func isUserRoleSuperAdmin(ctx context.Context) bool          { return auth.IsUserRoleSuperAdmin(ctx) }
 
+// validateWorkflow 校验工作流 YAML（needs 引用、依赖环、重复步骤名、runs-on 类别、on.schedule 等），空内容不校验（返回 nil, nil）
+// 错误信息包含行列号，便于前端定位
+func (s *pipelineService) validateWorkflow(ctx context.Context, content string) (*parser.Workflow, error) {
+	if content == "" {
+		return nil, nil
+	}
+	wf, err := parser.ValidateWorkflowYAMLWith(content, s.validateOptions(ctx))
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid workflow: %v", err)
+	}
+	if err := validateWorkflowSchedules(wf); err != nil {
+		return nil, err
+	}
+	return wf, nil
+}
+
+// validateOptions 从执行器读取已定义的执行器类别用于校验 runs-on；执行器不可用时跳过该项，由执行器在出队时校验
+func (s *pipelineService) validateOptions(ctx context.Context) parser.ValidateOptions {
+	if s.executorClient == nil {
+		return parser.ValidateOptions{}
+	}
+	resp, err := s.executorClient.ListRunnerClasses(ctx, &civ1.ListRunnerClassesRequest{})
+	if err != nil {
+		log.Printf("pipeline: list runner classes: %v", err)
+		return parser.ValidateOptions{}
+	}
+	// 未定义任何类别时不校验 runs-on
+	if len(resp.GetData()) == 0 {
+		return parser.ValidateOptions{}
+	}
+	labels := make([]string, 0, len(resp.GetData()))
+	for _, c := range resp.GetData() {
+		labels = append(labels, c.GetLabel())
+	}
+	return parser.ValidateOptions{RunnerLabels: labels}
+}
+
 func (s *pipelineService) isMemberOrHigher(ctx context.Context, projectID uint64, actorID uint64) (bool, error) {
 	resp, err := s.projectClient.GetProject(ctx, &projectv1.GetProjectRequest{ProjectId: projectID})
 	if err != nil {
@@ -126,6 +174,10 @@ func (s *pipelineService) CreatePipeline(ctx context.Context, req *civ1.CreatePi
 	} else {
 		return nil, status.Errorf(codes.AlreadyExists, "pipeline already exists in project")
 	}
+	wf, err := s.validateWorkflow(ctx, req.GetWorkflowYaml())
+	if err != nil {
+		return nil, err
+	}
 
 	m := models.Pipeline{
 		ProjectID:    projectID,
@@ -134,9 +186,18 @@ func (s *pipelineService) CreatePipeline(ctx context.Context, req *civ1.CreatePi
 		WorkflowYAML: req.GetWorkflowYaml(),
 		IsActive:     req.GetIsActive(),
 	}
-	// 显式选择字段以确保布尔值 false 被正确持久化
-	if err := s.db.WithContext(ctx).Select("ProjectID", "Name", "Description", "WorkflowYAML", "IsActive").Create(&m).Error; err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to create pipeline: %v", err)
+	// 显式选择字段以确保布尔值 false 被正确持久化；同一事务内同步 on.schedule
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("ProjectID", "Name", "Description", "WorkflowYAML", "IsActive").Create(&m).Error; err != nil {
+			return status.Errorf(codes.Internal, "failed to create pipeline: %v", err)
+		}
+		if wf != nil {
+			return syncWorkflowSchedules(tx, m.ID, wf)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return &civ1.CreatePipelineResponse{Pipeline: m.ToProto()}, nil
 }
@@ -236,14 +297,28 @@ func (s *pipelineService) UpdatePipeline(ctx context.Context, req *civ1.UpdatePi
 	if v := req.GetProjectId(); v != 0 {
 		m.ProjectID = v
 	}
+	var wf *parser.Workflow
 	if v := req.GetWorkflowYaml(); v != "" {
+		if wf, err = s.validateWorkflow(ctx, v); err != nil {
+			return nil, err
+		}
 		m.WorkflowYAML = v
 	}
 	// 保留显式的 false 值
 	m.IsActive = req.GetIsActive()
 
-	if err := s.db.WithContext(ctx).Save(&m).Error; err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to update pipeline: %v", err)
+	// YAML 变更时在同一事务内同步 on.schedule
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&m).Error; err != nil {
+			return status.Errorf(codes.Internal, "failed to update pipeline: %v", err)
+		}
+		if wf != nil {
+			return syncWorkflowSchedules(tx, m.ID, wf)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return &civ1.UpdatePipelineResponse{Pipeline: m.ToProto()}, nil
 }
@@ -268,8 +343,18 @@ func (s *pipelineService) DeletePipeline(ctx context.Context, req *civ1.DeletePi
 			return nil, err
 		}
 	}
-	if err := s.db.WithContext(ctx).Delete(&m).Error; err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to delete pipeline: %v", err)
+	// 连同定时计划一并删除，避免调度循环触发已不存在的流水线
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("pipeline_id = ?", m.ID).Delete(&models.PipelineSchedule{}).Error; err != nil {
+			return status.Errorf(codes.Internal, "failed to delete schedules: %v", err)
+		}
+		if err := tx.Delete(&m).Error; err != nil {
+			return status.Errorf(codes.Internal, "failed to delete pipeline: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return &civ1.DeletePipelineResponse{Success: true}, nil
 }
@@ -4,7 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"time"
+
+	"xcoding/apps/ci/executor_service/buildqueue"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -27,24 +28,28 @@ func NewRabbitMQBuildQueue(url, queue string) (*RabbitMQBuildQueue, error) {
 		_ = conn.Close()
 		return nil, fmt.Errorf("open channel: %w", err)
 	}
-	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+	if err := buildqueue.DeclareQueue(ch, queue); err != nil {
 		_ = ch.Close()
 		_ = conn.Close()
-		return nil, fmt.Errorf("declare queue: %w", err)
+		return nil, err
 	}
 	return &RabbitMQBuildQueue{conn: conn, channel: ch, queue: queue}, nil
 }
 
-// Enqueue 向 RabbitMQ 发布构建任务
+// Enqueue 向 RabbitMQ 发布构建任务（JSON 消息，格式见 buildqueue.Message）
 func (q *RabbitMQBuildQueue) Enqueue(ctx context.Context, job BuildJob) error {
-	body := fmt.Sprintf("%d|%d|%d|%s|%s", job.BuildID, job.PipelineID, job.ProjectID, job.CommitSHA, job.Branch)
-	// 简单字符串编码；生产环境建议使用 JSON
-	return q.channel.PublishWithContext(ctx, "", q.queue, false, false, amqp.Publishing{
-		ContentType:  "text/plain",
-		Body:         []byte(body),
-		DeliveryMode: amqp.Persistent,
-		Timestamp:    time.Now(),
+	pub, err := buildqueue.Publishing(buildqueue.Message{
+		BuildID:    job.BuildID,
+		PipelineID: job.PipelineID,
+		ProjectID:  job.ProjectID,
+		CommitSHA:  job.CommitSHA,
+		Branch:     job.Branch,
+		Variables:  job.Variables,
 	})
+	if err != nil {
+		return err
+	}
+	return q.channel.PublishWithContext(ctx, "", q.queue, false, false, pub)
 }
 
 // Close 关闭通道与连接
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"xcoding/apps/ci/pipeline_service/internal/cron"
+	"xcoding/apps/ci/pipeline_service/internal/models"
+)
+
+// scheduleNextRunCount Create/UpdateSchedule 返回的后续触发时间个数
+const scheduleNextRunCount = 5
+
+// parseSchedule 校验 cron 表达式与时区，返回解析后的计划
+func parseSchedule(spec, timezone string) (*cron.Schedule, *time.Location, error) {
+	sched, err := cron.Parse(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc, err := cron.LoadLocation(timezone)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sched.Next(time.Now().In(loc)).IsZero() {
+		return nil, nil, fmt.Errorf("cron %q never fires", spec)
+	}
+	return sched, loc, nil
+}
+
+// scheduleRunTimes 校验计划并计算后续触发时间；同时刷新 NextRunAt（禁用时置空）
+func scheduleRunTimes(sdl *models.PipelineSchedule, now time.Time) ([]*timestamppb.Timestamp, error) {
+	sched, loc, err := parseSchedule(sdl.Cron, sdl.Timezone)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid schedule: %v", err)
+	}
+	times := sched.NextN(now.In(loc), scheduleNextRunCount)
+	out := make([]*timestamppb.Timestamp, len(times))
+	for i, t := range times {
+		out[i] = timestamppb.New(t)
+	}
+	sdl.NextRunAt = nil
+	if sdl.Enabled && len(times) > 0 {
+		next := times[0]
+		sdl.NextRunAt = &next
+	}
+	return out, nil
+}
+
+// ScheduleRunnerOptions 定时调度参数
+type ScheduleRunnerOptions struct {
+	Interval     time.Duration // 扫描周期，默认 30s
+	MisfireGrace time.Duration // 错过触发点的容忍时长：超出则跳过本次（如服务长时间停机），默认 5m
+	BatchSize    int           // 单次认领的计划上限，默认 100
+}
+
+// ScheduleRunner 定时触发 PipelineSchedule
+// 说明：
+// - 每个计划持久化下次触发时间 NextRunAt；扫描时在事务内以 FOR UPDATE SKIP LOCKED 认领到期行并推进 NextRunAt，多副本之间互不重复
+// - 认领提交后再创建构建（TriggeredBy="schedule:<id>"）：进程在两者之间退出最多丢失一次触发，重启后不会重复触发
+// - 多个错过的触发点合并为一次；超出 MisfireGrace 的触发点直接跳过
+type ScheduleRunner struct {
+	svc  *pipelineService
+	opts ScheduleRunnerOptions
+	now  func() time.Time
+}
+
+// NewScheduleRunner 创建定时调度器；构建通过与 StartPipelineBuild 相同的路径创建并入队
+func NewScheduleRunner(db *gorm.DB, opts ScheduleRunnerOptions) *ScheduleRunner {
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+	if opts.MisfireGrace <= 0 {
+		opts.MisfireGrace = 5 * time.Minute
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	return &ScheduleRunner{svc: &pipelineService{db: db}, opts: opts, now: time.Now}
+}
+
+// Run 周期扫描到期计划，直到 ctx 取消
+func (r *ScheduleRunner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Tick(ctx); err != nil {
+			log.Printf("schedule: tick error: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick 认领到期计划并触发构建
+func (r *ScheduleRunner) Tick(ctx context.Context) error {
+	now := r.now()
+	due, err := r.claim(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, sdl := range due {
+		r.fire(ctx, sdl)
+	}
+	return nil
+}
+
+// claim 在事务内锁定到期计划并推进 NextRunAt，返回需要触发的计划
+// NextRunAt 为空的计划（历史数据）仅初始化下次触发时间，不立即触发
+func (r *ScheduleRunner) claim(ctx context.Context, now time.Time) ([]models.PipelineSchedule, error) {
+	var due []models.PipelineSchedule
+	err := r.svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []models.PipelineSchedule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("enabled = ? AND (next_run_at IS NULL OR next_run_at <= ?)", true, now).
+			Order("id").Limit(r.opts.BatchSize).Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			updates := map[string]any{}
+			sched, loc, err := parseSchedule(row.Cron, row.Timezone)
+			if err != nil {
+				// 无效计划（绕过接口校验写入的数据）：一小时后重试，避免每轮重复扫描
+				log.Printf("schedule: id=%d invalid: %v", row.ID, err)
+				updates["next_run_at"] = now.Add(time.Hour)
+			} else {
+				updates["next_run_at"] = sched.Next(now.In(loc))
+				if row.NextRunAt != nil {
+					if now.Sub(*row.NextRunAt) <= r.opts.MisfireGrace {
+						updates["last_triggered_at"] = now
+						due = append(due, row)
+					} else {
+						log.Printf("schedule: id=%d missed run at %s, skipped", row.ID, row.NextRunAt.Format(time.RFC3339))
+					}
+				}
+			}
+			if err := tx.Model(&models.PipelineSchedule{}).Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// fire 为计划创建构建；流水线已停用或缺少工作流时跳过
+func (r *ScheduleRunner) fire(ctx context.Context, sdl models.PipelineSchedule) {
+	var p models.Pipeline
+	if err := r.svc.db.WithContext(ctx).First(&p, sdl.PipelineID).Error; err != nil {
+		log.Printf("schedule: id=%d load pipeline %d: %v", sdl.ID, sdl.PipelineID, err)
+		return
+	}
+	if !p.IsActive || p.WorkflowYAML == "" {
+		return
+	}
+	b, err := r.svc.startBuild(ctx, &p, fmt.Sprintf("schedule:%d", sdl.ID), "", "", nil, nil)
+	if err != nil {
+		log.Printf("schedule: id=%d start build: %v", sdl.ID, err)
+		return
+	}
+	log.Printf("schedule: id=%d triggered build %d for pipeline %d", sdl.ID, b.ID, p.ID)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"xcoding/apps/ci/pipeline_service/internal/models"
+)
+
+// newTestDB 创建内存 SQLite 数据库并迁移给定的表
+func newTestDB(t *testing.T, tables ...any) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestScheduleRunnerClaim(t *testing.T) {
+	db := newTestDB(t, &models.PipelineSchedule{})
+	now := time.Date(2026, 3, 2, 10, 1, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		v := now.Add(d)
+		return &v
+	}
+	rows := map[string]*models.PipelineSchedule{
+		"due":      {PipelineID: 1, Cron: "*/5 * * * *", Timezone: "UTC", NextRunAt: at(-time.Minute)},
+		"missed":   {PipelineID: 2, Cron: "*/5 * * * *", Timezone: "UTC", NextRunAt: at(-time.Hour)},
+		"disabled": {PipelineID: 3, Cron: "*/5 * * * *", Timezone: "UTC", NextRunAt: at(-time.Minute)},
+		"future":   {PipelineID: 4, Cron: "*/5 * * * *", Timezone: "UTC", NextRunAt: at(4 * time.Minute)},
+		"unset":    {PipelineID: 5, Cron: "*/5 * * * *", Timezone: "UTC"},
+	}
+	for _, name := range []string{"due", "missed", "disabled", "future", "unset"} {
+		if err := db.Create(rows[name]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Enabled 的零值会被列默认值 true 覆盖，创建后单独禁用
+	if err := db.Model(rows["disabled"]).Update("enabled", false).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewScheduleRunner(db, ScheduleRunnerOptions{MisfireGrace: 5 * time.Minute})
+	due, err := r.claim(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].ID != rows["due"].ID {
+		t.Fatalf("claim = %+v, want only schedule %d", due, rows["due"].ID)
+	}
+	// 同一时刻再次认领：NextRunAt 已推进，不重复触发
+	if again, err := r.claim(context.Background(), now); err != nil || len(again) != 0 {
+		t.Errorf("second claim = %+v, %v, want none", again, err)
+	}
+
+	load := func(name string) models.PipelineSchedule {
+		var s models.PipelineSchedule
+		if err := db.First(&s, rows[name].ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	next := now.Add(4 * time.Minute) // */5 在 10:01 之后的下一个触发点为 10:05
+	cases := []struct {
+		name      string
+		next      *time.Time
+		triggered bool
+	}{
+		{"due", &next, true},
+		{"missed", &next, false},
+		{"disabled", at(-time.Minute), false},
+		{"future", at(4 * time.Minute), false},
+		{"unset", &next, false},
+	}
+	for _, c := range cases {
+		s := load(c.name)
+		if s.NextRunAt == nil || !s.NextRunAt.Equal(*c.next) {
+			t.Errorf("%s: NextRunAt = %v, want %v", c.name, s.NextRunAt, *c.next)
+		}
+		if got := s.LastTriggeredAt != nil; got != c.triggered {
+			t.Errorf("%s: LastTriggeredAt = %v, want triggered=%v", c.name, s.LastTriggeredAt, c.triggered)
+		}
+		if c.triggered && !s.LastTriggeredAt.Equal(now) {
+			t.Errorf("%s: LastTriggeredAt = %v, want %v", c.name, s.LastTriggeredAt, now)
+		}
+	}
+
+	// 到达下一个触发点后再次触发
+	due, err = r.claim(context.Background(), next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []uint64
+	for _, s := range due {
+		ids = append(ids, s.ID)
+	}
+	want := []uint64{rows["due"].ID, rows["missed"].ID, rows["future"].ID, rows["unset"].ID}
+	if len(ids) != len(want) {
+		t.Fatalf("claim at next run = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("claim at next run = %v, want %v", ids, want)
+			break
+		}
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -12,6 +13,8 @@ import (
 )
 
 // 日程计划 CRUD（创建、查询、更新、删除）
+// 创建与更新时校验 cron 表达式与时区，并返回后续若干次触发时间；实际触发由 ScheduleRunner 完成
+// 工作流 on.schedule 同步而来的计划（Source=yaml）只读，不可通过接口更新或删除
 func (s *pipelineService) CreateSchedule(ctx context.Context, req *civ1.CreatePipelineScheduleRequest) (*civ1.CreatePipelineScheduleResponse, error) {
 	if req == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "request nil")
@@ -38,14 +41,19 @@ func (s *pipelineService) CreateSchedule(ctx context.Context, req *civ1.CreatePi
 		Cron:       req.GetCron(),
 		Timezone:   req.GetTimezone(),
 		Enabled:    req.GetEnabled(),
+		Source:     models.ScheduleSourceAPI,
 	}
 	if sdl.Timezone == "" {
 		sdl.Timezone = "UTC"
 	}
-	if err := s.db.WithContext(ctx).Select("PipelineID", "Cron", "Timezone", "Enabled").Create(&sdl).Error; err != nil {
+	runTimes, err := scheduleRunTimes(&sdl, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Select("PipelineID", "Cron", "Timezone", "Enabled", "Source", "NextRunAt").Create(&sdl).Error; err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to create schedule: %v", err)
 	}
-	return &civ1.CreatePipelineScheduleResponse{Schedule: sdl.ToProto()}, nil
+	return &civ1.CreatePipelineScheduleResponse{Schedule: sdl.ToProto(), NextRunTimes: runTimes}, nil
 }
 
 func (s *pipelineService) ListSchedules(ctx context.Context, req *civ1.ListPipelineSchedulesRequest) (*civ1.ListPipelineSchedulesResponse, error) {
@@ -130,6 +138,9 @@ func (s *pipelineService) UpdateSchedule(ctx context.Context, req *civ1.UpdatePi
 		}
 		return nil, status.Errorf(codes.Internal, "failed to get schedule: %v", err)
 	}
+	if sdl.Source == models.ScheduleSourceYAML {
+		return nil, status.Errorf(codes.FailedPrecondition, "schedule is managed by workflow on.schedule; edit the workflow YAML instead")
+	}
 	if v := req.GetCron(); v != "" {
 		sdl.Cron = v
 	}
@@ -137,10 +148,15 @@ func (s *pipelineService) UpdateSchedule(ctx context.Context, req *civ1.UpdatePi
 		sdl.Timezone = v
 	}
 	sdl.Enabled = req.GetEnabled()
+	// 规则变更后按当前时间重新计算下次触发时间
+	runTimes, err := scheduleRunTimes(&sdl, time.Now())
+	if err != nil {
+		return nil, err
+	}
 	if err := s.db.WithContext(ctx).Save(&sdl).Error; err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to update schedule: %v", err)
 	}
-	return &civ1.UpdatePipelineScheduleResponse{Schedule: sdl.ToProto()}, nil
+	return &civ1.UpdatePipelineScheduleResponse{Schedule: sdl.ToProto(), NextRunTimes: runTimes}, nil
 }
 
 func (s *pipelineService) DeleteSchedule(ctx context.Context, req *civ1.DeletePipelineScheduleRequest) (*civ1.DeletePipelineScheduleResponse, error) {
@@ -163,7 +179,18 @@ func (s *pipelineService) DeleteSchedule(ctx context.Context, req *civ1.DeletePi
 			return nil, err
 		}
 	}
-	if err := s.db.WithContext(ctx).Where("pipeline_id = ? AND id = ?", p.ID, req.GetScheduleId()).Delete(&models.PipelineSchedule{}).Error; err != nil {
+	var sdl models.PipelineSchedule
+	if err := s.db.WithContext(ctx).Where("pipeline_id = ? AND id = ?", p.ID, req.GetScheduleId()).First(&sdl).Error; err != nil {
+		// 删除保持幂等：计划不存在（含已删除）视为成功
+		if err == gorm.ErrRecordNotFound {
+			return &civ1.DeletePipelineScheduleResponse{Success: true}, nil
+		}
+		return nil, status.Errorf(codes.Internal, "failed to get schedule: %v", err)
+	}
+	if sdl.Source == models.ScheduleSourceYAML {
+		return nil, status.Errorf(codes.FailedPrecondition, "schedule is managed by workflow on.schedule; remove it from the workflow YAML instead")
+	}
+	if err := s.db.WithContext(ctx).Delete(&sdl).Error; err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to delete schedule: %v", err)
 	}
 	return &civ1.DeletePipelineScheduleResponse{Success: true}, nil
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"xcoding/apps/ci/executor_service/parser"
+	"xcoding/apps/ci/pipeline_service/internal/models"
+	civ1 "xcoding/gen/go/ci/v1"
+)
+
+// adminContext 以超级管理员身份调用接口
+func adminContext() context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", "1", "x-user-role", "SUPER_ADMIN"))
+}
+
+func TestSyncWorkflowSchedules(t *testing.T) {
+	db := newTestDB(t, &models.PipelineSchedule{})
+	triggered := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	seed := []models.PipelineSchedule{
+		{PipelineID: 1, Cron: "0 * * * *", Timezone: "UTC", Source: models.ScheduleSourceYAML, LastTriggeredAt: &triggered},
+		{PipelineID: 1, Cron: "0 * * * *", Timezone: "UTC", Source: models.ScheduleSourceYAML},  // 重复规则：保留一条
+		{PipelineID: 1, Cron: "30 2 * * *", Timezone: "UTC", Source: models.ScheduleSourceYAML}, // 已从 YAML 移除
+		{PipelineID: 1, Cron: "30 2 * * *", Timezone: "UTC", Source: models.ScheduleSourceAPI},  // 接口创建：不受影响
+		{PipelineID: 2, Cron: "30 2 * * *", Timezone: "UTC", Source: models.ScheduleSourceYAML}, // 其他流水线：不受影响
+	}
+	for i := range seed {
+		if err := db.Create(&seed[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	wf := &parser.Workflow{On: parser.Triggers{Schedule: []parser.ScheduleTrigger{
+		{Cron: "0  *  * * *"},
+		{Cron: "15 4 * * 1", Timezone: "Asia/Shanghai"},
+		{Cron: "15 4 * * 1", Timezone: "Asia/Shanghai"},
+	}}}
+	if err := syncWorkflowSchedules(db, 1, wf); err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []models.PipelineSchedule
+	if err := db.Order("id").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range rows {
+		got = append(got, r.Source+" "+scheduleKey(r.Cron, r.Timezone))
+		if r.ID > seed[len(seed)-1].ID && (!r.Enabled || r.NextRunAt == nil) {
+			t.Errorf("schedule %d: enabled=%v next=%v, want new schedule enabled with next run", r.ID, r.Enabled, r.NextRunAt)
+		}
+	}
+	want := []string{
+		"yaml 0 * * * *|UTC",
+		"api 30 2 * * *|UTC",
+		"yaml 30 2 * * *|UTC",
+		"yaml 15 4 * * 1|Asia/Shanghai",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("schedules = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("schedules = %q, want %q", got, want)
+			break
+		}
+	}
+	if rows[0].ID != seed[0].ID || rows[0].LastTriggeredAt == nil {
+		t.Errorf("unchanged rule recreated: %+v", rows[0])
+	}
+
+	// YAML 移除全部规则后删除该流水线的 YAML 计划
+	if err := syncWorkflowSchedules(db, 1, &parser.Workflow{}); err != nil {
+		t.Fatal(err)
+	}
+	var sources []string
+	if err := db.Model(&models.PipelineSchedule{}).Where("pipeline_id = ?", 1).Pluck("source", &sources).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 || sources[0] != models.ScheduleSourceAPI {
+		t.Errorf("schedules after removing on.schedule = %v, want only the api schedule", sources)
+	}
+}
+
+func TestScheduleSourceReadOnly(t *testing.T) {
+	db := newTestDB(t, &models.Pipeline{}, &models.PipelineSchedule{})
+	s := &pipelineService{db: db}
+	p := models.Pipeline{ProjectID: 1, Name: "p"}
+	if err := db.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	yamlSdl := models.PipelineSchedule{PipelineID: p.ID, Cron: "0 * * * *", Timezone: "UTC", Source: models.ScheduleSourceYAML}
+	apiSdl := models.PipelineSchedule{PipelineID: p.ID, Cron: "0 * * * *", Timezone: "UTC", Source: models.ScheduleSourceAPI}
+	for _, sdl := range []*models.PipelineSchedule{&yamlSdl, &apiSdl} {
+		if err := db.Create(sdl).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := adminContext()
+
+	_, err := s.UpdateSchedule(ctx, &civ1.UpdatePipelineScheduleRequest{PipelineId: p.ID, ScheduleId: yamlSdl.ID, Cron: "5 * * * *", Enabled: true})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("UpdateSchedule(yaml) error = %v, want FailedPrecondition", err)
+	}
+	_, err = s.DeleteSchedule(ctx, &civ1.DeletePipelineScheduleRequest{PipelineId: p.ID, ScheduleId: yamlSdl.ID})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("DeleteSchedule(yaml) error = %v, want FailedPrecondition", err)
+	}
+	resp, err := s.UpdateSchedule(ctx, &civ1.UpdatePipelineScheduleRequest{PipelineId: p.ID, ScheduleId: apiSdl.ID, Cron: "5 * * * *", Enabled: true})
+	if err != nil || resp.GetSchedule().GetCron() != "5 * * * *" {
+		t.Errorf("UpdateSchedule(api) = %v, %v", resp.GetSchedule(), err)
+	}
+	// 删除保持幂等：重复删除与不存在的计划均返回成功
+	for _, id := range []uint64{apiSdl.ID, apiSdl.ID, 999} {
+		if resp, err := s.DeleteSchedule(ctx, &civ1.DeletePipelineScheduleRequest{PipelineId: p.ID, ScheduleId: id}); err != nil || !resp.GetSuccess() {
+			t.Errorf("DeleteSchedule(%d) = %v, %v, want success", id, resp, err)
+		}
+	}
+	var n int64
+	db.Model(&models.PipelineSchedule{}).Where("id = ?", yamlSdl.ID).Count(&n)
+	if n != 1 {
+		t.Error("yaml schedule deleted through the API")
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"xcoding/apps/ci/executor_service/parser"
+	"xcoding/apps/ci/pipeline_service/internal/models"
+)
+
+// validateWorkflowSchedules 校验 on.schedule 中的 cron 与时区，错误附带行列号
+func validateWorkflowSchedules(wf *parser.Workflow) error {
+	var errs parser.ValidationErrors
+	for _, st := range wf.On.Schedule {
+		if _, _, err := parseSchedule(st.Cron, st.Timezone); err != nil {
+			errs = append(errs, &parser.ValidationError{Line: st.Line, Column: st.Column, Message: fmt.Sprintf("on.schedule: %v", err)})
+		}
+	}
+	if len(errs) > 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid workflow: %v", errs)
+	}
+	return nil
+}
+
+// scheduleKey 定时规则的去重键（cron 空白归一 + 时区）
+func scheduleKey(cron, timezone string) string {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	return strings.Join(strings.Fields(cron), " ") + "|" + timezone
+}
+
+// syncWorkflowSchedules 将工作流 on.schedule 同步为 YAML 来源的定时计划
+// 规则：
+// - 与 YAML 中规则一致（cron + 时区）的已有计划保留，不影响其 NextRunAt/LastTriggeredAt
+// - YAML 中新增的规则创建为启用状态的计划；YAML 中已移除的规则对应计划被删除
+// - 接口创建的计划（Source=api）不受影响
+func syncWorkflowSchedules(tx *gorm.DB, pipelineID uint64, wf *parser.Workflow) error {
+	var existing []models.PipelineSchedule
+	if err := tx.Where("pipeline_id = ? AND source = ?", pipelineID, models.ScheduleSourceYAML).Find(&existing).Error; err != nil {
+		return status.Errorf(codes.Internal, "failed to list workflow schedules: %v", err)
+	}
+	byKey := map[string]uint64{}
+	var stale []uint64
+	for _, sdl := range existing {
+		k := scheduleKey(sdl.Cron, sdl.Timezone)
+		if _, dup := byKey[k]; dup {
+			stale = append(stale, sdl.ID)
+			continue
+		}
+		byKey[k] = sdl.ID
+	}
+	now := time.Now()
+	desired := map[string]bool{}
+	for _, st := range wf.On.Schedule {
+		k := scheduleKey(st.Cron, st.Timezone)
+		if desired[k] {
+			continue
+		}
+		desired[k] = true
+		if _, ok := byKey[k]; ok {
+			continue
+		}
+		sdl := models.PipelineSchedule{PipelineID: pipelineID, Cron: strings.Join(strings.Fields(st.Cron), " "), Timezone: st.Timezone, Enabled: true, Source: models.ScheduleSourceYAML}
+		if sdl.Timezone == "" {
+			sdl.Timezone = "UTC"
+		}
+		if _, err := scheduleRunTimes(&sdl, now); err != nil {
+			return err
+		}
+		if err := tx.Select("PipelineID", "Cron", "Timezone", "Enabled", "Source", "NextRunAt").Create(&sdl).Error; err != nil {
+			return status.Errorf(codes.Internal, "failed to create workflow schedule: %v", err)
+		}
+	}
+	for k, id := range byKey {
+		if !desired[k] {
+			stale = append(stale, id)
+		}
+	}
+	if len(stale) > 0 {
+		if err := tx.Where("id IN ?", stale).Delete(&models.PipelineSchedule{}).Error; err != nil {
+			return status.Errorf(codes.Internal, "failed to delete workflow schedules: %v", err)
+		}
+	}
+	return nil
+}
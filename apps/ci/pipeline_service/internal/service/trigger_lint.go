@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"xcoding/apps/ci/executor_service/parser"
+	"xcoding/apps/ci/pipeline_service/internal/models"
+	"xcoding/apps/ci/pipeline_service/internal/trigger"
+	civ1 "xcoding/gen/go/ci/v1"
+)
+
+// LintPipelineTriggers 检查项目下启用的流水线的 on: 配置，报告永远不会命中的事件与过滤条件（项目成员可见）
+// 工作流无法解析的流水线同样报告（不会被任何事件触发）
+func (s *pipelineService) LintPipelineTriggers(ctx context.Context, req *civ1.LintPipelineTriggersRequest) (*civ1.LintPipelineTriggersResponse, error) {
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "request nil")
+	}
+	if req.GetProjectId() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "project_id is required")
+	}
+	if !isUserRoleSuperAdmin(ctx) {
+		actorID, err := getUserIDFromCtx(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ok, perr := s.isMemberOrHigher(ctx, req.GetProjectId(), actorID)
+		if perr != nil {
+			return nil, perr
+		}
+		if !ok {
+			return nil, status.Errorf(codes.PermissionDenied, "not allowed to access pipelines")
+		}
+	}
+	q := s.db.WithContext(ctx).Where("project_id = ? AND is_active = ?", req.GetProjectId(), true)
+	if req.GetPipelineId() != 0 {
+		q = q.Where("id = ?", req.GetPipelineId())
+	}
+	var pipelines []models.Pipeline
+	if err := q.Order("id").Find(&pipelines).Error; err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list pipelines: %v", err)
+	}
+	resp := &civ1.LintPipelineTriggersResponse{}
+	for _, p := range pipelines {
+		if p.WorkflowYAML == "" {
+			continue
+		}
+		wf, err := parser.ParseWorkflowYAML(p.WorkflowYAML)
+		if err != nil {
+			resp.Issues = append(resp.Issues, &civ1.TriggerLintIssue{PipelineId: p.ID, PipelineName: p.Name, Message: "invalid workflow: " + err.Error()})
+			continue
+		}
+		for _, is := range trigger.Lint(&wf.On) {
+			resp.Issues = append(resp.Issues, &civ1.TriggerLintIssue{
+				PipelineId:   p.ID,
+				PipelineName: p.Name,
+				Event:        is.Event,
+				Field:        is.Field,
+				Line:         int32(is.Line),
+				Column:       int32(is.Column),
+				Message:      is.Message,
+			})
+		}
+	}
+	return resp, nil
+}
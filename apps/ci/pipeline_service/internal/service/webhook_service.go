@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"xcoding/apps/ci/executor_service/parser"
+	"xcoding/apps/ci/pipeline_service/internal/models"
+	"xcoding/apps/ci/pipeline_service/internal/trigger"
+	"xcoding/apps/ci/pipeline_service/internal/webhook"
+	civ1 "xcoding/gen/go/ci/v1"
+	coderepositoryv1 "xcoding/gen/go/code_repository/v1"
+)
+
+// webhookRecordedHeaders 投递记录中保存的请求头（不含签名与 X-Gitlab-Token，避免落库密钥）
+var webhookRecordedHeaders = []string{
+	"Content-Type", "User-Agent",
+	"X-GitHub-Event", "X-GitHub-Delivery", "X-GitHub-Hook-ID",
+	"X-Gitlab-Event", "X-Gitlab-Event-UUID", "X-Gitlab-Instance",
+	"X-Gitea-Event", "X-Gitea-Delivery",
+}
+
+// ReceiveWebhook 处理一次入站 Webhook 投递并记录
+// 流程：
+// - 非推送事件记为 ignored；推送负载中的仓库地址与签名交由 code_repository 的 VerifyWebhook 按仓库密钥校验（密钥不出该服务）
+// - 同一地址关联多个仓库时，任一密钥通过即可；失败记为 unauthorized
+// - 验签通过后才保存负载，并按 (平台, 投递 ID) 去重：平台重复投递时返回原记录，不再触发构建
+// - 对仓库所属项目下所有启用的流水线，按 on.push 的分支/标签/路径过滤匹配并触发构建（TriggeredBy="webhook:<平台>[:<推送者>]"）
+// 返回的错误为 gRPC status，HTTP 入口据此映射状态码；投递记录始终写入
+func (s *pipelineService) ReceiveWebhook(ctx context.Context, d *webhook.Delivery, header http.Header) (*models.WebhookDelivery, error) {
+	rec := &models.WebhookDelivery{
+		Provider:   d.Provider,
+		Event:      d.Event,
+		DeliveryID: d.DeliveryID,
+		Status:     models.WebhookStatusReceived,
+	}
+	hdr := map[string]string{}
+	for _, k := range webhookRecordedHeaders {
+		if v := header.Get(k); v != "" {
+			hdr[k] = v
+		}
+	}
+	if b, err := json.Marshal(hdr); err == nil {
+		rec.Headers = string(b)
+	}
+	return rec, s.processWebhook(ctx, rec, d, 0)
+}
+
+// errDuplicateDelivery 已验签的投递 ID 此前已处理（平台重复投递）
+var errDuplicateDelivery = errors.New("duplicate webhook delivery")
+
+// processWebhook 匹配并触发构建，最后保存投递记录
+// trustedRepoID 非 0 时为重放：原投递已验签，直接使用该仓库而不再校验签名
+func (s *pipelineService) processWebhook(ctx context.Context, rec *models.WebhookDelivery, d *webhook.Delivery, trustedRepoID uint64) error {
+	err := s.matchWebhook(ctx, rec, d, trustedRepoID)
+	if errors.Is(err, errDuplicateDelivery) {
+		var orig models.WebhookDelivery
+		if ferr := s.verifiedDeliveries(ctx, rec.Provider, rec.DeliveryID).First(&orig).Error; ferr != nil {
+			return status.Errorf(codes.Internal, "failed to get webhook delivery: %v", ferr)
+		}
+		*rec = orig
+		return nil
+	}
+	if err != nil && rec.Status == models.WebhookStatusReceived {
+		rec.Status = models.WebhookStatusFailed
+	}
+	if err != nil && rec.Message == "" {
+		rec.Message = status.Convert(err).Message()
+	}
+	if cerr := s.db.WithContext(ctx).Save(rec).Error; cerr != nil {
+		log.Printf("webhook: save delivery %s/%s: %v", rec.Provider, rec.DeliveryID, cerr)
+		if err == nil {
+			err = status.Errorf(codes.Internal, "failed to save webhook delivery: %v", cerr)
+		}
+	}
+	return err
+}
+
+// claimDelivery 验签通过后写入投递记录（含负载），以 (平台, 投递 ID) 唯一索引占用投递；已被占用时返回 errDuplicateDelivery
+func (s *pipelineService) claimDelivery(ctx context.Context, rec *models.WebhookDelivery, d *webhook.Delivery) error {
+	rec.Payload = string(d.Body)
+	err := s.db.WithContext(ctx).Create(rec).Error
+	if err == nil {
+		return nil
+	}
+	rec.ID = 0
+	if rec.ReplayOf == 0 && rec.DeliveryID != "" {
+		var n int64
+		if cerr := s.verifiedDeliveries(ctx, rec.Provider, rec.DeliveryID).Count(&n).Error; cerr == nil && n > 0 {
+			return errDuplicateDelivery
+		}
+	}
+	return status.Errorf(codes.Internal, "failed to save webhook delivery: %v", err)
+}
+
+// verifiedDeliveries 已验签的非重放投递（按平台与投递 ID）
+func (s *pipelineService) verifiedDeliveries(ctx context.Context, provider, deliveryID string) *gorm.DB {
+	return s.db.WithContext(ctx).Model(&models.WebhookDelivery{}).Where("provider = ? AND delivery_id = ? AND verified = ? AND replay_of = 0", provider, deliveryID, true)
+}
+
+func (s *pipelineService) matchWebhook(ctx context.Context, rec *models.WebhookDelivery, d *webhook.Delivery, trustedRepoID uint64) error {
+	if d.IsPing() || !d.IsPush() {
+		rec.Status = models.WebhookStatusIgnored
+		rec.Message = fmt.Sprintf("event %q ignored", d.Event)
+		return nil
+	}
+	ev, err := webhook.ParsePush(d.Body)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid push payload: %v", err)
+	}
+	rec.Ref, rec.CommitSHA = ev.Ref, ev.After
+
+	if s.codeRepositoryClient == nil {
+		return status.Errorf(codes.Unavailable, "code repository service not configured")
+	}
+	if len(ev.RepoURLs) == 0 {
+		rec.Status = models.WebhookStatusNoRepository
+		return status.Errorf(codes.NotFound, "payload has no repository url")
+	}
+	req := &coderepositoryv1.VerifyWebhookRequest{GitUrls: ev.RepoURLs, Provider: d.Provider, Signature: d.Signature, Body: d.Body}
+	if trustedRepoID != 0 {
+		// 重放不再验签，仅按地址解析仓库
+		req.Signature, req.Body = "", nil
+	}
+	resp, err := s.codeRepositoryClient.VerifyWebhook(ctx, req)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to resolve repository: %v", err)
+	}
+	if len(resp.GetRepositories()) == 0 {
+		rec.Status = models.WebhookStatusNoRepository
+		return status.Errorf(codes.NotFound, "no repository matches %s", strings.Join(ev.RepoURLs, ", "))
+	}
+	want := resp.GetVerifiedRepositoryId()
+	if trustedRepoID != 0 {
+		want = trustedRepoID
+	}
+	var repo *coderepositoryv1.Repository
+	for _, r := range resp.GetRepositories() {
+		if want != 0 && r.GetId() == want {
+			repo = r
+			break
+		}
+	}
+	if repo == nil {
+		if trustedRepoID != 0 {
+			rec.Status = models.WebhookStatusNoRepository
+			return status.Errorf(codes.NotFound, "repository %d no longer matches payload", trustedRepoID)
+		}
+		rec.Status = models.WebhookStatusUnauthorized
+		return status.Errorf(codes.Unauthenticated, "webhook signature verification failed")
+	}
+	rec.Verified = true
+	rec.RepositoryID, rec.ProjectID = repo.GetId(), repo.GetProjectId()
+	if err := s.claimDelivery(ctx, rec, d); err != nil {
+		return err
+	}
+	if !repo.GetIsActive() {
+		rec.Status = models.WebhookStatusIgnored
+		rec.Message = "repository is inactive"
+		return nil
+	}
+	if ev.Deleted {
+		rec.Status = models.WebhookStatusIgnored
+		rec.Message = "ref deleted"
+		return nil
+	}
+
+	var pipelines []models.Pipeline
+	if err := s.db.WithContext(ctx).Where("project_id = ? AND is_active = ?", repo.GetProjectId(), true).Order("id").Find(&pipelines).Error; err != nil {
+		return status.Errorf(codes.Internal, "failed to list pipelines: %v", err)
+	}
+	tev := trigger.Event{Name: trigger.EventPush, Ref: ev.Ref, ChangedFiles: ev.ChangedFiles}
+	triggeredBy := "webhook:" + d.Provider
+	if ev.Pusher != "" {
+		triggeredBy += ":" + ev.Pusher
+	}
+	var buildIDs []uint64
+	var failures []string
+	for i := range pipelines {
+		p := &pipelines[i]
+		if p.WorkflowYAML == "" {
+			continue
+		}
+		wf, err := parser.ParseWorkflowYAML(p.WorkflowYAML)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("pipeline %d: %v", p.ID, err))
+			continue
+		}
+		if !trigger.Match(&wf.On, tev) {
+			continue
+		}
+		b, err := s.startBuild(ctx, p, triggeredBy, ev.After, ev.Branch(), nil, nil)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("pipeline %d: %v", p.ID, status.Convert(err).Message()))
+			continue
+		}
+		buildIDs = append(buildIDs, b.ID)
+	}
+	rec.SetBuildIDs(buildIDs)
+	rec.Message = strings.Join(failures, "; ")
+	switch {
+	case len(buildIDs) > 0:
+		rec.Status = models.WebhookStatusTriggered
+	case len(failures) > 0:
+		rec.Status = models.WebhookStatusFailed
+	default:
+		rec.Status = models.WebhookStatusNoMatch
+	}
+	return nil
+}
+
+// ListWebhookDeliveries 列出项目的 Webhook 投递记录（项目成员可见）
+func (s *pipelineService) ListWebhookDeliveries(ctx context.Context, req *civ1.ListWebhookDeliveriesRequest) (*civ1.ListWebhookDeliveriesResponse, error) {
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "request nil")
+	}
+	if req.GetProjectId() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "project_id is required")
+	}
+	if !isUserRoleSuperAdmin(ctx) {
+		actorID, err := getUserIDFromCtx(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ok, perr := s.isMemberOrHigher(ctx, req.GetProjectId(), actorID)
+		if perr != nil {
+			return nil, perr
+		}
+		if !ok {
+			return nil, status.Errorf(codes.PermissionDenied, "not allowed to access webhook deliveries")
+		}
+	}
+	page := req.GetPage()
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.GetPageSize()
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	var total int64
+	q := s.db.WithContext(ctx).Model(&models.WebhookDelivery{}).Where("project_id = ?", req.GetProjectId())
+	if err := q.Count(&total).Error; err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to count webhook deliveries: %v", err)
+	}
+	var items []models.WebhookDelivery
+	if err := q.Offset(int(offset)).Limit(int(pageSize)).Order("id DESC").Find(&items).Error; err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list webhook deliveries: %v", err)
+	}
+	data := make([]*civ1.WebhookDelivery, len(items))
+	for i := range items {
+		data[i] = items[i].ToProto()
+	}
+	totalPages := int32((total + int64(pageSize) - 1) / int64(pageSize))
+	return &civ1.ListWebhookDeliveriesResponse{
+		Data:       data,
+		Pagination: &civ1.ListWebhookDeliveriesResponse_Pagination{Page: page, PageSize: pageSize, TotalItems: int32(total), TotalPages: totalPages},
+	}, nil
+}
+
+// ReplayWebhookDelivery 重放投递（仅项目 owner/admin）
+// 仅允许重放已验签的投递；按原始负载重新匹配当前的流水线配置，结果记录为新的投递（ReplayOf 指向原记录）
+func (s *pipelineService) ReplayWebhookDelivery(ctx context.Context, req *civ1.ReplayWebhookDeliveryRequest) (*civ1.ReplayWebhookDeliveryResponse, error) {
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "request nil")
+	}
+	var orig models.WebhookDelivery
+	if err := s.db.WithContext(ctx).First(&orig, req.GetDeliveryId()).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, status.Errorf(codes.NotFound, "webhook delivery not found")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to get webhook delivery: %v", err)
+	}
+	if !orig.Verified || orig.RepositoryID == 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "only verified deliveries can be replayed")
+	}
+	actorID, err := getUserIDFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureOwnerOrAdmin(ctx, orig.ProjectID, actorID); err != nil {
+		return nil, err
+	}
+	rec := &models.WebhookDelivery{
+		Provider:   orig.Provider,
+		Event:      orig.Event,
+		DeliveryID: orig.DeliveryID,
+		Status:     models.WebhookStatusReceived,
+		Headers:    orig.Headers,
+		Payload:    orig.Payload,
+		ReplayOf:   orig.ID,
+	}
+	d := &webhook.Delivery{Provider: orig.Provider, Event: orig.Event, DeliveryID: orig.DeliveryID, Body: []byte(orig.Payload)}
+	if err := s.processWebhook(ctx, rec, d, orig.RepositoryID); err != nil && rec.ID == 0 {
+		return nil, err
+	}
+	return &civ1.ReplayWebhookDeliveryResponse{Delivery: rec.ToProto()}, nil
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"xcoding/apps/ci/pipeline_service/internal/models"
+	"xcoding/apps/ci/pipeline_service/internal/webhook"
+	coderepositoryv1 "xcoding/gen/go/code_repository/v1"
+)
+
+// fakeRepoClient 仅实现 VerifyWebhook：返回固定的仓库列表，并以 secrets 中的密钥模拟验签（GitLab 比较令牌，其余比较 HMAC）
+type fakeRepoClient struct {
+	coderepositoryv1.CodeRepositoryServiceClient
+	repos   []*coderepositoryv1.Repository
+	secrets map[uint64]string
+}
+
+func (f *fakeRepoClient) VerifyWebhook(ctx context.Context, in *coderepositoryv1.VerifyWebhookRequest, opts ...grpc.CallOption) (*coderepositoryv1.VerifyWebhookResponse, error) {
+	out := &coderepositoryv1.VerifyWebhookResponse{Repositories: f.repos}
+	for _, r := range f.repos {
+		want := sign(f.secrets[r.GetId()], in.GetBody())
+		if in.GetProvider() == webhook.ProviderGitLab {
+			want = f.secrets[r.GetId()]
+		}
+		if in.GetSignature() != "" && in.GetSignature() == want {
+			out.VerifiedRepositoryId = r.GetId()
+			break
+		}
+	}
+	return out, nil
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newWebhookService 同一地址关联两个仓库：仓库 1（项目 3，密钥 first）与仓库 2（项目 7，密钥 second）
+func newWebhookService(t *testing.T) *pipelineService {
+	t.Helper()
+	db := newTestDB(t, &models.Pipeline{}, &models.WebhookDelivery{})
+	if err := db.Create(&models.Pipeline{ProjectID: 7, Name: "release", WorkflowYAML: "on:\n  push:\n    branches: [release]\njobs:\n  a:\n    steps:\n      - run: make\n"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &pipelineService{db: db, codeRepositoryClient: &fakeRepoClient{
+		repos: []*coderepositoryv1.Repository{
+			{Id: 1, ProjectId: 3, IsActive: true},
+			{Id: 2, ProjectId: 7, IsActive: true},
+		},
+		secrets: map[uint64]string{1: "first", 2: "second"},
+	}}
+}
+
+func TestMatchWebhook(t *testing.T) {
+	// 同一地址关联两个仓库：任一密钥校验通过即命中该仓库
+	s := newWebhookService(t)
+	push := []byte(`{"ref":"refs/heads/main","after":"abc","repository":{"clone_url":"https://git.example.com/o/r.git"}}`)
+	deleted := []byte(`{"ref":"refs/heads/main","after":"0000000000000000000000000000000000000000","repository":{"clone_url":"https://git.example.com/o/r.git"}}`)
+	cases := []struct {
+		name      string
+		delivery  webhook.Delivery
+		trusted   uint64
+		code      codes.Code
+		status    string
+		verified  bool
+		repoID    uint64
+		projectID uint64
+	}{
+		{"ping", webhook.Delivery{Provider: webhook.ProviderGitHub, Event: "ping"}, 0, codes.OK, models.WebhookStatusIgnored, false, 0, 0},
+		{"bad signature", webhook.Delivery{Provider: webhook.ProviderGitHub, Event: "push", Body: push, Signature: sign("wrong", push)}, 0, codes.Unauthenticated, models.WebhookStatusUnauthorized, false, 0, 0},
+		{"unsigned", webhook.Delivery{Provider: webhook.ProviderGitea, Event: "push", Body: push}, 0, codes.Unauthenticated, models.WebhookStatusUnauthorized, false, 0, 0},
+		{"second secret", webhook.Delivery{Provider: webhook.ProviderGitHub, Event: "push", Body: push, Signature: sign("second", push)}, 0, codes.OK, models.WebhookStatusNoMatch, true, 2, 7},
+		{"gitlab token", webhook.Delivery{Provider: webhook.ProviderGitLab, Event: "Push Hook", Body: push, Signature: "first"}, 0, codes.OK, models.WebhookStatusNoMatch, true, 1, 3},
+		{"deleted ref", webhook.Delivery{Provider: webhook.ProviderGitHub, Event: "push", Body: deleted, Signature: sign("first", deleted)}, 0, codes.OK, models.WebhookStatusIgnored, true, 1, 3},
+		// 重放：原投递已验签，按 trustedRepoID 选择仓库而不校验签名
+		{"replay", webhook.Delivery{Provider: webhook.ProviderGitHub, Event: "push", Body: push}, 2, codes.OK, models.WebhookStatusNoMatch, true, 2, 7},
+		{"replay ignores signature", webhook.Delivery{Provider: webhook.ProviderGitHub, Event: "push", Body: push, Signature: sign("first", push)}, 2, codes.OK, models.WebhookStatusNoMatch, true, 2, 7},
+		{"replay unknown repository", webhook.Delivery{Provider: webhook.ProviderGitHub, Event: "push", Body: push}, 9, codes.NotFound, models.WebhookStatusNoRepository, false, 0, 0},
+		{"invalid payload", webhook.Delivery{Provider: webhook.ProviderGitHub, Event: "push", Body: []byte(`{"ref":"refs/pull/1"}`)}, 0, codes.InvalidArgument, models.WebhookStatusReceived, false, 0, 0},
+	}
+	for _, c := range cases {
+		rec := &models.WebhookDelivery{Status: models.WebhookStatusReceived}
+		err := s.matchWebhook(context.Background(), rec, &c.delivery, c.trusted)
+		if status.Code(err) != c.code {
+			t.Errorf("%s: error = %v, want %v", c.name, err, c.code)
+		}
+		if rec.Status != c.status || rec.Verified != c.verified || rec.RepositoryID != c.repoID || rec.ProjectID != c.projectID {
+			t.Errorf("%s: record = %s verified=%v repo=%d project=%d, want %s verified=%v repo=%d project=%d",
+				c.name, rec.Status, rec.Verified, rec.RepositoryID, rec.ProjectID, c.status, c.verified, c.repoID, c.projectID)
+		}
+	}
+}
+
+func TestReceiveWebhook(t *testing.T) {
+	ctx := context.Background()
+	s := newWebhookService(t)
+	push := []byte(`{"ref":"refs/heads/main","after":"abc","repository":{"clone_url":"https://git.example.com/o/r.git"}}`)
+
+	// 未通过验签的投递只记录状态，不保存负载，也不占用投递 ID
+	forged, err := s.ReceiveWebhook(ctx, &webhook.Delivery{Provider: webhook.ProviderGitHub, Event: "push", DeliveryID: "d1", Body: push, Signature: sign("wrong", push)}, http.Header{})
+	if status.Code(err) != codes.Unauthenticated || forged.ID == 0 || forged.Payload != "" {
+		t.Fatalf("forged delivery = %+v, %v; want unauthorized record without payload", forged, err)
+	}
+	genuine := &webhook.Delivery{Provider: webhook.ProviderGitHub, Event: "push", DeliveryID: "d1", Body: push, Signature: sign("second", push)}
+	first, err := s.ReceiveWebhook(ctx, genuine, http.Header{})
+	if err != nil || first.Status != models.WebhookStatusNoMatch || first.Payload != string(push) {
+		t.Fatalf("first delivery = %+v, %v; want no_match with payload", first, err)
+	}
+	// 平台重复投递：返回原记录，不新增记录
+	again, err := s.ReceiveWebhook(ctx, genuine, http.Header{})
+	if err != nil || again.ID != first.ID {
+		t.Errorf("redelivery = %+v, %v; want original record %d", again, err, first.ID)
+	}
+	// 其它平台的相同投递 ID 与无投递 ID 的投递不受影响
+	for _, d := range []*webhook.Delivery{
+		{Provider: webhook.ProviderGitea, Event: "push", DeliveryID: "d1", Body: push, Signature: sign("second", push)},
+		{Provider: webhook.ProviderGitLab, Event: "Push Hook", Body: push, Signature: "first"},
+		{Provider: webhook.ProviderGitLab, Event: "Push Hook", Body: push, Signature: "first"},
+	} {
+		rec, err := s.ReceiveWebhook(ctx, d, http.Header{})
+		if err != nil || rec.ID == first.ID {
+			t.Errorf("%s/%q delivery = %+v, %v; want a new record", d.Provider, d.DeliveryID, rec, err)
+		}
+	}
+	var n int64
+	s.db.Model(&models.WebhookDelivery{}).Count(&n)
+	if n != 5 {
+		t.Errorf("deliveries = %d, want 5", n)
+	}
+}
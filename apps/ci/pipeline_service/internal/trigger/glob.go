@@ -0,0 +1,106 @@
+package trigger
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	globMu    sync.Mutex
+	globCache = map[string]*regexp.Regexp{}
+)
+
+// Glob 按 GitHub Actions 过滤器语法匹配分支、标签或路径
+// 语法：
+// - *：匹配除 / 外的任意字符（可为空）
+// - **：匹配任意字符（含 /）
+// - ?：匹配单个任意字符（不含 /）
+// - +：前一个字符或字符集合重复一次或多次，如 v[0-9]+
+// - [abc] / [a-z]：字符集合
+// - \：转义后一个字符
+func Glob(pattern, s string) bool {
+	globMu.Lock()
+	re, ok := globCache[pattern]
+	if !ok {
+		// 非法模式（如 [z-a]）退化为字面量比较
+		re, _ = compileGlob(pattern)
+		globCache[pattern] = re
+	}
+	globMu.Unlock()
+	if re == nil {
+		return pattern == s
+	}
+	return re.MatchString(s)
+}
+
+// ValidateGlob 校验模式语法（忽略开头的 ! 否定前缀）
+func ValidateGlob(pattern string) error {
+	_, err := compileGlob(strings.TrimPrefix(pattern, "!"))
+	return err
+}
+
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, errors.New("empty pattern")
+	}
+	expr, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// globToRegexp 将 glob 转换为正则表达式
+func globToRegexp(pattern string) (string, error) {
+	var b strings.Builder
+	atom := false // 上一个输出是否为单字符或字符集合（+ 只能跟在其后）
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+			atom = false
+		case '?':
+			b.WriteString("[^/]")
+			atom = true
+		case '+':
+			if !atom {
+				return "", fmt.Errorf("invalid pattern %q: + must follow a character or [...]", pattern)
+			}
+			b.WriteByte('+')
+			atom = false
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("invalid pattern %q: unterminated [", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+			atom = true
+		case '\\':
+			if i+1 >= len(pattern) {
+				return "", fmt.Errorf("invalid pattern %q: trailing \\", pattern)
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			atom = true
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			atom = true
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,141 @@
+package trigger
+
+import (
+	"fmt"
+
+	"xcoding/apps/ci/executor_service/parser"
+)
+
+// supportedEvents 可触发构建的事件（其余事件声明后不会触发）
+var supportedEvents = map[string]bool{
+	EventPush:           true,
+	EventPullRequest:    true,
+	"schedule":          true,
+	"workflow_dispatch": true,
+}
+
+// Issue 触发配置中永远不会命中的部分
+type Issue struct {
+	Event   string // 事件名，如 push
+	Field   string // 过滤字段，如 branches；事件级问题为空
+	Line    int    // 事件键所在行列号（未知为 0）
+	Column  int
+	Message string
+}
+
+func (i Issue) String() string {
+	loc := "on." + i.Event
+	if i.Field != "" {
+		loc += "." + i.Field
+	}
+	if i.Line > 0 {
+		return fmt.Sprintf("line %d, column %d: %s: %s", i.Line, i.Column, loc, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", loc, i.Message)
+}
+
+// Lint 静态检查 on: 配置，报告永远不会命中的事件与过滤条件
+// 检查项：
+// - 不支持的事件
+// - 非法的匹配模式（退化为字面量比较，通常不是预期）
+// - 只有 ! 否定模式、或最后被 !** 全部排除的 branches/tags/paths 列表
+// - 以 ** 忽略全部的 *-ignore 列表
+// - 分支与标签均被排除时，整个 push 事件不会触发
+func Lint(on *parser.Triggers) []Issue {
+	if on == nil {
+		return nil
+	}
+	var issues []Issue
+	for _, e := range on.Events {
+		if !supportedEvents[e] {
+			issues = append(issues, Issue{Event: e, Message: "event is not supported and never triggers"})
+		}
+	}
+	if on.Push != nil {
+		issues = append(issues, lintRefFilter(EventPush, on.Push, true)...)
+	}
+	if on.PullRequest != nil {
+		issues = append(issues, lintRefFilter(EventPullRequest, &on.PullRequest.RefFilter, false)...)
+	}
+	return issues
+}
+
+func lintRefFilter(event string, f *parser.RefFilter, withTags bool) []Issue {
+	var issues []Issue
+	add := func(field, msg string) {
+		issues = append(issues, Issue{Event: event, Field: field, Line: f.Line, Column: f.Column, Message: msg})
+	}
+	fields := []struct {
+		name     string
+		patterns []string
+		ignore   bool
+	}{
+		{"branches", f.Branches, false},
+		{"branches-ignore", f.BranchesIgnore, true},
+		{"tags", f.Tags, false},
+		{"tags-ignore", f.TagsIgnore, true},
+		{"paths", f.Paths, false},
+		{"paths-ignore", f.PathsIgnore, true},
+	}
+	excluded := map[string]bool{}
+	for _, fd := range fields {
+		for _, p := range fd.patterns {
+			if err := ValidateGlob(p); err != nil {
+				add(fd.name, err.Error())
+			}
+		}
+		switch {
+		case !fd.ignore && len(fd.patterns) > 0 && neverMatches(fd.patterns):
+			add(fd.name, "no pattern can match (all candidates are negated)")
+			excluded[fd.name] = true
+		case fd.ignore && ignoresAll(fd.patterns):
+			add(fd.name, "ignores everything")
+			excluded[fd.name] = true
+		}
+	}
+
+	hasBranch := len(f.Branches) > 0 || len(f.BranchesIgnore) > 0
+	hasTag := len(f.Tags) > 0 || len(f.TagsIgnore) > 0
+	branchOK := !excluded["branches"] && !excluded["branches-ignore"] && !excluded["paths"] && !excluded["paths-ignore"]
+	if withTags {
+		if !hasBranch && hasTag {
+			branchOK = false
+		}
+		tagOK := !excluded["tags"] && !excluded["tags-ignore"] && !(hasBranch && !hasTag)
+		if !branchOK && !tagOK {
+			add("", "never matches any branch or tag")
+		}
+	} else if !branchOK {
+		add("", "never matches any branch")
+	}
+	return issues
+}
+
+// neverMatches 模式列表按 MatchPatterns 求值时是否必然为假：没有普通模式，或最后一个普通模式之后出现 !**
+func neverMatches(patterns []string) bool {
+	possible := false
+	for _, p := range patterns {
+		switch {
+		case p == "!**":
+			possible = false
+		case len(p) > 0 && p[0] == '!':
+		default:
+			possible = true
+		}
+	}
+	return !possible
+}
+
+// ignoresAll 忽略列表是否必然命中全部：出现 ** 且之后没有否定模式
+func ignoresAll(patterns []string) bool {
+	all := false
+	for _, p := range patterns {
+		switch {
+		case p == "**":
+			all = true
+		case len(p) > 0 && p[0] == '!':
+			all = false
+		}
+	}
+	return all
+}
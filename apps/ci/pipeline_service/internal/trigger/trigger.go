@@ -0,0 +1,155 @@
+// Package trigger 判断事件是否命中工作流的 on: 触发配置
+package trigger
+
+import (
+	"strings"
+
+	"xcoding/apps/ci/executor_service/parser"
+)
+
+// 支持过滤的事件
+const (
+	EventPush        = "push"
+	EventPullRequest = "pull_request"
+)
+
+// defaultPullRequestTypes on.pull_request 未声明 types 时触发的动作
+var defaultPullRequestTypes = []string{"opened", "synchronize", "reopened"}
+
+// Event 触发事件
+type Event struct {
+	Name         string   // 事件名：push | pull_request
+	Ref          string   // push 为推送的引用；pull_request 为目标（base）分支引用。形如 refs/heads/<branch> 或 refs/tags/<tag>
+	Action       string   // pull_request 动作，如 opened、synchronize；为空视为 opened
+	ChangedFiles []string // 变更文件（相对仓库根目录）；为空表示未知，不做路径过滤
+}
+
+// Branch 返回分支名；非分支引用返回空
+func (e Event) Branch() string {
+	if b, ok := strings.CutPrefix(e.Ref, "refs/heads/"); ok {
+		return b
+	}
+	return ""
+}
+
+// Tag 返回标签名；非标签引用返回空
+func (e Event) Tag() string {
+	if t, ok := strings.CutPrefix(e.Ref, "refs/tags/"); ok {
+		return t
+	}
+	return ""
+}
+
+// Match 判断事件是否触发工作流
+// push 语义（与 GitHub Actions 一致）：
+// - 未配置任何过滤：所有分支与标签均触发
+// - 仅配置分支过滤（branches/branches-ignore）时标签推送不触发；仅配置标签过滤时分支推送不触发
+// - branches/tags：按顺序求值，匹配的模式纳入、! 开头的模式排除，以最后一个命中的模式为准；*-ignore 求值结果为命中即排除
+// - paths/paths-ignore 仅对分支推送生效：至少一个变更文件通过过滤即触发
+// pull_request 语义：branches/branches-ignore 匹配目标分支，paths 同 push，动作需在 types（默认 opened/synchronize/reopened）内
+func Match(on *parser.Triggers, ev Event) bool {
+	if on == nil {
+		return false
+	}
+	switch ev.Name {
+	case EventPush:
+		return on.Push != nil && matchRefFilter(on.Push, ev)
+	case EventPullRequest:
+		return on.PullRequest != nil && matchPullRequest(on.PullRequest, ev)
+	}
+	return false
+}
+
+func matchRefFilter(f *parser.RefFilter, ev Event) bool {
+	hasBranch := len(f.Branches) > 0 || len(f.BranchesIgnore) > 0
+	hasTag := len(f.Tags) > 0 || len(f.TagsIgnore) > 0
+	if tag := ev.Tag(); tag != "" {
+		if !hasTag && hasBranch {
+			return false
+		}
+		return matchName(tag, f.Tags, f.TagsIgnore)
+	}
+	branch := ev.Branch()
+	if branch == "" {
+		return false
+	}
+	if !hasBranch && hasTag {
+		return false
+	}
+	if !matchName(branch, f.Branches, f.BranchesIgnore) {
+		return false
+	}
+	return matchPaths(ev.ChangedFiles, f.Paths, f.PathsIgnore)
+}
+
+func matchPullRequest(f *parser.PullRequestFilter, ev Event) bool {
+	action := ev.Action
+	if action == "" {
+		action = "opened"
+	}
+	types := []string(f.Types)
+	if len(types) == 0 {
+		types = defaultPullRequestTypes
+	}
+	found := false
+	for _, t := range types {
+		if t == action {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	branch := ev.Branch()
+	if branch == "" {
+		return false
+	}
+	if !matchName(branch, f.Branches, f.BranchesIgnore) {
+		return false
+	}
+	return matchPaths(ev.ChangedFiles, f.Paths, f.PathsIgnore)
+}
+
+// matchName 匹配分支或标签名：include 为空表示全部
+func matchName(name string, include, ignore []string) bool {
+	if len(include) > 0 && !MatchPatterns(include, name) {
+		return false
+	}
+	return !MatchPatterns(ignore, name)
+}
+
+// matchPaths 匹配变更文件；变更列表未知时不过滤
+func matchPaths(files, include, ignore []string) bool {
+	if len(files) == 0 || (len(include) == 0 && len(ignore) == 0) {
+		return true
+	}
+	for _, f := range files {
+		if len(include) > 0 && !MatchPatterns(include, f) {
+			continue
+		}
+		if MatchPatterns(ignore, f) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// MatchPatterns 按顺序求值模式列表：普通模式命中时纳入，! 开头的模式命中时排除，后出现的模式覆盖先前结果
+// 例如 [release/**, !release/**-alpha] 匹配 release/1.0、不匹配 release/1.0-alpha
+func MatchPatterns(patterns []string, s string) bool {
+	matched := false
+	for _, p := range patterns {
+		if neg, ok := strings.CutPrefix(p, "!"); ok {
+			if matched && Glob(neg, s) {
+				matched = false
+			}
+			continue
+		}
+		if !matched && Glob(p, s) {
+			matched = true
+		}
+	}
+	return matched
+}
@@ -0,0 +1,227 @@
+package trigger
+
+import (
+	"strings"
+	"testing"
+
+	"xcoding/apps/ci/executor_service/parser"
+)
+
+func parseOn(t *testing.T, on string) *parser.Triggers {
+	t.Helper()
+	wf, err := parser.ParseWorkflowYAML(on + "\njobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - run: echo\n")
+	if err != nil {
+		t.Fatalf("parse %q: %v", on, err)
+	}
+	return &wf.On
+}
+
+func TestGlob(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"main", "main", true},
+		{"main", "main2", false},
+		{"*", "feature", true},
+		{"*", "feature/x", false},
+		{"feature/*", "feature/x", true},
+		{"feature/*", "feature/x/y", false},
+		{"feature/**", "feature/x/y", true},
+		{"**", "a/b/c", true},
+		{"**/*.go", "cmd/main.go", true},
+		{"**/*.go", "main.go", false},
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"docs/**", "docs/a/b.md", true},
+		{"v?.0", "v1.0", true},
+		{"v?.0", "v10.0", false},
+		{"v[12].*", "v2.3", true},
+		{"v[12].*", "v3.3", false},
+		{"v[0-9]+.[0-9]+", "v10.22", true},
+		{"v[0-9]+.[0-9]+", "v.1", false},
+		{"release-+", "release--", true},
+		{`a\*b`, "a*b", true},
+		{`a\*b`, "axb", false},
+		{"a.b", "axb", false},
+		{"[z-a]", "[z-a]", true},
+	}
+	for _, c := range cases {
+		if got := Glob(c.pattern, c.s); got != c.want {
+			t.Errorf("Glob(%q, %q) = %v, want %v", c.pattern, c.s, got, c.want)
+		}
+	}
+}
+
+func TestValidateGlob(t *testing.T) {
+	for _, p := range []string{"main", "!release/**", "v[0-9]+", `a\*`} {
+		if err := ValidateGlob(p); err != nil {
+			t.Errorf("ValidateGlob(%q): %v", p, err)
+		}
+	}
+	for _, p := range []string{"", "+a", "**+", "[abc", `a\`, "[z-a]"} {
+		if err := ValidateGlob(p); err == nil {
+			t.Errorf("ValidateGlob(%q): expected error", p)
+		}
+	}
+}
+
+func TestMatchPatterns(t *testing.T) {
+	cases := []struct {
+		patterns []string
+		s        string
+		want     bool
+	}{
+		{nil, "main", false},
+		{[]string{"main"}, "main", true},
+		{[]string{"release/**", "!release/**-alpha"}, "release/1.0", true},
+		{[]string{"release/**", "!release/**-alpha"}, "release/1.0-alpha", false},
+		{[]string{"release/**", "!release/**-alpha", "release/2.0-alpha"}, "release/2.0-alpha", true},
+		{[]string{"!main"}, "main", false},
+		{[]string{"!main"}, "dev", false},
+		{[]string{"!main", "**"}, "main", true},
+		{[]string{"**", "!main"}, "main", false},
+	}
+	for _, c := range cases {
+		if got := MatchPatterns(c.patterns, c.s); got != c.want {
+			t.Errorf("MatchPatterns(%q, %q) = %v, want %v", c.patterns, c.s, got, c.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	push := func(ref string, files ...string) Event {
+		return Event{Name: EventPush, Ref: ref, ChangedFiles: files}
+	}
+	pr := func(action, base string, files ...string) Event {
+		return Event{Name: EventPullRequest, Action: action, Ref: "refs/heads/" + base, ChangedFiles: files}
+	}
+	cases := []struct {
+		name string
+		on   string
+		ev   Event
+		want bool
+	}{
+		// 事件声明
+		{"no on", "", push("refs/heads/main"), false},
+		{"scalar push", "on: push", push("refs/heads/main"), true},
+		{"list push tag", "on: [push]", push("refs/tags/v1"), true},
+		{"other event only", "on: [workflow_dispatch]", push("refs/heads/main"), false},
+		{"schedule only", "on:\n  schedule:\n    - cron: '0 0 * * *'", push("refs/heads/main"), false},
+		{"unknown ref", "on: push", push("refs/pull/1/head"), false},
+		{"empty push map", "on:\n  push:", push("refs/heads/dev"), true},
+
+		// branches / branches-ignore
+		{"branches hit", "on:\n  push:\n    branches: [main]", push("refs/heads/main"), true},
+		{"branches miss", "on:\n  push:\n    branches: [main]", push("refs/heads/dev"), false},
+		{"branches scalar", "on:\n  push:\n    branches: main", push("refs/heads/main"), true},
+		{"branches glob", "on:\n  push:\n    branches: ['feature/*']", push("refs/heads/feature/login"), true},
+		{"branches glob depth", "on:\n  push:\n    branches: ['feature/*']", push("refs/heads/feature/a/b"), false},
+		{"branches double star", "on:\n  push:\n    branches: ['feature/**']", push("refs/heads/feature/a/b"), true},
+		{"branches negation", "on:\n  push:\n    branches: ['release/**', '!release/**-alpha']", push("refs/heads/release/1.0-alpha"), false},
+		{"branches negation other", "on:\n  push:\n    branches: ['release/**', '!release/**-alpha']", push("refs/heads/release/1.0"), true},
+		{"branches re-include", "on:\n  push:\n    branches: ['**', '!dev*', 'dev-keep']", push("refs/heads/dev-keep"), true},
+		{"branches only tag push", "on:\n  push:\n    branches: [main]", push("refs/tags/v1"), false},
+		{"branches-ignore hit", "on:\n  push:\n    branches-ignore: ['wip/**']", push("refs/heads/wip/x"), false},
+		{"branches-ignore miss", "on:\n  push:\n    branches-ignore: ['wip/**']", push("refs/heads/main"), true},
+		{"branches-ignore negation", "on:\n  push:\n    branches-ignore: ['wip/**', '!wip/keep']", push("refs/heads/wip/keep"), true},
+		{"branches-ignore only tag push", "on:\n  push:\n    branches-ignore: [dev]", push("refs/tags/v1"), false},
+
+		// tags / tags-ignore
+		{"tags hit", "on:\n  push:\n    tags: ['v*']", push("refs/tags/v1.2.0"), true},
+		{"tags miss", "on:\n  push:\n    tags: ['v*']", push("refs/tags/rc1"), false},
+		{"tags only branch push", "on:\n  push:\n    tags: ['v*']", push("refs/heads/main"), false},
+		{"tags plus pattern", "on:\n  push:\n    tags: ['v[0-9]+.[0-9]+.[0-9]+']", push("refs/tags/v10.2.33"), true},
+		{"tags plus pattern miss", "on:\n  push:\n    tags: ['v[0-9]+.[0-9]+.[0-9]+']", push("refs/tags/v10.2"), false},
+		{"tags-ignore hit", "on:\n  push:\n    tags-ignore: ['*-rc*']", push("refs/tags/v1-rc1"), false},
+		{"tags-ignore miss", "on:\n  push:\n    tags-ignore: ['*-rc*']", push("refs/tags/v1"), true},
+		{"branches and tags: branch", "on:\n  push:\n    branches: [main]\n    tags: ['v*']", push("refs/heads/main"), true},
+		{"branches and tags: tag", "on:\n  push:\n    branches: [main]\n    tags: ['v*']", push("refs/tags/v2"), true},
+
+		// paths / paths-ignore
+		{"paths hit", "on:\n  push:\n    paths: ['src/**']", push("refs/heads/main", "src/a/b.go", "README.md"), true},
+		{"paths miss", "on:\n  push:\n    paths: ['src/**']", push("refs/heads/main", "README.md"), false},
+		{"paths unknown files", "on:\n  push:\n    paths: ['src/**']", push("refs/heads/main"), true},
+		{"paths ext", "on:\n  push:\n    paths: ['**.go']", push("refs/heads/main", "cmd/x/main.go"), true},
+		{"paths negation", "on:\n  push:\n    paths: ['src/**', '!src/**/*.md']", push("refs/heads/main", "src/doc/a.md"), false},
+		{"paths negation mixed", "on:\n  push:\n    paths: ['src/**', '!src/**/*.md']", push("refs/heads/main", "src/doc/a.md", "src/a.go"), true},
+		{"paths-ignore all ignored", "on:\n  push:\n    paths-ignore: ['docs/**', '*.md']", push("refs/heads/main", "docs/a.txt", "README.md"), false},
+		{"paths-ignore some kept", "on:\n  push:\n    paths-ignore: ['docs/**']", push("refs/heads/main", "docs/a.txt", "go.mod"), true},
+		{"paths-ignore negation", "on:\n  push:\n    paths-ignore: ['docs/**', '!docs/api/**']", push("refs/heads/main", "docs/api/x.yaml"), true},
+		{"paths ignored for tags", "on:\n  push:\n    tags: ['v*']\n    paths: ['src/**']", push("refs/tags/v1", "README.md"), true},
+		{"branches and paths", "on:\n  push:\n    branches: [main]\n    paths: ['src/**']", push("refs/heads/dev", "src/a.go"), false},
+
+		// pull_request
+		{"pr default", "on: pull_request", pr("opened", "main"), true},
+		{"pr empty action", "on: pull_request", pr("", "main"), true},
+		{"pr default types exclude closed", "on: pull_request", pr("closed", "main"), false},
+		{"pr types", "on:\n  pull_request:\n    types: [closed]", pr("closed", "main"), true},
+		{"pr types miss", "on:\n  pull_request:\n    types: [closed]", pr("opened", "main"), false},
+		{"pr base branches", "on:\n  pull_request:\n    branches: [main, 'release/**']", pr("synchronize", "release/1.x"), true},
+		{"pr base branches miss", "on:\n  pull_request:\n    branches: [main]", pr("synchronize", "dev"), false},
+		{"pr branches-ignore", "on:\n  pull_request:\n    branches-ignore: ['dev*']", pr("opened", "dev2"), false},
+		{"pr paths", "on:\n  pull_request:\n    paths: ['api/**']", pr("opened", "main", "web/a.ts"), false},
+		{"pr paths hit", "on:\n  pull_request:\n    paths: ['api/**']", pr("reopened", "main", "api/v1/x.proto"), true},
+		{"pr tag base", "on: pull_request", Event{Name: EventPullRequest, Ref: "refs/tags/v1"}, false},
+		{"push only, pr event", "on: push", pr("opened", "main"), false},
+		{"pr only, push event", "on: pull_request", push("refs/heads/main"), false},
+	}
+	for _, c := range cases {
+		var on *parser.Triggers
+		if c.on != "" {
+			on = parseOn(t, c.on)
+		}
+		if got := Match(on, c.ev); got != c.want {
+			t.Errorf("%s: Match(%q, %+v) = %v, want %v", c.name, c.on, c.ev, got, c.want)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	cases := []struct {
+		name string
+		on   string
+		want []string // 每条期望问题中应包含的片段；为空表示无问题
+	}{
+		{"push all", "on: push", nil},
+		{"normal filters", "on:\n  push:\n    branches: ['release/**', '!release/**-alpha']\n    paths-ignore: ['docs/**']", nil},
+		{"schedule and dispatch", "on:\n  schedule:\n    - cron: '0 0 * * *'\n  workflow_dispatch:", nil},
+		{"unsupported event", "on: [push, release]", []string{"on.release: event is not supported"}},
+		{"only negations", "on:\n  push:\n    branches: ['!main']", []string{
+			"line 2, column 3: on.push.branches: no pattern can match",
+			"on.push: never matches any branch or tag",
+		}},
+		{"negated everything", "on:\n  push:\n    branches: [main, '!**']\n    tags: ['v*']", []string{"on.push.branches: no pattern can match"}},
+		{"ignore everything", "on:\n  push:\n    branches-ignore: ['**']", []string{
+			"on.push.branches-ignore: ignores everything",
+			"on.push: never matches any branch or tag",
+		}},
+		{"ignore all but some", "on:\n  push:\n    branches-ignore: ['**', '!main']", nil},
+		{"tags never", "on:\n  push:\n    tags: ['!v1']", []string{
+			"on.push.tags: no pattern can match",
+			"on.push: never matches any branch or tag",
+		}},
+		{"paths ignore all", "on:\n  push:\n    paths-ignore: ['**']", []string{"on.push.paths-ignore: ignores everything"}},
+		{"paths ignore all, branches only", "on:\n  push:\n    branches: [main]\n    paths-ignore: ['**']", []string{
+			"on.push.paths-ignore: ignores everything",
+			"on.push: never matches any branch or tag",
+		}},
+		{"invalid pattern", "on:\n  push:\n    branches: ['[abc']", []string{"on.push.branches: invalid pattern"}},
+		{"pr never", "on:\n  pull_request:\n    branches: ['!main']", []string{
+			"on.pull_request.branches: no pattern can match",
+			"on.pull_request: never matches any branch",
+		}},
+	}
+	for _, c := range cases {
+		issues := Lint(parseOn(t, c.on))
+		if len(issues) != len(c.want) {
+			t.Errorf("%s: got %d issues %v, want %d", c.name, len(issues), issues, len(c.want))
+			continue
+		}
+		for i, w := range c.want {
+			if !strings.Contains(issues[i].String(), w) {
+				t.Errorf("%s: issue %d = %q, want it to contain %q", c.name, i, issues[i].String(), w)
+			}
+		}
+	}
+}
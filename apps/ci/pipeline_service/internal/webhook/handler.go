@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/status"
+
+	"xcoding/apps/ci/pipeline_service/internal/models"
+)
+
+// maxBodyBytes 单次投递负载上限：平台会截断推送负载中的提交列表（GitHub/GitLab 最多 20 个），正常负载远小于此值
+const maxBodyBytes = 2 << 20
+
+// Receiver 处理已识别平台的投递；返回的错误为 gRPC status
+type Receiver interface {
+	ReceiveWebhook(ctx context.Context, d *Delivery, h http.Header) (*models.WebhookDelivery, error)
+}
+
+type response struct {
+	ID       uint64   `json:"id,omitempty"`
+	Status   string   `json:"status,omitempty"`
+	Message  string   `json:"message,omitempty"`
+	BuildIDs []uint64 `json:"build_ids,omitempty"`
+}
+
+// NewHandler 返回 Webhook 接收入口（POST）
+// 该入口不经过网关鉴权，身份由仓库密钥签名保证
+func NewHandler(recv Receiver) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, response{Message: "method not allowed"})
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			writeJSON(w, http.StatusRequestEntityTooLarge, response{Message: err.Error()})
+			return
+		}
+		d, err := FromRequest(r.Header, body)
+		if err != nil {
+			code := http.StatusBadRequest
+			if !errors.Is(err, ErrUnknownProvider) {
+				code = http.StatusInternalServerError
+			}
+			writeJSON(w, code, response{Message: err.Error()})
+			return
+		}
+		rec, err := recv.ReceiveWebhook(r.Context(), d, r.Header)
+		resp := response{}
+		if rec != nil {
+			resp = response{ID: rec.ID, Status: rec.Status, Message: rec.Message, BuildIDs: rec.GetBuildIDs()}
+		}
+		if err != nil {
+			st := status.Convert(err)
+			resp.Message = st.Message()
+			writeJSON(w, runtime.HTTPStatusFromCode(st.Code()), resp)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,168 @@
+// Package webhook 解析 GitHub、GitLab、Gitea 的入站 Webhook 请求
+// 说明：
+// - 按请求头识别平台、事件类型与签名；签名由 code_repository 按仓库密钥校验（VerifyWebhook）
+// - 将 push（含标签推送）负载统一为 PushEvent
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 支持的平台
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+)
+
+// ErrUnknownProvider 无法从请求头识别平台
+var ErrUnknownProvider = errors.New("unknown webhook provider")
+
+// Delivery 一次 Webhook 投递（原始请求）
+type Delivery struct {
+	Provider   string
+	Event      string // 平台原始事件名，如 push / Push Hook / Tag Push Hook
+	DeliveryID string // 平台投递 ID（可能为空）
+	Signature  string // GitHub/Gitea 为 HMAC 十六进制摘要；GitLab 为 X-Gitlab-Token 明文
+	Body       []byte
+}
+
+// FromRequest 从请求头识别平台与事件
+// Gitea 同时发送 X-GitHub-Event 兼容头，需优先识别
+func FromRequest(h http.Header, body []byte) (*Delivery, error) {
+	d := &Delivery{Body: body}
+	switch {
+	case h.Get("X-Gitea-Event") != "":
+		d.Provider = ProviderGitea
+		d.Event = h.Get("X-Gitea-Event")
+		d.DeliveryID = h.Get("X-Gitea-Delivery")
+		d.Signature = h.Get("X-Gitea-Signature")
+	case h.Get("X-Gitlab-Event") != "":
+		d.Provider = ProviderGitLab
+		d.Event = h.Get("X-Gitlab-Event")
+		d.DeliveryID = h.Get("X-Gitlab-Event-UUID")
+		d.Signature = h.Get("X-Gitlab-Token")
+	case h.Get("X-GitHub-Event") != "":
+		d.Provider = ProviderGitHub
+		d.Event = h.Get("X-GitHub-Event")
+		d.DeliveryID = h.Get("X-GitHub-Delivery")
+		d.Signature = strings.TrimPrefix(h.Get("X-Hub-Signature-256"), "sha256=")
+	default:
+		return nil, ErrUnknownProvider
+	}
+	return d, nil
+}
+
+// IsPing 是否为平台的连通性测试事件
+func (d *Delivery) IsPing() bool {
+	return d.Provider == ProviderGitHub && d.Event == "ping"
+}
+
+// IsPush 是否为推送事件（含标签推送）
+func (d *Delivery) IsPush() bool {
+	switch d.Provider {
+	case ProviderGitLab:
+		return d.Event == "Push Hook" || d.Event == "Tag Push Hook"
+	default:
+		return d.Event == "push"
+	}
+}
+
+// PushEvent 统一的推送事件
+type PushEvent struct {
+	Ref          string   // refs/heads/<branch> 或 refs/tags/<tag>
+	Before       string   // 推送前提交
+	After        string   // 推送后提交（CommitSHA）
+	Deleted      bool     // 分支/标签被删除
+	RepoURLs     []string // 仓库地址候选（https/ssh/web），用于解析 code_repository 仓库
+	ChangedFiles []string // 推送中各提交变更的文件（去重）
+	Pusher       string
+}
+
+// Branch 返回分支名；标签推送返回空
+func (e *PushEvent) Branch() string {
+	if b, ok := strings.CutPrefix(e.Ref, "refs/heads/"); ok {
+		return b
+	}
+	return ""
+}
+
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+type payloadCommit struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+type payloadRepo struct {
+	CloneURL   string `json:"clone_url"`
+	SSHURL     string `json:"ssh_url"`
+	HTMLURL    string `json:"html_url"`
+	GitHTTPURL string `json:"git_http_url"`
+	GitSSHURL  string `json:"git_ssh_url"`
+	WebURL     string `json:"web_url"`
+	Homepage   string `json:"homepage"`
+}
+
+type pushPayload struct {
+	Ref         string          `json:"ref"`
+	Before      string          `json:"before"`
+	After       string          `json:"after"`
+	CheckoutSHA string          `json:"checkout_sha"` // GitLab
+	Deleted     bool            `json:"deleted"`      // GitHub
+	Repository  payloadRepo     `json:"repository"`
+	Project     payloadRepo     `json:"project"` // GitLab
+	Commits     []payloadCommit `json:"commits"`
+	Pusher      struct {
+		Name     string `json:"name"`
+		Login    string `json:"login"`
+		Username string `json:"username"`
+	} `json:"pusher"`
+	UserUsername string `json:"user_username"` // GitLab
+}
+
+// ParsePush 解析推送负载（三种平台字段基本兼容，差异字段分别读取）
+func ParsePush(body []byte) (*PushEvent, error) {
+	var p pushPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("decode push payload: %w", err)
+	}
+	if !strings.HasPrefix(p.Ref, "refs/heads/") && !strings.HasPrefix(p.Ref, "refs/tags/") {
+		return nil, fmt.Errorf("unsupported ref %q", p.Ref)
+	}
+	ev := &PushEvent{Ref: p.Ref, Before: p.Before, After: p.After}
+	if p.CheckoutSHA != "" {
+		ev.After = p.CheckoutSHA
+	}
+	ev.Deleted = p.Deleted || p.After == zeroSHA
+	for _, r := range []payloadRepo{p.Repository, p.Project} {
+		for _, u := range []string{r.CloneURL, r.SSHURL, r.HTMLURL, r.GitHTTPURL, r.GitSSHURL, r.WebURL, r.Homepage} {
+			if u != "" {
+				ev.RepoURLs = append(ev.RepoURLs, u)
+			}
+		}
+	}
+	seen := map[string]bool{}
+	for _, c := range p.Commits {
+		for _, list := range [][]string{c.Added, c.Removed, c.Modified} {
+			for _, f := range list {
+				if !seen[f] {
+					seen[f] = true
+					ev.ChangedFiles = append(ev.ChangedFiles, f)
+				}
+			}
+		}
+	}
+	for _, n := range []string{p.Pusher.Login, p.Pusher.Username, p.Pusher.Name, p.UserUsername} {
+		if n != "" {
+			ev.Pusher = n
+			break
+		}
+	}
+	return ev, nil
+}
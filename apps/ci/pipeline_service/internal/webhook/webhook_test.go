@@ -0,0 +1,120 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func header(kv ...string) http.Header {
+	h := http.Header{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		h.Set(kv[i], kv[i+1])
+	}
+	return h
+}
+
+func TestFromRequest(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	sig := sign("s3cret", body)
+	cases := []struct {
+		name      string
+		header    http.Header
+		provider  string
+		event     string
+		delivery  string
+		signature string
+	}{
+		{"github", header("X-GitHub-Event", "push", "X-GitHub-Delivery", "d1", "X-Hub-Signature-256", "sha256="+sig), ProviderGitHub, "push", "d1", sig},
+		{"github without prefix", header("X-GitHub-Event", "push", "X-Hub-Signature-256", sig), ProviderGitHub, "push", "", sig},
+		{"github missing signature", header("X-GitHub-Event", "push"), ProviderGitHub, "push", "", ""},
+		{"gitea", header("X-Gitea-Event", "push", "X-Gitea-Delivery", "d2", "X-Gitea-Signature", sig), ProviderGitea, "push", "d2", sig},
+		// Gitea 同时发送 GitHub 兼容头：按 Gitea 识别并取 X-Gitea-Signature
+		{"gitea with github headers", header("X-Gitea-Event", "push", "X-Gitea-Signature", sig, "X-GitHub-Event", "push", "X-Hub-Signature-256", "sha256=bad"), ProviderGitea, "push", "", sig},
+		{"gitlab", header("X-Gitlab-Event", "Tag Push Hook", "X-Gitlab-Event-UUID", "d3", "X-Gitlab-Token", "s3cret"), ProviderGitLab, "Tag Push Hook", "d3", "s3cret"},
+	}
+	for _, c := range cases {
+		d, err := FromRequest(c.header, body)
+		if err != nil {
+			t.Errorf("%s: FromRequest: %v", c.name, err)
+			continue
+		}
+		if d.Provider != c.provider || d.Event != c.event || d.DeliveryID != c.delivery || d.Signature != c.signature {
+			t.Errorf("%s: delivery = %s/%s/%s sig %q, want %s/%s/%s sig %q", c.name, d.Provider, d.Event, d.DeliveryID, d.Signature, c.provider, c.event, c.delivery, c.signature)
+		}
+		if !d.IsPush() {
+			t.Errorf("%s: IsPush = false", c.name)
+		}
+	}
+	if _, err := FromRequest(header("X-Event", "push"), body); err != ErrUnknownProvider {
+		t.Errorf("FromRequest(unknown) error = %v, want ErrUnknownProvider", err)
+	}
+}
+
+func TestParsePush(t *testing.T) {
+	cases := []struct {
+		name    string
+		body    string
+		want    PushEvent
+		branch  string
+		wantErr string
+	}{
+		{
+			name: "github branch",
+			body: `{"ref":"refs/heads/main","before":"a1","after":"b2","repository":{"clone_url":"https://github.com/o/r.git","ssh_url":"git@github.com:o/r.git","html_url":"https://github.com/o/r"},
+				"commits":[{"added":["a.go"],"modified":["b.go"]},{"modified":["a.go"],"removed":["c.go"]}],"pusher":{"name":"alice"}}`,
+			want: PushEvent{Ref: "refs/heads/main", Before: "a1", After: "b2",
+				RepoURLs:     []string{"https://github.com/o/r.git", "git@github.com:o/r.git", "https://github.com/o/r"},
+				ChangedFiles: []string{"a.go", "b.go", "c.go"}, Pusher: "alice"},
+			branch: "main",
+		},
+		{
+			name: "gitlab tag",
+			body: `{"ref":"refs/tags/v1.0","before":"` + zeroSHA + `","after":"c3","checkout_sha":"d4","project":{"git_http_url":"https://gitlab.com/o/r.git","git_ssh_url":"git@gitlab.com:o/r.git","web_url":"https://gitlab.com/o/r"},"user_username":"bob"}`,
+			want: PushEvent{Ref: "refs/tags/v1.0", Before: zeroSHA, After: "d4",
+				RepoURLs: []string{"https://gitlab.com/o/r.git", "git@gitlab.com:o/r.git", "https://gitlab.com/o/r"}, Pusher: "bob"},
+		},
+		{
+			name:   "github deleted branch",
+			body:   `{"ref":"refs/heads/feature","before":"a1","after":"` + zeroSHA + `","deleted":true,"pusher":{"name":"alice"}}`,
+			want:   PushEvent{Ref: "refs/heads/feature", Before: "a1", After: zeroSHA, Deleted: true, Pusher: "alice"},
+			branch: "feature",
+		},
+		{
+			name: "gitea deleted tag",
+			body: `{"ref":"refs/tags/v1.0","before":"a1","after":"` + zeroSHA + `","pusher":{"login":"carol","username":"carol2"}}`,
+			want: PushEvent{Ref: "refs/tags/v1.0", Before: "a1", After: zeroSHA, Deleted: true, Pusher: "carol"},
+		},
+		{name: "unsupported ref", body: `{"ref":"refs/pull/1/head"}`, wantErr: "unsupported ref"},
+		{name: "invalid json", body: `{`, wantErr: "decode push payload"},
+	}
+	for _, c := range cases {
+		ev, err := ParsePush([]byte(c.body))
+		if c.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("%s: error = %v, want %q", c.name, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*ev, c.want) {
+			t.Errorf("%s: ParsePush = %+v, want %+v", c.name, *ev, c.want)
+		}
+		if ev.Branch() != c.branch {
+			t.Errorf("%s: Branch = %q, want %q", c.name, ev.Branch(), c.branch)
+		}
+	}
+}
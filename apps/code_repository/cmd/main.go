@@ -17,6 +17,7 @@ import (
 	"xcoding/apps/code_repository/internal/grpc/interceptors"
 	"xcoding/apps/code_repository/internal/models"
 	"xcoding/apps/code_repository/internal/service"
+	civ1 "xcoding/gen/go/ci/v1"
 	coderepositoryv1 "xcoding/gen/go/code_repository/v1"
 	projectv1 "xcoding/gen/go/project/v1"
 	repodb "xcoding/pkg/db"
@@ -67,12 +68,32 @@ func startGRPCServer(cfg *config.Config, gdb *gorm.DB, grpcAddr string) *grpc.Se
 	}
 	projectClient := projectv1.NewProjectServiceClient(projectConn)
 
+	// 拨号 CI 执行器（发放仓库凭据前校验构建正在执行）
+	var executorClient civ1.ExecutorServiceClient
+	if cfg.Executor.Address != "" {
+		executorConn, err := grpc.DialContext(
+			context.Background(),
+			fmt.Sprintf("%s:%d", cfg.Executor.Address, cfg.Executor.Port),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		if err != nil {
+			log.Fatalf("拨号 CI 执行器失败: %v", err)
+		}
+		executorClient = civ1.NewExecutorServiceClient(executorConn)
+	} else {
+		log.Printf("未配置 CI 执行器地址，GetRepositoryCredentials 将拒绝所有请求")
+	}
+	if cfg.Internal.ServiceToken == "" {
+		log.Printf("未配置 INTERNAL_SERVICE_TOKEN，内部接口将拒绝所有请求")
+	}
+
 	// 初始化服务与 gRPC 处理器
-	svc := service.NewCodeRepositoryService(gdb, projectClient)
+	svc := service.NewCodeRepositoryService(gdb, projectClient, executorClient)
 	grpcServer := server.StartGRPCServer(
 		grpcAddr,
 		[]grpc.UnaryServerInterceptor{
 			interceptors.RecoveryInterceptor(),
+			interceptors.ServiceTokenInterceptor(cfg),
 			interceptors.LoggingInterceptor(cfg),
 			interceptors.MetricsInterceptor(cfg),
 		},
@@ -18,6 +18,8 @@ type Config struct {
 	App      AppConfig           `mapstructure:"app"`
 	Logging  LoggingConfig       `mapstructure:"logging"`
 	Project  ProjectClientConfig `mapstructure:"project"`
+	Executor ProjectClientConfig `mapstructure:"executor"`
+	Internal InternalConfig      `mapstructure:"internal"`
 }
 
 type DatabaseConfig struct {
@@ -82,6 +84,11 @@ type ProjectClientConfig struct {
 	Port    int    `mapstructure:"port"`
 }
 
+// 内部调用配置：服务间共享令牌（内部接口须携带 x-service-token，未配置时内部接口一律拒绝）
+type InternalConfig struct {
+	ServiceToken string `mapstructure:"service_token"`
+}
+
 func Load() (*Config, error) {
 	viper.SetEnvPrefix("XCODING")
 	// 移除 AutomaticEnv，避免 K8s 注入的服务环境变量（例如 PROJECT_PORT）覆盖我们的显式绑定
@@ -103,6 +110,10 @@ func Load() (*Config, error) {
 	// 外部 Project 服务地址
 	viper.BindEnv("project.address", "PROJECT_GRPC_ADDRESS")
 	viper.BindEnv("project.port", "PROJECT_GRPC_PORT")
+	// CI 执行器地址（校验申请仓库凭据的构建正在运行）
+	viper.BindEnv("executor.address", "CI_EXECUTOR_GRPC_ADDRESS")
+	viper.BindEnv("executor.port", "CI_EXECUTOR_GRPC_PORT")
+	viper.BindEnv("internal.service_token", "INTERNAL_SERVICE_TOKEN")
 
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
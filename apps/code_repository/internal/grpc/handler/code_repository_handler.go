@@ -33,7 +33,7 @@ func (h *CodeRepositoryGRPCHandler) CreateRepository(ctx context.Context, req *c
 	if req.Branch == "" {
 		req.Branch = "main"
 	}
-	repo, err := h.session.CreateRepository(ctx, req.ProjectId, req.Name, req.Description, req.GitUrl, req.Branch, req.AuthType, req.GitUsername, req.GitPassword, req.GitSshKey)
+	repo, err := h.session.CreateRepository(ctx, req.ProjectId, req.Name, req.Description, req.GitUrl, req.Branch, req.AuthType, req.GitUsername, req.GitPassword, req.GitSshKey, req.WebhookSecret)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +103,9 @@ func (h *CodeRepositoryGRPCHandler) UpdateRepository(ctx context.Context, req *c
 	if req.GitSshKey != "" {
 		updates["git_ssh_key"] = req.GitSshKey
 	}
+	if req.WebhookSecret != "" {
+		updates["webhook_secret"] = req.WebhookSecret
+	}
 	updates["is_active"] = req.IsActive
 	repo, err := h.session.UpdateRepository(ctx, req.ProjectId, req.RepositoryId, updates)
 	if err != nil {
@@ -158,3 +161,27 @@ func (h *CodeRepositoryGRPCHandler) GetRepositoryBranches(ctx context.Context, r
 // 分支处理器已迁移至 branch.go
 
 // 提交处理器已迁移至 commit.go
+
+// 校验 Webhook 投递：内部接口，供 CI Webhook 使用（密钥不出本服务）
+func (h *CodeRepositoryGRPCHandler) VerifyWebhook(ctx context.Context, req *coderepositoryv1.VerifyWebhookRequest) (*coderepositoryv1.VerifyWebhookResponse, error) {
+	if len(req.GetGitUrls()) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "git_urls is required")
+	}
+	return h.session.VerifyWebhook(ctx, req.GetGitUrls(), req.GetProvider(), req.GetSignature(), req.GetBody())
+}
+
+// 获取仓库克隆凭据：内部接口，供 CI 执行器检出代码使用
+func (h *CodeRepositoryGRPCHandler) GetRepositoryCredentials(ctx context.Context, req *coderepositoryv1.GetRepositoryCredentialsRequest) (*coderepositoryv1.GetRepositoryCredentialsResponse, error) {
+	if req.GetProjectId() == 0 || req.GetBuildId() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "project_id and build_id are required")
+	}
+	return h.session.GetRepositoryCredentials(ctx, req.GetProjectId(), req.GetBuildId(), req.GetName())
+}
+
+// 获取仓库默认分支：内部接口，供 CI 执行器缓存回退查找使用
+func (h *CodeRepositoryGRPCHandler) GetRepositoryDefaultBranch(ctx context.Context, req *coderepositoryv1.GetRepositoryDefaultBranchRequest) (*coderepositoryv1.GetRepositoryDefaultBranchResponse, error) {
+	if req.GetProjectId() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "project_id is required")
+	}
+	return h.session.GetRepositoryDefaultBranch(ctx, req.GetProjectId(), req.GetName())
+}
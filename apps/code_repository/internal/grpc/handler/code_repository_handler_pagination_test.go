@@ -13,7 +13,7 @@ import (
 // fake service implementing CodeRepositoryService for tests
 type fakeService struct{}
 
-func (f *fakeService) CreateRepository(ctx context.Context, projectID uint64, name, description, gitURL, branch string, authType coderepositoryv1.RepositoryAuthType, gitUsername, gitPassword, gitSSHKey string) (*coderepositoryv1.Repository, error) {
+func (f *fakeService) CreateRepository(ctx context.Context, projectID uint64, name, description, gitURL, branch string, authType coderepositoryv1.RepositoryAuthType, gitUsername, gitPassword, gitSSHKey, webhookSecret string) (*coderepositoryv1.Repository, error) {
 	return nil, nil
 }
 func (f *fakeService) GetRepository(ctx context.Context, projectID, repositoryID uint64) (*coderepositoryv1.Repository, error) {
@@ -34,6 +34,15 @@ func (f *fakeService) TestRepositoryConnection(ctx context.Context, projectID, r
 func (f *fakeService) GetRepositoryBranches(ctx context.Context, projectID, repositoryID uint64, page, pageSize int32) ([]string, int32, int32, error) {
 	return []string{}, 0, 0, nil
 }
+func (f *fakeService) VerifyWebhook(ctx context.Context, gitURLs []string, provider, signature string, body []byte) (*coderepositoryv1.VerifyWebhookResponse, error) {
+	return nil, nil
+}
+func (f *fakeService) GetRepositoryCredentials(ctx context.Context, projectID, buildID uint64, name string) (*coderepositoryv1.GetRepositoryCredentialsResponse, error) {
+	return nil, nil
+}
+func (f *fakeService) GetRepositoryDefaultBranch(ctx context.Context, projectID uint64, name string) (*coderepositoryv1.GetRepositoryDefaultBranchResponse, error) {
+	return nil, nil
+}
 
 // Branch
 func (f *fakeService) CreateBranch(ctx context.Context, projectID, repositoryID uint64, name string, isDefault bool) (*coderepositoryv1.Branch, error) {
@@ -10,8 +10,16 @@ import (
 	"google.golang.org/grpc/status"
 
 	"xcoding/apps/code_repository/internal/config"
+	"xcoding/pkg/auth"
 )
 
+// internalMethods 仅供内部服务调用的方法（返回凭据或校验 Webhook 密钥），须携带服务间共享令牌
+var internalMethods = map[string]bool{
+	"/code_repository.v1.CodeRepositoryService/VerifyWebhook":              true,
+	"/code_repository.v1.CodeRepositoryService/GetRepositoryCredentials":   true,
+	"/code_repository.v1.CodeRepositoryService/GetRepositoryDefaultBranch": true,
+}
+
 // RecoveryInterceptor 捕获panic
 func RecoveryInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
@@ -41,6 +49,18 @@ func LoggingInterceptor(cfg *config.Config) grpc.UnaryServerInterceptor {
 	}
 }
 
+// ServiceTokenInterceptor 校验内部方法的服务间共享令牌（x-service-token），令牌未配置时拒绝全部内部调用
+func ServiceTokenInterceptor(cfg *config.Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if internalMethods[info.FullMethod] {
+			if err := auth.MustServiceToken(ctx, cfg.Internal.ServiceToken); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
 // MetricsInterceptor 预留
 func MetricsInterceptor(cfg *config.Config) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
@@ -17,12 +17,14 @@ type Repository struct {
 	GitUsername string `gorm:"size:255"`
 	GitPassword string `gorm:"size:1024"`
 	GitSSHKey   string `gorm:"type:text"`
-	IsActive    bool   `gorm:"default:true"`
-	LastSyncAt  *time.Time
-	SyncStatus  int32  `gorm:"type:int"`
-	SyncMessage string `gorm:"type:text"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	// WebhookSecret 入站 push Webhook 的 HMAC 密钥（只写，不在接口中返回）
+	WebhookSecret string `gorm:"size:255"`
+	IsActive      bool   `gorm:"default:true"`
+	LastSyncAt    *time.Time
+	SyncStatus    int32  `gorm:"type:int"`
+	SyncMessage   string `gorm:"type:text"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
 }
 
 func ToProto(m *Repository) *coderepositoryv1.Repository {
@@ -34,20 +36,21 @@ func ToProto(m *Repository) *coderepositoryv1.Repository {
 		lastSyncAt = timestamppb.New(*m.LastSyncAt)
 	}
 	return &coderepositoryv1.Repository{
-		Id:          m.ID,
-		ProjectId:   m.ProjectID,
-		Name:        m.Name,
-		Description: m.Description,
-		GitUrl:      m.GitURL,
-		Branch:      "",
-		AuthType:    coderepositoryv1.RepositoryAuthType(m.AuthType),
-		GitUsername: m.GitUsername,
-		IsActive:    m.IsActive,
-		LastSyncAt:  lastSyncAt,
-		SyncStatus:  coderepositoryv1.RepositorySyncStatus(m.SyncStatus),
-		SyncMessage: m.SyncMessage,
-		CreatedAt:   timestamppb.New(m.CreatedAt),
-		UpdatedAt:   timestamppb.New(m.UpdatedAt),
+		Id:               m.ID,
+		ProjectId:        m.ProjectID,
+		Name:             m.Name,
+		Description:      m.Description,
+		GitUrl:           m.GitURL,
+		Branch:           "",
+		AuthType:         coderepositoryv1.RepositoryAuthType(m.AuthType),
+		GitUsername:      m.GitUsername,
+		IsActive:         m.IsActive,
+		LastSyncAt:       lastSyncAt,
+		SyncStatus:       coderepositoryv1.RepositorySyncStatus(m.SyncStatus),
+		SyncMessage:      m.SyncMessage,
+		CreatedAt:        timestamppb.New(m.CreatedAt),
+		UpdatedAt:        timestamppb.New(m.UpdatedAt),
+		HasWebhookSecret: m.WebhookSecret != "",
 	}
 }
 
@@ -60,20 +63,21 @@ func ToProtoWithBranch(m *Repository, branch string) *coderepositoryv1.Repositor
 		lastSyncAt = timestamppb.New(*m.LastSyncAt)
 	}
 	return &coderepositoryv1.Repository{
-		Id:          m.ID,
-		ProjectId:   m.ProjectID,
-		Name:        m.Name,
-		Description: m.Description,
-		GitUrl:      m.GitURL,
-		Branch:      branch,
-		AuthType:    coderepositoryv1.RepositoryAuthType(m.AuthType),
-		GitUsername: m.GitUsername,
-		IsActive:    m.IsActive,
-		LastSyncAt:  lastSyncAt,
-		SyncStatus:  coderepositoryv1.RepositorySyncStatus(m.SyncStatus),
-		SyncMessage: m.SyncMessage,
-		CreatedAt:   timestamppb.New(m.CreatedAt),
-		UpdatedAt:   timestamppb.New(m.UpdatedAt),
+		Id:               m.ID,
+		ProjectId:        m.ProjectID,
+		Name:             m.Name,
+		Description:      m.Description,
+		GitUrl:           m.GitURL,
+		Branch:           branch,
+		AuthType:         coderepositoryv1.RepositoryAuthType(m.AuthType),
+		GitUsername:      m.GitUsername,
+		IsActive:         m.IsActive,
+		LastSyncAt:       lastSyncAt,
+		SyncStatus:       coderepositoryv1.RepositorySyncStatus(m.SyncStatus),
+		SyncMessage:      m.SyncMessage,
+		CreatedAt:        timestamppb.New(m.CreatedAt),
+		UpdatedAt:        timestamppb.New(m.UpdatedAt),
+		HasWebhookSecret: m.WebhookSecret != "",
 	}
 }
 
@@ -101,6 +105,9 @@ func UpdateModelFromRequest(m *Repository, req *coderepositoryv1.UpdateRepositor
 	if req.GitSshKey != "" {
 		m.GitSSHKey = req.GitSshKey
 	}
+	if req.WebhookSecret != "" {
+		m.WebhookSecret = req.WebhookSecret
+	}
 	// is_active 的布尔值无法判断是否提供，默认覆盖
 	m.IsActive = req.IsActive
 }
@@ -14,6 +14,7 @@ import (
 	"gorm.io/gorm"
 
 	"xcoding/apps/code_repository/internal/models"
+	civ1 "xcoding/gen/go/ci/v1"
 	coderepositoryv1 "xcoding/gen/go/code_repository/v1"
 	projectv1 "xcoding/gen/go/project/v1"
 	"xcoding/pkg/auth"
@@ -22,13 +23,19 @@ import (
 // CodeRepositoryService 定义代码仓库的业务逻辑接口。
 // CRUD 方法在项目作用域内工作。
 type CodeRepositoryService interface {
-	CreateRepository(ctx context.Context, projectID uint64, name, description, gitURL, branch string, authType coderepositoryv1.RepositoryAuthType, gitUsername, gitPassword, gitSSHKey string) (*coderepositoryv1.Repository, error)
+	CreateRepository(ctx context.Context, projectID uint64, name, description, gitURL, branch string, authType coderepositoryv1.RepositoryAuthType, gitUsername, gitPassword, gitSSHKey, webhookSecret string) (*coderepositoryv1.Repository, error)
 	GetRepository(ctx context.Context, projectID, repositoryID uint64) (*coderepositoryv1.Repository, error)
 	ListRepositories(ctx context.Context, projectID uint64, page, pageSize int32) ([]*coderepositoryv1.Repository, int32, int32, error)
 	UpdateRepository(ctx context.Context, projectID, repositoryID uint64, updates map[string]interface{}) (*coderepositoryv1.Repository, error)
 	DeleteRepository(ctx context.Context, projectID, repositoryID uint64) error
 	TestRepositoryConnection(ctx context.Context, projectID, repositoryID uint64) (bool, string, error)
 	GetRepositoryBranches(ctx context.Context, projectID, repositoryID uint64, page, pageSize int32) ([]string, int32, int32, error)
+	// VerifyWebhook 按 Git 地址解析仓库并以其 Webhook 密钥校验投递（内部接口，不返回密钥）
+	VerifyWebhook(ctx context.Context, gitURLs []string, provider, signature string, body []byte) (*coderepositoryv1.VerifyWebhookResponse, error)
+	// GetRepositoryCredentials 获取项目仓库的克隆凭据（内部接口，返回密码与 SSH 私钥）
+	GetRepositoryCredentials(ctx context.Context, projectID, buildID uint64, name string) (*coderepositoryv1.GetRepositoryCredentialsResponse, error)
+	// GetRepositoryDefaultBranch 获取项目仓库的默认分支（内部接口）
+	GetRepositoryDefaultBranch(ctx context.Context, projectID uint64, name string) (*coderepositoryv1.GetRepositoryDefaultBranchResponse, error)
 
 	// Branch CRUD
 	CreateBranch(ctx context.Context, projectID, repositoryID uint64, name string, isDefault bool) (*coderepositoryv1.Branch, error)
@@ -46,15 +53,17 @@ type CodeRepositoryService interface {
 }
 
 type codeRepositoryService struct {
-	db            *gorm.DB
-	projectClient projectv1.ProjectServiceClient
+	db             *gorm.DB
+	projectClient  projectv1.ProjectServiceClient
+	executorClient civ1.ExecutorServiceClient
 }
 
-func NewCodeRepositoryService(db *gorm.DB, projectClient projectv1.ProjectServiceClient) CodeRepositoryService {
-	return &codeRepositoryService{db: db, projectClient: projectClient}
+// NewCodeRepositoryService 创建服务；executorClient 用于校验申请仓库凭据的构建，为空时拒绝发放凭据
+func NewCodeRepositoryService(db *gorm.DB, projectClient projectv1.ProjectServiceClient, executorClient civ1.ExecutorServiceClient) CodeRepositoryService {
+	return &codeRepositoryService{db: db, projectClient: projectClient, executorClient: executorClient}
 }
 
-func (s *codeRepositoryService) CreateRepository(ctx context.Context, projectID uint64, name, description, gitURL, branch string, authType coderepositoryv1.RepositoryAuthType, gitUsername, gitPassword, gitSSHKey string) (*coderepositoryv1.Repository, error) {
+func (s *codeRepositoryService) CreateRepository(ctx context.Context, projectID uint64, name, description, gitURL, branch string, authType coderepositoryv1.RepositoryAuthType, gitUsername, gitPassword, gitSSHKey, webhookSecret string) (*coderepositoryv1.Repository, error) {
 	// 权限：仅项目所有者或管理员可创建
 	actorID, err := getUserIDFromCtx(ctx)
 	if err != nil {
@@ -73,16 +82,17 @@ func (s *codeRepositoryService) CreateRepository(ctx context.Context, projectID
 	}
 
 	repo := models.Repository{
-		ProjectID:   projectID,
-		Name:        name,
-		Description: description,
-		GitURL:      gitURL,
-		AuthType:    int32(authType),
-		GitUsername: gitUsername,
-		GitPassword: gitPassword,
-		GitSSHKey:   gitSSHKey,
-		IsActive:    true,
-		SyncStatus:  int32(coderepositoryv1.RepositorySyncStatus_REPOSITORY_SYNC_STATUS_PENDING),
+		ProjectID:     projectID,
+		Name:          name,
+		Description:   description,
+		GitURL:        gitURL,
+		AuthType:      int32(authType),
+		GitUsername:   gitUsername,
+		GitPassword:   gitPassword,
+		GitSSHKey:     gitSSHKey,
+		IsActive:      true,
+		WebhookSecret: webhookSecret,
+		SyncStatus:    int32(coderepositoryv1.RepositorySyncStatus_REPOSITORY_SYNC_STATUS_PENDING),
 	}
 
 	if err := s.db.WithContext(ctx).Create(&repo).Error; err != nil {
@@ -184,6 +194,9 @@ func (s *codeRepositoryService) UpdateRepository(ctx context.Context, projectID,
 	if v, ok := updates["git_ssh_key"].(string); ok {
 		repo.GitSSHKey = v
 	}
+	if v, ok := updates["webhook_secret"].(string); ok && v != "" {
+		repo.WebhookSecret = v
+	}
 	if v, ok := updates["is_active"].(bool); ok {
 		repo.IsActive = v
 	}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"xcoding/apps/code_repository/internal/models"
+	civ1 "xcoding/gen/go/ci/v1"
+	coderepositoryv1 "xcoding/gen/go/code_repository/v1"
+)
+
+// GetRepositoryCredentials 获取项目内启用中仓库的克隆地址与凭据，供 CI 执行器检出代码
+// 说明：
+// - 内部接口（不经 HTTP 网关暴露，调用须携带服务间令牌），不做用户权限校验
+// - 仅向执行中的构建发放：buildID 须为执行器上属于该项目且状态为 RUNNING 的构建
+// - name 为空时项目须恰有一个启用中的仓库，否则返回 FailedPrecondition 要求显式指定
+// - 凭据按认证方式返回：PASSWORD 返回用户名与密码，SSH 返回私钥，NONE 均为空
+func (s *codeRepositoryService) GetRepositoryCredentials(ctx context.Context, projectID, buildID uint64, name string) (*coderepositoryv1.GetRepositoryCredentialsResponse, error) {
+	if err := s.ensureRunningBuild(ctx, projectID, buildID); err != nil {
+		return nil, err
+	}
+	repo, err := s.activeRepository(ctx, projectID, name)
+	if err != nil {
+		return nil, err
+	}
+	out := &coderepositoryv1.GetRepositoryCredentialsResponse{
+		RepositoryId: repo.ID,
+		GitUrl:       repo.GitURL,
+		AuthType:     coderepositoryv1.RepositoryAuthType(repo.AuthType),
+	}
+	switch out.AuthType {
+	case coderepositoryv1.RepositoryAuthType_REPOSITORY_AUTH_TYPE_PASSWORD:
+		out.GitUsername, out.GitPassword = repo.GitUsername, repo.GitPassword
+	case coderepositoryv1.RepositoryAuthType_REPOSITORY_AUTH_TYPE_SSH:
+		out.GitSshKey = repo.GitSSHKey
+	}
+	return out, nil
+}
+
+// ensureRunningBuild 经 CI 执行器校验构建属于该项目且正在执行
+func (s *codeRepositoryService) ensureRunningBuild(ctx context.Context, projectID, buildID uint64) error {
+	if s.executorClient == nil {
+		return status.Errorf(codes.FailedPrecondition, "ci executor not configured; repository credentials unavailable")
+	}
+	resp, err := s.executorClient.GetBuild(ctx, &civ1.GetExecutorBuildRequest{BuildId: buildID})
+	if err != nil {
+		return status.Errorf(codes.PermissionDenied, "build %d not found: %v", buildID, err)
+	}
+	b := resp.GetBuild()
+	if b.GetProjectId() != projectID || b.GetStatus() != civ1.BuildStatus_BUILD_STATUS_RUNNING {
+		return status.Errorf(codes.PermissionDenied, "build %d is not running in project %d", buildID, projectID)
+	}
+	return nil
+}
+
+// GetRepositoryDefaultBranch 获取项目内启用中仓库的默认分支（is_default 标记的分支），供 CI 执行器缓存回退查找
+// 内部接口，不返回凭据；仓库的选取规则同 GetRepositoryCredentials，仓库未标记默认分支时 branch 为空
+func (s *codeRepositoryService) GetRepositoryDefaultBranch(ctx context.Context, projectID uint64, name string) (*coderepositoryv1.GetRepositoryDefaultBranchResponse, error) {
+	repo, err := s.activeRepository(ctx, projectID, name)
+	if err != nil {
+		return nil, err
+	}
+	out := &coderepositoryv1.GetRepositoryDefaultBranchResponse{RepositoryId: repo.ID}
+	var branch models.RepositoryBranch
+	err = s.db.WithContext(ctx).Where("repository_id = ? AND is_default = ?", repo.ID, true).Order("id").First(&branch).Error
+	switch {
+	case err == nil:
+		out.Branch = branch.Name
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, status.Errorf(codes.Internal, "failed to get default branch: %v", err)
+	}
+	return out, nil
+}
+
+// activeRepository 按名称选取项目内启用中的仓库；name 为空时项目须恰有一个启用中的仓库
+func (s *codeRepositoryService) activeRepository(ctx context.Context, projectID uint64, name string) (*models.Repository, error) {
+	q := s.db.WithContext(ctx).Where("project_id = ? AND is_active = ?", projectID, true)
+	if name = strings.TrimSpace(name); name != "" {
+		var repo models.Repository
+		if err := q.Where("name = ?", name).First(&repo).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, status.Errorf(codes.NotFound, "repository %q not found in project %d", name, projectID)
+			}
+			return nil, status.Errorf(codes.Internal, "failed to get repository: %v", err)
+		}
+		return &repo, nil
+	}
+	var repos []models.Repository
+	if err := q.Order("id").Limit(2).Find(&repos).Error; err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get repository: %v", err)
+	}
+	switch len(repos) {
+	case 0:
+		return nil, status.Errorf(codes.NotFound, "project %d has no active repository", projectID)
+	case 1:
+		return &repos[0], nil
+	default:
+		return nil, status.Errorf(codes.FailedPrecondition, "project %d has multiple repositories, repository name is required", projectID)
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	civ1 "xcoding/gen/go/ci/v1"
+)
+
+// fakeExecutor 仅实现 GetBuild，按 ID 返回预置的构建
+type fakeExecutor struct {
+	civ1.ExecutorServiceClient
+	builds map[uint64]*civ1.Build
+}
+
+func (f *fakeExecutor) GetBuild(ctx context.Context, req *civ1.GetExecutorBuildRequest, opts ...grpc.CallOption) (*civ1.GetExecutorBuildResponse, error) {
+	b, ok := f.builds[req.GetBuildId()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "record not found")
+	}
+	return &civ1.GetExecutorBuildResponse{Build: b}, nil
+}
+
+func TestEnsureRunningBuild(t *testing.T) {
+	exec := &fakeExecutor{builds: map[uint64]*civ1.Build{
+		1: {Id: 1, ProjectId: 7, Status: civ1.BuildStatus_BUILD_STATUS_RUNNING},
+		2: {Id: 2, ProjectId: 7, Status: civ1.BuildStatus_BUILD_STATUS_SUCCEEDED},
+		3: {Id: 3, ProjectId: 8, Status: civ1.BuildStatus_BUILD_STATUS_RUNNING},
+	}}
+	s := &codeRepositoryService{executorClient: exec}
+	cases := []struct {
+		name      string
+		projectID uint64
+		buildID   uint64
+		want      codes.Code
+	}{
+		{"running in project", 7, 1, codes.OK},
+		{"finished build", 7, 2, codes.PermissionDenied},
+		{"build of another project", 7, 3, codes.PermissionDenied},
+		{"unknown build", 7, 9, codes.PermissionDenied},
+	}
+	for _, c := range cases {
+		if got := status.Code(s.ensureRunningBuild(context.Background(), c.projectID, c.buildID)); got != c.want {
+			t.Errorf("%s: code = %v, want %v", c.name, got, c.want)
+		}
+	}
+	s.executorClient = nil
+	if got := status.Code(s.ensureRunningBuild(context.Background(), 7, 1)); got != codes.FailedPrecondition {
+		t.Errorf("without executor: code = %v, want FailedPrecondition", got)
+	}
+}
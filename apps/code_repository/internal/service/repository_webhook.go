@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"xcoding/apps/code_repository/internal/models"
+	coderepositoryv1 "xcoding/gen/go/code_repository/v1"
+)
+
+// Webhook 平台（与 CI 的 webhook 包一致）
+const (
+	webhookProviderGitHub = "github"
+	webhookProviderGitLab = "gitlab"
+	webhookProviderGitea  = "gitea"
+)
+
+// VerifyWebhook 按 Git 地址解析启用中的仓库，并以各仓库的 Webhook 密钥校验投递，供 CI Webhook 定位项目
+// 说明：
+// - 内部接口（不经 HTTP 网关暴露），不做用户权限校验；密钥只在本服务内比较，不返回给调用方
+// - 地址经 normalizeGitURL 归一后比较，https/ssh/web 地址均可命中同一仓库
+// - 同一地址关联多个仓库时，返回第一个校验通过的仓库 ID；均未通过时为 0
+func (s *codeRepositoryService) VerifyWebhook(ctx context.Context, gitURLs []string, provider, signature string, body []byte) (*coderepositoryv1.VerifyWebhookResponse, error) {
+	repos, err := s.repositoriesByGitURL(ctx, gitURLs)
+	if err != nil {
+		return nil, err
+	}
+	out := &coderepositoryv1.VerifyWebhookResponse{}
+	for i := range repos {
+		if out.VerifiedRepositoryId == 0 && verifyWebhookSignature(provider, signature, repos[i].WebhookSecret, body) {
+			out.VerifiedRepositoryId = repos[i].ID
+		}
+		repos[i].GitPassword = ""
+		repos[i].GitSSHKey = ""
+		out.Repositories = append(out.Repositories, models.ToProto(&repos[i]))
+	}
+	return out, nil
+}
+
+// repositoriesByGitURL 按归一化的 Git 地址查找启用中的仓库（按 ID 排序）
+func (s *codeRepositoryService) repositoriesByGitURL(ctx context.Context, gitURLs []string) ([]models.Repository, error) {
+	wanted := map[string]bool{}
+	var paths []string
+	for _, u := range gitURLs {
+		if n := normalizeGitURL(u); n != "" && !wanted[n] {
+			wanted[n] = true
+			if i := strings.Index(n, "/"); i >= 0 {
+				paths = append(paths, n[i+1:])
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	// 以仓库路径做模糊预筛，再在内存中按归一化地址精确比较
+	q := s.db.WithContext(ctx).Where("is_active = ?", true)
+	cond := s.db
+	for i, p := range paths {
+		if i == 0 {
+			cond = cond.Where("LOWER(git_url) LIKE ?", "%"+p+"%")
+		} else {
+			cond = cond.Or("LOWER(git_url) LIKE ?", "%"+p+"%")
+		}
+	}
+	var repos []models.Repository
+	if err := q.Where(cond).Order("id").Find(&repos).Error; err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to resolve repositories: %v", err)
+	}
+	out := repos[:0]
+	for _, r := range repos {
+		if wanted[normalizeGitURL(r.GitURL)] {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// verifyWebhookSignature 使用仓库密钥校验投递
+// - GitHub/Gitea：HMAC-SHA256(body, secret) 的十六进制摘要
+// - GitLab：不签名请求体，比较 X-Gitlab-Token 与密钥
+// 密钥或签名为空、平台未知时一律校验失败
+func verifyWebhookSignature(provider, signature, secret string, body []byte) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+	switch provider {
+	case webhookProviderGitLab:
+		return subtle.ConstantTimeCompare([]byte(signature), []byte(secret)) == 1
+	case webhookProviderGitHub, webhookProviderGitea:
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		want := hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(strings.ToLower(signature)), []byte(want))
+	default:
+		return false
+	}
+}
+
+// normalizeGitURL 将 Git 地址归一为 host/path 形式（小写，去除协议、凭据、端口、.git 后缀与末尾斜杠）
+// 例如 git@github.com:Org/Repo.git、ssh://git@github.com:22/org/repo、https://github.com/org/repo/ 均归一为 github.com/org/repo
+func normalizeGitURL(raw string) string {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return ""
+	}
+	var host, path string
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return ""
+		}
+		host, path = u.Hostname(), u.Path
+	} else if at := strings.Index(s, "@"); at >= 0 && strings.Contains(s[at:], ":") {
+		// scp 风格：user@host:path
+		rest := s[at+1:]
+		i := strings.Index(rest, ":")
+		host, path = rest[:i], rest[i+1:]
+	} else {
+		i := strings.Index(s, "/")
+		if i < 0 {
+			return ""
+		}
+		host, path = s[:i], s[i:]
+	}
+	path = strings.Trim(path, "/")
+	path = strings.TrimSuffix(path, ".git")
+	if host == "" || path == "" {
+		return ""
+	}
+	return strings.ToLower(host + "/" + path)
+}
@@ -44,11 +44,27 @@ export function getExecutorBuildLogs(buildId: string | number, offset = 0, limit
   return request({ url: `${CI_PREFIX}/executor/builds/${buildId}/logs`, method: 'get', params })
 }
 
+// 服务容器（services）日志，jobName/service 为空时不过滤
+export function getExecutorServiceLogs(buildId: string | number, jobName = '', service = '', offset = 0, limit = 200) {
+  const params: any = { job_name: jobName, service, offset, limit }
+  return request({ url: `${CI_PREFIX}/executor/builds/${buildId}/service_logs`, method: 'get', params })
+}
+
 export function getExecutorK8sStatus(buildId: string | number, jobNamePrefix = '', page = 1, pageSize = 20) {
   const params: any = { job_name_prefix: jobNamePrefix, page, page_size: pageSize }
   return request({ url: `${CI_PREFIX}/executor/builds/${buildId}/k8s_status`, method: 'get', params })
 }
 
+// 构建制品列表
+export function listExecutorBuildArtifacts(buildId: string | number) {
+  return request({ url: `${CI_PREFIX}/executor/builds/${buildId}/artifacts`, method: 'get' })
+}
+
+// 下载构建制品（tar.gz），返回 Blob
+export function downloadExecutorBuildArtifact(buildId: string | number, name: string) {
+  return request({ url: `${CI_PREFIX}/executor/builds/${buildId}/artifacts/${encodeURIComponent(name)}/download`, method: 'get', responseType: 'blob' })
+}
+
 export function cancelExecutorBuild(buildId: string | number) {
   return request({ url: `${CI_PREFIX}/executor/builds/${buildId}/cancel`, method: 'post' })
 }
\ No newline at end of file